@@ -0,0 +1,10 @@
+// thumbnail-api는 Lambda와 같은 변환기(converter 패키지)를 내부 HTTP API로 제공합니다.
+// S3 이벤트 배선 없이 다른 백엔드 서비스가 키와 프리셋 이름으로 썸네일 하나를 동기적으로 받아 갈 때 씁니다.
+package main
+
+import "github.com/berryssoda/test-encode/converter"
+
+func main() {
+	converter.Init()
+	converter.ServeAPI()
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// formatSupportsAlpha는 출력 포맷이 알파 채널을 담을 수 있는지 확인합니다.
+func formatSupportsAlpha(format string) bool {
+	switch format {
+	case "avif", "webp", "png", "jxl":
+		return true
+	}
+	return false
+}
+
+// applyAlphaPolicy는 ALPHA_POLICY에 따라 알파 채널을 유지하거나 배경색에 합성(flatten)합니다.
+// 알파를 지원하지 않는 출력 포맷이면 정책과 관계없이 background(RGB 0~255)에 합성합니다.
+// 결과는 "preserved" | "flattened" | "" (알파 없음) 중 하나입니다.
+func applyAlphaPolicy(image *vips.Image, outputFormat string, background []float64, c Config) (string, error) {
+	if !image.HasAlpha() {
+		return "", nil
+	}
+	if c.AlphaPolicy == "preserve" && formatSupportsAlpha(outputFormat) {
+		return "preserved", nil
+	}
+	if err := image.Flatten(&vips.FlattenOptions{Background: flattenBackground(image, background)}); err != nil {
+		return "", fmt.Errorf("failed to flatten alpha onto background: %w", err)
+	}
+	return "flattened", nil
+}
+
+// flattenBackground는 RGB 배경색을 이미지의 밴드 수와 비트 깊이에 맞게 변환합니다.
+func flattenBackground(image *vips.Image, rgb []float64) []float64 {
+	background := rgb
+	if image.Bands()-1 == 1 {
+		// 흑백 + 알파 이미지는 배경도 단일 밴드 밝기 값이어야 합니다.
+		background = []float64{0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]}
+	}
+	switch image.Interpretation() {
+	case vips.InterpretationRgb16, vips.InterpretationGrey16:
+		scaled := make([]float64, len(background))
+		for i, v := range background {
+			scaled[i] = v * 257
+		}
+		background = scaled
+	}
+	return background
+}
+
+// verifyAlphaPreserved는 인코딩된 버퍼를 다시 열어 알파 채널이 남아 있는지 확인합니다.
+// 헤더만 읽으므로 전체 디코딩 비용은 들지 않습니다.
+func verifyAlphaPreserved(buf []byte, outputFormat string) error {
+	encoded, err := vips.NewImageFromBuffer(buf, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reopen encoded %s for alpha check: %w", outputFormat, err)
+	}
+	defer encoded.Close()
+	if !encoded.HasAlpha() {
+		return fmt.Errorf("alpha channel was lost during %s encode", outputFormat)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// alphaCase는 투명한 원본 하나를 변환해 출력별 밴드 수와 모서리(투명했던) 픽셀을 확인하는 경우입니다.
+type alphaCase struct {
+	name    string
+	fixture string
+	env     map[string]string
+	// background는 요청의 background 필드입니다.
+	background string
+	alpha      string
+	// outputs는 출력 키별로 기대하는 밴드 수와, 비어 있지 않으면 (0, 0) 픽셀의 RGB입니다.
+	outputs map[string]alphaOutput
+}
+
+type alphaOutput struct {
+	bands  int
+	corner []float64
+}
+
+func TestAlphaPolicy(t *testing.T) {
+	magenta := []float64{255, 0, 255}
+	runAlphaCases(t, []alphaCase{
+		{
+			name:    "png preserve",
+			fixture: "alpha.png",
+			alpha:   "preserved",
+			outputs: map[string]alphaOutput{"alpha.avif": {bands: 4}},
+		},
+		{
+			name:    "webp preserve",
+			fixture: "alpha.webp",
+			alpha:   "preserved",
+			outputs: map[string]alphaOutput{"alpha.avif": {bands: 4}},
+		},
+		{
+			name:    "png flatten",
+			fixture: "alpha.png",
+			env:     map[string]string{"ALPHA_POLICY": "flatten", "ALPHA_BACKGROUND": "#ff00ff"},
+			alpha:   "flattened",
+			outputs: map[string]alphaOutput{"alpha.avif": {bands: 3, corner: magenta}},
+		},
+		{
+			name:    "webp flatten",
+			fixture: "alpha.webp",
+			env:     map[string]string{"ALPHA_POLICY": "flatten"},
+			alpha:   "flattened",
+			outputs: map[string]alphaOutput{"alpha.avif": {bands: 3}},
+		},
+		{
+			name:    "png preserve with jpeg fallback",
+			fixture: "alpha.png",
+			env:     map[string]string{"JPEG_FALLBACK": "true", "ALPHA_BACKGROUND": "#ff00ff"},
+			alpha:   "preserved",
+			outputs: map[string]alphaOutput{
+				"alpha.avif": {bands: 4},
+				"alpha.jpg":  {bands: 3, corner: magenta},
+			},
+		},
+	})
+}
+
+// TestAlphaFlattenBackground는 투명한 RGBA·팔레트 PNG를 불투명한 포맷으로 바꿀 때
+// 투명했던 픽셀이 검은색이 아니라 배경색(기본 흰색, 요청의 background)이 되는지 확인합니다.
+func TestAlphaFlattenBackground(t *testing.T) {
+	white := []float64{255, 255, 255}
+	navy := []float64{0, 0, 128}
+	fallback := map[string]string{"JPEG_FALLBACK": "true"}
+	runAlphaCases(t, []alphaCase{
+		{
+			name:    "rgba jpeg fallback",
+			fixture: "alpha.png",
+			env:     fallback,
+			alpha:   "preserved",
+			outputs: map[string]alphaOutput{
+				"alpha.avif": {bands: 4},
+				"alpha.jpg":  {bands: 3, corner: white},
+			},
+		},
+		{
+			name:    "palette jpeg fallback",
+			fixture: "palette-alpha.png",
+			env:     fallback,
+			alpha:   "preserved",
+			outputs: map[string]alphaOutput{
+				"palette-alpha.avif": {bands: 4},
+				"palette-alpha.jpg":  {bands: 3, corner: white},
+			},
+		},
+		{
+			name:       "palette jpeg fallback with request background",
+			fixture:    "palette-alpha.png",
+			env:        fallback,
+			background: "#000080",
+			alpha:      "preserved",
+			outputs: map[string]alphaOutput{
+				"palette-alpha.avif": {bands: 4},
+				"palette-alpha.jpg":  {bands: 3, corner: navy},
+			},
+		},
+		{
+			name:    "rgba flatten default background",
+			fixture: "alpha.png",
+			env:     map[string]string{"ALPHA_POLICY": "flatten"},
+			alpha:   "flattened",
+			outputs: map[string]alphaOutput{"alpha.avif": {bands: 3, corner: white}},
+		},
+		{
+			name:    "palette flatten default background",
+			fixture: "palette-alpha.png",
+			env:     map[string]string{"ALPHA_POLICY": "flatten"},
+			alpha:   "flattened",
+			outputs: map[string]alphaOutput{"palette-alpha.avif": {bands: 3, corner: white}},
+		},
+	})
+}
+
+func runAlphaCases(t *testing.T, cases []alphaCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeS3()
+			key := "alpha/" + tc.fixture
+			client.put("uploads", key, readFixture(t, tc.fixture))
+			h := newTestHandler(t, client, tc.env)
+
+			result, err := h.HandleRequest(context.Background(), S3Event{S3Bucket: "uploads", S3Key: key, Background: tc.background})
+			if err != nil {
+				t.Fatalf("HandleRequest: %v", err)
+			}
+			if result.Status != StatusConverted || result.Alpha != tc.alpha {
+				t.Fatalf("status = %s, alpha = %q, want %s %q", result.Status, result.Alpha, StatusConverted, tc.alpha)
+			}
+			if len(result.Outputs) != len(tc.outputs) {
+				t.Errorf("outputs = %+v, want %d outputs", result.Outputs, len(tc.outputs))
+			}
+			for name, want := range tc.outputs {
+				body, ok := client.object("uploads", "alpha/"+name)
+				if !ok {
+					t.Errorf("alpha/%s was not uploaded, uploaded %v", name, client.uploaded())
+					continue
+				}
+				checkAlphaOutput(t, name, body, want)
+			}
+		})
+	}
+}
+
+// checkAlphaOutput은 인코딩된 출력을 다시 디코딩해 밴드 수와 모서리 픽셀을 확인합니다.
+// 손실 압축이므로 픽셀 값은 채널마다 16까지 차이를 허용합니다.
+func checkAlphaOutput(t *testing.T, name string, body []byte, want alphaOutput) {
+	t.Helper()
+	image, err := vips.NewImageFromBuffer(body, nil)
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", name, err)
+	}
+	defer image.Close()
+	if got := image.Bands(); got != want.bands {
+		t.Errorf("%s has %d bands, want %d", name, got, want.bands)
+	}
+	if want.corner == nil {
+		return
+	}
+	pixel, err := image.Getpoint(0, 0, nil)
+	if err != nil {
+		t.Fatalf("failed to read %s pixel: %v", name, err)
+	}
+	for i, v := range want.corner {
+		if i >= len(pixel) || math.Abs(pixel[i]-v) > 16 {
+			t.Errorf("%s corner pixel = %v, want about %v", name, pixel, want.corner)
+			return
+		}
+	}
+}
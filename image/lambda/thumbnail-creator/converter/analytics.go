@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	fhtypes "github.com/aws/aws-sdk-go-v2/service/firehose/types"
+)
+
+// FirehoseAPI는 분석 레코드 전송에 사용하는 Kinesis Data Firehose 호출입니다.
+type FirehoseAPI interface {
+	PutRecord(ctx context.Context, params *firehose.PutRecordInput, optFns ...func(*firehose.Options)) (*firehose.PutRecordOutput, error)
+}
+
+// UseAnalytics는 변환마다 분석 레코드를 Firehose로 보내는 미들웨어를 등록합니다.
+func (h *Handler) UseAnalytics(api FirehoseAPI) {
+	h.hooks.Use(&analyticsSink{api: api, stream: h.conf.AnalyticsStream, clock: h.clock, namespace: h.conf.MetricsNamespace})
+}
+
+// analyticsRecord는 데이터 레이크에 쌓이는 변환 한 건의 레코드입니다. 줄바꿈으로 구분된 JSON으로 보냅니다.
+type analyticsRecord struct {
+	Timestamp   time.Time      `json:"timestamp"`
+	Bucket      string         `json:"bucket"`
+	Key         string         `json:"key"`
+	Status      Status         `json:"status"`
+	Tenant      string         `json:"tenant,omitempty"`
+	Format      string         `json:"format,omitempty"`
+	Encoder     string         `json:"encoder,omitempty"`
+	Compression string         `json:"compression,omitempty"`
+	Preset      string         `json:"preset,omitempty"`
+	Effort      int            `json:"effort"`
+	Quality     int            `json:"quality"` // 0이면 포맷별 기본 품질
+	SourceBytes int64          `json:"sourceBytes"`
+	OutputBytes int64          `json:"outputBytes"`
+	DurationMs  int64          `json:"durationMs"`
+	Outputs     []OutputResult `json:"outputs,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	ErrorType   string         `json:"errorType,omitempty"`
+}
+
+// analyticsSink는 성공·실패한 변환마다 analyticsRecord 하나를 Firehose 전송 스트림에 씁니다.
+// 분석용이므로 전송에 실패해도 변환 결과는 바꾸지 않고 경고와 AnalyticsRecordFailed 지표만 남깁니다.
+type analyticsSink struct {
+	api       FirehoseAPI
+	stream    string
+	clock     Clock
+	namespace string
+}
+
+func (s *analyticsSink) PostConvert(ctx context.Context, job *Job) error {
+	s.put(ctx, s.record(job, job.Result.Status))
+	return nil
+}
+
+func (s *analyticsSink) OnFailure(ctx context.Context, job *Job, err error) {
+	record := s.record(job, StatusFailed)
+	record.Error = err.Error()
+	record.ErrorType = strings.TrimPrefix(fmt.Sprintf("%T", err), "*")
+	s.put(ctx, record)
+}
+
+func (s *analyticsSink) record(job *Job, status Status) analyticsRecord {
+	now := s.clock.Now()
+	record := analyticsRecord{
+		Timestamp:   now.UTC(),
+		Bucket:      job.Bucket,
+		Key:         job.SrcKey,
+		Status:      status,
+		Tenant:      job.Result.Tenant,
+		Format:      job.Result.Format,
+		Encoder:     job.Result.Encoder,
+		Compression: job.Result.Compression,
+		Preset:      job.Event.Preset,
+		Effort:      job.Effort,
+		Quality:     job.Quality,
+		SourceBytes: int64(len(job.Source)),
+		DurationMs:  now.Sub(job.Started).Milliseconds(),
+		Outputs:     job.Result.Outputs,
+	}
+	for _, output := range job.Result.Outputs {
+		record.OutputBytes += output.Size
+	}
+	return record
+}
+
+func (s *analyticsSink) put(ctx context.Context, record analyticsRecord) {
+	data, err := json.Marshal(record)
+	if err == nil {
+		_, err = s.api.PutRecord(ctx, &firehose.PutRecordInput{
+			DeliveryStreamName: &s.stream,
+			Record:             &fhtypes.Record{Data: append(data, '\n')},
+		})
+	}
+	if err != nil {
+		log.Printf("Warning: failed to send analytics record for %s: %v", record.Key, err)
+		emitMetrics(s.namespace, "Count", map[string]float64{"AnalyticsRecordFailed": 1})
+	}
+}
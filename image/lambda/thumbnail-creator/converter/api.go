@@ -0,0 +1,145 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 이 파일은 Lambda 대신 내부 HTTP API로 변환기를 제공하는 실행 파일(cmd/thumbnail-api)의 서버입니다.
+// S3 이벤트 배선 없이 다른 백엔드 서비스가 키와 프리셋 이름으로 썸네일 하나를 동기적으로 받아 갈 때 씁니다.
+// 변환 경로(다운로드, EXIF 방향 적용, 파이프라인, 인코딩, 업로드, 훅)는 Lambda 빌드와 같습니다.
+
+// ThumbnailRequest는 POST /v1/thumbnails의 요청 본문입니다.
+type ThumbnailRequest struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// Preset은 설정된 프리셋 이름입니다. 비어 있으면 기본 변환 하나만 만듭니다.
+	Preset string `json:"preset,omitempty"`
+	// OutputKey는 출력 키의 기준 경로입니다. 비어 있으면 원본 키에서 확장자만 바꿉니다.
+	OutputKey string `json:"outputKey,omitempty"`
+}
+
+// apiError는 실패 응답의 본문입니다. errorType은 이벤트 image.failed의 errorType과 같습니다.
+type apiError struct {
+	Error     string `json:"error"`
+	ErrorType string `json:"errorType,omitempty"`
+	// Code는 결과 errors 항목과 같은 기계 판독용 오류 코드입니다.
+	Code ErrorCode `json:"code"`
+}
+
+// ServeAPI는 API_LISTEN_ADDR에서 HTTP API를 열고 SIGTERM을 받으면 진행 중인 요청을 마친 뒤 돌아옵니다.
+// 요청은 동시에 처리되므로 설정 다시 읽기(SECRETS_REFRESH)는 요청과 별도의 고루틴 하나에서 합니다.
+func ServeAPI() {
+	conf := current.Load().conf
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/thumbnails", serveThumbnail)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := &http.Server{
+		Addr:              conf.APIListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	go refreshEvery(refreshCtx, conf.SecretsRefresh)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	// ListenAndServe는 Shutdown이 시작되자마자 돌아오므로, 진행 중인 요청이 끝났음을 drained로 기다립니다.
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), conf.APIRequestTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Warning: failed to drain API requests: %v", err)
+		}
+	}()
+
+	log.Printf("Thumbnail API listening on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("API server failed: %v", err)
+	}
+	<-drained
+	stopRefresh()
+	current.Load().Shutdown()
+	otelProviders.Shutdown()
+}
+
+// serveThumbnail은 요청 하나를 변환 이벤트로 바꿔 처리합니다. 결과는 Lambda 응답과 같은 ConversionResult입니다.
+// 건너뛴 요청(SKIPPED_*)도 200으로 돌려주므로 호출자는 status와 newKey를 확인해야 합니다.
+func serveThumbnail(w http.ResponseWriter, r *http.Request) {
+	var req ThumbnailRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid request: %v", err), Code: ErrorInvalidEvent})
+		return
+	}
+	if req.Bucket == "" || req.Key == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request: bucket and key are required", Code: ErrorInvalidEvent})
+		return
+	}
+
+	h, release := acquireHandler()
+	defer release()
+	// 변환 코드는 남은 시간을 컨텍스트 기한으로 계산하므로 요청마다 Lambda 제한 시간처럼 기한을 둡니다.
+	ctx, cancel := context.WithTimeout(r.Context(), h.conf.APIRequestTimeout)
+	defer cancel()
+	ctx, end := startPhase(ctx, "invoke", attribute.String("thumbnail.mode", "api"))
+	result, err := h.HandleRequest(ctx, S3Event{
+		S3Bucket:  req.Bucket,
+		S3Key:     req.Key,
+		Preset:    req.Preset,
+		OutputKey: req.OutputKey,
+	})
+	end(err, attribute.String("thumbnail.status", string(result.Status)))
+	otelProviders.Flush(ctx)
+	if err != nil {
+		writeJSON(w, apiStatus(w, err), apiError{Error: err.Error(), ErrorType: errorType(err), Code: errorCode(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiStatus는 변환 오류를 HTTP 상태로 바꿉니다. 재시도해도 되는 오류는 429/503/504로 구분합니다.
+func apiStatus(w http.ResponseWriter, err error) int {
+	var limited *RateLimited
+	var breaker *EncoderCircuitOpen
+	var budget *TimeoutBudgetExceeded
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid event:"):
+		return http.StatusBadRequest
+	case errors.As(err, &limited):
+		w.Header().Set("Retry-After", strconv.Itoa(int(limited.RetryAfter.Seconds())+1))
+		return http.StatusTooManyRequests
+	case errors.As(err, &breaker):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &budget), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Warning: failed to write API response: %v", err)
+	}
+}
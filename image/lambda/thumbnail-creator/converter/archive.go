@@ -0,0 +1,254 @@
+package converter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+)
+
+// archiveImageExtensions는 아카이브 안에서 변환을 시도할 이미지 확장자입니다. 나머지 항목은 SKIPPED_NOT_IMAGE로 남깁니다.
+var archiveImageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true,
+	".heic": true, ".heif": true, ".tif": true, ".tiff": true, ".bmp": true, ".jxl": true,
+}
+
+// archiveEntry는 아카이브 항목 하나입니다. TAR에서는 Open한 내용을 다음 항목으로 넘어가기 전에 읽어야 합니다.
+type archiveEntry struct {
+	Name string
+	// Regular는 일반 파일이면 true입니다. 디렉터리, 심볼릭 링크 등은 건너뜁니다.
+	Regular bool
+	// Size는 헤더에 적힌 압축 해제 크기입니다. 실제 크기는 읽으면서 다시 확인합니다.
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// archiveFormat은 원본의 아카이브 형식을 시그니처로 판별합니다. 아카이브가 아니면 빈 문자열입니다.
+// gzip은 압축을 풀어 TAR 헤더(ustar)가 있을 때만 tar.gz로 봅니다.
+func archiveFormat(source []byte) string {
+	switch {
+	case bytes.HasPrefix(source, []byte("PK\x03\x04")):
+		return "zip"
+	case isTarHeader(source):
+		return "tar"
+	case bytes.HasPrefix(source, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(source))
+		if err != nil {
+			return ""
+		}
+		defer gz.Close()
+		header := make([]byte, 512)
+		if n, _ := io.ReadFull(gz, header); isTarHeader(header[:n]) {
+			return "tar.gz"
+		}
+	}
+	return ""
+}
+
+func isTarHeader(b []byte) bool {
+	return len(b) >= 262 && string(b[257:262]) == "ustar"
+}
+
+// walkArchive는 아카이브 항목을 순서대로 visit에 넘깁니다. TAR는 원본을 앞에서부터 스트리밍으로 풉니다.
+func walkArchive(source []byte, format string, visit func(archiveEntry) error) error {
+	if format == "zip" {
+		archive, err := zip.NewReader(bytes.NewReader(source), int64(len(source)))
+		if err != nil {
+			return fmt.Errorf("failed to open zip archive: %w", err)
+		}
+		for _, f := range archive.File {
+			entry := archiveEntry{Name: f.Name, Regular: f.Mode().IsRegular(), Size: int64(f.UncompressedSize64), Open: f.Open}
+			if err := visit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(source)
+	if format == "tar.gz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to open tar.gz archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s archive: %w", format, err)
+		}
+		entry := archiveEntry{
+			Name:    header.Name,
+			Regular: header.Typeflag == tar.TypeReg,
+			Size:    header.Size,
+			Open:    func() (io.ReadCloser, error) { return io.NopCloser(tr), nil },
+		}
+		if err := visit(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// convertArchive는 ZIP/TAR/TAR.GZ 안의 이미지를 하나씩 풀어 변환합니다. 항목마다 원본 변환과 같은 흐름과 훅을 거치며,
+// 출력은 아카이브 키에서 확장자를 뗀 접두사 아래에 항목 경로 그대로 올라갑니다. (uploads/a.zip → uploads/a/<항목>)
+// 항목 하나가 실패해도 나머지는 계속 변환하고, 결과의 Items에 항목별 상태를 남깁니다.
+// 항목 수나 전체 압축 해제 크기가 한도를 넘으면 압축 폭탄으로 보고 그 자리에서 중단합니다.
+func (h *Handler) convertArchive(ctx context.Context, job *Job, format string) (ConversionResult, error) {
+	prefix := archivePrefix(job.BaseKey)
+	log.Printf("Converting %s archive %s, output prefix %s", format, job.SrcKey, prefix)
+
+	entries, converted, failed := 0, 0, 0
+	remaining := h.conf.ArchiveMaxTotalBytes
+	err := walkArchive(job.Source, format, func(e archiveEntry) error {
+		entries++
+		if entries > h.conf.ArchiveMaxEntries {
+			return fmt.Errorf("%s archive has more than ARCHIVE_MAX_ENTRIES %d entries", format, h.conf.ArchiveMaxEntries)
+		}
+		// TAR는 건너뛰는 항목(디렉터리, 숨김 파일, __MACOSX/, 이미지가 아닌 파일)도 다음 헤더까지 풀어야 하므로
+		// 이름과 종류를 보기 전에 헤더 크기를 전체 한도에서 뺍니다. tar.Reader는 헤더 크기보다 더 읽지 않습니다.
+		limit := remaining
+		if format != "zip" {
+			if e.Size > remaining {
+				return fmt.Errorf("archive entry %s would exceed ARCHIVE_MAX_TOTAL_MB", e.Name)
+			}
+			remaining -= e.Size
+			limit = e.Size
+		}
+		name, ok := archiveEntryName(e)
+		if !ok {
+			return nil
+		}
+		if err := h.checkBudget(ctx, "archive entry "+name); err != nil {
+			return err
+		}
+		item, read, err := h.convertArchiveEntry(ctx, job, e, name, prefix+name, limit)
+		if err != nil {
+			return err
+		}
+		if format == "zip" {
+			remaining -= read
+		}
+		switch item.Status {
+		case StatusConverted:
+			converted++
+		case StatusFailed:
+			failed++
+			job.Result.Errors = append(job.Result.Errors, item.Errors...)
+		}
+		job.Result.Outputs = append(job.Result.Outputs, item.Outputs...)
+		job.Result.Items = append(job.Result.Items, item)
+		return nil
+	})
+	if err != nil {
+		return ConversionResult{}, err
+	}
+
+	if h.quarantine != nil {
+		h.quarantine.PostConvert(ctx, job)
+	}
+	job.Result.Status = StatusArchiveConverted
+	job.Result.Message = fmt.Sprintf("%d of %d entries converted, %d failed", converted, len(job.Result.Items), failed)
+	log.Printf("Archive %s: %s", job.SrcKey, job.Result.Message)
+	return *job.Result, nil
+}
+
+// archivePrefix는 아카이브 키에서 확장자(.tar.gz 포함)를 뗀 출력 접두사입니다.
+func archivePrefix(key string) string {
+	if strings.HasSuffix(strings.ToLower(key), ".tar.gz") {
+		return key[:len(key)-len(".tar.gz")] + "/"
+	}
+	return strings.TrimSuffix(key, keyExtension(key)) + "/"
+}
+
+// convertArchiveEntry는 항목 하나를 풀어 변환하고 항목 결과와 읽은 바이트 수를 돌려줍니다.
+// 항목을 읽다가 남은 전체 한도(remaining)를 넘으면 아카이브 전체를 중단하는 오류를 돌려줍니다.
+func (h *Handler) convertArchiveEntry(ctx context.Context, archive *Job, e archiveEntry, name, baseKey string, remaining int64) (ConversionResult, int64, error) {
+	srcKey := archive.SrcKey + "/" + name
+	if !archiveImageExtensions[strings.ToLower(keyExtension(name))] {
+		return ConversionResult{Status: StatusSkippedNotImage, Tenant: archive.Result.Tenant, OriginalKey: srcKey}, 0, nil
+	}
+	if e.Size > remaining {
+		return ConversionResult{}, 0, fmt.Errorf("archive entry %s would exceed ARCHIVE_MAX_TOTAL_MB", name)
+	}
+	if e.Size > h.conf.ArchiveMaxEntryBytes {
+		err := fmt.Errorf("archive entry %s is %d bytes, more than ARCHIVE_MAX_ENTRY_MB", name, e.Size)
+		return failedResult(archive.Result.Tenant, srcKey, err), 0, nil
+	}
+	source, err := readArchiveEntry(e, min(h.conf.ArchiveMaxEntryBytes, remaining))
+	if err != nil {
+		// 헤더보다 실제 내용이 큰 항목은 크기를 속인 것이므로 아카이브 전체를 중단합니다.
+		return ConversionResult{}, int64(len(source)), err
+	}
+
+	job := &Job{
+		Event:        archive.Event,
+		Bucket:       archive.Bucket,
+		SrcKey:       srcKey,
+		BaseKey:      baseKey,
+		Steps:        archive.Steps,
+		Preset:       archive.Preset,
+		Tenant:       archive.Tenant,
+		OutputBucket: archive.OutputBucket,
+		Archive:      archive.SrcKey,
+		Started:      h.clock.Now(),
+		Background:   archive.Background,
+		Effort:       archive.Effort,
+		Source:       source,
+		Result:       &ConversionResult{Tenant: archive.Result.Tenant, OriginalKey: srcKey},
+	}
+	result, err := h.process(ctx, job)
+	var skipped *skipError
+	if errors.As(err, &skipped) {
+		return ConversionResult{Status: skipped.Status, Tenant: job.Result.Tenant, OriginalKey: srcKey, Message: skipped.Message}, int64(len(source)), nil
+	}
+	if err != nil {
+		log.Printf("Error: archive entry %s failed: %v", name, err)
+		h.hooks.OnFailure(ctx, job, err)
+		return failedResult(job.Result.Tenant, srcKey, err), int64(len(source)), nil
+	}
+	return result, int64(len(source)), nil
+}
+
+// archiveEntryName은 변환할 항목의 경로를 돌려줍니다. 일반 파일이 아닌 항목, macOS 메타데이터, 숨김 파일은 건너뛰고,
+// 출력 키가 접두사 밖으로 나가지 않도록 절대 경로와 ".."를 포함한 항목도 건너뜁니다.
+func archiveEntryName(e archiveEntry) (string, bool) {
+	name := strings.TrimPrefix(e.Name, "./")
+	if !e.Regular || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+		return "", false
+	}
+	if path.IsAbs(name) || name != path.Clean(name) || strings.HasPrefix(name, "../") {
+		log.Printf("Warning: skipping archive entry with unsafe path %q", e.Name)
+		return "", false
+	}
+	return name, true
+}
+
+// readArchiveEntry는 항목 하나를 풀어 읽습니다. limit 바이트를 넘으면 오류입니다.
+func readArchiveEntry(e archiveEntry, limit int64) ([]byte, error) {
+	r, err := e.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive entry %s: %w", e.Name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archive entry %s: %w", e.Name, err)
+	}
+	if int64(len(data)) > limit {
+		return data, fmt.Errorf("archive entry %s is larger than its header says or than the archive size limits", e.Name)
+	}
+	return data, nil
+}
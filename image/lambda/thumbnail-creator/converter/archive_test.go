@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestArchiveTotalLimitCountsSkippedTarEntries는 변환하지 않는 TAR 항목도 풀면서 지나가므로
+// ARCHIVE_MAX_TOTAL_MB에 세는지 확인합니다.
+func TestArchiveTotalLimitCountsSkippedTarEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		header tar.Header
+	}{
+		{name: "hidden file", header: tar.Header{Name: "photos/.big.jpg", Typeflag: tar.TypeReg}},
+		{name: "macos metadata", header: tar.Header{Name: "__MACOSX/photos/._a.jpg", Typeflag: tar.TypeReg}},
+		{name: "not an image", header: tar.Header{Name: "photos/notes.txt", Typeflag: tar.TypeReg}},
+		{name: "non-regular entry", header: tar.Header{Name: "photos/fifo", Typeflag: tar.TypeFifo}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for range 3 {
+				header := tt.header
+				if header.Typeflag == tar.TypeReg {
+					header.Size = 400
+				}
+				if err := tw.WriteHeader(&header); err != nil {
+					t.Fatal(err)
+				}
+				if header.Size > 0 {
+					if _, err := tw.Write(make([]byte, header.Size)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			h := &Handler{conf: Config{ArchiveMaxEntries: 10, ArchiveMaxEntryBytes: 1024, ArchiveMaxTotalBytes: 1000}}
+			job := &Job{SrcKey: "uploads/a.tar", BaseKey: "uploads/a.tar", Source: buf.Bytes(), Result: &ConversionResult{}}
+			_, err := h.convertArchive(context.Background(), job, "tar")
+			if tt.header.Typeflag != tar.TypeReg {
+				// 내용이 없는 항목은 한도를 쓰지 않습니다.
+				if err != nil {
+					t.Fatalf("convertArchive: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "ARCHIVE_MAX_TOTAL_MB") {
+				t.Fatalf("convertArchive error = %v, want ARCHIVE_MAX_TOTAL_MB error", err)
+			}
+		})
+	}
+}
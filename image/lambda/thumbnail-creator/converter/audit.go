@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// UseAudit은 변환 요청마다 감사 레코드를 AUDIT_BUCKET에 남기도록 설정합니다.
+func (h *Handler) UseAudit() {
+	h.audit = &auditLog{h: h, bucket: h.conf.AuditBucket, prefix: h.conf.AuditPrefix}
+}
+
+// auditRecord는 처리 결정 하나의 감사 레코드입니다. (누가 / 무엇을 / 언제 / 입력 / 출력 / 결정)
+type auditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	// RequestID, FunctionARN, FunctionVersion은 처리한 Lambda 호출입니다.
+	RequestID       string `json:"requestId,omitempty"`
+	FunctionARN     string `json:"functionArn,omitempty"`
+	FunctionVersion string `json:"functionVersion,omitempty"`
+
+	Bucket string  `json:"bucket"`
+	Key    string  `json:"key"`
+	Tenant string  `json:"tenant,omitempty"`
+	Input  S3Event `json:"input"`
+
+	Decision Status         `json:"decision"` // 결과 상태, 실패하면 FAILED
+	Message  string         `json:"message,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Outputs  []OutputResult `json:"outputs,omitempty"`
+	// SourceModified는 원본 객체(태그 포함)를 바꿨는지입니다. 원본은 삭제하지 않으며, 바뀐 내용은 SourceChanges에 남습니다.
+	SourceModified bool     `json:"sourceModified"`
+	SourceChanges  []string `json:"sourceChanges,omitempty"`
+}
+
+// auditLog는 감사 레코드를 <prefix>dt=YYYY-MM-DD/ 아래 JSON Lines 객체로 씁니다.
+// 레코드마다 새 키에 If-None-Match로 올리므로 기존 레코드를 덮어쓰지 않습니다.
+// 삭제까지 막으려면 버킷에 Object Lock이나 삭제 거부 정책을 함께 설정합니다.
+type auditLog struct {
+	h      *Handler
+	bucket string
+	prefix string
+}
+
+// Record는 변환 결과의 감사 레코드를 씁니다. 기록하지 못하면 오류를 돌려 호출이 재시도되게 합니다.
+func (a *auditLog) Record(ctx context.Context, job *Job, result ConversionResult, cause error) error {
+	if a == nil {
+		return nil
+	}
+	now := a.h.clock.Now().UTC()
+	record := auditRecord{
+		Timestamp:       now,
+		FunctionVersion: os.Getenv("AWS_LAMBDA_FUNCTION_VERSION"),
+		Bucket:          job.Bucket,
+		Key:             job.SrcKey,
+		Tenant:          job.Result.Tenant,
+		Input:           job.Event,
+		Decision:        result.Status,
+		Message:         result.Message,
+		Outputs:         result.Outputs,
+		SourceModified:  len(job.SourceChanges) > 0,
+		SourceChanges:   job.SourceChanges,
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		record.RequestID = lc.AwsRequestID
+		record.FunctionARN = lc.InvokedFunctionArn
+	}
+	if cause != nil {
+		record.Decision = StatusFailed
+		record.Error = cause.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	key, err := a.key(now, record.RequestID)
+	if err != nil {
+		return err
+	}
+	body := append(line, '\n')
+	_, err = a.h.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/x-ndjson"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit record s3://%s/%s: %w", a.bucket, key, err)
+	}
+	log.Printf("Audit record written: s3://%s/%s", a.bucket, key)
+	return nil
+}
+
+// key는 날짜로 나눈 감사 레코드 키입니다. 같은 호출의 배치 항목끼리 겹치지 않도록 임의 접미사를 붙입니다.
+func (a *auditLog) key(now time.Time, requestID string) (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate audit record key: %w", err)
+	}
+	name := now.Format("20060102T150405.000000000Z")
+	if requestID != "" {
+		name += "-" + requestID
+	}
+	return fmt.Sprintf("%sdt=%s/%s-%s.jsonl", a.prefix, now.Format(time.DateOnly), name, hex.EncodeToString(suffix)), nil
+}
@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// avifEncoders는 AVIF_ENCODER 값과 libheif 인코더의 대응입니다.
+var avifEncoders = map[string]vips.HeifEncoder{
+	"svt": vips.HeifEncoderSvt,
+	"aom": vips.HeifEncoderAom,
+}
+
+// selectAVIFEncoder는 픽셀 수와 비트 깊이로 AV1 인코더를 고릅니다.
+// SVT-AV1은 큰 이미지에서 빠르고, AOM은 작은 이미지를 더 잘 압축합니다.
+func selectAVIFEncoder(width, height, bitdepth int, c Config) (string, string) {
+	if bitdepth > 10 {
+		// SVT-AV1은 10비트까지만 지원하므로 12비트 HDR은 AOM으로 인코딩합니다.
+		return "aom", fmt.Sprintf("bitdepth %d requires aom", bitdepth)
+	}
+	if c.AVIFEncoder != "auto" {
+		return c.AVIFEncoder, "forced by AVIF_ENCODER"
+	}
+	pixels := width * height
+	if pixels <= c.AOMMaxPixels {
+		return "aom", fmt.Sprintf("%d pixels <= AOM_MAX_PIXELS %d", pixels, c.AOMMaxPixels)
+	}
+	return "svt", fmt.Sprintf("%d pixels > AOM_MAX_PIXELS %d", pixels, c.AOMMaxPixels)
+}
+
+// resolveEffort는 이벤트의 effort/speed 값과 AVIF_EFFORT 설정으로 AVIF 인코딩 노력 수준을 정합니다.
+// speed는 libheif 관례대로 effort의 반대 값(speed = 9 - effort)입니다.
+func resolveEffort(event S3Event, c Config) (int, error) {
+	switch {
+	case event.Effort != nil && event.Speed != nil:
+		return 0, fmt.Errorf("effort and speed cannot be set together")
+	case event.Effort != nil:
+		if *event.Effort < 0 || *event.Effort > 9 {
+			return 0, fmt.Errorf("invalid effort %d: must be between 0 and 9", *event.Effort)
+		}
+		return *event.Effort, nil
+	case event.Speed != nil:
+		if *event.Speed < 0 || *event.Speed > 9 {
+			return 0, fmt.Errorf("invalid speed %d: must be between 0 and 9", *event.Speed)
+		}
+		return 9 - *event.Speed, nil
+	}
+	return c.AVIFEffort, nil
+}
+
+// avifOptions는 손실/무손실 여부와 색 공간 정책에 맞는 heifsave 옵션을 만듭니다.
+// 선택된 인코더 이름도 함께 돌려줍니다.
+func avifOptions(image *vips.Image, graphics bool, color colorPlan, keep vips.Keep, effort int, c Config) (*vips.HeifsaveBufferOptions, string) {
+	encoder, reason := selectAVIFEncoder(image.Width(), image.Height(), color.Bitdepth, c)
+	log.Printf("AVIF encoder selected: %s (%s)", encoder, reason)
+
+	options := &vips.HeifsaveBufferOptions{
+		Q:             50,
+		Bitdepth:      color.Bitdepth,
+		Lossless:      false,
+		SubsampleMode: vips.SubsampleAuto,
+		Effort:        effort,
+		Compression:   vips.HeifCompressionAv1,
+		Encoder:       avifEncoders[encoder],
+		Keep:          keep,
+	}
+	if graphics {
+		// 그래픽은 크로마 서브샘플링 없이(4:4:4) 인코딩해야 글자와 선이 번지지 않습니다.
+		options.SubsampleMode = vips.SubsampleOff
+		if c.GraphicsNearLossless {
+			options.Q = c.GraphicsNearLosslessQuality
+		} else {
+			options.Lossless = true
+		}
+	}
+	return options, encoder
+}
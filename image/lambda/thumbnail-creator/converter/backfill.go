@@ -0,0 +1,230 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// BackfillRequest는 "mode": "backfill" 요청의 설정입니다. 매니페스트와 체크포인트는 s3Bucket에 있습니다.
+type BackfillRequest struct {
+	// ManifestKey는 변환할 키 목록입니다. .csv이면 S3 인벤토리 CSV(버킷, 키, ...)이고,
+	// 그 밖에는 한 줄에 키 하나입니다. 인벤토리 버킷이 다르면 CSV의 버킷 열을 씁니다.
+	ManifestKey string `json:"manifestKey,omitempty"`
+	// Start와 End는 이 호출이 맡을 매니페스트 구간 [start, end)입니다. End가 0이면 끝까지입니다.
+	Start int `json:"start,omitempty"`
+	End   int `json:"end,omitempty"`
+	// CheckpointKey는 체크포인트 객체 키입니다. 기본은 .checkpoints/<manifestKey>.<start>-<end>.json입니다.
+	CheckpointKey string `json:"checkpointKey,omitempty"`
+	// CheckpointEvery는 체크포인트를 쓰는 항목 간격이며 한 번에 배치로 처리하는 크기이기도 합니다.
+	// 비어 있으면 BACKFILL_CHECKPOINT_EVERY입니다.
+	CheckpointEvery int `json:"checkpointEvery,omitempty"`
+	// Preset, Effort, Pipeline은 모든 항목에 적용할 변환 프리셋, 인코딩 노력 수준, 처리 단계입니다.
+	Preset   string          `json:"preset,omitempty"`
+	Effort   *int            `json:"effort,omitempty"`
+	Pipeline []pipeline.Step `json:"pipeline,omitempty"`
+	// ResumeFrom은 이전 호출이 남긴 체크포인트 키입니다. 있으면 나머지 필드 대신 체크포인트의 설정으로 이어서 처리합니다.
+	ResumeFrom string `json:"resumeFrom,omitempty"`
+}
+
+// BackfillCheckpoint는 체크포인트 객체의 내용이며, 결과의 backfill에는 Items를 뺀 요약이 들어갑니다.
+type BackfillCheckpoint struct {
+	Request BackfillRequest `json:"request"`
+	// NextIndex는 다음에 처리할 매니페스트 항목 번호입니다. End와 같으면 끝난 것입니다.
+	NextIndex     int            `json:"nextIndex"`
+	End           int            `json:"end"`
+	Done          bool           `json:"done"`
+	Counts        map[Status]int `json:"counts"`
+	CheckpointKey string         `json:"checkpointKey"`
+	UpdatedAt     string         `json:"updatedAt"`
+	// Items는 처리한 항목별 상태이며 Start부터 순서대로입니다.
+	Items []BackfillItemStatus `json:"items,omitempty"`
+}
+
+// BackfillItemStatus는 매니페스트 항목 하나의 결과입니다.
+type BackfillItemStatus struct {
+	Index   int       `json:"index"`
+	Key     string    `json:"key"`
+	Status  Status    `json:"status"`
+	Code    ErrorCode `json:"code,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Backfill은 매니페스트 구간을 CheckpointEvery개씩 배치로 변환하고, 묶음마다 체크포인트를 씁니다.
+// 다음 묶음을 끝낼 시간이 남지 않으면 체크포인트를 남기고 BACKFILL_PARTIAL로 끝나므로,
+// 호출자는 결과의 backfill.checkpointKey를 resumeFrom에 넣어 다시 호출하면 됩니다.
+func (h *Handler) Backfill(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if event.S3Bucket == "" || event.Backfill == nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: backfill requires s3Bucket and backfill")
+	}
+	cp, err := h.openCheckpoint(ctx, event.S3Bucket, *event.Backfill)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	req := cp.Request
+	entries, err := h.readManifest(ctx, event.S3Bucket, req.ManifestKey)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	if cp.End == 0 || cp.End > len(entries) {
+		cp.End = len(entries)
+	}
+	log.Printf("Backfill %s: items %d-%d, resuming at %d", req.ManifestKey, req.Start, cp.End, cp.NextIndex)
+
+	var lastChunk time.Duration
+	for cp.NextIndex < cp.End {
+		// 직전 묶음만큼의 시간과 DEADLINE_RESERVE_MS가 남아 있어야 다음 묶음을 시작합니다.
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(h.clock.Now()) < lastChunk+h.conf.DeadlineReserve {
+			break
+		}
+		end := min(cp.NextIndex+req.CheckpointEvery, cp.End)
+		batch := S3Event{}
+		for _, e := range entries[cp.NextIndex:end] {
+			batch.Items = append(batch.Items, S3Event{S3Bucket: e.bucket, S3Key: e.key, Preset: req.Preset, Effort: req.Effort, Pipeline: req.Pipeline})
+		}
+		started := h.clock.Now()
+		result, err := h.Batch(ctx, batch)
+		if err != nil {
+			return ConversionResult{}, err
+		}
+		lastChunk = h.clock.Now().Sub(started)
+		// 시간이 모자라 시작하지 못한 항목은 실패로 세지 않고, 그 항목부터 다음 호출에서 이어서 처리합니다.
+		processed := len(result.Items)
+		for i, item := range result.Items {
+			if item.Status == StatusFailed && item.Errors[0].Code == ErrorTimeoutBudgetExceeded {
+				processed = i
+				break
+			}
+		}
+		for i, item := range result.Items[:processed] {
+			status := BackfillItemStatus{Index: cp.NextIndex + i, Key: entries[cp.NextIndex+i].key, Status: item.Status}
+			if item.Status == StatusFailed {
+				status.Code = item.Errors[0].Code
+				status.Message = item.Message
+			}
+			cp.Items = append(cp.Items, status)
+			cp.Counts[item.Status]++
+		}
+		cp.NextIndex += processed
+		if err := h.writeCheckpoint(ctx, event.S3Bucket, cp); err != nil {
+			return ConversionResult{}, err
+		}
+		if processed < len(result.Items) {
+			break
+		}
+	}
+	cp.Done = cp.NextIndex >= cp.End
+	if err := h.writeCheckpoint(ctx, event.S3Bucket, cp); err != nil {
+		return ConversionResult{}, err
+	}
+
+	summary := *cp
+	summary.Items = nil
+	status := StatusBackfillCompleted
+	if !cp.Done {
+		status = StatusBackfillPartial
+	}
+	msg := fmt.Sprintf("Backfill %s: processed up to %d of %d (%d failed), checkpoint %s", req.ManifestKey, cp.NextIndex, cp.End, cp.Counts["FAILED"], cp.CheckpointKey)
+	log.Println(msg)
+	return ConversionResult{Status: status, Message: msg, Backfill: &summary}, nil
+}
+
+// openCheckpoint는 resumeFrom이 있으면 체크포인트를 읽고, 없으면 요청으로 새 체크포인트를 만듭니다.
+func (h *Handler) openCheckpoint(ctx context.Context, bucket string, req BackfillRequest) (*BackfillCheckpoint, error) {
+	if req.ResumeFrom != "" {
+		data, err := h.downloadObject(ctx, bucket, req.ResumeFrom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %s: %w", req.ResumeFrom, err)
+		}
+		var cp BackfillCheckpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("invalid checkpoint %s: %w", req.ResumeFrom, err)
+		}
+		cp.CheckpointKey = req.ResumeFrom
+		if cp.Counts == nil {
+			cp.Counts = map[Status]int{}
+		}
+		return &cp, nil
+	}
+	if req.ManifestKey == "" {
+		return nil, fmt.Errorf("invalid event: backfill requires manifestKey or resumeFrom")
+	}
+	if req.Start < 0 || req.End < 0 || (req.End > 0 && req.End <= req.Start) {
+		return nil, fmt.Errorf("invalid event: backfill start and end must satisfy 0 <= start < end")
+	}
+	if req.CheckpointEvery == 0 {
+		req.CheckpointEvery = h.conf.BackfillCheckpointEvery
+	}
+	if req.CheckpointEvery < 1 {
+		return nil, fmt.Errorf("invalid event: backfill checkpointEvery must be positive")
+	}
+	if req.CheckpointKey == "" {
+		req.CheckpointKey = fmt.Sprintf(".checkpoints/%s.%d-%d.json", req.ManifestKey, req.Start, req.End)
+	}
+	return &BackfillCheckpoint{Request: req, NextIndex: req.Start, End: req.End, Counts: map[Status]int{}, CheckpointKey: req.CheckpointKey}, nil
+}
+
+func (h *Handler) writeCheckpoint(ctx context.Context, bucket string, cp *BackfillCheckpoint) error {
+	cp.UpdatedAt = h.clock.Now().UTC().Format(time.RFC3339)
+	body, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := h.putObject(ctx, bucket, cp.CheckpointKey, "application/json", body); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", cp.CheckpointKey, err)
+	}
+	return nil
+}
+
+type manifestEntry struct {
+	bucket string
+	key    string
+}
+
+// readManifest는 매니페스트를 읽어 항목 목록으로 만듭니다. 빈 줄은 건너뛰므로 항목 번호는 빈 줄을 세지 않습니다.
+// S3 인벤토리 CSV의 키는 URL 인코딩되어 있어 원래 키로 되돌립니다.
+func (h *Handler) readManifest(ctx context.Context, bucket, key string) ([]manifestEntry, error) {
+	data, err := h.downloadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", key, err)
+	}
+	var entries []manifestEntry
+	if strings.HasSuffix(key, ".csv") {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		for {
+			row, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid manifest %s: %w", key, err)
+			}
+			if len(row) < 2 || row[1] == "" {
+				continue
+			}
+			objectKey, err := url.QueryUnescape(row[1])
+			if err != nil {
+				objectKey = row[1]
+			}
+			entries = append(entries, manifestEntry{bucket: row[0], key: objectKey})
+		}
+		return entries, nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entries = append(entries, manifestEntry{bucket: bucket, key: line})
+		}
+	}
+	return entries, nil
+}
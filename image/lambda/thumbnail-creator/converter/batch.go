@@ -0,0 +1,225 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+)
+
+// BatchRecord는 SQS 메시지 또는 S3 이벤트 알림의 레코드 하나입니다. eventSource로 구분합니다.
+type BatchRecord struct {
+	EventSource string `json:"eventSource"` // "aws:sqs" | "aws:s3"
+	AWSRegion   string `json:"awsRegion,omitempty"`
+	// MessageID와 Body는 SQS 레코드 필드입니다. Body는 S3Event JSON이거나 S3→SQS 알림입니다.
+	MessageID string `json:"messageId,omitempty"`
+	Body      string `json:"body,omitempty"`
+	// S3는 S3 이벤트 알림 레코드 필드입니다.
+	S3 *struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			ETag      string `json:"eTag"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3,omitempty"`
+}
+
+// BatchItemFailure는 SQS 부분 배치 응답의 실패 항목입니다.
+// 이벤트 소스 매핑에 ReportBatchItemFailures를 켜면 실패한 메시지만 다시 받습니다.
+type BatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// batchItem은 배치에서 변환할 요청 하나와 그 요청이 나온 SQS 메시지입니다.
+type batchItem struct {
+	event     S3Event
+	messageID string
+}
+
+// batchItems는 SQS 메시지, S3 알림 레코드, items 목록을 변환 요청 목록으로 펼칩니다.
+// SQS 메시지 하나가 S3 알림 여러 건을 담고 있으면 모두 같은 메시지의 항목이 됩니다.
+func batchItems(event S3Event) ([]batchItem, error) {
+	var items []batchItem
+	for _, e := range event.Items {
+		if (e.Mode != "" && e.Mode != "metadata") || len(e.Records) > 0 || len(e.Items) > 0 {
+			return nil, fmt.Errorf("invalid event: batch items must be plain conversion or metadata requests")
+		}
+		items = append(items, batchItem{event: e})
+	}
+	for i, r := range event.Records {
+		switch r.EventSource {
+		case "aws:s3":
+			if r.S3 == nil {
+				return nil, fmt.Errorf("invalid event: record %d has no s3 field", i)
+			}
+			items = append(items, batchItem{event: S3Event{
+				S3Bucket: r.S3.Bucket.Name,
+				S3Key:    r.S3.Object.Key,
+				// S3 알림의 키는 URL 인코딩되어 옵니다.
+				S3KeyEncoded: true,
+				S3Size:       r.S3.Object.Size,
+				S3ETag:       r.S3.Object.ETag,
+				S3Sequencer:  r.S3.Object.Sequencer,
+				S3Region:     r.AWSRegion,
+			}})
+		case "aws:sqs":
+			if isS3TestEvent(r.Body) {
+				// 알림을 설정할 때 S3가 보내는 확인 메시지는 변환할 객체가 없으므로 실패 항목 없이 확인만 합니다.
+				log.Printf("Skipping S3 test event in SQS message %s", r.MessageID)
+				continue
+			}
+			var body S3Event
+			if err := json.Unmarshal([]byte(r.Body), &body); err != nil {
+				// 읽을 수 없는 메시지는 다른 메시지를 막지 않도록 실패 항목으로만 둡니다.
+				log.Printf("Warning: invalid SQS message %s: %v", r.MessageID, err)
+				items = append(items, batchItem{messageID: r.MessageID})
+				continue
+			}
+			nested, err := batchItems(S3Event{Records: body.Records, Items: body.Items})
+			if err != nil || len(nested) == 0 {
+				nested = []batchItem{{event: body}}
+			}
+			for _, n := range nested {
+				n.messageID = r.MessageID
+				if n.event.Priority == "" {
+					n.event.Priority = body.Priority
+				}
+				items = append(items, n)
+			}
+		default:
+			return nil, fmt.Errorf("invalid event: record %d has unsupported eventSource %q", i, r.EventSource)
+		}
+	}
+	return items, nil
+}
+
+// isS3TestEvent는 SQS 메시지 본문이 S3 알림 설정 시 보내는 s3:TestEvent인지 확인합니다.
+// 이 메시지에는 Records가 없어 변환 요청으로 읽으면 s3Bucket/s3Key가 없는 실패 항목이 되고, SQS가 계속 다시 보냅니다.
+func isS3TestEvent(body string) bool {
+	var test struct {
+		Event string `json:"Event"`
+	}
+	if err := json.Unmarshal([]byte(body), &test); err != nil {
+		return false
+	}
+	return test.Event == "s3:TestEvent"
+}
+
+// priorityRank는 배치에서 먼저 시작할 순서입니다. 값이 작을수록 먼저 처리합니다.
+func priorityRank(priority string) (int, error) {
+	switch priority {
+	case "high":
+		return 0, nil
+	case "", "normal":
+		return 1, nil
+	case "low":
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid event: unknown priority %q, must be high, normal or low", priority)
+	}
+}
+
+// deferLowPriority는 남은 시간이 BATCH_LOW_PRIORITY_RESERVE_MS보다 적으면 low 항목을 시작하지 않을 오류를 돌려줍니다.
+// 실패 항목으로 남기므로 SQS는 그 메시지만 다시 보내고, 먼저 시작한 high/normal 항목은 시간을 그대로 씁니다.
+func (h *Handler) deferLowPriority(ctx context.Context) error {
+	if h.conf.BatchLowPriorityReserve == 0 {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	if remaining := deadline.Sub(h.clock.Now()); remaining < h.conf.BatchLowPriorityReserve {
+		return &TimeoutBudgetExceeded{Phase: "low-priority item", Remaining: remaining, Reserve: h.conf.BatchLowPriorityReserve}
+	}
+	return nil
+}
+
+// Batch는 여러 변환 요청을 BATCH_CONCURRENCY개의 작업자로 동시에 처리하고 결과를 모읍니다.
+// 항목은 priority 순(같으면 받은 순서)으로 시작하며, 결과는 받은 순서대로 돌려줍니다.
+// 항목마다 BATCH_ITEM_TIMEOUT_MS 제한 시간이 따로 적용되며, 실패한 항목은 FAILED 결과로 남고 나머지는 계속 처리합니다.
+// SQS 배치는 실패한 메시지를 batchItemFailures로 돌려주고, S3 알림 배치는 비동기 재시도를 위해 오류를 돌려줍니다.
+func (h *Handler) Batch(ctx context.Context, event S3Event) (ConversionResult, error) {
+	items, err := batchItems(event)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	log.Printf("Processing batch of %d items with concurrency %d", len(items), h.conf.BatchConcurrency)
+
+	results := make([]ConversionResult, len(items))
+	errs := make([]error, len(items))
+	ranks := make([]int, len(items))
+	order := make([]int, len(items))
+	for i, item := range items {
+		order[i] = i
+		ranks[i], errs[i] = priorityRank(item.event.Priority)
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return ranks[a] - ranks[b] })
+
+	slots := make(chan struct{}, h.conf.BatchConcurrency)
+	var wg sync.WaitGroup
+	deferred := 0
+	for _, i := range order {
+		item := items[i]
+		if errs[i] != nil {
+			continue
+		}
+		slots <- struct{}{}
+		// 작업자 자리가 날 때까지 기다린 뒤에 판단해야 실제로 시작할 시점의 남은 시간을 봅니다.
+		if ranks[i] == 2 {
+			if errs[i] = h.deferLowPriority(ctx); errs[i] != nil {
+				<-slots
+				deferred++
+				continue
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			if item.event.S3Bucket == "" || item.event.S3Key == "" {
+				errs[i] = fmt.Errorf("invalid event: s3Bucket and s3Key are required")
+				return
+			}
+			itemCtx := ctx
+			if h.conf.BatchItemTimeout > 0 {
+				var cancel context.CancelFunc
+				itemCtx, cancel = context.WithTimeout(ctx, h.conf.BatchItemTimeout)
+				defer cancel()
+			}
+			results[i], errs[i] = h.convertEvent(itemCtx, item.event)
+		}()
+	}
+	wg.Wait()
+	if deferred > 0 {
+		log.Printf("Deferred %d low-priority items, remaining time is below BATCH_LOW_PRIORITY_RESERVE_MS", deferred)
+		emitMetrics(h.conf.MetricsNamespace, "Count", map[string]float64{"LowPriorityDeferred": float64(deferred)})
+	}
+
+	batch := ConversionResult{Status: StatusBatchCompleted, Items: results}
+	failedMessages := map[string]bool{}
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed++
+		log.Printf("Batch item %d (%s) failed: %v", i, items[i].event.S3Key, err)
+		batch.Items[i] = failedResult("", items[i].event.S3Key, err)
+		batch.Errors = append(batch.Errors, batch.Items[i].Errors...)
+		if id := items[i].messageID; id != "" && !failedMessages[id] {
+			failedMessages[id] = true
+			batch.BatchItemFailures = append(batch.BatchItemFailures, BatchItemFailure{ItemIdentifier: id})
+		}
+	}
+	batch.Message = fmt.Sprintf("%d of %d items failed", failed, len(items))
+	if failed > 0 && len(failedMessages) == 0 && len(event.Records) > 0 {
+		return batch, fmt.Errorf("%s", batch.Message)
+	}
+	return batch, nil
+}
@@ -0,0 +1,167 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestBatchItemsSkipsS3TestEvent(t *testing.T) {
+	event := S3Event{Records: []BatchRecord{
+		{
+			EventSource: "aws:sqs",
+			MessageID:   "test-event",
+			Body:        `{"Service":"Amazon S3","Event":"s3:TestEvent","Time":"2024-01-01T00:00:00.000Z","Bucket":"uploads","RequestId":"R","HostId":"H"}`,
+		},
+		{
+			EventSource: "aws:sqs",
+			MessageID:   "conversion",
+			Body:        `{"s3Bucket":"uploads","s3Key":"photos/a.jpg"}`,
+		},
+		{
+			EventSource: "aws:sqs",
+			MessageID:   "invalid",
+			Body:        `not json`,
+		},
+	}}
+	items, err := batchItems(event)
+	if err != nil {
+		t.Fatalf("batchItems: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("batchItems returned %d items, want 2: %+v", len(items), items)
+	}
+	if items[0].messageID != "conversion" || items[0].event.S3Key != "photos/a.jpg" {
+		t.Errorf("items[0] = %+v, want conversion message", items[0])
+	}
+	if items[1].messageID != "invalid" || items[1].event.S3Key != "" {
+		t.Errorf("items[1] = %+v, want invalid message as failure item", items[1])
+	}
+}
+
+func TestIsS3TestEvent(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"uploads"}`, true},
+		{`{"Records":[{"eventSource":"aws:s3","eventName":"ObjectCreated:Put"}]}`, false},
+		{`{"s3Bucket":"uploads","s3Key":"photos/a.jpg"}`, false},
+		{`not json`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := isS3TestEvent(tt.body); got != tt.want {
+			t.Errorf("isS3TestEvent(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+// clockedS3는 GetObject마다 clock을 step만큼 흘려 항목 하나를 처리하는 데 시간이 걸리는 것처럼 만듭니다.
+type clockedS3 struct {
+	*fakeS3
+	clock *manualClock
+	step  time.Duration
+}
+
+func (c *clockedS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.clock.Advance(c.step)
+	return c.fakeS3.GetObject(ctx, params, optFns...)
+}
+
+func TestBatchPriority(t *testing.T) {
+	messages := []struct{ id, key, priority string }{
+		{"m1", "low-1.png", "low"},
+		{"m2", "normal.png", ""},
+		{"m3", "high-1.png", "high"},
+		{"m4", "low-2.png", "low"},
+		{"m5", "high-2.png", "high"},
+		{"m6", "urgent.png", "urgent"},
+	}
+	tests := []struct {
+		name string
+		// step은 항목 하나를 처리하는 데 걸리는 시간입니다. 제한 시간은 60초, BATCH_LOW_PRIORITY_RESERVE_MS는 30초입니다.
+		step time.Duration
+		// wantGets는 항목을 시작한 순서이고, wantFailures는 batchItemFailures의 메시지입니다.
+		wantGets     []string
+		wantFailures []string
+	}{
+		{
+			name:         "within budget",
+			wantGets:     []string{"high-1.png", "high-2.png", "normal.png", "low-1.png", "low-2.png"},
+			wantFailures: []string{"m6"},
+		},
+		{
+			name:         "low priority deferred",
+			step:         12 * time.Second,
+			wantGets:     []string{"high-1.png", "high-2.png", "normal.png"},
+			wantFailures: []string{"m1", "m4", "m6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startVips(t)
+			clock := &manualClock{now: time.Now()}
+			client := &clockedS3{fakeS3: newFakeS3(), clock: clock, step: tt.step}
+			event := S3Event{}
+			for _, m := range messages {
+				// 빈 객체는 디코딩 전에 SKIPPED_EMPTY_OBJECT로 끝나므로 항목을 시작한 순서만 남습니다.
+				client.put("uploads", m.key, []byte{})
+				body, err := json.Marshal(S3Event{S3Bucket: "uploads", S3Key: m.key, Priority: m.priority})
+				if err != nil {
+					t.Fatal(err)
+				}
+				event.Records = append(event.Records, BatchRecord{EventSource: "aws:sqs", MessageID: m.id, Body: string(body)})
+			}
+			h := NewHandler(client, clock, testConfig(t, map[string]string{
+				"BATCH_CONCURRENCY":             "1",
+				"BATCH_LOW_PRIORITY_RESERVE_MS": "30000",
+				"DEADLINE_RESERVE_MS":           "1000",
+			}))
+			ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(time.Minute))
+			defer cancel()
+
+			result, err := h.Batch(ctx, event)
+			if err != nil {
+				t.Fatalf("Batch: %v", err)
+			}
+			if !slices.Equal(client.gets, tt.wantGets) {
+				t.Errorf("items started in order %v, want %v", client.gets, tt.wantGets)
+			}
+			var failures []string
+			for _, f := range result.BatchItemFailures {
+				failures = append(failures, f.ItemIdentifier)
+			}
+			if !slices.Equal(failures, tt.wantFailures) {
+				t.Errorf("batchItemFailures = %v, want %v", failures, tt.wantFailures)
+			}
+			if len(result.Items) != len(messages) {
+				t.Fatalf("batch has %d items, want %d", len(result.Items), len(messages))
+			}
+			// 결과는 받은 순서대로입니다.
+			for i, m := range messages {
+				item := result.Items[i]
+				want := StatusSkippedEmptyObject
+				switch {
+				case m.priority == "urgent":
+					want = StatusFailed
+					if len(item.Errors) != 1 || item.Errors[0].Code != ErrorInvalidEvent {
+						t.Errorf("%s errors = %+v, want %s", m.key, item.Errors, ErrorInvalidEvent)
+					}
+				case slices.Contains(tt.wantFailures, m.id):
+					want = StatusFailed
+					if len(item.Errors) != 1 || item.Errors[0].Code != ErrorTimeoutBudgetExceeded || !item.Errors[0].Retryable {
+						t.Errorf("%s errors = %+v, want retryable %s", m.key, item.Errors, ErrorTimeoutBudgetExceeded)
+					}
+				}
+				if item.Status != want || item.OriginalKey != m.key {
+					t.Errorf("items[%d] = %s %s, want %s %s", i, item.Status, item.OriginalKey, want, m.key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// maxBenchmarkIterations는 조합 하나당 최대 반복 횟수입니다.
+const maxBenchmarkIterations = 50
+
+// BenchmarkRequest는 "mode": "benchmark" 이벤트의 설정입니다.
+// Efforts × Qualities의 모든 조합을 Iterations번씩 디코딩·인코딩하며, 출력은 업로드하지 않습니다.
+type BenchmarkRequest struct {
+	Iterations int    `json:"iterations,omitempty"` // 기본 3
+	Format     string `json:"format,omitempty"`     // 기본 avif
+	Efforts    []int  `json:"efforts,omitempty"`    // 기본 [AVIF_EFFORT]
+	Qualities  []int  `json:"qualities,omitempty"`  // 기본 [0] (포맷별 기본 품질)
+	Profile    bool   `json:"profile,omitempty"`    // true이면 CPU 프로파일(pprof)을 S3에 업로드
+	ProfileKey string `json:"profileKey,omitempty"` // 기본 benchmarks/<원본 키>.<unix>.pprof
+}
+
+// BenchmarkReport는 벤치마크 결과입니다.
+type BenchmarkReport struct {
+	MemoryMB   string         `json:"memoryMB,omitempty"` // Lambda 메모리 크기 (AWS_LAMBDA_FUNCTION_MEMORY_SIZE)
+	SourceSize int64          `json:"sourceSize"`
+	Width      int            `json:"width"`
+	Height     int            `json:"height"`
+	Runs       []BenchmarkRun `json:"runs"`
+	ProfileKey string         `json:"profileKey,omitempty"`
+	// Truncated는 남은 실행 시간이 부족해 일부 반복을 건너뛰었으면 true입니다.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// BenchmarkRun은 설정 조합 하나의 측정 결과입니다.
+type BenchmarkRun struct {
+	Format     string        `json:"format"`
+	Effort     int           `json:"effort"`
+	Quality    int           `json:"quality,omitempty"`
+	Encoder    string        `json:"encoder,omitempty"`
+	Size       int64         `json:"size"`
+	Iterations int           `json:"iterations"`
+	DecodeMs   DurationStats `json:"decodeMs"`
+	EncodeMs   DurationStats `json:"encodeMs"`
+}
+
+// DurationStats는 반복 측정값의 요약(밀리초)입니다.
+type DurationStats struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	Max  float64 `json:"max"`
+}
+
+func newDurationStats(samples []time.Duration) DurationStats {
+	if len(samples) == 0 {
+		return DurationStats{}
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return DurationStats{
+		Min:  ms(sorted[0]),
+		Mean: ms(total / time.Duration(len(sorted))),
+		P50:  ms(sorted[len(sorted)/2]),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+}
+
+// Benchmark는 원본 하나를 설정 조합별로 반복 변환해 시간과 크기를 잽니다.
+// Lambda 메모리 크기별로 실행해 비교하는 용도입니다.
+func (h *Handler) Benchmark(ctx context.Context, event S3Event) (ConversionResult, error) {
+	req := BenchmarkRequest{}
+	if event.Benchmark != nil {
+		req = *event.Benchmark
+	}
+	if req.Iterations == 0 {
+		req.Iterations = 3
+	}
+	if req.Iterations < 1 || req.Iterations > maxBenchmarkIterations {
+		return ConversionResult{}, fmt.Errorf("invalid event: benchmark iterations must be between 1 and %d", maxBenchmarkIterations)
+	}
+	if req.Format == "" {
+		req.Format = "avif"
+	}
+	encoder, err := h.encoders.Get(req.Format)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if len(req.Efforts) == 0 {
+		req.Efforts = []int{h.conf.AVIFEffort}
+	}
+	if len(req.Qualities) == 0 {
+		req.Qualities = []int{0}
+	}
+	for _, e := range req.Efforts {
+		if e < 0 || e > 9 {
+			return ConversionResult{}, fmt.Errorf("invalid event: benchmark effort %d must be between 0 and 9", e)
+		}
+	}
+	for _, q := range req.Qualities {
+		if q < 0 || q > 100 {
+			return ConversionResult{}, fmt.Errorf("invalid event: benchmark quality %d must be between 0 and 100", q)
+		}
+	}
+
+	srcKey, err := h.decodeKey(event.S3Key, event.S3KeyEncoded)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	source, err := h.downloadObject(ctx, event.S3Bucket, srcKey)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	report := &BenchmarkReport{
+		MemoryMB:   os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"),
+		SourceSize: int64(len(source)),
+	}
+
+	var profile bytes.Buffer
+	if req.Profile {
+		if err := pprof.StartCPUProfile(&profile); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+	}
+	runErr := h.runBenchmark(ctx, source, encoder, req, report)
+	if req.Profile {
+		pprof.StopCPUProfile()
+	}
+	if runErr != nil {
+		return ConversionResult{}, runErr
+	}
+
+	if req.Profile {
+		report.ProfileKey = req.ProfileKey
+		if report.ProfileKey == "" {
+			report.ProfileKey = fmt.Sprintf("benchmarks/%s.%d.pprof", strings.TrimPrefix(srcKey, "/"), h.clock.Now().Unix())
+		}
+		if report.ProfileKey == srcKey {
+			return ConversionResult{}, fmt.Errorf("profile key %s would overwrite the source object", srcKey)
+		}
+		if err := h.putObject(ctx, event.S3Bucket, report.ProfileKey, "application/octet-stream", profile.Bytes()); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to upload CPU profile: %w", err)
+		}
+	}
+
+	msg := fmt.Sprintf("Benchmarked %d configurations", len(report.Runs))
+	log.Println(msg)
+	return ConversionResult{
+		Status:      StatusBenchmarked,
+		OriginalKey: srcKey,
+		Format:      req.Format,
+		Benchmark:   report,
+		Message:     msg,
+	}, nil
+}
+
+func (h *Handler) runBenchmark(ctx context.Context, source []byte, encoder Encoder, req BenchmarkRequest, report *BenchmarkReport) error {
+	for _, effort := range req.Efforts {
+		for _, quality := range req.Qualities {
+			run := BenchmarkRun{Format: req.Format, Effort: effort, Quality: quality}
+			var decodes, encodes []time.Duration
+			for i := 0; i < req.Iterations; i++ {
+				if err := h.checkBudget(ctx, "benchmark iteration"); err != nil {
+					log.Printf("Warning: stopping benchmark early: %v", err)
+					report.Truncated = true
+					break
+				}
+				start := h.clock.Now()
+				image, err := vips.NewImageFromBuffer(source, nil)
+				if err != nil {
+					return fmt.Errorf("failed to process image with vips from buffer: %w", err)
+				}
+				decoded := h.clock.Now()
+				encoded, err := encoder.Encode(image, EncodeOptions{
+					Color:   colorPlan{Bitdepth: 10},
+					Keep:    vips.KeepNone,
+					Effort:  effort,
+					Quality: quality,
+				})
+				report.Width, report.Height = image.Width(), image.Height()
+				image.Close()
+				if err != nil {
+					return fmt.Errorf("benchmark encode to %s failed: %w", req.Format, err)
+				}
+				decodes = append(decodes, decoded.Sub(start))
+				encodes = append(encodes, h.clock.Now().Sub(decoded))
+				run.Size = int64(len(encoded.Data))
+				run.Encoder = encoded.Encoder
+			}
+			if len(encodes) == 0 {
+				return nil
+			}
+			run.Iterations = len(encodes)
+			run.DecodeMs = newDurationStats(decodes)
+			run.EncodeMs = newDurationStats(encodes)
+			log.Printf("Benchmark %s effort=%d quality=%d: %d bytes, encode p50 %.1fms", run.Format, effort, quality, run.Size, run.EncodeMs.P50)
+			report.Runs = append(report.Runs, run)
+			if report.Truncated {
+				return nil
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"math"
+	"strings"
+)
+
+// blurHashX와 blurHashY는 BlurHash의 가로·세로 성분 수입니다. 4×3은 가로로 긴 사진에 흔히 쓰는 값입니다.
+const (
+	blurHashX = 4
+	blurHashY = 3
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurHash는 8비트 sRGB RGB 픽셀 버퍼(width×height×3)의 BlurHash 문자열을 만듭니다. (https://blurha.sh)
+// 픽셀 수에 비례해 계산하므로 32px 정도로 줄인 이미지를 넘깁니다.
+func blurHash(pixels []byte, width, height, xComponents, yComponents int) string {
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := range yComponents {
+		for i := range xComponents {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var f [3]float64
+			for y := range height {
+				for x := range width {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					p := (y*width + x) * 3
+					for c := range 3 {
+						f[c] += basis * srgbToLinear(pixels[p+c])
+					}
+				}
+			}
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(encodeBase83((xComponents-1)+(yComponents-1)*9, 1))
+	maximum := 1.0
+	if ac := factors[1:]; len(ac) > 0 {
+		actual := 0.0
+		for _, f := range ac {
+			actual = math.Max(actual, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		b.WriteString(encodeBase83(quantised, 1))
+	} else {
+		b.WriteString(encodeBase83(0, 1))
+	}
+	dc := factors[0]
+	b.WriteString(encodeBase83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range factors[1:] {
+		q := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+		}
+		b.WriteString(encodeBase83(q(f[0])*19*19+q(f[1])*19+q(f[2]), 2))
+	}
+	return b.String()
+}
+
+func encodeBase83(value, length int) string {
+	out := make([]byte, length)
+	for i := range length {
+		digit := value
+		for range length - i - 1 {
+			digit /= 83
+		}
+		out[i] = base83Chars[digit%83]
+	}
+	return string(out)
+}
+
+func srgbToLinear(c byte) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
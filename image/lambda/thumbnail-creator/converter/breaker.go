@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// EncoderCircuitOpen은 인코더가 연달아 실패해 회로 차단기가 열려 있음을 나타냅니다.
+// 배포한 라이브러리 레이어가 잘못된 경우처럼 모든 요청이 실패할 때 이벤트마다 인코딩을 시도하지 않고 바로 끝냅니다.
+type EncoderCircuitOpen struct {
+	Format   string
+	Failures int
+	Until    time.Time
+}
+
+func (e *EncoderCircuitOpen) Error() string {
+	return fmt.Sprintf("ENCODER_CIRCUIT_OPEN: %s encoder failed %d times in a row, failing fast until %s", e.Format, e.Failures, e.Until.Format(time.RFC3339))
+}
+
+// encoderBreaker는 포맷별 회로 차단기입니다. 연속 실패가 threshold에 이르면 cooldown 동안 열리고,
+// 그 뒤 한 요청만 시험 삼아 통과시켜(half-open) 성공하면 닫고 실패하면 다시 엽니다.
+// 실행 환경마다 따로 셉니다.
+type encoderBreaker struct {
+	clock     Clock
+	threshold int
+	cooldown  time.Duration
+	namespace string
+
+	mu     sync.Mutex
+	states map[string]*breakerState
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// newEncoderBreaker는 threshold가 0이면 nil을 돌려줍니다. nil 차단기는 항상 통과시킵니다.
+func newEncoderBreaker(clock Clock, threshold int, cooldown time.Duration, namespace string) *encoderBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &encoderBreaker{clock: clock, threshold: threshold, cooldown: cooldown, namespace: namespace, states: map[string]*breakerState{}}
+}
+
+// Allow는 format 인코더를 호출해도 되는지 확인합니다. 열려 있으면 EncoderCircuitOpen을 돌려줍니다.
+func (b *encoderBreaker) Allow(format string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.state(format)
+	if s.failures < b.threshold {
+		return nil
+	}
+	if b.clock.Now().Before(s.openUntil) || s.probing {
+		return &EncoderCircuitOpen{Format: format, Failures: s.failures, Until: s.openUntil}
+	}
+	s.probing = true
+	log.Printf("Encoder circuit for %s is half-open, trying one request", format)
+	return nil
+}
+
+// Record는 인코딩 결과를 기록합니다. 연속 실패가 threshold에 이르면 회로를 열고 알람 메트릭을 남깁니다.
+// 크기 맞추기처럼 요청 하나가 인코더를 여러 번 부르므로, repeat는 같은 요청에서 이미 실패를 기록했으면 true이며
+// 이때는 연속 실패 수를 늘리지 않습니다. 시험 삼아 통과시킨 요청이 실패하면 repeat와 관계없이 다시 엽니다.
+func (b *encoderBreaker) Record(format string, err error, repeat bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.state(format)
+	probing := s.probing
+	s.probing = false
+	if err == nil {
+		if s.failures >= b.threshold {
+			log.Printf("Encoder circuit for %s closed", format)
+		}
+		s.failures = 0
+		return
+	}
+	if !repeat {
+		s.failures++
+	} else if !probing {
+		return
+	}
+	if s.failures >= b.threshold {
+		s.openUntil = b.clock.Now().Add(b.cooldown)
+		log.Printf("Error: encoder circuit for %s opened after %d consecutive failures: %v", format, s.failures, err)
+		emitMetrics(b.namespace, "Count", map[string]float64{"EncoderCircuitOpen": 1})
+	}
+}
+
+func (b *encoderBreaker) state(format string) *breakerState {
+	s, ok := b.states[format]
+	if !ok {
+		s = &breakerState{}
+		b.states[format] = s
+	}
+	return s
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errEncode = errors.New("heifsave: encoder not found")
+
+func TestEncoderBreakerTransitions(t *testing.T) {
+	clock := newManualClock()
+	b := newEncoderBreaker(clock, 2, time.Minute, "test")
+
+	// 닫힌 상태: threshold 미만의 실패는 통과시킵니다.
+	b.Record("avif", errEncode, false)
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after 1 failure: %v", err)
+	}
+	// 열림: 연속 실패가 threshold에 이르면 cooldown 동안 막습니다.
+	b.Record("avif", errEncode, false)
+	var open *EncoderCircuitOpen
+	if err := b.Allow("avif"); !errors.As(err, &open) {
+		t.Fatalf("Allow after 2 failures = %v, want EncoderCircuitOpen", err)
+	}
+	if want := clock.Now().Add(time.Minute); !open.Until.Equal(want) || open.Failures != 2 {
+		t.Errorf("open = %+v, want 2 failures until %s", open, want)
+	}
+	if err := b.Allow("webp"); err != nil {
+		t.Errorf("Allow(webp) = %v, want other formats unaffected", err)
+	}
+	clock.Advance(59 * time.Second)
+	if err := b.Allow("avif"); err == nil {
+		t.Fatal("Allow before cooldown = nil, want EncoderCircuitOpen")
+	}
+
+	// 반열림: cooldown이 지나면 한 요청만 통과시키고, 그 요청이 실패하면 다시 엽니다.
+	clock.Advance(time.Second)
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after cooldown: %v", err)
+	}
+	if err := b.Allow("avif"); err == nil {
+		t.Fatal("second Allow while probing = nil, want EncoderCircuitOpen")
+	}
+	b.Record("avif", errEncode, false)
+	if err := b.Allow("avif"); !errors.As(err, &open) || !open.Until.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("Allow after failed probe = %v, want reopened for cooldown", err)
+	}
+
+	// 닫힘: 시험 요청이 성공하면 닫고 실패 수를 지웁니다.
+	clock.Advance(time.Minute)
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after second cooldown: %v", err)
+	}
+	b.Record("avif", nil, false)
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after successful probe: %v", err)
+	}
+	b.Record("avif", errEncode, false)
+	if err := b.Allow("avif"); err != nil {
+		t.Errorf("Allow after 1 failure since closing = %v, want closed", err)
+	}
+}
+
+func TestEncoderBreakerCountsOneFailurePerEvent(t *testing.T) {
+	clock := newManualClock()
+	b := newEncoderBreaker(clock, 2, time.Minute, "test")
+	job := &Job{}
+
+	// 크기 맞추기로 한 요청이 여러 번 실패해도 한 번만 셉니다.
+	for range 5 {
+		b.Record("avif", errEncode, !job.firstEncoderFailure("avif"))
+	}
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after one failed event = %v, want closed", err)
+	}
+	// 다른 요청의 성공은 실패 수를 지웁니다.
+	b.Record("avif", nil, false)
+	b.Record("avif", errEncode, !(&Job{}).firstEncoderFailure("avif"))
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after success and one failure = %v, want closed", err)
+	}
+	b.Record("avif", errEncode, !(&Job{}).firstEncoderFailure("avif"))
+	if err := b.Allow("avif"); err == nil {
+		t.Fatal("Allow after two failed events = nil, want EncoderCircuitOpen")
+	}
+
+	// 같은 요청이 반열림 시험에서 다시 실패하면 세지 않더라도 다시 엽니다.
+	clock.Advance(time.Minute)
+	if err := b.Allow("avif"); err != nil {
+		t.Fatalf("Allow after cooldown: %v", err)
+	}
+	b.Record("avif", errEncode, true)
+	if err := b.Allow("avif"); err == nil {
+		t.Fatal("Allow after repeated probe failure = nil, want EncoderCircuitOpen")
+	}
+}
+
+func TestEncoderBreakerDisabled(t *testing.T) {
+	b := newEncoderBreaker(newManualClock(), 0, time.Minute, "test")
+	for range 10 {
+		b.Record("avif", errEncode, false)
+	}
+	if err := b.Allow("avif"); err != nil {
+		t.Errorf("nil breaker Allow = %v, want nil", err)
+	}
+}
@@ -0,0 +1,288 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// CampaignRequest는 "mode": "campaign" 요청의 설정입니다. 설정 객체와 캠페인 상태 객체는 모두 s3Bucket에 있습니다.
+// EventBridge 예약 규칙으로 같은 요청을 주기적으로 보내면, 완료 표시가 생길 때까지 이어서 처리합니다.
+type CampaignRequest struct {
+	ConfigKey string `json:"configKey"`
+}
+
+// CampaignConfig는 재인코딩 캠페인 설정 객체의 내용입니다.
+// 출력이 이미 있는 원본만 골라 새 품질·포맷 설정으로 다시 변환합니다.
+type CampaignConfig struct {
+	// Name은 캠페인 이름이며 상태 객체 경로(.campaigns/<name>/)에 씁니다. 설정을 바꿔 다시 하려면 이름을 바꿉니다.
+	Name string `json:"name"`
+	// SourcePrefix 아래 원본 중 Include/Exclude(SKIP_RULES와 같은 형식)에 맞는 것이 대상입니다.
+	SourcePrefix string    `json:"sourcePrefix,omitempty"`
+	Include      []KeyRule `json:"include,omitempty"`
+	Exclude      []KeyRule `json:"exclude,omitempty"`
+	// OutputPrefix는 기존 출력이 있는 접두사입니다. (테넌트의 outputPrefix) 비어 있으면 원본 옆에서 찾습니다.
+	OutputPrefix string `json:"outputPrefix,omitempty"`
+	// Format, Quality, Effort, Preset은 재인코딩 설정입니다. 비어 있는 값은 현재 설정을 따릅니다.
+	Format  string `json:"format,omitempty"`
+	Quality int    `json:"quality,omitempty"`
+	Effort  *int   `json:"effort,omitempty"`
+	Preset  string `json:"preset,omitempty"`
+	// Until(RFC3339)이 지나면 남은 원본을 처리하지 않고 CAMPAIGN_EXPIRED로 끝냅니다.
+	Until string `json:"until,omitempty"`
+	// MarkerKey는 완료 표시 객체 키입니다. 기본 .campaigns/<name>/done.json
+	MarkerKey string `json:"markerKey,omitempty"`
+	// CheckpointEvery는 진행 상황을 기록하는 간격입니다. 비어 있으면 BACKFILL_CHECKPOINT_EVERY입니다.
+	CheckpointEvery int `json:"checkpointEvery,omitempty"`
+}
+
+// CampaignProgress는 캠페인 진행 상황입니다. 결과의 campaign과 완료 표시 객체에 들어갑니다.
+type CampaignProgress struct {
+	Name          string         `json:"name"`
+	ConfigKey     string         `json:"configKey"`
+	ManifestKey   string         `json:"manifestKey"`
+	CheckpointKey string         `json:"checkpointKey"`
+	MarkerKey     string         `json:"markerKey"`
+	Total         int            `json:"total"`
+	Processed     int            `json:"processed"`
+	Counts        map[Status]int `json:"counts,omitempty"`
+	Done          bool           `json:"done"`
+	Expired       bool           `json:"expired,omitempty"`
+	CompletedAt   string         `json:"completedAt,omitempty"`
+}
+
+// Campaign은 설정 객체의 재인코딩 캠페인을 시간이 허락하는 만큼 진행합니다.
+// 처음 호출에서 대상 원본 목록(manifest.txt)을 만들고, 처리는 backfill과 같은 체크포인트(checkpoint.json)로 이어 갑니다.
+// 모두 처리하면 완료 표시 객체를 쓰고, 그 뒤의 호출은 아무것도 하지 않고 CAMPAIGN_COMPLETED를 돌려줍니다.
+func (h *Handler) Campaign(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if event.S3Bucket == "" || event.Campaign == nil || event.Campaign.ConfigKey == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: campaign requires s3Bucket and campaign.configKey")
+	}
+	bucket, configKey := event.S3Bucket, event.Campaign.ConfigKey
+	conf, err := h.loadCampaign(ctx, bucket, configKey)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	base := ".campaigns/" + conf.Name + "/"
+	progress := CampaignProgress{
+		Name:          conf.Name,
+		ConfigKey:     configKey,
+		ManifestKey:   base + "manifest.txt",
+		CheckpointKey: base + "checkpoint.json",
+		MarkerKey:     conf.MarkerKey,
+	}
+	if progress.MarkerKey == "" {
+		progress.MarkerKey = base + "done.json"
+	}
+
+	// 완료 표시가 있으면 끝난 캠페인입니다.
+	marker, err := h.downloadObject(ctx, bucket, progress.MarkerKey)
+	var missing *types.NoSuchKey
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(marker, &progress); err != nil {
+			return ConversionResult{}, fmt.Errorf("invalid campaign marker %s: %w", progress.MarkerKey, err)
+		}
+		return campaignResult(StatusCampaignCompleted, progress), nil
+	case !errors.As(err, &missing):
+		return ConversionResult{}, fmt.Errorf("failed to read campaign marker %s: %w", progress.MarkerKey, err)
+	}
+
+	if conf.Until != "" {
+		until, _ := time.Parse(time.RFC3339, conf.Until)
+		if !h.clock.Now().Before(until) {
+			progress.Expired = true
+			return campaignResult(StatusCampaignExpired, progress), nil
+		}
+		// 마감 시각이 이 호출 안에 오면 그때까지만 처리합니다.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, until)
+		defer cancel()
+	}
+
+	backfill := BackfillRequest{ResumeFrom: progress.CheckpointKey}
+	_, err = h.downloadObject(ctx, bucket, progress.CheckpointKey)
+	switch {
+	case errors.As(err, &missing):
+		if progress.Total, err = h.writeCampaignManifest(ctx, bucket, conf, progress.ManifestKey); err != nil {
+			return ConversionResult{}, err
+		}
+		backfill = BackfillRequest{
+			ManifestKey:     progress.ManifestKey,
+			CheckpointKey:   progress.CheckpointKey,
+			CheckpointEvery: conf.CheckpointEvery,
+			Preset:          conf.Preset,
+			Effort:          conf.Effort,
+			Pipeline:        conf.steps(),
+		}
+	case err != nil:
+		return ConversionResult{}, fmt.Errorf("failed to read campaign checkpoint %s: %w", progress.CheckpointKey, err)
+	}
+
+	result, err := h.Backfill(ctx, S3Event{S3Bucket: bucket, Backfill: &backfill})
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	cp := result.Backfill
+	progress.Total, progress.Processed, progress.Counts, progress.Done = cp.End, cp.NextIndex, cp.Counts, cp.Done
+	if !progress.Done {
+		return campaignResult(StatusCampaignInProgress, progress), nil
+	}
+	progress.CompletedAt = h.clock.Now().UTC().Format(time.RFC3339)
+	body, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode campaign marker: %w", err)
+	}
+	if err := h.putObject(ctx, bucket, progress.MarkerKey, "application/json", body); err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to write campaign marker %s: %w", progress.MarkerKey, err)
+	}
+	return campaignResult(StatusCampaignCompleted, progress), nil
+}
+
+func campaignResult(status Status, p CampaignProgress) ConversionResult {
+	msg := fmt.Sprintf("Campaign %s: %d of %d sources processed (%d failed)", p.Name, p.Processed, p.Total, p.Counts[StatusFailed])
+	switch status {
+	case StatusCampaignCompleted:
+		msg += ", completed"
+	case StatusCampaignExpired:
+		msg = fmt.Sprintf("Campaign %s has passed its deadline, see %s for progress", p.Name, p.CheckpointKey)
+	}
+	log.Println(msg)
+	return ConversionResult{Status: status, Message: msg, Campaign: &p}
+}
+
+// loadCampaign은 캠페인 설정 객체를 읽고 검증합니다.
+func (h *Handler) loadCampaign(ctx context.Context, bucket, key string) (CampaignConfig, error) {
+	var conf CampaignConfig
+	data, err := h.downloadObject(ctx, bucket, key)
+	if err != nil {
+		return conf, fmt.Errorf("failed to read campaign config %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("invalid campaign config %s: %w", key, err)
+	}
+	if conf.Name == "" || strings.Contains(conf.Name, "/") {
+		return conf, fmt.Errorf("invalid campaign config %s: name is required and must not contain /", key)
+	}
+	if conf.Format == "" && conf.Quality == 0 && conf.Effort == nil && conf.Preset == "" {
+		return conf, fmt.Errorf("invalid campaign config %s: set at least one of format, quality, effort and preset", key)
+	}
+	if conf.Format != "" {
+		if _, err := h.encoders.Get(conf.Format); err != nil {
+			return conf, fmt.Errorf("invalid campaign config %s: %w", key, err)
+		}
+	}
+	if conf.Quality < 0 || conf.Quality > 100 {
+		return conf, fmt.Errorf("invalid campaign config %s: quality must be between 0 and 100", key)
+	}
+	if conf.Until != "" {
+		if _, err := time.Parse(time.RFC3339, conf.Until); err != nil {
+			return conf, fmt.Errorf("invalid campaign config %s: until must be RFC3339: %w", key, err)
+		}
+	}
+	for name, list := range map[string][]KeyRule{"include": conf.Include, "exclude": conf.Exclude} {
+		for i := range list {
+			if err := list[i].compile(); err != nil {
+				return conf, fmt.Errorf("invalid campaign config %s: %s rule %d: %w", key, name, i, err)
+			}
+		}
+	}
+	return conf, nil
+}
+
+// steps는 format·quality 설정을 파이프라인의 format 단계로 바꿉니다.
+func (c CampaignConfig) steps() []pipeline.Step {
+	if c.Format == "" && c.Quality == 0 {
+		return nil
+	}
+	format := c.Format
+	if format == "" {
+		format = "avif"
+	}
+	params, _ := json.Marshal(map[string]any{"op": "format", "format": format, "quality": c.Quality})
+	return []pipeline.Step{{Op: "format", Params: params}}
+}
+
+// selects는 원본 키가 캠페인의 포함·제외 규칙에 맞는지 확인합니다.
+func (c CampaignConfig) selects(bucket, key string) bool {
+	for _, r := range c.Exclude {
+		if r.match(bucket, key) {
+			return false
+		}
+	}
+	if len(c.Include) == 0 {
+		return true
+	}
+	for _, r := range c.Include {
+		if r.match(bucket, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCampaignManifest는 기존 출력이 있는 대상 원본의 목록을 만들어 manifestKey에 올리고 그 개수를 돌려줍니다.
+// 출력은 <OutputPrefix><원본 키에서 확장자를 바꾸거나 접미사를 붙인 것>이므로, 출력 키의 기준 경로에서
+// 접미사를 하나씩 뗀 경로마다 객체 수를 세어 두고 원본 키의 기준 경로가 그중에 있는지 봅니다. (GC와 같은 대응)
+// OutputPrefix가 비어 있으면 출력이 원본 옆에 있어 목록 하나에 섞이므로, 원본 자신을 빼고 다른 객체가 있어야 하며
+// 캠페인 출력 포맷의 확장자인 객체(이전 출력)는 원본으로 보지 않습니다.
+func (h *Handler) writeCampaignManifest(ctx context.Context, bucket string, c CampaignConfig, manifestKey string) (int, error) {
+	outputs := map[string]int{}
+	err := h.listObjects(ctx, h.outputClient(bucket), bucket, c.OutputPrefix, func(obj types.Object) {
+		stem := replaceExtension(strings.TrimPrefix(aws.ToString(obj.Key), c.OutputPrefix), "")
+		dir := strings.LastIndex(stem, "/")
+		for {
+			outputs[stem]++
+			i := strings.LastIndexAny(stem, "._")
+			if i <= dir {
+				break
+			}
+			stem = stem[:i]
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	inPlace := c.OutputPrefix == ""
+	format := c.Format
+	if format == "" {
+		format = "avif"
+	}
+	var keys []string
+	err = h.listObjects(ctx, h.s3, bucket, c.SourcePrefix, func(obj types.Object) {
+		key := aws.ToString(obj.Key)
+		if strings.HasPrefix(key, ".campaigns/") || !c.selects(bucket, key) {
+			return
+		}
+		existing := outputs[h.outputKey(replaceExtension(key, ""))]
+		if inPlace {
+			if strings.EqualFold(keyExtension(key), extensionOf(format)) {
+				return
+			}
+			existing--
+		} else if strings.HasPrefix(key, c.OutputPrefix) {
+			return
+		}
+		if existing > 0 {
+			keys = append(keys, key)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	body := strings.Join(keys, "\n")
+	if err := h.putObject(ctx, bucket, manifestKey, "text/plain", []byte(body)); err != nil {
+		return 0, fmt.Errorf("failed to write campaign manifest %s: %w", manifestKey, err)
+	}
+	log.Printf("Campaign %s: %d sources with existing outputs under %q", c.Name, len(keys), c.SourcePrefix)
+	return len(keys), nil
+}
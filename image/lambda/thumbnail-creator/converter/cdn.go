@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// CloudFrontAPI는 덮어쓴 출력의 캐시 무효화에 사용하는 CloudFront 호출입니다.
+type CloudFrontAPI interface {
+	CreateInvalidation(ctx context.Context, params *cloudfront.CreateInvalidationInput, optFns ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error)
+}
+
+// UseCDNInvalidation은 덮어쓴 출력 키의 CloudFront 캐시를 무효화하는 미들웨어를 등록합니다.
+func (h *Handler) UseCDNInvalidation(cf CloudFrontAPI) {
+	h.hooks.Use(&cdnInvalidator{
+		s3:           h.outputClient,
+		cf:           cf,
+		clock:        h.clock,
+		distribution: h.conf.CloudFrontDistributionID,
+		pathTemplate: h.conf.CloudFrontPathTemplate,
+		namespace:    h.conf.MetricsNamespace,
+	})
+}
+
+// cdnInvalidator는 재처리로 이미 있던 출력 객체를 덮어쓰면 해당 경로의 CloudFront 캐시를 무효화합니다.
+// 업로드 전에 HeadObject로 덮어쓰기인지 확인하고, 변환이 끝나면 CreateInvalidation 한 번으로 모아 보냅니다.
+// 변환이 중간에 실패하면 무효화하지 않지만, 재시도에서 같은 키를 다시 덮어쓰므로 그때 무효화됩니다.
+type cdnInvalidator struct {
+	s3           func(bucket string) S3API
+	cf           CloudFrontAPI
+	clock        Clock
+	distribution string
+	pathTemplate string
+	namespace    string
+}
+
+func (i *cdnInvalidator) PreUpload(ctx context.Context, job *Job, upload *Upload) error {
+	_, err := i.s3(job.OutputBucket).HeadObject(ctx, &s3.HeadObjectInput{Bucket: &job.OutputBucket, Key: &upload.Key})
+	var notFound *types.NotFound
+	switch {
+	case err == nil:
+		upload.Overwrite = true
+	case errors.As(err, &notFound):
+	default:
+		// 확인할 수 없으면 오래된 캐시가 남지 않도록 덮어쓰기로 봅니다.
+		log.Printf("Warning: failed to check whether %s exists, assuming overwrite: %v", upload.Key, err)
+		upload.Overwrite = true
+	}
+	return nil
+}
+
+func (i *cdnInvalidator) PostConvert(ctx context.Context, job *Job) error {
+	var paths []string
+	for _, output := range job.Result.Outputs {
+		if output.Overwritten {
+			paths = append(paths, cdnPath(i.pathTemplate, job.OutputBucket, output.Key))
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	_, err := i.cf.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: &i.distribution,
+		InvalidationBatch: &cftypes.InvalidationBatch{
+			CallerReference: aws.String(fmt.Sprintf("%s-%d", job.SrcKey, i.clock.Now().UnixNano())),
+			Paths:           &cftypes.Paths{Items: paths, Quantity: aws.Int32(int32(len(paths)))},
+		},
+	})
+	if err != nil {
+		// 출력은 이미 올라갔으므로 변환을 실패시키지 않고 경고와 지표만 남깁니다.
+		log.Printf("Warning: failed to invalidate CloudFront paths %v: %v", paths, err)
+		emitMetrics(i.namespace, "Count", map[string]float64{"CloudFrontInvalidationFailed": 1})
+		return nil
+	}
+	log.Printf("CloudFront invalidation created for %d paths: %v", len(paths), paths)
+	return nil
+}
+
+// cdnPath는 CLOUDFRONT_PATH_TEMPLATE의 {key}, {bucket}을 채우고 경로를 URL 인코딩합니다.
+func cdnPath(template, bucket, key string) string {
+	p := strings.NewReplacer("{bucket}", bucket, "{key}", key).Replace(template)
+	return (&url.URL{Path: p}).EscapedPath()
+}
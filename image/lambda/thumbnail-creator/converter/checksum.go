@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// crc64NVME은 S3 CRC64NVME 체크섬의 표입니다. (CRC-64/NVME, 반사 다항식)
+var crc64NVME = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// UploadChecksum은 출력 업로드에 붙이는 체크섬 설정입니다.
+// Type이 FULL_OBJECT이면 멀티파트 업로드도 객체 전체의 체크섬 하나로 저장되며 CRC 계열만 쓸 수 있습니다.
+// COMPOSITE이면 멀티파트 업로드의 체크섬은 파트 체크섬의 체크섬(<값>-<파트 수>)이 됩니다. CRC64NVME는 FULL_OBJECT만 됩니다.
+type UploadChecksum struct {
+	Algorithm string `json:"algorithm"` // CRC32 | CRC32C | CRC64NVME | SHA1 | SHA256
+	Type      string `json:"type,omitempty"`
+}
+
+// ObjectChecksum은 결과의 출력에 넣는, S3에 저장된 체크섬입니다. Value는 base64 값입니다.
+type ObjectChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+}
+
+// parseUploadChecksums는 UPLOAD_CHECKSUMS(JSON, 버킷 이름별 UploadChecksum)를 읽습니다.
+func parseUploadChecksums(data []byte) (map[string]UploadChecksum, error) {
+	var checksums map[string]UploadChecksum
+	if err := json.Unmarshal(data, &checksums); err != nil {
+		return nil, err
+	}
+	for bucket, c := range checksums {
+		if err := c.withDefaults(); err != nil {
+			return nil, fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+		checksums[bucket] = c
+	}
+	return checksums, nil
+}
+
+// withDefaults는 Type 기본값(CRC64NVME는 FULL_OBJECT, 그 밖은 COMPOSITE)을 채우고 조합을 검증합니다.
+func (c *UploadChecksum) withDefaults() error {
+	algorithm := types.ChecksumAlgorithm(c.Algorithm)
+	if !slices.Contains(algorithm.Values(), algorithm) {
+		return fmt.Errorf("unknown checksum algorithm %q", c.Algorithm)
+	}
+	full := c.Algorithm == string(types.ChecksumAlgorithmCrc32) || c.Algorithm == string(types.ChecksumAlgorithmCrc32c) ||
+		c.Algorithm == string(types.ChecksumAlgorithmCrc64nvme)
+	if c.Type == "" {
+		c.Type = string(types.ChecksumTypeComposite)
+		if algorithm == types.ChecksumAlgorithmCrc64nvme {
+			c.Type = string(types.ChecksumTypeFullObject)
+		}
+	}
+	switch types.ChecksumType(c.Type) {
+	case types.ChecksumTypeFullObject:
+		if !full {
+			return fmt.Errorf("checksum type FULL_OBJECT requires CRC32, CRC32C or CRC64NVME, not %s", c.Algorithm)
+		}
+	case types.ChecksumTypeComposite:
+		if algorithm == types.ChecksumAlgorithmCrc64nvme {
+			return fmt.Errorf("checksum algorithm CRC64NVME supports FULL_OBJECT only")
+		}
+	default:
+		return fmt.Errorf("unknown checksum type %q: must be FULL_OBJECT or COMPOSITE", c.Type)
+	}
+	return nil
+}
+
+// uploadChecksum은 버킷에 적용할 체크섬 설정입니다. UPLOAD_CHECKSUMS에 없으면 UPLOAD_CHECKSUM_ALGORITHM입니다.
+func (h *Handler) uploadChecksum(bucket string) UploadChecksum {
+	if c, ok := h.conf.UploadChecksums[bucket]; ok {
+		return c
+	}
+	return h.conf.UploadChecksum
+}
+
+// sum은 data의 체크섬(base64)입니다. S3에 미리 계산한 값을 보내므로 SDK가 본문을 다시 읽지 않고, S3가 저장 전에 검증합니다.
+func (c UploadChecksum) sum(data []byte) string {
+	var digest hash.Hash
+	switch types.ChecksumAlgorithm(c.Algorithm) {
+	case types.ChecksumAlgorithmCrc32:
+		digest = crc32.NewIEEE()
+	case types.ChecksumAlgorithmCrc32c:
+		digest = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case types.ChecksumAlgorithmCrc64nvme:
+		digest = crc64.New(crc64NVME)
+	case types.ChecksumAlgorithmSha1:
+		digest = sha1.New()
+	default:
+		digest = sha256.New()
+	}
+	digest.Write(data)
+	return base64.StdEncoding.EncodeToString(digest.Sum(nil))
+}
+
+// fields는 value를 알고리즘에 맞는 요청 필드 하나에 넣은 값입니다.
+// 순서는 SDK 입력 구조체와 같은 CRC32, CRC32C, CRC64NVME, SHA1, SHA256입니다.
+func (c UploadChecksum) fields(value string) (crc32, crc32c, crc64nvme, sha1, sha256 *string) {
+	v := aws.String(value)
+	switch types.ChecksumAlgorithm(c.Algorithm) {
+	case types.ChecksumAlgorithmCrc32:
+		return v, nil, nil, nil, nil
+	case types.ChecksumAlgorithmCrc32c:
+		return nil, v, nil, nil, nil
+	case types.ChecksumAlgorithmCrc64nvme:
+		return nil, nil, v, nil, nil
+	case types.ChecksumAlgorithmSha1:
+		return nil, nil, nil, v, nil
+	default:
+		return nil, nil, nil, nil, v
+	}
+}
+
+// pick은 응답의 체크섬 필드에서 알고리즘에 맞는 값을 고릅니다.
+func (c UploadChecksum) pick(crc32, crc32c, crc64nvme, sha1, sha256 *string) string {
+	switch types.ChecksumAlgorithm(c.Algorithm) {
+	case types.ChecksumAlgorithmCrc32:
+		return aws.ToString(crc32)
+	case types.ChecksumAlgorithmCrc32c:
+		return aws.ToString(crc32c)
+	case types.ChecksumAlgorithmCrc64nvme:
+		return aws.ToString(crc64nvme)
+	case types.ChecksumAlgorithmSha1:
+		return aws.ToString(sha1)
+	default:
+		return aws.ToString(sha256)
+	}
+}
@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// colorInfo는 입력 이미지의 전달 특성(transfer)과 색역(gamut) 정보입니다.
+type colorInfo struct {
+	Transfer string // "sdr" | "pq" | "hlg"
+	Gamut    string // "srgb" | "display-p3" | "rec2020" | "adobe-rgb" | "other"
+	HighBit  bool   // 8비트를 넘는 샘플 깊이인지 여부
+}
+
+func (i colorInfo) IsHDR() bool {
+	return i.Transfer == "pq" || i.Transfer == "hlg"
+}
+
+// colorPlan은 색 공간 정책을 적용한 뒤 인코더에 넘길 값입니다.
+type colorPlan struct {
+	Action   string // 결과에 기록할 처리 내용, sRGB 입력이면 빈 문자열
+	KeepICC  bool
+	Bitdepth int
+}
+
+// inspectColor는 내장 ICC 프로파일의 cicp/desc 태그와 샘플 깊이로 HDR·광색역 여부를 판별합니다.
+func inspectColor(image *vips.Image) colorInfo {
+	info := colorInfo{Transfer: "sdr", Gamut: "srgb"}
+	switch image.BandFormat() {
+	case vips.BandFormatUshort, vips.BandFormatFloat:
+		info.HighBit = true
+	}
+	if depth, err := image.GetInt("heif-bitdepth"); err == nil && depth > 8 {
+		info.HighBit = true
+	}
+
+	profile, ok := image.GetICCProfile()
+	if !ok || len(profile) == 0 {
+		return info
+	}
+
+	// ICC v4.4 cicp 태그가 있으면 ITU-T H.273 코드 포인트를 그대로 신뢰합니다.
+	if primaries, transfer, found := iccCICP(profile); found {
+		switch transfer {
+		case 16:
+			info.Transfer = "pq"
+		case 18:
+			info.Transfer = "hlg"
+		}
+		switch primaries {
+		case 1:
+			info.Gamut = "srgb"
+		case 9:
+			info.Gamut = "rec2020"
+		case 11, 12:
+			info.Gamut = "display-p3"
+		default:
+			info.Gamut = "other"
+		}
+		return info
+	}
+
+	desc := strings.ToLower(iccDescription(profile))
+	switch {
+	case strings.Contains(desc, "pq") && strings.Contains(desc, "2100"):
+		info.Transfer = "pq"
+	case strings.Contains(desc, "hlg"):
+		info.Transfer = "hlg"
+	}
+	switch {
+	case desc == "" || strings.Contains(desc, "srgb"):
+		info.Gamut = "srgb"
+	case strings.Contains(desc, "p3"):
+		info.Gamut = "display-p3"
+	case strings.Contains(desc, "2020") || strings.Contains(desc, "2100"):
+		info.Gamut = "rec2020"
+	case strings.Contains(desc, "adobe rgb"):
+		info.Gamut = "adobe-rgb"
+	default:
+		info.Gamut = "other"
+	}
+	return info
+}
+
+// applyColorPolicy는 HDR_POLICY / WIDE_GAMUT_POLICY에 따라 이미지를 변환하고 인코딩 설정을 정합니다.
+// sRGB로 변환하는 경우 ICC 프로파일 없이 저장해도 브라우저가 올바르게 해석합니다.
+func applyColorPolicy(image *vips.Image, info colorInfo, c Config) (colorPlan, error) {
+	plan := colorPlan{Bitdepth: 10}
+
+	switch {
+	case info.IsHDR() && c.HDRPolicy == "preserve":
+		plan.Action = "hdr-preserved-" + info.Transfer
+		plan.KeepICC = true
+		plan.Bitdepth = c.HDRBitdepth
+		return plan, nil
+	case info.IsHDR():
+		// 톤 매핑은 lcms의 perceptual intent로 SDR sRGB에 맞춰 압축합니다.
+		if err := toSRGB(image, vips.IntentPerceptual); err != nil {
+			return plan, fmt.Errorf("failed to tone-map %s HDR source to SDR: %w", info.Transfer, err)
+		}
+		plan.Action = "hdr-tonemapped-" + info.Transfer
+		return plan, nil
+	case info.Gamut == "srgb":
+		return plan, nil
+	case c.WideGamutPolicy == "preserve":
+		plan.Action = "wide-gamut-preserved-" + info.Gamut
+		plan.KeepICC = true
+		return plan, nil
+	default:
+		if err := toSRGB(image, vips.IntentRelative); err != nil {
+			return plan, fmt.Errorf("failed to convert %s source to sRGB: %w", info.Gamut, err)
+		}
+		plan.Action = "converted-to-srgb-" + info.Gamut
+		return plan, nil
+	}
+}
+
+func toSRGB(image *vips.Image, intent vips.Intent) error {
+	return image.IccTransform("srgb", &vips.IccTransformOptions{
+		Intent:                 intent,
+		BlackPointCompensation: true,
+		Embedded:               true,
+		Depth:                  8,
+	})
+}
+
+// iccTag는 ICC 프로파일 태그 테이블에서 sig에 해당하는 태그 데이터를 찾습니다.
+func iccTag(profile []byte, sig string) []byte {
+	if len(profile) < 132 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint32(profile[128:132]))
+	for i := 0; i < count; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(profile) {
+			return nil
+		}
+		if string(profile[entry:entry+4]) != sig {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(profile[entry+4 : entry+8]))
+		size := int(binary.BigEndian.Uint32(profile[entry+8 : entry+12]))
+		if offset < 0 || size < 0 || offset+size > len(profile) {
+			return nil
+		}
+		return profile[offset : offset+size]
+	}
+	return nil
+}
+
+// iccCICP는 cicp 태그의 색 원색(primaries)과 전달 특성(transfer) 코드를 읽습니다.
+func iccCICP(profile []byte) (primaries, transfer byte, ok bool) {
+	tag := iccTag(profile, "cicp")
+	if len(tag) < 12 || string(tag[0:4]) != "cicp" {
+		return 0, 0, false
+	}
+	return tag[8], tag[9], true
+}
+
+// iccDescription은 desc 태그(v2 textDescriptionType 또는 v4 mluc)의 설명 문자열을 읽습니다.
+func iccDescription(profile []byte) string {
+	tag := iccTag(profile, "desc")
+	if len(tag) < 12 {
+		return ""
+	}
+	switch string(tag[0:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if 12+n > len(tag) {
+			return ""
+		}
+		return string(bytes.TrimRight(tag[12:12+n], "\x00"))
+	case "mluc":
+		if len(tag) < 28 {
+			return ""
+		}
+		// 첫 번째 언어 레코드만 사용합니다.
+		size := int(binary.BigEndian.Uint32(tag[20:24]))
+		offset := int(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+size > len(tag) || size%2 != 0 {
+			return ""
+		}
+		units := make([]uint16, size/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[offset+i*2:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return ""
+}
@@ -0,0 +1,274 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+const (
+	// ssimWindow와 ssimStride는 SSIM을 계산하는 창 크기와 간격입니다.
+	ssimWindow = 8
+	ssimStride = 4
+	// changedThreshold는 ChangedPixelsPercent에서 바뀐 픽셀로 셀 최소 채널 차이(0~255)입니다.
+	changedThreshold = 2
+	// heatmapGain은 차이 히트맵에서 작은 차이도 보이도록 곱하는 배율입니다.
+	heatmapGain = 4
+)
+
+// CompareRequest는 "mode": "compare" 요청의 설정입니다. 두 키 모두 s3Bucket 기준입니다.
+type CompareRequest struct {
+	ReferenceKey string `json:"referenceKey"` // 기준 이미지 (예: 원본)
+	CandidateKey string `json:"candidateKey"` // 비교할 이미지 (예: 새 인코더 설정의 출력)
+	// HeatmapKey는 차이 히트맵 PNG 키입니다. 기본은 <candidateKey>.diff.png, "-"이면 올리지 않습니다.
+	HeatmapKey string `json:"heatmapKey,omitempty"`
+}
+
+// CompareReport는 두 이미지의 비교 결과입니다. 차이는 0~255 sRGB 채널 기준입니다.
+type CompareReport struct {
+	ReferenceKey string `json:"referenceKey"`
+	CandidateKey string `json:"candidateKey"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	// Resized는 크기가 달라 candidate를 reference 크기로 맞춰 비교했으면 true입니다.
+	Resized bool `json:"resized,omitempty"`
+
+	ReferenceSize    int64   `json:"referenceSize"`
+	CandidateSize    int64   `json:"candidateSize"`
+	SizeDelta        int64   `json:"sizeDelta"`
+	SizeDeltaPercent float64 `json:"sizeDeltaPercent"`
+
+	// SSIM은 휘도의 8×8 창(간격 4) 평균 SSIM입니다. 1이면 같습니다.
+	SSIM float64 `json:"ssim"`
+	// PSNR은 RGB 전체의 PSNR(dB)입니다. 같은 이미지면 +Inf 대신 0을 넣고 Identical을 true로 둡니다.
+	PSNR                 float64 `json:"psnr"`
+	Identical            bool    `json:"identical,omitempty"`
+	MeanAbsDiff          float64 `json:"meanAbsDiff"`
+	MaxDiff              int     `json:"maxDiff"`
+	ChangedPixelsPercent float64 `json:"changedPixelsPercent"`
+	HeatmapKey           string  `json:"heatmapKey,omitempty"`
+}
+
+// Compare는 두 이미지의 픽셀 차이, SSIM, 크기 차이를 계산하고 차이 히트맵을 올립니다.
+// 새 인코더 설정을 배포하기 전에 품질 변화를 확인하는 용도입니다.
+func (h *Handler) Compare(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if event.Compare == nil || event.Compare.ReferenceKey == "" || event.Compare.CandidateKey == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: compare requires referenceKey and candidateKey")
+	}
+	req := *event.Compare
+	report := CompareReport{ReferenceKey: req.ReferenceKey, CandidateKey: req.CandidateKey}
+
+	ref, refSize, err := h.loadComparable(ctx, event.S3Bucket, req.ReferenceKey)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	defer ref.Close()
+	cand, candSize, err := h.loadComparable(ctx, event.S3Bucket, req.CandidateKey)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	defer cand.Close()
+	if cand.Width() != ref.Width() || cand.Height() != ref.Height() {
+		if err := pipeline.Resize(cand, ref.Width(), ref.Height(), "fill", true, ""); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to resize candidate to reference size: %w", err)
+		}
+		report.Resized = true
+	}
+	report.Width, report.Height = ref.Width(), ref.Height()
+	report.ReferenceSize, report.CandidateSize = refSize, candSize
+	report.SizeDelta = candSize - refSize
+	if refSize > 0 {
+		report.SizeDeltaPercent = float64(report.SizeDelta) / float64(refSize) * 100
+	}
+
+	if err := measure(ref, cand, &report); err != nil {
+		return ConversionResult{}, err
+	}
+
+	var outputs []OutputResult
+	if req.HeatmapKey != "-" {
+		key := req.HeatmapKey
+		if key == "" {
+			key = replaceExtension(req.CandidateKey, ".diff.png")
+		}
+		heatmap, err := diffHeatmap(ref, cand)
+		if err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to render diff heatmap: %w", err)
+		}
+		if err := h.putObject(ctx, event.S3Bucket, key, "image/png", heatmap); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to upload diff heatmap %s: %w", key, err)
+		}
+		report.HeatmapKey = key
+		outputs = append(outputs, OutputResult{Key: key, Format: "png", Size: int64(len(heatmap)), Width: report.Width, Height: report.Height})
+	}
+
+	msg := fmt.Sprintf("Compared %s with %s: SSIM=%.4f, PSNR=%.2fdB, size delta=%+d bytes (%+.1f%%)",
+		req.CandidateKey, req.ReferenceKey, report.SSIM, report.PSNR, report.SizeDelta, report.SizeDeltaPercent)
+	log.Println(msg)
+	return ConversionResult{
+		Status:      StatusCompared,
+		OriginalKey: req.ReferenceKey,
+		NewKey:      report.HeatmapKey,
+		Outputs:     outputs,
+		Message:     msg,
+		Comparison:  &report,
+	}, nil
+}
+
+// loadComparable은 이미지를 읽어 EXIF 방향을 적용하고, 알파를 흰색에 합성한 8비트 sRGB로 맞춥니다.
+func (h *Handler) loadComparable(ctx context.Context, bucket, key string) (*vips.Image, int64, error) {
+	key, err := h.decodeKey(key, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := h.downloadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	image, err := comparableImage(data, key)
+	return image, int64(len(data)), err
+}
+
+// comparableImage는 비교할 수 있도록 디코딩한 8비트 sRGB 이미지입니다. name은 오류 메시지에 씁니다.
+func comparableImage(data []byte, name string) (*vips.Image, error) {
+	image, err := vips.NewImageFromBuffer(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	steps := []func() error{
+		image.Autorot,
+		func() error { return image.Colourspace(vips.InterpretationSrgb, nil) },
+		func() error {
+			if !image.HasAlpha() {
+				return nil
+			}
+			return image.Flatten(&vips.FlattenOptions{Background: []float64{255, 255, 255}})
+		},
+		func() error { return image.Cast(vips.BandFormatUchar, nil) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			image.Close()
+			return nil, fmt.Errorf("failed to prepare %s for comparison: %w", name, err)
+		}
+	}
+	return image, nil
+}
+
+// measure는 크기가 같은 8비트 sRGB 이미지 두 개의 픽셀 차이와 SSIM을 report에 채웁니다.
+func measure(ref, cand *vips.Image, report *CompareReport) error {
+	refPixels, err := ref.RawsaveBuffer(vips.DefaultRawsaveBufferOptions())
+	if err != nil {
+		return err
+	}
+	candPixels, err := cand.RawsaveBuffer(vips.DefaultRawsaveBufferOptions())
+	if err != nil {
+		return err
+	}
+	pixelDiff(refPixels, candPixels, report)
+	report.SSIM = ssim(luma(refPixels), luma(candPixels), ref.Width(), ref.Height())
+	return nil
+}
+
+// pixelDiff는 RGB 픽셀 버퍼 두 개의 평균·최대 차이, 바뀐 픽셀 비율, PSNR을 계산합니다.
+func pixelDiff(ref, cand []byte, report *CompareReport) {
+	var sumAbs, sumSq float64
+	changed := 0
+	for i := 0; i+2 < len(ref) && i+2 < len(cand); i += 3 {
+		pixelChanged := false
+		for c := range 3 {
+			d := int(ref[i+c]) - int(cand[i+c])
+			if d < 0 {
+				d = -d
+			}
+			sumAbs += float64(d)
+			sumSq += float64(d * d)
+			report.MaxDiff = max(report.MaxDiff, d)
+			pixelChanged = pixelChanged || d > changedThreshold
+		}
+		if pixelChanged {
+			changed++
+		}
+	}
+	samples := float64(min(len(ref), len(cand)))
+	if samples == 0 {
+		return
+	}
+	report.MeanAbsDiff = sumAbs / samples
+	report.ChangedPixelsPercent = float64(changed) / (samples / 3) * 100
+	if mse := sumSq / samples; mse > 0 {
+		report.PSNR = 10 * math.Log10(255*255/mse)
+	} else {
+		report.Identical = true
+	}
+}
+
+// luma는 RGB 버퍼를 Rec.601 휘도로 바꿉니다.
+func luma(rgb []byte) []float64 {
+	y := make([]float64, len(rgb)/3)
+	for i := range y {
+		y[i] = 0.299*float64(rgb[3*i]) + 0.587*float64(rgb[3*i+1]) + 0.114*float64(rgb[3*i+2])
+	}
+	return y
+}
+
+// ssim은 휘도 평면 두 개의 평균 SSIM입니다. 이미지가 창보다 작으면 전체를 창 하나로 계산합니다.
+func ssim(a, b []float64, width, height int) float64 {
+	const c1, c2 = (0.01 * 255) * (0.01 * 255), (0.03 * 255) * (0.03 * 255)
+	win := min(ssimWindow, width, height)
+	var total float64
+	windows := 0
+	for y0 := 0; y0+win <= height; y0 += ssimStride {
+		for x0 := 0; x0+win <= width; x0 += ssimStride {
+			var sa, sb, saa, sbb, sab float64
+			for y := y0; y < y0+win; y++ {
+				for x := x0; x < x0+win; x++ {
+					va, vb := a[y*width+x], b[y*width+x]
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+			n := float64(win * win)
+			ma, mb := sa/n, sb/n
+			va, vb := saa/n-ma*ma, sbb/n-mb*mb
+			cov := sab/n - ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+// diffHeatmap은 채널 차이의 평균을 heatmapGain배로 키워 false colour(파랑=같음, 빨강=다름) PNG로 만듭니다.
+func diffHeatmap(ref, cand *vips.Image) ([]byte, error) {
+	diff, err := ref.Copy(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer diff.Close()
+	steps := []func() error{
+		func() error { return diff.Subtract(cand) },
+		diff.Abs,
+		diff.Bandmean,
+		func() error { return diff.Linear([]float64{heatmapGain}, []float64{0}, nil) },
+		func() error { return diff.Cast(vips.BandFormatUchar, nil) },
+		diff.Falsecolour,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	return diff.PngsaveBuffer(vips.DefaultPngsaveBufferOptions())
+}
@@ -0,0 +1,965 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// Config는 환경 변수에서 읽어 오는 변환 설정입니다.
+// 콜드 스타트 시 한 번 읽으며, 잘못된 값이 있으면 init에서 실패합니다.
+// ssm:/secretsmanager: 참조로 둔 값이 바뀌면 SecretsRefresh마다 다시 읽습니다. (secrets.go 참고)
+type Config struct {
+	// GraphicsMode는 스크린샷, 로고, 라인 아트 같은 그래픽 입력의 무손실 전환 정책입니다.
+	// auto: 휴리스틱으로 판별, always: 항상 무손실, never: 항상 손실 (GRAPHICS_MODE, 기본 auto)
+	GraphicsMode string
+	// GraphicsFormat은 그래픽 입력에 사용할 무손실 출력 포맷입니다. avif | webp (GRAPHICS_FORMAT, 기본 avif)
+	GraphicsFormat string
+	// GraphicsMaxColors는 그래픽으로 판단하는 최대 색상 수입니다. (GRAPHICS_MAX_COLORS, 기본 256)
+	GraphicsMaxColors int
+	// GraphicsNearLossless가 true이면 완전 무손실 대신 near-lossless로 인코딩합니다. (GRAPHICS_NEAR_LOSSLESS)
+	GraphicsNearLossless bool
+	// GraphicsNearLosslessQuality는 near-lossless 인코딩 품질입니다. (GRAPHICS_NEAR_LOSSLESS_QUALITY, 기본 90)
+	GraphicsNearLosslessQuality int
+
+	// AlphaPolicy는 투명 입력의 알파 채널 처리 정책입니다.
+	// preserve: 알파를 지원하는 포맷이면 유지, flatten: 항상 배경색에 합성 (ALPHA_POLICY, 기본 preserve)
+	// 알파를 지원하지 않는 포맷(JPEG 등)은 정책과 관계없이 배경색에 합성합니다.
+	AlphaPolicy string
+	// AlphaBackground는 알파를 합성할 배경색(RGB 0~255)입니다. (ALPHA_BACKGROUND, 기본 #ffffff)
+	AlphaBackground []float64
+
+	// HDRPolicy는 PQ/HLG HDR 입력의 처리 정책입니다.
+	// preserve: 고비트 AVIF로 HDR 유지, tonemap: SDR sRGB로 톤 매핑 (HDR_POLICY, 기본 preserve)
+	HDRPolicy string
+	// HDRBitdepth는 HDR을 유지할 때의 AVIF 비트 깊이입니다. 10 | 12 (HDR_BITDEPTH, 기본 10)
+	HDRBitdepth int
+	// WideGamutPolicy는 Display-P3 등 sRGB보다 넓은 색역 입력의 처리 정책입니다.
+	// preserve: ICC 프로파일을 유지, srgb: sRGB로 변환 (WIDE_GAMUT_POLICY, 기본 preserve)
+	WideGamutPolicy string
+
+	// JXLOutput가 true이면 AVIF와 함께 실험적 .jxl 출력을 생성합니다. (JXL_OUTPUT)
+	JXLOutput bool
+	// JXLQuality는 손실 JXL 인코딩 품질입니다. (JXL_QUALITY, 기본 75)
+	JXLQuality int
+	// JXLEffort는 JXL 인코딩 노력 수준(1~9)입니다. (JXL_EFFORT, 기본 7)
+	JXLEffort int
+
+	// JPEGFallback이 true이면 AVIF/WebP 미지원 클라이언트용 progressive .jpg 출력을 함께 생성합니다. (JPEG_FALLBACK)
+	JPEGFallback bool
+	// JPEGQuality는 JPEG 대체 출력 품질입니다. (JPEG_QUALITY, 기본 80)
+	JPEGQuality int
+
+	// AVIFEncoder는 AV1 인코더 선택입니다. auto: 픽셀 수로 선택, svt | aom: 고정 (AVIF_ENCODER, 기본 auto)
+	AVIFEncoder string
+	// AOMMaxPixels 이하의 픽셀 수를 가진 이미지는 auto일 때 AOM으로 인코딩합니다. (AOM_MAX_PIXELS, 기본 1000000)
+	AOMMaxPixels int
+	// AVIFEffort는 AVIF 인코딩 노력 수준(0~9)의 기본값입니다. 이벤트의 effort/speed가 우선합니다.
+	// 0이 가장 빠르며 이전 동작과 같습니다. (AVIF_EFFORT, 기본 0)
+	AVIFEffort int
+	// VipsConcurrency는 libvips 작업 스레드 수입니다. 0이면 CPU 수를 따릅니다.
+	// Startup 시점에만 적용되므로 요청별로 바꿀 수 없습니다. (VIPS_CONCURRENCY, 기본 1)
+	VipsConcurrency int
+	// VipsMaxCacheSize는 libvips 연산 캐시에 둘 최대 연산 수입니다. 0이면 캐시를 끕니다. (VIPS_MAX_CACHE_SIZE, 기본 0)
+	// 요청마다 다른 이미지를 처리하므로 캐시 적중이 드물고, 작은 메모리 함수에서는 OOM 원인이 됩니다.
+	VipsMaxCacheSize int
+	// VipsMaxCacheMem은 연산 캐시가 쓸 수 있는 최대 메모리(바이트)입니다. (VIPS_MAX_CACHE_MEM_MB, 기본 0)
+	VipsMaxCacheMem int
+	// VipsMaxCacheFiles는 연산 캐시가 열어 둘 최대 파일 수입니다. (VIPS_MAX_CACHE_FILES, 기본 0)
+	VipsMaxCacheFiles int
+	// InitWarmupFormats의 인코더는 초기화 단계에서 작은 이미지를 한 번 인코딩해 첫 요청의 코덱 초기화 지연을 없앱니다.
+	// 쉼표로 구분한 포맷 이름이며, all이면 등록된 모든 인코더입니다. (INIT_WARMUP_FORMATS, 기본 없음 = 끔)
+	InitWarmupFormats []string
+
+	// Subsample과 Bitdepth는 출력 포맷별 크로마 서브샘플링(auto | 444 | 420)과 비트 깊이입니다.
+	// (<FORMAT>_SUBSAMPLE, 기본 auto / <FORMAT>_BITDEPTH, 기본 0) 예: AVIF_SUBSAMPLE=444, AVIF_BITDEPTH=8
+	// 비트 깊이 0은 인코더 기본값이며, AVIF는 SDR 10비트, HDR은 HDR_BITDEPTH입니다.
+	// 포맷마다 지원하는 값이 다르므로 samplingCapabilities로 검증합니다.
+	Subsample map[string]string
+	Bitdepth  map[string]int
+
+	// CloudFrontDistributionID가 있으면 이미 있던 출력 키를 덮어쓸 때 CloudFront 캐시를 무효화합니다.
+	// 출력마다 HeadObject 요청이 하나 늘어나며, cloudfront:CreateInvalidation 권한이 필요합니다. (CLOUDFRONT_DISTRIBUTION_ID)
+	CloudFrontDistributionID string
+	// CloudFrontPathTemplate은 출력 키를 배포 경로로 바꾸는 템플릿입니다. {key}, {bucket}을 쓸 수 있습니다.
+	// (CLOUDFRONT_PATH_TEMPLATE, 기본 /{key})
+	CloudFrontPathTemplate string
+
+	// EventBusName이 있으면 변환이 끝날 때마다 이 이벤트 버스에 image.converted / image.failed 이벤트를
+	// ConversionResult를 detail로 발행합니다. events:PutEvents 권한이 필요합니다. (EVENT_BUS_NAME)
+	EventBusName string
+	// EventSource는 발행하는 이벤트의 source입니다. (EVENT_SOURCE, 기본 thumbnail-creator)
+	EventSource string
+	// Notifiers는 EVENT_BUS_NAME 외에 추가로 변환 결과를 보낼 대상(SNS, 웹훅, DynamoDB, 다른 이벤트 버스)입니다.
+	// 대상마다 보낼 알림, 재시도 정책, 실패 시 변환을 실패시킬지를 정합니다. (NOTIFIERS, JSON 배열, notify.go 참고)
+	Notifiers []NotifierSpec
+
+	// AnalyticsStream이 있으면 변환마다 분석 레코드(JSON 한 줄)를 이 Firehose 전송 스트림에 보냅니다.
+	// firehose:PutRecord 권한이 필요합니다. (ANALYTICS_FIREHOSE_STREAM)
+	AnalyticsStream string
+
+	// FaceDetection이 true이면 redact 단계의 "auto-faces"가 Rekognition DetectFaces로 얼굴을 찾습니다.
+	// 실행 역할에 rekognition:DetectFaces 권한이 필요합니다. (FACE_DETECTION)
+	FaceDetection bool
+
+	// MaxOutputWidth, MaxOutputHeight를 넘는 이미지는 인코딩 전에 비율을 유지하며 줄입니다.
+	// 0이면 제한하지 않습니다. (MAX_OUTPUT_WIDTH, MAX_OUTPUT_HEIGHT, 기본 0)
+	MaxOutputWidth  int
+	MaxOutputHeight int
+
+	// Tenants는 이름별 테넌트 설정입니다. 원본 버킷·키 접두사로 요청에 연결해 출력 위치, 프리셋, 품질,
+	// 이벤트 버스를 바꾸고 결과에 테넌트 이름을 남깁니다. (TENANTS, JSON, tenants.go 참고)
+	Tenants map[string]*Tenant
+	// SkipRules는 다운로드 전에 평가하는 포함·제외 규칙이며 없으면 nil입니다. (SKIP_RULES, JSON, filters.go 참고)
+	SkipRules *SkipRules
+
+	// Presets는 이름별 변환 프리셋입니다. 기본 프리셋(avatar, hero, og-image) 위에
+	// PRESETS 환경 변수(JSON)와 PRESETS_OBJECT(s3://bucket/key, 콜드 스타트 시 로드)를 차례로 덮어씁니다.
+	Presets map[string]Preset
+	// PresetsObject는 프리셋 JSON이 저장된 S3 객체 URI입니다. (PRESETS_OBJECT)
+	PresetsObject string
+
+	// MemoryLeakStreak번 연속으로 호출 종료 시점의 vips 추적 메모리가 늘어나면 누수 의심 경고와
+	// VipsMemoryLeakSuspected 지표를 남깁니다. 0이면 경고하지 않습니다. (MEMORY_LEAK_STREAK, 기본 3)
+	MemoryLeakStreak int
+	// MetricsNamespace는 EMF 지표의 CloudWatch 네임스페이스입니다. (METRICS_NAMESPACE, 기본 ThumbnailCreator)
+	MetricsNamespace string
+
+	// 비용 추정(결과의 cost, EstimatedCostUSD 지표)에 쓰는 단가(USD)입니다. 기본값은 us-east-1 arm64 기준입니다.
+	// CostPerGBSecond: Lambda GB-초당 (COST_PER_GB_SECOND, 기본 0.0000133334)
+	// CostPerInvocation: Lambda 호출 1건 (COST_PER_INVOCATION, 기본 0.0000002)
+	// CostPerS3Get, CostPerS3Put: S3 GET/HEAD, PUT/POST 요청 1건 (COST_PER_S3_GET, 기본 0.0000004 / COST_PER_S3_PUT, 기본 0.000005)
+	CostPerGBSecond   float64
+	CostPerInvocation float64
+	CostPerS3Get      float64
+	CostPerS3Put      float64
+
+	// RecordsTable은 변환 기록을 남길 DynamoDB 테이블입니다. 비어 있으면 기록하지 않습니다. (RECORDS_TABLE)
+	// 키: Day(S, 파티션) + Id(S, 정렬), TTL 속성: ExpiresAt
+	RecordsTable string
+	// ReportsBucket은 절감량 보고서를 올릴 버킷입니다. (REPORTS_BUCKET)
+	ReportsBucket string
+	// ReportsPrefix는 보고서 키 접두사입니다. (REPORTS_PREFIX, 기본 reports/savings/)
+	ReportsPrefix string
+	// ReportPrefixDepth는 보고서에서 원본 키를 묶을 경로 깊이입니다. (REPORT_PREFIX_DEPTH, 기본 1)
+	ReportPrefixDepth int
+
+	// VariantConcurrency는 프리셋 크기들을 동시에 인코딩할 최대 수입니다. 동시에 인코딩하는 만큼 메모리를 더 씁니다.
+	// Lambda는 메모리 1769MB마다 vCPU 하나를 주므로 vCPU 수보다 크게 잡아도 빨라지지 않습니다. (VARIANT_CONCURRENCY, 기본 1)
+	VariantConcurrency int
+	// BatchConcurrency는 배치 요청을 동시에 처리할 작업자 수입니다. (BATCH_CONCURRENCY, 기본 4)
+	// 작업자마다 원본과 디코딩한 이미지를 메모리에 올리므로 함수 메모리에 맞게 정합니다.
+	BatchConcurrency int
+	// BatchItemTimeout은 배치 항목 하나의 제한 시간입니다. 0이면 함수 제한 시간만 적용합니다. (BATCH_ITEM_TIMEOUT_MS, 기본 0)
+	BatchItemTimeout time.Duration
+	// BatchLowPriorityReserve가 있으면 남은 시간이 이보다 적을 때 priority=low 항목을 시작하지 않고
+	// TimeoutBudgetExceeded 실패로 남겨 다음 호출로 미룹니다. 0이면 미루지 않습니다. (BATCH_LOW_PRIORITY_RESERVE_MS, 기본 0)
+	BatchLowPriorityReserve time.Duration
+	// BackfillCheckpointEvery는 "mode": "backfill"이 체크포인트를 쓰는 항목 간격입니다. (BACKFILL_CHECKPOINT_EVERY, 기본 50)
+	BackfillCheckpointEvery int
+	// GCMaxDeletes는 "mode": "gc"가 호출 한 번에 지울 기본 최대 개수입니다. (GC_MAX_DELETES, 기본 1000)
+	// GCReportsPrefix는 그 보고서 키 접두사입니다. (GC_REPORTS_PREFIX, 기본 reports/gc/)
+	GCMaxDeletes    int
+	GCReportsPrefix string
+	// TilesUploadConcurrency는 "mode": "tiles"가 타일을 동시에 올리는 개수입니다. (TILES_UPLOAD_CONCURRENCY, 기본 16)
+	TilesUploadConcurrency int
+	// PresignExpiry가 있으면 결과와 알림의 출력마다 이 기간 동안 유효한 서명된 GET URL을 넣습니다.
+	// (PRESIGN_EXPIRY_SECONDS, 기본 0 = 끔, 최대 604800 = 7일)
+	PresignExpiry time.Duration
+
+	// UploadRateLimit은 출력 버킷별 초당 업로드 수 한도입니다. 대량 백필이 S3 SlowDown을 일으키지 않게 합니다.
+	// 0이면 제한하지 않습니다. (UPLOAD_RATE_LIMIT, 기본 0) UploadRateBurst는 순간 허용량입니다. (UPLOAD_RATE_BURST, 기본 10)
+	UploadRateLimit float64
+	UploadRateBurst int
+	// NotifyRateLimit은 이벤트 버스별 초당 발행 수 한도입니다. (NOTIFY_RATE_LIMIT, 기본 0)
+	// NotifyRateBurst는 순간 허용량입니다. (NOTIFY_RATE_BURST, 기본 10)
+	NotifyRateLimit float64
+	NotifyRateBurst int
+	// RateLimitMaxWait은 한도에 걸렸을 때 기다릴 최대 시간입니다. 더 기다려야 하면 재시도 가능한
+	// RATE_LIMITED 오류로 작업을 미룹니다. (RATE_LIMIT_MAX_WAIT_MS, 기본 1000)
+	RateLimitMaxWait time.Duration
+
+	// EncoderBreakerThreshold는 인코더 회로 차단기를 여는 연속 실패 횟수입니다. 0이면 쓰지 않습니다.
+	// 열리면 EncoderBreakerCooldown 동안 인코딩 없이 ENCODER_CIRCUIT_OPEN 오류로 바로 끝냅니다.
+	// (ENCODER_BREAKER_THRESHOLD, 기본 5 / ENCODER_BREAKER_COOLDOWN_MS, 기본 60000)
+	EncoderBreakerThreshold int
+	EncoderBreakerCooldown  time.Duration
+
+	// QuarantineAfter는 원본을 격리하기까지의 시도 횟수입니다. 0이면 격리하지 않습니다. (QUARANTINE_AFTER, 기본 0)
+	// 시도 횟수는 원본 객체 태그로 세므로 변환마다 태그 요청이 2~3개 늘어납니다.
+	QuarantineAfter int
+	// QuarantinePrefix는 격리한 원본과 실패 보고서를 둘 키 접두사입니다. (QUARANTINE_PREFIX, 기본 quarantine/)
+	QuarantinePrefix string
+
+	// AuditBucket이 있으면 변환 요청마다 감사 레코드(JSON Lines)를 AuditPrefix 아래 날짜별로 씁니다.
+	// 기록하지 못하면 호출을 실패시켜 재시도합니다. (AUDIT_BUCKET / AUDIT_PREFIX, 기본 audit/)
+	AuditBucket string
+	AuditPrefix string
+
+	// ArchiveMaxEntries, ArchiveMaxEntryBytes, ArchiveMaxTotalBytes는 ZIP/TAR 입력의 항목 수, 항목 하나와
+	// 전체의 압축 해제 크기 한도입니다. 항목 수나 전체 크기를 넘으면 압축 폭탄으로 보고 중단합니다.
+	// (ARCHIVE_MAX_ENTRIES, 기본 1000 / ARCHIVE_MAX_ENTRY_MB, 기본 100 / ARCHIVE_MAX_TOTAL_MB, 기본 2048)
+	ArchiveMaxEntries    int
+	ArchiveMaxEntryBytes int64
+	ArchiveMaxTotalBytes int64
+
+	// FFmpegPath가 있으면 MP4/MOV 원본에서 PosterSeconds 시각의 프레임을 뽑아 이미지로 변환합니다.
+	// 레이어(/opt/bin/ffmpeg 등)나 함께 배포한 실행 파일 경로입니다. (FFMPEG_PATH / POSTER_SECONDS, 기본 1)
+	FFmpegPath    string
+	PosterSeconds float64
+	// SpriteMaxFrames는 "mode": "sprite" 요청 하나의 최대 프레임 수입니다. (SPRITE_MAX_FRAMES, 기본 200)
+	SpriteMaxFrames int
+	// MontageMaxImages는 "mode": "montage" 요청 하나의 최대 원본 수입니다. (MONTAGE_MAX_IMAGES, 기본 100)
+	MontageMaxImages int
+	// VideoPreview는 동영상 앞부분의 움직이는 미리보기(<키>.preview.webp|avif) 설정입니다. 형식이 비어 있으면 만들지 않습니다.
+	// (VIDEO_PREVIEW_FORMAT, VIDEO_PREVIEW_SECONDS 기본 3, VIDEO_PREVIEW_FPS 기본 10, VIDEO_PREVIEW_WIDTH 기본 320,
+	// VIDEO_PREVIEW_QUALITY 기본 60)
+	VideoPreview videoPreview
+	// OutputStorageClass는 출력 이미지의 S3 저장 클래스(STANDARD, INTELLIGENT_TIERING, STANDARD_IA 등)입니다.
+	// 비어 있으면 버킷 기본값(STANDARD)이며, 테넌트의 storageClass가 우선합니다. (OUTPUT_STORAGE_CLASS)
+	OutputStorageClass string
+	// OutputLockMode(GOVERNANCE | COMPLIANCE)가 있으면 출력에 업로드 시각부터 OutputRetentionDays일의 Object Lock 보존을,
+	// OutputLegalHold가 true이면 법적 보존을 겁니다. 대상 버킷에 Object Lock이 켜져 있어야 합니다.
+	// (OUTPUT_OBJECT_LOCK_MODE, OUTPUT_RETENTION_DAYS, OUTPUT_LEGAL_HOLD)
+	OutputLockMode      string
+	OutputRetentionDays int
+	OutputLegalHold     bool
+	// SourceContentType은 원본의 저장된 Content-Type을 확장자·내용(매직 바이트)과 비교하는 방식입니다.
+	// off: 비교 안 함, log: 어긋나면 경고와 결과의 sourceType을 남김, fix: 변환에 성공하면 원본의 Content-Type을 내용에 맞게
+	// CopyObject로 고침(s3:PutObject 권한 필요) (SOURCE_CONTENT_TYPE, 기본 log)
+	SourceContentType string
+	// OriginalsStorageClass가 있으면 변환에 성공한 원본을 이 저장 클래스(STANDARD_IA, GLACIER_IR 등)로 옮깁니다.
+	// OriginalsAction은 rewrite(같은 키에 다시 쓰기) | copy(OriginalsCopyBucket/OriginalsCopyPrefix에 사본)입니다.
+	// (ORIGINALS_STORAGE_CLASS, ORIGINALS_ACTION 기본 rewrite, ORIGINALS_COPY_BUCKET 기본 원본 버킷, ORIGINALS_COPY_PREFIX 기본 originals/)
+	OriginalsStorageClass string
+	// GlacierRestoreTier가 있으면 Glacier·Deep Archive·Intelligent-Tiering 보관 계층의 원본에 RestoreObject를 요청하고
+	// RETRY_AFTER_RESTORE로 끝냅니다. 없으면 SOURCE_ARCHIVED 오류입니다.
+	// (GLACIER_RESTORE_TIER: Expedited | Standard | Bulk, GLACIER_RESTORE_DAYS: 복원본 보관 일수, 기본 1)
+	GlacierRestoreTier  string
+	GlacierRestoreDays  int
+	OriginalsAction     string
+	OriginalsCopyBucket string
+	OriginalsCopyPrefix string
+	// OTelEnabled는 OTEL_EXPORTER_OTLP_ENDPOINT(또는 _TRACES_/_METRICS_ENDPOINT)가 있고 OTEL_SDK_DISABLED가
+	// true가 아니면 켜집니다. 그 밖의 OTEL_* 변수는 OpenTelemetry SDK가 직접 읽습니다. (telemetry.go 참고)
+	OTelEnabled bool
+	// PrometheusPushURL이 있으면 호출마다 같은 지표를 Prometheus 텍스트 형식으로 Pushgateway(또는 같은 API를 받는 확장)에
+	// job=PrometheusJob, instance=로그 스트림 그룹으로 밀어 넣습니다. PrometheusListenAddr이 있으면 그 주소의 /metrics에서
+	// 내어 줍니다(Lambda 확장이 localhost로 긁어 갈 때). (PROMETHEUS_PUSH_URL, PROMETHEUS_JOB 기본 함수 이름, PROMETHEUS_LISTEN_ADDR)
+	PrometheusPushURL    string
+	PrometheusJob        string
+	PrometheusListenAddr string
+	// AppConfigApplication/Environment/Profile이 있으면 AppConfig 기능 플래그로 위험한 기능을 버킷별로
+	// 다시 배포하지 않고 켜고 끕니다. 플래그 문서에 없는 기능은 환경 변수 설정을 따릅니다. (flags.go 참고)
+	// (APPCONFIG_APPLICATION, APPCONFIG_ENVIRONMENT, APPCONFIG_PROFILE)
+	AppConfigApplication string
+	AppConfigEnvironment string
+	AppConfigProfile     string
+	// SecretsRefresh는 ssm:/secretsmanager: 참조 값을 다시 확인하는 간격입니다. 0이면 콜드 스타트에만 읽습니다.
+	// (SECRETS_REFRESH_SECONDS, 기본 300)
+	SecretsRefresh time.Duration
+	// DestinationRoleARN이 있으면 DestinationBuckets에 출력을 쓸 때 이 역할을 맡습니다(STS AssumeRole).
+	// 다른 계정의 KMS 키로 암호화된 버킷처럼 버킷 정책만으로는 쓸 수 없는 대상에 사용합니다.
+	// (DESTINATION_ROLE_ARN, DESTINATION_BUCKETS 쉼표 구분, DESTINATION_EXTERNAL_ID)
+	DestinationRoleARN    string
+	DestinationBuckets    []string
+	DestinationExternalID string
+	// ReplicaGuard가 true이면 교차 리전 복제로 들어온 복제본을 SKIPPED_REPLICA로 건너뜁니다.
+	// 원본을 확인하는 HeadObject가 요청마다 한 번 늘어납니다. (REPLICA_GUARD, replica.go 참고)
+	ReplicaGuard bool
+	// FunctionRegion은 함수가 실행되는 리전입니다. (AWS_REGION, Lambda가 설정)
+	FunctionRegion string
+	// LogLevel이 debug이면 모든 호출에서 인코더 옵션 등 자세한 로그를 남깁니다. 이벤트의 debug로 호출 하나만 켤 수도 있습니다.
+	// DebugPrefix는 debug.artifacts 요청의 중간 결과를 올리는 출력 버킷의 접두사이며, 이 아래 키는 변환하지 않습니다.
+	// (LOG_LEVEL 기본 info | debug, DEBUG_PREFIX 기본 .debug/)
+	LogLevel    string
+	DebugPrefix string
+	// APIListenAddr와 APIRequestTimeout은 HTTP API(cmd/thumbnail-api)의 주소와 요청별 제한 시간입니다.
+	// Lambda 빌드에서는 쓰지 않습니다. (API_LISTEN_ADDR 기본 :8080, API_REQUEST_TIMEOUT_SECONDS 기본 60)
+	APIListenAddr     string
+	APIRequestTimeout time.Duration
+	// RunMode는 Lambda 빌드의 실행 방식입니다. (RUN_MODE 기본 lambda | sqs | worker, worker.go 참고)
+	// sqs는 SQSQueueURL을 SQSBatchSize개씩 폴링하며, SQSVisibilityTimeout을 배치 하나의 제한 시간으로도 씁니다.
+	// (SQS_QUEUE_URL, SQS_BATCH_SIZE 기본 10, SQS_VISIBILITY_TIMEOUT_SECONDS 기본 900)
+	// worker는 WorkerEvent(비어 있으면 표준 입력)의 이벤트 하나를 처리합니다. (WORKER_EVENT)
+	RunMode              string
+	SQSQueueURL          string
+	SQSBatchSize         int
+	SQSVisibilityTimeout time.Duration
+	WorkerEvent          string
+	// SelfTestBucket은 "mode": "self-test"에서 쓰기·삭제를 확인할 버킷이며, 이벤트의 s3Bucket이 우선합니다.
+	// SelfTestPrefix 아래 키는 변환하지 않습니다. (SELFTEST_BUCKET, SELFTEST_PREFIX 기본 .selftest/)
+	SelfTestBucket string
+	SelfTestPrefix string
+	// RegressionBucket과 RegressionPrefix는 "mode": "regression"의 코퍼스 위치입니다. 이벤트의 s3Bucket이 우선합니다.
+	// (REGRESSION_BUCKET, REGRESSION_PREFIX 기본 regression/corpus/)
+	RegressionBucket string
+	RegressionPrefix string
+
+	// VerifyUploads는 출력 업로드 검증 수준입니다. head: HeadObject로 크기·SHA-256 비교,
+	// full: head에 더해 업로드 전에 출력을 다시 디코딩 (VERIFY_UPLOADS, 기본 없음)
+	VerifyUploads string
+	// DedupTable이 있으면 같은 S3 이벤트(sequencer 또는 ETag)가 DedupWindow 안에 다시 오면 SKIPPED_DUPLICATE_EVENT로 끝냅니다.
+	// (DEDUP_TABLE, DEDUP_WINDOW_SECONDS 기본 300)
+	DedupTable  string
+	DedupWindow time.Duration
+	// DecodeMax*는 디코딩 전에 헤더로 확인하는 한도이며, 넘으면 REJECTED_DECODE_LIMIT로 건너뜁니다. 0이면 확인하지 않습니다.
+	// DecodeMaxRatio는 선언된 디코딩 크기 / 파일 크기이며, 64MB 이상으로 풀리는 이미지에만 적용합니다.
+	// (DECODE_MAX_DIMENSION 기본 0, DECODE_MAX_PIXELS 기본 100000000, DECODE_MAX_FRAMES 기본 1000, DECODE_MAX_RATIO 기본 500)
+	DecodeMaxDimension int
+	DecodeMaxPixels    int64
+	DecodeMaxFrames    int
+	DecodeMaxRatio     float64
+	// TransformRules는 원본 속성(크기, 포맷, 알파 등)에 따라 건너뛰기, 그대로 복사, 포맷·품질 변경을 정하는 규칙입니다.
+	// (TRANSFORM_RULES, JSON 배열, rules.go 참고)
+	TransformRules []TransformRule
+	// MetadataIncludeGPS이면 "mode": "metadata" 결과에 GPS EXIF 태그(위치)를 넣습니다. (METADATA_INCLUDE_GPS)
+	MetadataIncludeGPS bool
+	// MinSourceBytes보다 작은 원본은 디코딩하지 않고 SKIPPED_EMPTY_OBJECT로 건너뜁니다. 빈 객체는 항상 건너뜁니다.
+	// 기본값은 온전한 이미지 파일이 될 수 없는 크기입니다. (MIN_SOURCE_BYTES, 기본 16)
+	MinSourceBytes int64
+	// KeyDecoding은 이벤트 s3Key의 URL 디코딩 방식입니다. (KEY_DECODING 기본 auto | always | never, keys.go 참고)
+	// OutputKeyNFC이면 출력 키의 기준 경로를 유니코드 NFC로 정규화합니다. (OUTPUT_KEY_NFC, 기본 true)
+	KeyDecoding  string
+	OutputKeyNFC bool
+	// SkipBytesPerPixel이 있으면 디코딩 뒤 원본 크기 ÷ 픽셀 수가 이보다 작은 원본을 인코딩하지 않고
+	// SKIPPED_ALREADY_OPTIMIZED로 건너뜁니다. 0이면 끕니다. (SKIP_BYTES_PER_PIXEL, 예: 0.1)
+	SkipBytesPerPixel float64
+	// ObjectLambda이면 S3 Object Lambda 액세스 포인트의 GetObject 요청을 변환해 응답합니다. (OBJECT_LAMBDA)
+	// ObjectLambdaCacheControl은 그 응답의 Cache-Control입니다. (OBJECT_LAMBDA_CACHE_CONTROL, 기본 public, max-age=86400)
+	ObjectLambda             bool
+	ObjectLambdaCacheControl string
+	// OutputNaming이 content이면 출력 키를 <ContentKeyPrefix><해시 앞 2자>/<SHA-256 16자><접미사><확장자>로 바꾸고,
+	// ContentManifest이면 원래 키와의 대응을 <기준 키>.manifest.json으로 올립니다.
+	// (OUTPUT_NAMING 기본 source | content, CONTENT_KEY_PREFIX 기본 thumbs/, CONTENT_MANIFEST)
+	OutputNaming     string
+	ContentKeyPrefix string
+	ContentManifest  bool
+	// Deterministic이면 같은 입력 바이트가 항상 같은 출력 바이트가 되도록 인코딩합니다. (DETERMINISTIC_ENCODE)
+	// libvips 스레드를 1로 고정하고, 스레드 수에 따라 결과가 달라지는 SVT-AV1 대신 AOM을 쓰며,
+	// ffmpeg 출력에서 생성 시각과 버전 문자열을 뺍니다. 출력 해시 기반 캐시 검증과 내용 주소 키에 필요합니다.
+	Deterministic bool
+
+	// Settings는 설정된 환경 변수의 원래 값입니다. 참조는 해석한 값이 아닌 참조 그대로 남습니다. (info 모드)
+	Settings map[string]string
+
+	// DeadlineReserve는 인코딩 등 중단할 수 없는 단계를 시작하기 전에 남아 있어야 하는 최소 실행 시간입니다.
+	// 부족하면 재시도 가능한 TIMEOUT_BUDGET_EXCEEDED 오류로 끝냅니다. (DEADLINE_RESERVE_MS, 기본 3000)
+	DeadlineReserve time.Duration
+
+	// ParallelDownloadThreshold 이상 크기의 원본은 동시 ranged GET으로 받습니다. 0이면 항상 단일 GetObject를 씁니다.
+	// S3 알림에 크기가 없는 원본은 크기 확인을 위해 HeadObject 요청이 하나 늘어납니다. (PARALLEL_DOWNLOAD_THRESHOLD_MB, 기본 32)
+	ParallelDownloadThreshold int64
+	// DownloadConcurrency는 병렬 다운로드의 동시 요청 수입니다. (DOWNLOAD_CONCURRENCY, 기본 8)
+	DownloadConcurrency int
+	// MultipartThreshold 이상 크기의 출력은 멀티파트로 업로드하며, 실패하면 업로드를 중단(abort)합니다.
+	// 0이면 항상 단일 PutObject를 사용합니다. (MULTIPART_THRESHOLD_MB, 기본 16)
+	MultipartThreshold int64
+	// UploadChecksum은 업로드에 붙이는 체크섬 알고리즘과 종류입니다. (UPLOAD_CHECKSUM_ALGORITHM, 기본 SHA256 /
+	// UPLOAD_CHECKSUM_TYPE, 기본 COMPOSITE, CRC64NVME는 FULL_OBJECT) UploadChecksums는 버킷별 설정이며 기본값보다
+	// 우선합니다. (UPLOAD_CHECKSUMS, JSON 예: {"archive-bucket": {"algorithm": "CRC32C", "type": "FULL_OBJECT"}})
+	UploadChecksum  UploadChecksum
+	UploadChecksums map[string]UploadChecksum
+
+	// S3EndpointURL은 AWS 기본 엔드포인트 대신 사용할 S3 호환 엔드포인트입니다.
+	// MinIO, LocalStack 등에서 사용합니다. (S3_ENDPOINT_URL)
+	S3EndpointURL string
+	// S3Region은 S3 클라이언트에만 적용할 리전입니다. 비어 있으면 AWS_REGION 등 SDK 기본값을 따릅니다. (S3_REGION)
+	S3Region string
+	// S3UsePathStyle이 true이면 버킷 이름을 호스트 대신 경로에 넣어 요청합니다. (S3_USE_PATH_STYLE)
+	S3UsePathStyle bool
+	// S3UseAccelerate가 true이면 S3 Transfer Acceleration 엔드포인트를 사용합니다.
+	// 버킷에 가속이 켜져 있어야 하며, 경로 방식이나 사용자 지정 엔드포인트와 함께 쓸 수 없습니다. (S3_USE_ACCELERATE)
+	S3UseAccelerate bool
+	// S3UseDualStack이 true이면 IPv4/IPv6 듀얼 스택 엔드포인트를 사용합니다. (S3_USE_DUALSTACK)
+	S3UseDualStack bool
+}
+
+// loadConfig는 환경 변수에서 Config를 읽고 값을 검증합니다. ctx는 ssm:/secretsmanager: 참조를 읽을 때 씁니다.
+func loadConfig(ctx context.Context, secrets *secretResolver) (Config, error) {
+	env := &envReader{ctx: ctx, secrets: secrets}
+	c := Config{
+		GraphicsMode:                env.String("GRAPHICS_MODE", "auto"),
+		GraphicsFormat:              env.String("GRAPHICS_FORMAT", "avif"),
+		GraphicsMaxColors:           env.Int("GRAPHICS_MAX_COLORS", 256),
+		GraphicsNearLossless:        env.Bool("GRAPHICS_NEAR_LOSSLESS", false),
+		GraphicsNearLosslessQuality: env.Int("GRAPHICS_NEAR_LOSSLESS_QUALITY", 90),
+		AlphaPolicy:                 env.String("ALPHA_POLICY", "preserve"),
+		HDRPolicy:                   env.String("HDR_POLICY", "preserve"),
+		HDRBitdepth:                 env.Int("HDR_BITDEPTH", 10),
+		WideGamutPolicy:             env.String("WIDE_GAMUT_POLICY", "preserve"),
+		JXLOutput:                   env.Bool("JXL_OUTPUT", false),
+		JXLQuality:                  env.Int("JXL_QUALITY", 75),
+		JXLEffort:                   env.Int("JXL_EFFORT", 7),
+		JPEGFallback:                env.Bool("JPEG_FALLBACK", false),
+		JPEGQuality:                 env.Int("JPEG_QUALITY", 80),
+		AVIFEncoder:                 env.String("AVIF_ENCODER", "auto"),
+		Deterministic:               env.Bool("DETERMINISTIC_ENCODE", false),
+		OutputNaming:                env.String("OUTPUT_NAMING", "source"),
+		VerifyUploads:               env.String("VERIFY_UPLOADS", ""),
+		DedupTable:                  env.String("DEDUP_TABLE", ""),
+		DedupWindow:                 time.Duration(env.Int("DEDUP_WINDOW_SECONDS", 300)) * time.Second,
+		DecodeMaxDimension:          env.Int("DECODE_MAX_DIMENSION", 0),
+		DecodeMaxPixels:             int64(env.Int("DECODE_MAX_PIXELS", 100_000_000)),
+		DecodeMaxFrames:             env.Int("DECODE_MAX_FRAMES", 1000),
+		DecodeMaxRatio:              env.Float("DECODE_MAX_RATIO", 500),
+		MinSourceBytes:              int64(env.Int("MIN_SOURCE_BYTES", 16)),
+		KeyDecoding:                 env.String("KEY_DECODING", "auto"),
+		OutputKeyNFC:                env.Bool("OUTPUT_KEY_NFC", true),
+		SkipBytesPerPixel:           env.Float("SKIP_BYTES_PER_PIXEL", 0),
+		ObjectLambda:                env.Bool("OBJECT_LAMBDA", false),
+		ObjectLambdaCacheControl:    env.String("OBJECT_LAMBDA_CACHE_CONTROL", "public, max-age=86400"),
+		MetadataIncludeGPS:          env.Bool("METADATA_INCLUDE_GPS", false),
+		ContentKeyPrefix:            env.String("CONTENT_KEY_PREFIX", "thumbs/"),
+		ContentManifest:             env.Bool("CONTENT_MANIFEST", false),
+		AOMMaxPixels:                env.Int("AOM_MAX_PIXELS", 1_000_000),
+		AVIFEffort:                  env.Int("AVIF_EFFORT", 0),
+		VipsConcurrency:             env.Int("VIPS_CONCURRENCY", 1),
+		VipsMaxCacheSize:            env.Int("VIPS_MAX_CACHE_SIZE", 0),
+		VipsMaxCacheMem:             env.Int("VIPS_MAX_CACHE_MEM_MB", 0) << 20,
+		VipsMaxCacheFiles:           env.Int("VIPS_MAX_CACHE_FILES", 0),
+		CloudFrontDistributionID:    env.String("CLOUDFRONT_DISTRIBUTION_ID", ""),
+		CloudFrontPathTemplate:      env.String("CLOUDFRONT_PATH_TEMPLATE", "/{key}"),
+		EventBusName:                env.String("EVENT_BUS_NAME", ""),
+		EventSource:                 env.String("EVENT_SOURCE", "thumbnail-creator"),
+		AnalyticsStream:             env.String("ANALYTICS_FIREHOSE_STREAM", ""),
+		FaceDetection:               env.Bool("FACE_DETECTION", false),
+		MaxOutputWidth:              env.Int("MAX_OUTPUT_WIDTH", 0),
+		MaxOutputHeight:             env.Int("MAX_OUTPUT_HEIGHT", 0),
+		Presets:                     defaultPresets,
+		PresetsObject:               env.String("PRESETS_OBJECT", ""),
+		MemoryLeakStreak:            env.Int("MEMORY_LEAK_STREAK", 3),
+		MetricsNamespace:            env.String("METRICS_NAMESPACE", "ThumbnailCreator"),
+		CostPerGBSecond:             env.Float("COST_PER_GB_SECOND", 0.0000133334),
+		CostPerInvocation:           env.Float("COST_PER_INVOCATION", 0.0000002),
+		CostPerS3Get:                env.Float("COST_PER_S3_GET", 0.0000004),
+		CostPerS3Put:                env.Float("COST_PER_S3_PUT", 0.000005),
+		RecordsTable:                env.String("RECORDS_TABLE", ""),
+		ReportsBucket:               env.String("REPORTS_BUCKET", ""),
+		ReportsPrefix:               env.String("REPORTS_PREFIX", "reports/savings/"),
+		ReportPrefixDepth:           env.Int("REPORT_PREFIX_DEPTH", 1),
+		BatchConcurrency:            env.Int("BATCH_CONCURRENCY", 4),
+		VariantConcurrency:          env.Int("VARIANT_CONCURRENCY", 1),
+		BatchItemTimeout:            time.Duration(env.Int("BATCH_ITEM_TIMEOUT_MS", 0)) * time.Millisecond,
+		BatchLowPriorityReserve:     time.Duration(env.Int("BATCH_LOW_PRIORITY_RESERVE_MS", 0)) * time.Millisecond,
+		BackfillCheckpointEvery:     env.Int("BACKFILL_CHECKPOINT_EVERY", 50),
+		GCMaxDeletes:                env.Int("GC_MAX_DELETES", 1000),
+		GCReportsPrefix:             env.String("GC_REPORTS_PREFIX", "reports/gc/"),
+		TilesUploadConcurrency:      env.Int("TILES_UPLOAD_CONCURRENCY", 16),
+		PresignExpiry:               time.Duration(env.Int("PRESIGN_EXPIRY_SECONDS", 0)) * time.Second,
+		UploadRateLimit:             env.Float("UPLOAD_RATE_LIMIT", 0),
+		UploadRateBurst:             env.Int("UPLOAD_RATE_BURST", 10),
+		NotifyRateLimit:             env.Float("NOTIFY_RATE_LIMIT", 0),
+		NotifyRateBurst:             env.Int("NOTIFY_RATE_BURST", 10),
+		RateLimitMaxWait:            time.Duration(env.Int("RATE_LIMIT_MAX_WAIT_MS", 1000)) * time.Millisecond,
+		EncoderBreakerThreshold:     env.Int("ENCODER_BREAKER_THRESHOLD", 5),
+		EncoderBreakerCooldown:      time.Duration(env.Int("ENCODER_BREAKER_COOLDOWN_MS", 60000)) * time.Millisecond,
+		QuarantineAfter:             env.Int("QUARANTINE_AFTER", 0),
+		QuarantinePrefix:            env.String("QUARANTINE_PREFIX", "quarantine/"),
+		AuditBucket:                 env.String("AUDIT_BUCKET", ""),
+		AuditPrefix:                 env.String("AUDIT_PREFIX", "audit/"),
+		ArchiveMaxEntries:           env.Int("ARCHIVE_MAX_ENTRIES", 1000),
+		ArchiveMaxEntryBytes:        int64(env.Int("ARCHIVE_MAX_ENTRY_MB", 100)) << 20,
+		ArchiveMaxTotalBytes:        int64(env.Int("ARCHIVE_MAX_TOTAL_MB", 2048)) << 20,
+		FFmpegPath:                  env.String("FFMPEG_PATH", ""),
+		PosterSeconds:               env.Float("POSTER_SECONDS", 1),
+		SpriteMaxFrames:             env.Int("SPRITE_MAX_FRAMES", 200),
+		MontageMaxImages:            env.Int("MONTAGE_MAX_IMAGES", 100),
+		VideoPreview: videoPreview{
+			Format:  env.String("VIDEO_PREVIEW_FORMAT", ""),
+			Seconds: env.Float("VIDEO_PREVIEW_SECONDS", 3),
+			FPS:     env.Int("VIDEO_PREVIEW_FPS", 10),
+			Width:   env.Int("VIDEO_PREVIEW_WIDTH", 320),
+			Quality: env.Int("VIDEO_PREVIEW_QUALITY", 60),
+		},
+		OutputStorageClass:        env.String("OUTPUT_STORAGE_CLASS", ""),
+		OutputLockMode:            env.String("OUTPUT_OBJECT_LOCK_MODE", ""),
+		OutputRetentionDays:       env.Int("OUTPUT_RETENTION_DAYS", 0),
+		OutputLegalHold:           env.Bool("OUTPUT_LEGAL_HOLD", false),
+		SourceContentType:         env.String("SOURCE_CONTENT_TYPE", "log"),
+		OriginalsStorageClass:     env.String("ORIGINALS_STORAGE_CLASS", ""),
+		GlacierRestoreTier:        env.String("GLACIER_RESTORE_TIER", ""),
+		GlacierRestoreDays:        env.Int("GLACIER_RESTORE_DAYS", 1),
+		OriginalsAction:           env.String("ORIGINALS_ACTION", "rewrite"),
+		OriginalsCopyBucket:       env.String("ORIGINALS_COPY_BUCKET", ""),
+		OriginalsCopyPrefix:       env.String("ORIGINALS_COPY_PREFIX", "originals/"),
+		AppConfigApplication:      env.String("APPCONFIG_APPLICATION", ""),
+		AppConfigEnvironment:      env.String("APPCONFIG_ENVIRONMENT", ""),
+		AppConfigProfile:          env.String("APPCONFIG_PROFILE", ""),
+		SecretsRefresh:            time.Duration(env.Int("SECRETS_REFRESH_SECONDS", 300)) * time.Second,
+		DestinationRoleARN:        env.String("DESTINATION_ROLE_ARN", ""),
+		DestinationExternalID:     env.String("DESTINATION_EXTERNAL_ID", ""),
+		ReplicaGuard:              env.Bool("REPLICA_GUARD", false),
+		FunctionRegion:            env.String("AWS_REGION", ""),
+		LogLevel:                  env.String("LOG_LEVEL", "info"),
+		DebugPrefix:               env.String("DEBUG_PREFIX", ".debug/"),
+		APIListenAddr:             env.String("API_LISTEN_ADDR", ":8080"),
+		APIRequestTimeout:         time.Duration(env.Int("API_REQUEST_TIMEOUT_SECONDS", 60)) * time.Second,
+		RunMode:                   env.String("RUN_MODE", "lambda"),
+		SQSQueueURL:               env.String("SQS_QUEUE_URL", ""),
+		SQSBatchSize:              env.Int("SQS_BATCH_SIZE", 10),
+		SQSVisibilityTimeout:      time.Duration(env.Int("SQS_VISIBILITY_TIMEOUT_SECONDS", 900)) * time.Second,
+		WorkerEvent:               env.String("WORKER_EVENT", ""),
+		SelfTestBucket:            env.String("SELFTEST_BUCKET", ""),
+		SelfTestPrefix:            env.String("SELFTEST_PREFIX", ".selftest/"),
+		RegressionBucket:          env.String("REGRESSION_BUCKET", ""),
+		RegressionPrefix:          env.String("REGRESSION_PREFIX", "regression/corpus/"),
+		DeadlineReserve:           time.Duration(env.Int("DEADLINE_RESERVE_MS", 3000)) * time.Millisecond,
+		ParallelDownloadThreshold: int64(env.Int("PARALLEL_DOWNLOAD_THRESHOLD_MB", 32)) << 20,
+		DownloadConcurrency:       env.Int("DOWNLOAD_CONCURRENCY", 8),
+		MultipartThreshold:        int64(env.Int("MULTIPART_THRESHOLD_MB", 16)) << 20,
+		UploadChecksum: UploadChecksum{
+			Algorithm: env.String("UPLOAD_CHECKSUM_ALGORITHM", "SHA256"),
+			Type:      env.String("UPLOAD_CHECKSUM_TYPE", ""),
+		},
+		S3EndpointURL:   env.String("S3_ENDPOINT_URL", ""),
+		S3Region:        env.String("S3_REGION", ""),
+		S3UsePathStyle:  env.Bool("S3_USE_PATH_STYLE", false),
+		S3UseAccelerate: env.Bool("S3_USE_ACCELERATE", false),
+		S3UseDualStack:  env.Bool("S3_USE_DUALSTACK", false),
+	}
+	c.Subsample = map[string]string{}
+	c.Bitdepth = map[string]int{}
+	for _, format := range []string{"avif", "webp", "jpeg", "jxl", "png"} {
+		prefix := strings.ToUpper(format)
+		c.Subsample[format] = env.String(prefix+"_SUBSAMPLE", "auto")
+		c.Bitdepth[format] = env.Int(prefix+"_BITDEPTH", 0)
+	}
+	if env.err != nil {
+		return Config{}, env.err
+	}
+	for format := range c.Subsample {
+		if err := validateSampling(format, c.Subsample[format], c.Bitdepth[format]); err != nil {
+			prefix := strings.ToUpper(format)
+			return Config{}, fmt.Errorf("invalid %s_SUBSAMPLE/%s_BITDEPTH: %w", prefix, prefix, err)
+		}
+	}
+
+	switch c.GraphicsMode {
+	case "auto", "always", "never":
+	default:
+		return Config{}, fmt.Errorf("invalid GRAPHICS_MODE %q: must be one of auto, always, never", c.GraphicsMode)
+	}
+	switch c.GraphicsFormat {
+	case "avif", "webp":
+	default:
+		return Config{}, fmt.Errorf("invalid GRAPHICS_FORMAT %q: must be avif or webp", c.GraphicsFormat)
+	}
+	if c.GraphicsNearLosslessQuality < 1 || c.GraphicsNearLosslessQuality > 100 {
+		return Config{}, fmt.Errorf("invalid GRAPHICS_NEAR_LOSSLESS_QUALITY %d: must be between 1 and 100", c.GraphicsNearLosslessQuality)
+	}
+	switch c.AlphaPolicy {
+	case "preserve", "flatten":
+	default:
+		return Config{}, fmt.Errorf("invalid ALPHA_POLICY %q: must be preserve or flatten", c.AlphaPolicy)
+	}
+	switch c.HDRPolicy {
+	case "preserve", "tonemap":
+	default:
+		return Config{}, fmt.Errorf("invalid HDR_POLICY %q: must be preserve or tonemap", c.HDRPolicy)
+	}
+	if c.HDRBitdepth != 10 && c.HDRBitdepth != 12 {
+		return Config{}, fmt.Errorf("invalid HDR_BITDEPTH %d: must be 10 or 12", c.HDRBitdepth)
+	}
+	switch c.WideGamutPolicy {
+	case "preserve", "srgb":
+	default:
+		return Config{}, fmt.Errorf("invalid WIDE_GAMUT_POLICY %q: must be preserve or srgb", c.WideGamutPolicy)
+	}
+	if c.JXLQuality < 1 || c.JXLQuality > 100 {
+		return Config{}, fmt.Errorf("invalid JXL_QUALITY %d: must be between 1 and 100", c.JXLQuality)
+	}
+	if c.JXLEffort < 1 || c.JXLEffort > 9 {
+		return Config{}, fmt.Errorf("invalid JXL_EFFORT %d: must be between 1 and 9", c.JXLEffort)
+	}
+	if c.JPEGQuality < 1 || c.JPEGQuality > 100 {
+		return Config{}, fmt.Errorf("invalid JPEG_QUALITY %d: must be between 1 and 100", c.JPEGQuality)
+	}
+	switch c.AVIFEncoder {
+	case "auto", "svt", "aom":
+	default:
+		return Config{}, fmt.Errorf("invalid AVIF_ENCODER %q: must be one of auto, svt, aom", c.AVIFEncoder)
+	}
+	if c.AVIFEffort < 0 || c.AVIFEffort > 9 {
+		return Config{}, fmt.Errorf("invalid AVIF_EFFORT %d: must be between 0 and 9", c.AVIFEffort)
+	}
+	if c.VipsConcurrency < 0 {
+		return Config{}, fmt.Errorf("invalid VIPS_CONCURRENCY %d: must not be negative", c.VipsConcurrency)
+	}
+	switch c.VerifyUploads {
+	case "", "head", "full":
+	default:
+		return Config{}, fmt.Errorf("invalid VERIFY_UPLOADS %q: must be head or full", c.VerifyUploads)
+	}
+	if c.DedupWindow < 0 {
+		return Config{}, fmt.Errorf("invalid DEDUP_WINDOW_SECONDS %d: must not be negative", int(c.DedupWindow/time.Second))
+	}
+	if c.DecodeMaxDimension < 0 || c.DecodeMaxPixels < 0 || c.DecodeMaxFrames < 0 || c.DecodeMaxRatio < 0 {
+		return Config{}, fmt.Errorf("invalid DECODE_MAX_DIMENSION/DECODE_MAX_PIXELS/DECODE_MAX_FRAMES/DECODE_MAX_RATIO: must not be negative")
+	}
+	if c.MinSourceBytes < 0 {
+		return Config{}, fmt.Errorf("invalid MIN_SOURCE_BYTES %d: must not be negative", c.MinSourceBytes)
+	}
+	if c.KeyDecoding != "auto" && c.KeyDecoding != "always" && c.KeyDecoding != "never" {
+		return Config{}, fmt.Errorf("invalid KEY_DECODING %q: must be auto, always or never", c.KeyDecoding)
+	}
+	if c.SkipBytesPerPixel < 0 {
+		return Config{}, fmt.Errorf("invalid SKIP_BYTES_PER_PIXEL %g: must not be negative", c.SkipBytesPerPixel)
+	}
+	if c.OutputNaming != "source" && c.OutputNaming != "content" {
+		return Config{}, fmt.Errorf("invalid OUTPUT_NAMING %q: must be source or content", c.OutputNaming)
+	}
+	if c.ContentManifest && c.OutputNaming != "content" {
+		return Config{}, fmt.Errorf("invalid CONTENT_MANIFEST: requires OUTPUT_NAMING=content")
+	}
+	if c.Deterministic {
+		if c.AVIFEncoder == "svt" {
+			return Config{}, fmt.Errorf("invalid AVIF_ENCODER %q: DETERMINISTIC_ENCODE requires aom", c.AVIFEncoder)
+		}
+		if c.VipsConcurrency > 1 {
+			return Config{}, fmt.Errorf("invalid VIPS_CONCURRENCY %d: DETERMINISTIC_ENCODE requires 1", c.VipsConcurrency)
+		}
+		c.AVIFEncoder = "aom"
+		c.VipsConcurrency = 1
+		c.VideoPreview.Bitexact = true
+	}
+	if c.VipsMaxCacheSize < 0 || c.VipsMaxCacheMem < 0 || c.VipsMaxCacheFiles < 0 {
+		return Config{}, fmt.Errorf("invalid VIPS_MAX_CACHE_SIZE/VIPS_MAX_CACHE_MEM_MB/VIPS_MAX_CACHE_FILES: must not be negative")
+	}
+	if !strings.HasPrefix(c.CloudFrontPathTemplate, "/") || !strings.Contains(c.CloudFrontPathTemplate, "{key}") {
+		return Config{}, fmt.Errorf("invalid CLOUDFRONT_PATH_TEMPLATE %q: must start with / and contain {key}", c.CloudFrontPathTemplate)
+	}
+	if c.MaxOutputWidth < 0 || c.MaxOutputHeight < 0 {
+		return Config{}, fmt.Errorf("invalid MAX_OUTPUT_WIDTH/MAX_OUTPUT_HEIGHT: must not be negative")
+	}
+	if c.MemoryLeakStreak < 0 {
+		return Config{}, fmt.Errorf("invalid MEMORY_LEAK_STREAK %d: must not be negative", c.MemoryLeakStreak)
+	}
+	if c.CostPerGBSecond < 0 || c.CostPerInvocation < 0 || c.CostPerS3Get < 0 || c.CostPerS3Put < 0 {
+		return Config{}, fmt.Errorf("invalid COST_PER_GB_SECOND/COST_PER_INVOCATION/COST_PER_S3_GET/COST_PER_S3_PUT: must not be negative")
+	}
+	if c.ReportPrefixDepth < 0 {
+		return Config{}, fmt.Errorf("invalid REPORT_PREFIX_DEPTH %d: must not be negative", c.ReportPrefixDepth)
+	}
+	if c.APIRequestTimeout <= 0 {
+		return Config{}, fmt.Errorf("invalid API_REQUEST_TIMEOUT_SECONDS %d: must be positive", int(c.APIRequestTimeout/time.Second))
+	}
+	switch c.RunMode {
+	case "lambda", "worker":
+	case "sqs":
+		if c.SQSQueueURL == "" {
+			return Config{}, fmt.Errorf("invalid RUN_MODE sqs: SQS_QUEUE_URL is required")
+		}
+		if c.SQSBatchSize < 1 || c.SQSBatchSize > 10 {
+			return Config{}, fmt.Errorf("invalid SQS_BATCH_SIZE %d: must be between 1 and 10", c.SQSBatchSize)
+		}
+		// SQS 가시성 제한 시간은 최대 12시간입니다.
+		if c.SQSVisibilityTimeout <= 0 || c.SQSVisibilityTimeout > 12*time.Hour {
+			return Config{}, fmt.Errorf("invalid SQS_VISIBILITY_TIMEOUT_SECONDS %d: must be between 1 and 43200", int(c.SQSVisibilityTimeout/time.Second))
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid RUN_MODE %q: must be lambda, sqs or worker", c.RunMode)
+	}
+	if c.LogLevel != "info" && c.LogLevel != "debug" {
+		return Config{}, fmt.Errorf("invalid LOG_LEVEL %q: must be info or debug", c.LogLevel)
+	}
+	if c.VariantConcurrency < 1 {
+		return Config{}, fmt.Errorf("invalid VARIANT_CONCURRENCY %d: must be at least 1", c.VariantConcurrency)
+	}
+	if c.BatchConcurrency < 1 {
+		return Config{}, fmt.Errorf("invalid BATCH_CONCURRENCY %d: must be at least 1", c.BatchConcurrency)
+	}
+	if c.BatchItemTimeout < 0 {
+		return Config{}, fmt.Errorf("invalid BATCH_ITEM_TIMEOUT_MS %d: must not be negative", c.BatchItemTimeout.Milliseconds())
+	}
+	if c.BatchLowPriorityReserve < 0 {
+		return Config{}, fmt.Errorf("invalid BATCH_LOW_PRIORITY_RESERVE_MS %d: must not be negative", c.BatchLowPriorityReserve.Milliseconds())
+	}
+	if c.BackfillCheckpointEvery < 1 {
+		return Config{}, fmt.Errorf("invalid BACKFILL_CHECKPOINT_EVERY %d: must be positive", c.BackfillCheckpointEvery)
+	}
+	if c.GlacierRestoreTier != "" && c.GlacierRestoreTier != "Expedited" && c.GlacierRestoreTier != "Standard" && c.GlacierRestoreTier != "Bulk" {
+		return Config{}, fmt.Errorf("invalid GLACIER_RESTORE_TIER %q: must be Expedited, Standard or Bulk", c.GlacierRestoreTier)
+	}
+	if c.GlacierRestoreDays < 1 {
+		return Config{}, fmt.Errorf("invalid GLACIER_RESTORE_DAYS %d: must be positive", c.GlacierRestoreDays)
+	}
+	if c.PresignExpiry < 0 || c.PresignExpiry > 7*24*time.Hour {
+		return Config{}, fmt.Errorf("invalid PRESIGN_EXPIRY_SECONDS %d: must be between 0 and 604800", int(c.PresignExpiry/time.Second))
+	}
+	if c.GCMaxDeletes < 1 {
+		return Config{}, fmt.Errorf("invalid GC_MAX_DELETES %d: must be positive", c.GCMaxDeletes)
+	}
+	if c.TilesUploadConcurrency < 1 {
+		return Config{}, fmt.Errorf("invalid TILES_UPLOAD_CONCURRENCY %d: must be positive", c.TilesUploadConcurrency)
+	}
+	if c.UploadRateLimit < 0 || c.NotifyRateLimit < 0 {
+		return Config{}, fmt.Errorf("invalid UPLOAD_RATE_LIMIT/NOTIFY_RATE_LIMIT: must not be negative")
+	}
+	if c.UploadRateBurst < 1 || c.NotifyRateBurst < 1 {
+		return Config{}, fmt.Errorf("invalid UPLOAD_RATE_BURST/NOTIFY_RATE_BURST: must be at least 1")
+	}
+	if c.RateLimitMaxWait < 0 {
+		return Config{}, fmt.Errorf("invalid RATE_LIMIT_MAX_WAIT_MS %d: must not be negative", c.RateLimitMaxWait.Milliseconds())
+	}
+	if c.EncoderBreakerThreshold < 0 || c.EncoderBreakerCooldown < 0 {
+		return Config{}, fmt.Errorf("invalid ENCODER_BREAKER_THRESHOLD/ENCODER_BREAKER_COOLDOWN_MS: must not be negative")
+	}
+	if c.QuarantineAfter < 0 {
+		return Config{}, fmt.Errorf("invalid QUARANTINE_AFTER %d: must not be negative", c.QuarantineAfter)
+	}
+	if c.QuarantineAfter > 0 && c.QuarantinePrefix == "" {
+		return Config{}, fmt.Errorf("invalid QUARANTINE_PREFIX: must not be empty")
+	}
+	if c.ArchiveMaxEntries < 1 || c.ArchiveMaxEntryBytes < 1 || c.ArchiveMaxTotalBytes < 1 {
+		return Config{}, fmt.Errorf("invalid ARCHIVE_MAX_ENTRIES/ARCHIVE_MAX_ENTRY_MB/ARCHIVE_MAX_TOTAL_MB: must be at least 1")
+	}
+	if c.PosterSeconds < 0 {
+		return Config{}, fmt.Errorf("invalid POSTER_SECONDS %g: must not be negative", c.PosterSeconds)
+	}
+	if c.SpriteMaxFrames < 1 || c.MontageMaxImages < 1 {
+		return Config{}, fmt.Errorf("invalid SPRITE_MAX_FRAMES/MONTAGE_MAX_IMAGES: must be at least 1")
+	}
+	if p := c.VideoPreview; p.Format != "" {
+		if p.Format != "webp" && p.Format != "avif" {
+			return Config{}, fmt.Errorf("invalid VIDEO_PREVIEW_FORMAT %q: must be webp or avif", p.Format)
+		}
+		if p.Seconds <= 0 || p.Seconds > 30 || p.FPS < 1 || p.FPS > 30 || p.Width < 16 || p.Width > 1920 || p.Quality < 1 || p.Quality > 100 {
+			return Config{}, fmt.Errorf("invalid VIDEO_PREVIEW_*: seconds must be in (0, 30], fps in [1, 30], width in [16, 1920], quality in [1, 100]")
+		}
+	}
+	if c.OutputStorageClass != "" && !knownStorageClass(c.OutputStorageClass) {
+		return Config{}, fmt.Errorf("invalid OUTPUT_STORAGE_CLASS %q: unknown S3 storage class", c.OutputStorageClass)
+	}
+	otlpEndpoint := env.String("OTEL_EXPORTER_OTLP_ENDPOINT", "") + env.String("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") +
+		env.String("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	c.OTelEnabled = otlpEndpoint != "" && !env.Bool("OTEL_SDK_DISABLED", false)
+	c.PrometheusPushURL = env.String("PROMETHEUS_PUSH_URL", "")
+	c.PrometheusJob = env.String("PROMETHEUS_JOB", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"))
+	c.PrometheusListenAddr = env.String("PROMETHEUS_LISTEN_ADDR", "")
+	if c.PrometheusPushURL != "" && c.PrometheusJob == "" {
+		return Config{}, fmt.Errorf("invalid PROMETHEUS_JOB: required with PROMETHEUS_PUSH_URL outside Lambda")
+	}
+	for _, f := range strings.Split(env.String("INIT_WARMUP_FORMATS", ""), ",") {
+		switch f = strings.TrimSpace(f); f {
+		case "":
+		case "all", "avif", "webp", "jpeg", "png", "jxl":
+			c.InitWarmupFormats = append(c.InitWarmupFormats, f)
+		default:
+			return Config{}, fmt.Errorf("invalid INIT_WARMUP_FORMATS: unknown format %q", f)
+		}
+	}
+	for _, b := range strings.Split(env.String("DESTINATION_BUCKETS", ""), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			c.DestinationBuckets = append(c.DestinationBuckets, b)
+		}
+	}
+	if (c.AppConfigApplication != "" || c.AppConfigEnvironment != "" || c.AppConfigProfile != "") &&
+		(c.AppConfigApplication == "" || c.AppConfigEnvironment == "" || c.AppConfigProfile == "") {
+		return Config{}, fmt.Errorf("invalid APPCONFIG_APPLICATION, APPCONFIG_ENVIRONMENT, APPCONFIG_PROFILE: all three are required together")
+	}
+	if c.SecretsRefresh < 0 {
+		return Config{}, fmt.Errorf("invalid SECRETS_REFRESH_SECONDS %d: must not be negative", int(c.SecretsRefresh.Seconds()))
+	}
+	if c.DestinationRoleARN != "" && len(c.DestinationBuckets) == 0 {
+		return Config{}, fmt.Errorf("invalid DESTINATION_BUCKETS: must list at least one bucket with DESTINATION_ROLE_ARN")
+	}
+	if c.DestinationRoleARN == "" && len(c.DestinationBuckets) > 0 {
+		return Config{}, fmt.Errorf("invalid DESTINATION_BUCKETS: requires DESTINATION_ROLE_ARN")
+	}
+	switch c.OutputLockMode {
+	case "":
+		if c.OutputRetentionDays != 0 {
+			return Config{}, fmt.Errorf("invalid OUTPUT_RETENTION_DAYS: requires OUTPUT_OBJECT_LOCK_MODE")
+		}
+	case "GOVERNANCE", "COMPLIANCE":
+		if c.OutputRetentionDays < 1 {
+			return Config{}, fmt.Errorf("invalid OUTPUT_RETENTION_DAYS %d: must be at least 1 with OUTPUT_OBJECT_LOCK_MODE", c.OutputRetentionDays)
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid OUTPUT_OBJECT_LOCK_MODE %q: must be GOVERNANCE or COMPLIANCE", c.OutputLockMode)
+	}
+	switch c.SourceContentType {
+	case "off", "log", "fix":
+	default:
+		return Config{}, fmt.Errorf("invalid SOURCE_CONTENT_TYPE %q: must be off, log or fix", c.SourceContentType)
+	}
+	if c.OriginalsStorageClass != "" {
+		if !validStorageClass(c.OriginalsStorageClass) {
+			return Config{}, fmt.Errorf("invalid ORIGINALS_STORAGE_CLASS %q: must be a non-STANDARD S3 storage class such as STANDARD_IA or GLACIER_IR", c.OriginalsStorageClass)
+		}
+		switch c.OriginalsAction {
+		case "rewrite":
+		case "copy":
+			if c.OriginalsCopyBucket == "" && c.OriginalsCopyPrefix == "" {
+				return Config{}, fmt.Errorf("invalid ORIGINALS_COPY_PREFIX: must not be empty when copying into the source bucket")
+			}
+		default:
+			return Config{}, fmt.Errorf("invalid ORIGINALS_ACTION %q: must be rewrite or copy", c.OriginalsAction)
+		}
+	}
+	if c.DeadlineReserve < 0 {
+		return Config{}, fmt.Errorf("invalid DEADLINE_RESERVE_MS %d: must not be negative", c.DeadlineReserve.Milliseconds())
+	}
+	if c.ParallelDownloadThreshold < 0 {
+		return Config{}, fmt.Errorf("invalid PARALLEL_DOWNLOAD_THRESHOLD_MB %d: must not be negative", c.ParallelDownloadThreshold>>20)
+	}
+	if c.DownloadConcurrency < 1 {
+		return Config{}, fmt.Errorf("invalid DOWNLOAD_CONCURRENCY %d: must be at least 1", c.DownloadConcurrency)
+	}
+	if c.MultipartThreshold < 0 {
+		return Config{}, fmt.Errorf("invalid MULTIPART_THRESHOLD_MB %d: must not be negative", c.MultipartThreshold>>20)
+	}
+	if err := c.UploadChecksum.withDefaults(); err != nil {
+		return Config{}, fmt.Errorf("invalid UPLOAD_CHECKSUM_ALGORITHM/UPLOAD_CHECKSUM_TYPE: %w", err)
+	}
+	if raw := env.String("UPLOAD_CHECKSUMS", ""); raw != "" {
+		checksums, err := parseUploadChecksums([]byte(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid UPLOAD_CHECKSUMS: %w", err)
+		}
+		c.UploadChecksums = checksums
+	}
+	if c.S3EndpointURL != "" {
+		if u, err := url.Parse(c.S3EndpointURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid S3_ENDPOINT_URL %q: expected an absolute URL such as http://localhost:9000", c.S3EndpointURL)
+		}
+	}
+	if c.S3UseAccelerate && (c.S3UsePathStyle || c.S3EndpointURL != "") {
+		return Config{}, fmt.Errorf("invalid S3_USE_ACCELERATE: cannot be combined with S3_USE_PATH_STYLE or S3_ENDPOINT_URL")
+	}
+	background, err := pipeline.ParseColor(env.String("ALPHA_BACKGROUND", "#ffffff"))
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid ALPHA_BACKGROUND: %w", err)
+	}
+	c.AlphaBackground = background
+	if raw := env.String("TRANSFORM_RULES", ""); raw != "" {
+		rules, err := parseTransformRules([]byte(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid TRANSFORM_RULES: %w", err)
+		}
+		c.TransformRules = rules
+	}
+	if raw := env.String("NOTIFIERS", ""); raw != "" {
+		notifiers, err := parseNotifiers([]byte(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid NOTIFIERS: %w", err)
+		}
+		c.Notifiers = notifiers
+	}
+	if raw := env.String("TENANTS", ""); raw != "" {
+		tenants, err := parseTenants([]byte(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid TENANTS: %w", err)
+		}
+		c.Tenants = tenants
+	}
+	if raw := env.String("SKIP_RULES", ""); raw != "" {
+		rules, err := parseSkipRules([]byte(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SKIP_RULES: %w", err)
+		}
+		c.SkipRules = rules
+	}
+	if raw := env.String("PRESETS", ""); raw != "" {
+		presets, err := parsePresets([]byte(raw))
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid PRESETS: %w", err)
+		}
+		c.Presets = mergePresets(c.Presets, presets)
+	}
+	// 위의 검사 뒤에 읽은 변수(TENANTS, PRESETS 등)의 참조를 읽지 못했으면 기본값으로 시작하지 않고 실패합니다.
+	if env.err != nil {
+		return Config{}, env.err
+	}
+	c.Settings = env.settings
+	return c, nil
+}
+
+// envReader는 환경 변수를 타입별로 읽으며, 처음 발생한 파싱 오류를 기억합니다.
+// secrets가 있으면 ssm:/secretsmanager: 참조 값을 실제 값으로 바꿔 읽습니다.
+// settings에는 설정된 변수의 원래 값(참조는 참조 그대로)을 모읍니다.
+type envReader struct {
+	ctx      context.Context
+	err      error
+	secrets  *secretResolver
+	settings map[string]string
+}
+
+func (r *envReader) lookup(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	v = strings.TrimSpace(v)
+	if ok && v != "" {
+		if r.settings == nil {
+			r.settings = map[string]string{}
+		}
+		r.settings[key] = v
+	}
+	if ok && r.secrets != nil && isSecretRef(v) {
+		resolved, err := r.secrets.Resolve(r.ctx, v)
+		if err != nil {
+			r.fail(key, v, err)
+			return "", false
+		}
+		v = strings.TrimSpace(resolved)
+	}
+	return v, ok && v != ""
+}
+
+func (r *envReader) String(key, def string) string {
+	if v, ok := r.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+func (r *envReader) Int(key string, def int) int {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		r.fail(key, v, err)
+		return def
+	}
+	return n
+}
+
+func (r *envReader) Float(key string, def float64) float64 {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		r.fail(key, v, err)
+		return def
+	}
+	return f
+}
+
+func (r *envReader) Bool(key string, def bool) bool {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.fail(key, v, err)
+		return def
+	}
+	return b
+}
+
+func (r *envReader) fail(key, value string, err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+}
@@ -0,0 +1,93 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// contentHashLength는 내용 주소 키에 쓰는 SHA-256 16진 문자열의 길이(64비트)입니다.
+const contentHashLength = 16
+
+// UseContentAddressedKeys는 출력 키를 출력 바이트의 SHA-256으로 바꾸는 미들웨어를 등록합니다.
+// 키를 바꾸므로 키를 보고 동작하는 다른 PreUpload 미들웨어(CDN 무효화 등)보다 먼저 등록해야 합니다.
+func (h *Handler) UseContentAddressedKeys() {
+	h.hooks.Use(&contentAddresser{
+		prefix:   h.conf.ContentKeyPrefix,
+		manifest: h.conf.ContentManifest,
+		put:      h.putObject,
+	})
+}
+
+// contentAddresser는 출력 키를 <prefix><해시 앞 2자>/<해시><접미사><확장자>로 바꿉니다.
+// 예: photos/cat.jpg의 _w512 AVIF 출력 → thumbs/ab/abcd1234ef567890_w512.avif
+// 같은 바이트는 항상 같은 키가 되므로 CDN 캐시를 무기한 둘 수 있고, 중복 출력은 같은 객체를 덮어쓸 뿐입니다.
+// manifest가 켜져 있으면 변환이 끝난 뒤 원래 키와 내용 주소 키의 대응을 <기준 키>.manifest.json으로 올립니다.
+type contentAddresser struct {
+	prefix   string
+	manifest bool
+	put      func(ctx context.Context, bucket, key, contentType string, buf []byte) error
+}
+
+func (c *contentAddresser) PreUpload(ctx context.Context, job *Job, upload *Upload) error {
+	sum := sha256.Sum256(upload.Body)
+	upload.SHA256 = hex.EncodeToString(sum[:])
+	upload.LogicalKey = upload.Key
+	upload.Key = contentKey(c.prefix, upload.SHA256, variantSuffix(job.BaseKey, upload.Key), keyExtension(upload.Key))
+	return nil
+}
+
+// contentManifest는 <기준 키>.manifest.json의 내용입니다. outputs는 원래 키별 내용 주소 출력입니다.
+type contentManifest struct {
+	OriginalKey string                        `json:"originalKey"`
+	Outputs     map[string]contentManifestRef `json:"outputs"`
+}
+
+type contentManifestRef struct {
+	Key    string `json:"key"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	Format string `json:"format"`
+}
+
+func (c *contentAddresser) PostConvert(ctx context.Context, job *Job) error {
+	if !c.manifest {
+		return nil
+	}
+	manifest := contentManifest{OriginalKey: job.SrcKey, Outputs: map[string]contentManifestRef{}}
+	for _, output := range job.Result.Outputs {
+		if output.LogicalKey != "" {
+			manifest.Outputs[output.LogicalKey] = contentManifestRef{Key: output.Key, SHA256: output.SHA256, Size: output.Size, Format: output.Format}
+		}
+	}
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode content manifest: %w", err)
+	}
+	key := replaceExtension(job.BaseKey, ".manifest.json")
+	if err := c.put(ctx, job.OutputBucket, key, "application/json", body); err != nil {
+		return fmt.Errorf("failed to upload content manifest %s: %w", key, err)
+	}
+	job.Result.Manifest = key
+	log.Printf("Uploaded content manifest with %d outputs: key=%s", len(manifest.Outputs), key)
+	return nil
+}
+
+func contentKey(prefix, sha, suffix, ext string) string {
+	hash := sha[:contentHashLength]
+	return prefix + hash[:2] + "/" + hash + suffix + ext
+}
+
+// variantSuffix는 출력 키에서 기준 키 뒤에 붙은 부분(_w512, .preview 등)입니다. 기준 키로 시작하지 않으면 빈 문자열입니다.
+func variantSuffix(baseKey, key string) string {
+	base := strings.TrimSuffix(baseKey, keyExtension(baseKey))
+	name := strings.TrimSuffix(key, keyExtension(key))
+	if suffix, ok := strings.CutPrefix(name, base); ok {
+		return suffix
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package converter
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// CostEstimate는 변환 한 건의 대략적인 비용입니다. 가격은 COST_* 설정을 따르며
+// 데이터 전송·스토리지 비용은 포함하지 않습니다.
+type CostEstimate struct {
+	MemoryMB      int     `json:"memoryMB"`
+	BilledMs      int64   `json:"billedMs"`
+	GBSeconds     float64 `json:"gbSeconds"`
+	S3GetRequests int64   `json:"s3GetRequests"` // GET, HEAD
+	S3PutRequests int64   `json:"s3PutRequests"` // PUT, 멀티파트 시작/파트/완료
+	ComputeUSD    float64 `json:"computeUSD"`
+	S3RequestsUSD float64 `json:"s3RequestsUSD"`
+	InvocationUSD float64 `json:"invocationUSD"`
+	TotalUSD      float64 `json:"totalUSD"`
+}
+
+// requestCounter는 호출 하나에서 보낸 S3 요청 수입니다.
+type requestCounter struct {
+	get atomic.Int64
+	put atomic.Int64
+}
+
+type requestCounterKey struct{}
+
+// withRequestCounter는 S3 요청 수를 셀 카운터를 컨텍스트에 붙입니다.
+func withRequestCounter(ctx context.Context) (context.Context, *requestCounter) {
+	c := &requestCounter{}
+	return context.WithValue(ctx, requestCounterKey{}, c), c
+}
+
+func countGet(ctx context.Context) {
+	if c, ok := ctx.Value(requestCounterKey{}).(*requestCounter); ok {
+		c.get.Add(1)
+	}
+}
+
+func countPut(ctx context.Context) {
+	if c, ok := ctx.Value(requestCounterKey{}).(*requestCounter); ok {
+		c.put.Add(1)
+	}
+}
+
+// countingS3는 S3API 호출을 컨텍스트의 requestCounter에 기록합니다.
+type countingS3 struct {
+	S3API
+}
+
+func (c countingS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	countGet(ctx)
+	return c.S3API.GetObject(ctx, params, optFns...)
+}
+
+func (c countingS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	countGet(ctx)
+	return c.S3API.HeadObject(ctx, params, optFns...)
+}
+
+func (c countingS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	countPut(ctx)
+	return c.S3API.PutObject(ctx, params, optFns...)
+}
+
+func (c countingS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	countPut(ctx)
+	return c.S3API.CreateMultipartUpload(ctx, params, optFns...)
+}
+
+func (c countingS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	countPut(ctx)
+	return c.S3API.UploadPart(ctx, params, optFns...)
+}
+
+func (c countingS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	countPut(ctx)
+	return c.S3API.CompleteMultipartUpload(ctx, params, optFns...)
+}
+
+func (c countingS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	countPut(ctx)
+	return c.S3API.CopyObject(ctx, params, optFns...)
+}
+
+func (c countingS3) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	countGet(ctx)
+	return c.S3API.GetObjectTagging(ctx, params, optFns...)
+}
+
+func (c countingS3) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	countPut(ctx)
+	return c.S3API.PutObjectTagging(ctx, params, optFns...)
+}
+
+// ListObjectsV2는 PUT과 같은 요금 등급이므로 PUT으로 셉니다.
+func (c countingS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	countPut(ctx)
+	return c.S3API.ListObjectsV2(ctx, params, optFns...)
+}
+
+// defaultMemoryMB는 AWS_LAMBDA_FUNCTION_MEMORY_SIZE가 없을 때(로컬 실행 등) 쓰는 값입니다.
+const defaultMemoryMB = 1024
+
+// estimateCost는 실행 시간, 메모리 크기, S3 요청 수로 호출 비용을 어림합니다.
+// 청구 시간은 1ms 단위로 올림하며, 콜드 스타트 초기화 시간은 포함하지 않습니다.
+func estimateCost(elapsed time.Duration, counter *requestCounter, c Config) CostEstimate {
+	est := CostEstimate{MemoryMB: defaultMemoryMB}
+	if mb, err := strconv.Atoi(os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE")); err == nil && mb > 0 {
+		est.MemoryMB = mb
+	}
+	est.BilledMs = int64(math.Ceil(float64(elapsed) / float64(time.Millisecond)))
+	est.GBSeconds = float64(est.MemoryMB) / 1024 * float64(est.BilledMs) / 1000
+	est.S3GetRequests = counter.get.Load()
+	est.S3PutRequests = counter.put.Load()
+
+	est.ComputeUSD = est.GBSeconds * c.CostPerGBSecond
+	est.S3RequestsUSD = float64(est.S3GetRequests)*c.CostPerS3Get + float64(est.S3PutRequests)*c.CostPerS3Put
+	est.InvocationUSD = c.CostPerInvocation
+	est.TotalUSD = est.ComputeUSD + est.S3RequestsUSD + est.InvocationUSD
+	return est
+}
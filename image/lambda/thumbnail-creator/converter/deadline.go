@@ -0,0 +1,61 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// uploadMargin은 Lambda 제한 시간보다 먼저 업로드 요청을 끊기 위한 여유 시간입니다.
+// 실행 환경이 강제로 종료되기 전에 오류를 돌려줄 수 있게 합니다.
+const uploadMargin = 500 * time.Millisecond
+
+// TimeoutBudgetExceeded는 남은 실행 시간이 부족해 변환을 중단했음을 나타냅니다.
+// 원본과 이미 올라간 출력은 그대로이므로 같은 이벤트로 다시 시도해도 안전합니다.
+// Lambda 오류 응답의 errorType이 타입 이름이 되므로 재시도 정책에서 이 이름으로 구분할 수 있습니다.
+type TimeoutBudgetExceeded struct {
+	Phase     string
+	Remaining time.Duration
+	Reserve   time.Duration
+}
+
+func (e *TimeoutBudgetExceeded) Error() string {
+	return fmt.Sprintf("TIMEOUT_BUDGET_EXCEEDED: %s remaining before %s, at least %s required", e.Remaining.Round(time.Millisecond), e.Phase, e.Reserve)
+}
+
+// checkBudget은 다음 단계를 시작하기 전에 남은 시간이 DEADLINE_RESERVE_MS 이상인지 확인합니다.
+// 인코딩은 cgo 호출이라 중간에 멈출 수 없으므로 시작 전에 판단해야 합니다.
+func (h *Handler) checkBudget(ctx context.Context, phase string) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	remaining := deadline.Sub(h.clock.Now())
+	if remaining < h.conf.DeadlineReserve {
+		return &TimeoutBudgetExceeded{Phase: phase, Remaining: remaining, Reserve: h.conf.DeadlineReserve}
+	}
+	return nil
+}
+
+// uploadContext는 Lambda 제한 시간보다 uploadMargin만큼 먼저 끝나는 컨텍스트입니다.
+func uploadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withMargin(ctx, uploadMargin)
+}
+
+// withMargin은 ctx의 제한 시간보다 margin만큼 먼저 끝나는 컨텍스트입니다. 제한 시간이 없으면 취소만 물려받습니다.
+func withMargin(ctx context.Context, margin time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
+
+// asBudgetError는 업로드가 제한 시간 때문에 끊긴 경우 TimeoutBudgetExceeded로 바꿉니다.
+func asBudgetError(phase string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &TimeoutBudgetExceeded{Phase: phase, Reserve: uploadMargin}
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// DebugRequest는 이벤트의 debug 설정입니다. 있으면 이 호출만 LOG_LEVEL=debug처럼 자세한 로그를 남깁니다.
+type DebugRequest struct {
+	// Artifacts이면 중간 결과(이벤트, 디코딩 직후 PNG 스냅숏, 인코딩 매개변수)를
+	// 출력 버킷의 DEBUG_PREFIX/<요청 ID>/ 아래에 올립니다.
+	Artifacts bool `json:"artifacts,omitempty"`
+}
+
+type debugKey struct{}
+
+// withDebug는 이 호출의 자세한 로그를 켠 컨텍스트를 돌려줍니다.
+func withDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// debugEnabled는 LOG_LEVEL=debug이거나 이벤트가 debug를 요청했는지 돌려줍니다.
+func debugEnabled(ctx context.Context) bool {
+	on, _ := ctx.Value(debugKey{}).(bool)
+	return on
+}
+
+// debugf는 자세한 로그가 켜진 호출에서만 로그를 남깁니다.
+func debugf(ctx context.Context, format string, args ...any) {
+	if debugEnabled(ctx) {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
+
+// debugParams는 encode-params.json의 내용입니다.
+type debugParams struct {
+	Loader       string        `json:"loader"`
+	Width        int           `json:"width"`
+	Height       int           `json:"height"`
+	OutputFormat string        `json:"outputFormat"`
+	Graphics     string        `json:"graphics"`
+	Color        string        `json:"color,omitempty"`
+	Alpha        string        `json:"alpha,omitempty"`
+	Options      EncodeOptions `json:"options"`
+	Preset       *Preset       `json:"preset,omitempty"`
+	Pipeline     int           `json:"pipelineSteps"`
+}
+
+// debugArtifact는 debug.artifacts를 요청한 호출에서 중간 결과 하나를 올립니다.
+// 디버그용이므로 실패해도 변환을 멈추지 않고 경고만 남깁니다.
+func (h *Handler) debugArtifact(ctx context.Context, job *Job, name, contentType string, body []byte) {
+	if job.Event.Debug == nil || !job.Event.Debug.Artifacts {
+		return
+	}
+	id := fmt.Sprintf("%d", job.Started.UnixNano())
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		id = lc.AwsRequestID
+	}
+	key := h.conf.DebugPrefix + id + "/" + name
+	if err := h.putObject(ctx, job.OutputBucket, key, contentType, body); err != nil {
+		log.Printf("Warning: failed to upload debug artifact %s: %v", key, err)
+		return
+	}
+	job.mu.Lock()
+	job.Result.DebugArtifacts = append(job.Result.DebugArtifacts, key)
+	job.mu.Unlock()
+	log.Printf("Uploaded debug artifact: key=%s, size=%d bytes", key, len(body))
+}
+
+// debugJSON은 v를 들여쓴 JSON 아티팩트로 올립니다.
+func (h *Handler) debugJSON(ctx context.Context, job *Job, name string, v any) {
+	if job.Event.Debug == nil || !job.Event.Debug.Artifacts {
+		return
+	}
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("Warning: failed to encode debug artifact %s: %v", name, err)
+		return
+	}
+	h.debugArtifact(ctx, job, name, "application/json", body)
+}
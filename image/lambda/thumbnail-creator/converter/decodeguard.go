@@ -0,0 +1,227 @@
+package converter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+)
+
+// decodeRatioMinBytes 미만으로 풀리는 이미지는 압축률이 높아도 메모리를 위협하지 않으므로 DECODE_MAX_RATIO를 보지 않습니다.
+const decodeRatioMinBytes = 64 << 20
+
+// imageHeader는 픽셀을 디코딩하지 않고 헤더에서 읽은 선언 값입니다.
+type imageHeader struct {
+	Format string
+	Width  int
+	Height int
+	// Frames는 애니메이션 프레임 수입니다. 정지 이미지는 1이며, 한도를 넘으면 한도+1에서 세기를 멈춥니다.
+	Frames int
+	// BytesPerPixel은 libvips가 디코딩한 한 픽셀의 크기 추정치입니다.
+	BytesPerPixel float64
+}
+
+// DecodedBytes는 한 프레임을 디코딩했을 때의 메모리 크기 추정치입니다.
+func (h imageHeader) DecodedBytes() float64 {
+	return float64(h.Width) * float64(h.Height) * h.BytesPerPixel
+}
+
+// checkDecodeLimits는 디코딩 전에 PNG/APNG, GIF, JPEG, WebP 헤더를 읽어 선언된 크기, 프레임 수,
+// 압축 해제 비율이 한도를 넘으면 REJECTED_DECODE_LIMIT로 건너뜁니다. 작은 파일에 거대한 크기를 선언하거나
+// 프레임이 수천 개인 악성 이미지가 메모리를 다 쓰기 전에 막습니다. 헤더를 읽지 못한 포맷은 libvips에 맡깁니다.
+// 다시 시도해도 결과가 같으므로 오류가 아닌 상태로 돌려줍니다.
+func (h *Handler) checkDecodeLimits(job *Job) error {
+	header, ok := inspectHeader(job.Source, h.conf.DecodeMaxFrames)
+	if !ok {
+		return nil
+	}
+	c := h.conf
+	var reason string
+	switch {
+	case c.DecodeMaxDimension > 0 && (header.Width > c.DecodeMaxDimension || header.Height > c.DecodeMaxDimension):
+		reason = fmt.Sprintf("declared %dx%d exceeds DECODE_MAX_DIMENSION %d", header.Width, header.Height, c.DecodeMaxDimension)
+	case c.DecodeMaxPixels > 0 && int64(header.Width)*int64(header.Height) > c.DecodeMaxPixels:
+		reason = fmt.Sprintf("declared %dx%d (%d pixels) exceeds DECODE_MAX_PIXELS %d", header.Width, header.Height, int64(header.Width)*int64(header.Height), c.DecodeMaxPixels)
+	case c.DecodeMaxFrames > 0 && header.Frames > c.DecodeMaxFrames:
+		reason = fmt.Sprintf("more than %d frames (DECODE_MAX_FRAMES)", c.DecodeMaxFrames)
+	case c.DecodeMaxRatio > 0 && header.DecodedBytes() >= decodeRatioMinBytes && header.DecodedBytes()/float64(len(job.Source)) > c.DecodeMaxRatio:
+		reason = fmt.Sprintf("decompression ratio %.0f:1 (%d bytes to %.0f bytes) exceeds DECODE_MAX_RATIO %g", header.DecodedBytes()/float64(len(job.Source)), len(job.Source), header.DecodedBytes(), c.DecodeMaxRatio)
+	}
+	if reason == "" {
+		return nil
+	}
+	log.Printf("Rejected %s %s before decoding: %s", header.Format, job.SrcKey, reason)
+	emitMetrics(c.MetricsNamespace, "Count", map[string]float64{"DecodeLimitRejected": 1})
+	return skip(StatusRejectedDecodeLimit, fmt.Sprintf("%s header %s. Skipping conversion.", header.Format, reason))
+}
+
+// inspectHeader는 알려진 포맷의 헤더를 읽습니다. 프레임은 maxFrames+1개까지만 셉니다(0이면 끝까지).
+func inspectHeader(data []byte, maxFrames int) (imageHeader, bool) {
+	switch {
+	case len(data) >= 24 && string(data[:8]) == "\x89PNG\r\n\x1a\n":
+		return inspectPNG(data, maxFrames)
+	case len(data) >= 13 && (string(data[:6]) == "GIF87a" || string(data[:6]) == "GIF89a"):
+		return inspectGIF(data, maxFrames)
+	case len(data) >= 4 && data[0] == 0xff && data[1] == 0xd8:
+		return inspectJPEG(data)
+	case len(data) >= 30 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return inspectWebP(data, maxFrames)
+	}
+	return imageHeader{}, false
+}
+
+// inspectPNG는 IHDR의 크기와 색 형식, APNG acTL의 프레임 수를 읽습니다.
+func inspectPNG(data []byte, maxFrames int) (imageHeader, bool) {
+	if len(data) < 29 || string(data[12:16]) != "IHDR" {
+		return imageHeader{}, false
+	}
+	channels := map[byte]float64{0: 1, 2: 3, 3: 3, 4: 2, 6: 4}[data[25]]
+	if channels == 0 {
+		return imageHeader{}, false
+	}
+	depth := float64(data[24])
+	if depth == 0 {
+		return imageHeader{}, false
+	}
+	if data[25] == 3 {
+		// 팔레트 이미지는 RGB(A)로 풀립니다.
+		depth = 8
+	}
+	header := imageHeader{
+		Format:        "png",
+		Width:         int(binary.BigEndian.Uint32(data[16:20])),
+		Height:        int(binary.BigEndian.Uint32(data[20:24])),
+		Frames:        1,
+		BytesPerPixel: channels * depth / 8,
+	}
+	for pos := 8; pos+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunk := string(data[pos+4 : pos+8])
+		if chunk == "IDAT" {
+			break
+		}
+		if chunk == "acTL" {
+			header.Frames = int(binary.BigEndian.Uint32(data[pos+8 : pos+12]))
+			break
+		}
+		pos += 12 + length
+	}
+	if maxFrames > 0 && header.Frames > maxFrames {
+		header.Frames = maxFrames + 1
+	}
+	return header, true
+}
+
+// inspectGIF는 논리 화면 크기를 읽고, 블록을 건너뛰며 이미지 기술자(프레임) 수를 셉니다.
+// 프레임 크기가 화면보다 크면 큰 쪽을 크기로 봅니다.
+func inspectGIF(data []byte, maxFrames int) (imageHeader, bool) {
+	header := imageHeader{
+		Format:        "gif",
+		Width:         int(binary.LittleEndian.Uint16(data[6:8])),
+		Height:        int(binary.LittleEndian.Uint16(data[8:10])),
+		BytesPerPixel: 4,
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	skipSubBlocks := func() {
+		for pos < len(data) && data[pos] != 0 {
+			pos += int(data[pos]) + 1
+		}
+		pos++
+	}
+	for pos < len(data) && (maxFrames == 0 || header.Frames <= maxFrames) {
+		switch data[pos] {
+		case 0x2c:
+			if pos+10 > len(data) {
+				return header, header.Frames > 0
+			}
+			header.Frames++
+			header.Width = max(header.Width, int(binary.LittleEndian.Uint16(data[pos+5:pos+7])))
+			header.Height = max(header.Height, int(binary.LittleEndian.Uint16(data[pos+7:pos+9])))
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			// LZW 최소 코드 크기 바이트 뒤에 이미지 데이터 하위 블록이 이어집니다.
+			pos++
+			skipSubBlocks()
+		case 0x21:
+			pos += 2
+			skipSubBlocks()
+		default:
+			// 0x3b(종료) 또는 손상된 블록
+			return header, header.Frames > 0
+		}
+	}
+	return header, header.Frames > 0
+}
+
+// inspectJPEG는 SOFn 세그먼트의 크기와 성분 수를 읽습니다.
+func inspectJPEG(data []byte) (imageHeader, bool) {
+	for pos := 2; pos+9 < len(data); {
+		if data[pos] != 0xff {
+			return imageHeader{}, false
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			pos++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc {
+			if data[pos+9] == 0 {
+				// 성분이 없는 프레임은 libjpeg도 읽지 못합니다.
+				return imageHeader{}, false
+			}
+			return imageHeader{
+				Format:        "jpeg",
+				Height:        int(binary.BigEndian.Uint16(data[pos+5 : pos+7])),
+				Width:         int(binary.BigEndian.Uint16(data[pos+7 : pos+9])),
+				Frames:        1,
+				BytesPerPixel: float64(data[pos+9]),
+			}, true
+		}
+		if marker == 0xda {
+			// 스캔을 만나기 전에 SOF가 없으면 읽지 않습니다.
+			return imageHeader{}, false
+		}
+		pos += 2 + length
+	}
+	return imageHeader{}, false
+}
+
+// inspectWebP는 VP8X 캔버스(또는 VP8/VP8L 비트스트림) 크기와 ANMF 프레임 수를 읽습니다.
+func inspectWebP(data []byte, maxFrames int) (imageHeader, bool) {
+	header := imageHeader{Format: "webp", Frames: 1, BytesPerPixel: 4}
+	switch chunk := data[12:16]; string(chunk) {
+	case "VP8X":
+		header.Width = 1 + (int(data[24]) | int(data[25])<<8 | int(data[26])<<16)
+		header.Height = 1 + (int(data[27]) | int(data[28])<<8 | int(data[29])<<16)
+	case "VP8 ":
+		header.Width = int(binary.LittleEndian.Uint16(data[26:28]) & 0x3fff)
+		header.Height = int(binary.LittleEndian.Uint16(data[28:30]) & 0x3fff)
+		header.BytesPerPixel = 3
+		return header, true
+	case "VP8L":
+		bits := binary.LittleEndian.Uint32(data[21:25])
+		header.Width = 1 + int(bits&0x3fff)
+		header.Height = 1 + int(bits>>14&0x3fff)
+		return header, true
+	default:
+		return imageHeader{}, false
+	}
+	frames := 0
+	for pos := 12; pos+8 <= len(data) && (maxFrames == 0 || frames <= maxFrames); {
+		if string(data[pos:pos+4]) == "ANMF" {
+			frames++
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		pos += 8 + size + size&1
+	}
+	if frames > 0 {
+		header.Frames = frames
+	}
+	return header, true
+}
@@ -0,0 +1,198 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	// 헤더 검사는 CRC를 보지 않습니다.
+	return append(chunk, 0, 0, 0, 0)
+}
+
+// testPNG는 IHDR 뒤에 frames가 0보다 크면 acTL을 두고 빈 IDAT로 끝나는 PNG입니다.
+func testPNG(width, height uint32, depth, colorType byte, frames uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, depth, colorType, 0, 0, 0)
+	data := append([]byte("\x89PNG\r\n\x1a\n"), pngChunk("IHDR", ihdr)...)
+	if frames > 0 {
+		data = append(data, pngChunk("acTL", binary.BigEndian.AppendUint32(make([]byte, 0, 8), frames))...)
+		data = append(data, 0, 0, 0, 0)
+	}
+	return append(data, pngChunk("IDAT", nil)...)
+}
+
+// testGIF는 width×height 화면에 frames 크기의 프레임을 차례로 둔 GIF입니다. 픽셀 데이터는 빈 하위 블록입니다.
+func testGIF(width, height uint16, frames ...[2]uint16) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, width)
+	data = binary.LittleEndian.AppendUint16(data, height)
+	data = append(data, 0, 0, 0)
+	// 그래픽 제어 확장은 건너뛰어야 하는 블록입니다.
+	data = append(data, 0x21, 0xf9, 4, 0, 10, 0, 0, 0)
+	for _, f := range frames {
+		data = append(data, 0x2c, 0, 0, 0, 0)
+		data = binary.LittleEndian.AppendUint16(data, f[0])
+		data = binary.LittleEndian.AppendUint16(data, f[1])
+		data = append(data, 0, 2, 1, 0, 0)
+	}
+	return append(data, 0x3b)
+}
+
+// testJPEG는 APP0, DHT 뒤에 marker의 SOF 세그먼트를 둔 JPEG입니다. marker가 0xda이면 SOF 없이 스캔이 시작됩니다.
+func testJPEG(marker byte, width, height uint16, components byte) []byte {
+	data := []byte{0xff, 0xd8, 0xff, 0xe0, 0, 16}
+	data = append(data, "JFIF\x00"...)
+	data = append(data, make([]byte, 9)...)
+	data = append(data, 0xff, 0xc4, 0, 3, 0)
+	data = append(data, 0xff, marker, 0, 8+3*components, 8)
+	data = binary.BigEndian.AppendUint16(data, height)
+	data = binary.BigEndian.AppendUint16(data, width)
+	data = append(data, components)
+	return append(data, make([]byte, 3*int(components))...)
+}
+
+// testWebP는 chunk 하나와 그 뒤의 ANMF 프레임 frames개로 된 WebP입니다.
+func testWebP(chunk string, payload []byte, frames int) []byte {
+	body := []byte("WEBP")
+	body = append(body, chunk...)
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(payload)))
+	body = append(body, payload...)
+	for range frames {
+		body = append(body, "ANMF"...)
+		body = binary.LittleEndian.AppendUint32(body, 16)
+		body = append(body, make([]byte, 16)...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+func vp8xPayload(width, height int) []byte {
+	payload := []byte{0x02, 0, 0, 0}
+	payload = append(payload, byte(width-1), byte((width-1)>>8), byte((width-1)>>16))
+	return append(payload, byte(height-1), byte((height-1)>>8), byte((height-1)>>16))
+}
+
+func TestInspectHeader(t *testing.T) {
+	vp8 := append([]byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a}, binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(nil, 640), 480)...)
+	vp8l := append([]byte{0x2f}, binary.LittleEndian.AppendUint32(nil, uint32(99)|uint32(49)<<14)...)
+	tests := []struct {
+		name      string
+		data      []byte
+		maxFrames int
+		want      imageHeader
+		wantOK    bool
+	}{
+		{name: "png rgba", data: testPNG(32, 32, 8, 6, 0), want: imageHeader{Format: "png", Width: 32, Height: 32, Frames: 1, BytesPerPixel: 4}, wantOK: true},
+		{name: "png 16-bit gray", data: testPNG(100, 50, 16, 0, 0), want: imageHeader{Format: "png", Width: 100, Height: 50, Frames: 1, BytesPerPixel: 2}, wantOK: true},
+		{name: "png palette", data: testPNG(10, 10, 2, 3, 0), want: imageHeader{Format: "png", Width: 10, Height: 10, Frames: 1, BytesPerPixel: 3}, wantOK: true},
+		{name: "png huge declared size", data: testPNG(1<<31, 1<<31, 8, 2, 0), want: imageHeader{Format: "png", Width: 1 << 31, Height: 1 << 31, Frames: 1, BytesPerPixel: 3}, wantOK: true},
+		{name: "apng frames", data: testPNG(8, 8, 8, 6, 12), want: imageHeader{Format: "png", Width: 8, Height: 8, Frames: 12, BytesPerPixel: 4}, wantOK: true},
+		{name: "apng frames capped", data: testPNG(8, 8, 8, 6, 5000), maxFrames: 100, want: imageHeader{Format: "png", Width: 8, Height: 8, Frames: 101, BytesPerPixel: 4}, wantOK: true},
+		{name: "png invalid color type", data: testPNG(8, 8, 8, 5, 0)},
+		{name: "png fixture", data: readFixture(t, "palette-alpha.png"), want: imageHeader{Format: "png", Width: 32, Height: 32, Frames: 1, BytesPerPixel: 3}, wantOK: true},
+
+		{name: "gif single frame", data: testGIF(24, 16, [2]uint16{24, 16}), want: imageHeader{Format: "gif", Width: 24, Height: 16, Frames: 1, BytesPerPixel: 4}, wantOK: true},
+		{name: "gif frame larger than screen", data: testGIF(10, 10, [2]uint16{10, 10}, [2]uint16{60000, 20}), want: imageHeader{Format: "gif", Width: 60000, Height: 20, Frames: 2, BytesPerPixel: 4}, wantOK: true},
+		{name: "gif frames capped", data: testGIF(4, 4, [2]uint16{4, 4}, [2]uint16{4, 4}, [2]uint16{4, 4}, [2]uint16{4, 4}), maxFrames: 2, want: imageHeader{Format: "gif", Width: 4, Height: 4, Frames: 3, BytesPerPixel: 4}, wantOK: true},
+		{name: "gif without frames", data: testGIF(4, 4)},
+		{name: "gif fixture", data: readFixture(t, "animated.gif"), want: imageHeader{Format: "gif", Width: 24, Height: 16, Frames: 3, BytesPerPixel: 4}, wantOK: true},
+
+		{name: "jpeg progressive", data: testJPEG(0xc2, 4000, 3000, 3), want: imageHeader{Format: "jpeg", Width: 4000, Height: 3000, Frames: 1, BytesPerPixel: 3}, wantOK: true},
+		{name: "jpeg without sof", data: testJPEG(0xda, 4000, 3000, 3)},
+		{name: "jpeg fixture", data: readFixture(t, "photo.jpg"), want: imageHeader{Format: "jpeg", Width: 64, Height: 48, Frames: 1, BytesPerPixel: 3}, wantOK: true},
+		{name: "jpeg cmyk fixture", data: readFixture(t, "cmyk.jpg"), want: imageHeader{Format: "jpeg", Width: 150, Height: 103, Frames: 1, BytesPerPixel: 4}, wantOK: true},
+
+		{name: "webp vp8x", data: testWebP("VP8X", vp8xPayload(20000, 300), 0), want: imageHeader{Format: "webp", Width: 20000, Height: 300, Frames: 1, BytesPerPixel: 4}, wantOK: true},
+		{name: "webp animated", data: testWebP("VP8X", vp8xPayload(16, 16), 7), want: imageHeader{Format: "webp", Width: 16, Height: 16, Frames: 7, BytesPerPixel: 4}, wantOK: true},
+		{name: "webp animated capped", data: testWebP("VP8X", vp8xPayload(16, 16), 7), maxFrames: 3, want: imageHeader{Format: "webp", Width: 16, Height: 16, Frames: 4, BytesPerPixel: 4}, wantOK: true},
+		{name: "webp lossy", data: testWebP("VP8 ", vp8, 0), want: imageHeader{Format: "webp", Width: 640, Height: 480, Frames: 1, BytesPerPixel: 3}, wantOK: true},
+		{name: "webp lossless", data: testWebP("VP8L", append(vp8l, make([]byte, 8)...), 0), want: imageHeader{Format: "webp", Width: 100, Height: 50, Frames: 1, BytesPerPixel: 4}, wantOK: true},
+		{name: "webp unknown chunk", data: testWebP("ICCP", make([]byte, 20), 0)},
+		{name: "webp fixture", data: readFixture(t, "alpha.webp"), want: imageHeader{Format: "webp", Width: 16, Height: 16, Frames: 1, BytesPerPixel: 4}, wantOK: true},
+
+		{name: "unknown format", data: bytes.Repeat([]byte{0}, 64)},
+		{name: "truncated png", data: []byte("\x89PNG\r\n\x1a\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := inspectHeader(tt.data, tt.maxFrames)
+			if ok != tt.wantOK {
+				t.Fatalf("inspectHeader ok = %t, want %t (header %+v)", ok, tt.wantOK, got)
+			}
+			if ok && got != tt.want {
+				t.Errorf("inspectHeader = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDecodeLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		conf     Config
+		rejected bool
+	}{
+		{name: "panorama within pixels", data: testJPEG(0xc0, 30000, 2000, 3), conf: Config{DecodeMaxPixels: 100_000_000}},
+		{name: "dimension limit", data: testJPEG(0xc0, 30000, 2000, 3), conf: Config{DecodeMaxDimension: 16384}, rejected: true},
+		{name: "pixel limit", data: testPNG(20000, 20000, 8, 6, 0), conf: Config{DecodeMaxPixels: 100_000_000}, rejected: true},
+		{name: "frame limit", data: testPNG(8, 8, 8, 6, 5000), conf: Config{DecodeMaxFrames: 1000}, rejected: true},
+		{name: "ratio limit", data: testPNG(10000, 10000, 8, 6, 0), conf: Config{DecodeMaxRatio: 500}, rejected: true},
+		{name: "unknown format", data: []byte("not an image"), conf: Config{DecodeMaxPixels: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{conf: tt.conf}
+			err := h.checkDecodeLimits(&Job{SrcKey: "photos/a", Source: tt.data})
+			var skipped *skipError
+			if !tt.rejected {
+				if err != nil {
+					t.Errorf("checkDecodeLimits = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &skipped) || skipped.Status != StatusRejectedDecodeLimit {
+				t.Errorf("checkDecodeLimits = %v, want %s", err, StatusRejectedDecodeLimit)
+			}
+		})
+	}
+}
+
+func FuzzInspectHeader(f *testing.F) {
+	f.Add(testPNG(8, 8, 8, 6, 3), 2)
+	f.Add(testGIF(4, 4, [2]uint16{4, 4}, [2]uint16{8, 8}), 0)
+	f.Add(testJPEG(0xc0, 64, 48, 3), 0)
+	f.Add(testWebP("VP8X", vp8xPayload(16, 16), 3), 1)
+	f.Add(testWebP("VP8L", make([]byte, 10), 0), 0)
+	for _, name := range []string{"photo.jpg", "alpha.png", "animated.gif", "alpha.webp", "corrupt.jpg"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data, 1000)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, maxFrames int) {
+		if maxFrames < 0 {
+			maxFrames = -maxFrames
+		}
+		header, ok := inspectHeader(data, maxFrames)
+		if !ok {
+			return
+		}
+		if header.Format == "" || header.Width < 0 || header.Height < 0 || header.Frames < 0 || header.BytesPerPixel <= 0 {
+			t.Fatalf("inspectHeader = %+v, want a valid header", header)
+		}
+		if maxFrames > 0 && header.Frames > maxFrames+1 {
+			t.Fatalf("inspectHeader frames = %d, want at most %d", header.Frames, maxFrames+1)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dedupLeaseMargin은 처리 중 표시의 임대 시간에 더하는 여유입니다. 실행 환경이 제한 시간에 죽어도
+// 이 시간이 지나면 재전송된 이벤트가 다시 처리됩니다.
+const dedupLeaseMargin = 10 * time.Second
+
+// UseEventDedup은 같은 S3 이벤트가 짧은 간격으로 두 번 전달될 때 두 번째 호출을 건너뛰게 합니다.
+// 테이블 키: Id(파티션, 문자열), TTL 속성: ExpiresAt. 실행 역할에 dynamodb:PutItem / DeleteItem 권한이 필요합니다.
+func (h *Handler) UseEventDedup(db DynamoAPI, table string) {
+	h.dedup = &eventDedup{db: db, table: table, clock: h.clock, window: h.conf.DedupWindow, namespace: h.conf.MetricsNamespace}
+	h.hooks.Use(h.dedup)
+}
+
+// eventDedup은 이벤트마다 <버킷>/<키>#<sequencer 또는 ETag>를 조건부 PutItem으로 선점합니다.
+// 처리 중에는 호출 제한 시간까지, 변환이 끝나면 window 동안 표시가 남아 그 사이의 중복 전달을 SKIPPED_DUPLICATE_EVENT로 끝냅니다.
+// 변환이 실패하면 표시를 지워 Lambda 재시도와 SQS 재전달이 막히지 않게 합니다.
+type eventDedup struct {
+	db        DynamoAPI
+	table     string
+	clock     Clock
+	window    time.Duration
+	namespace string
+}
+
+// dedupID는 이벤트 식별자입니다. sequencer와 ETag가 모두 없는 이벤트(직접 호출 등)는 건너뛰지 않습니다.
+func dedupID(job *Job) string {
+	switch {
+	case job.Event.S3Sequencer != "":
+		return job.Bucket + "/" + job.SrcKey + "#" + job.Event.S3Sequencer
+	case job.Event.S3ETag != "":
+		return job.Bucket + "/" + job.SrcKey + "#" + job.Event.S3ETag
+	}
+	return ""
+}
+
+// Claim은 이벤트를 처리 중으로 표시합니다. 다른 호출이 이미 표시했으면 skip 오류를 돌려줍니다.
+// 표시를 쓰지 못하면 중복 처리가 누락보다 낫기 때문에 경고만 남기고 변환합니다.
+func (d *eventDedup) Claim(ctx context.Context, job *Job) error {
+	if d == nil || job.Archive != "" {
+		return nil
+	}
+	id := dedupID(job)
+	if id == "" {
+		return nil
+	}
+	now := d.clock.Now()
+	lease := now.Add(dedupLeaseMargin)
+	if deadline, ok := ctx.Deadline(); ok {
+		lease = deadline.Add(dedupLeaseMargin)
+	}
+	_, err := d.db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]types.AttributeValue{
+			"Id":        &types.AttributeValueMemberS{Value: id},
+			"State":     &types.AttributeValueMemberS{Value: "IN_PROGRESS"},
+			"ExpiresAt": numberAttr(lease.Unix()),
+		},
+		ConditionExpression:       aws.String("attribute_not_exists(Id) OR ExpiresAt < :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": numberAttr(now.Unix())},
+	})
+	var conflict *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &conflict):
+		emitMetrics(d.namespace, "Count", map[string]float64{"DuplicateEventSkipped": 1})
+		return skip(StatusSkippedDuplicateEvent, fmt.Sprintf("Event %s is already being processed or was processed recently. Skipping conversion.", id))
+	case err != nil:
+		log.Printf("Warning: failed to claim event %s in %s, converting anyway: %v", id, d.table, err)
+	default:
+		job.dedupLease = lease.Unix()
+	}
+	return nil
+}
+
+// PostConvert는 이 호출이 선점한 표시를 완료로 바꾸고 window 동안 유지합니다.
+func (d *eventDedup) PostConvert(ctx context.Context, job *Job) error {
+	id := dedupID(job)
+	if id == "" || job.Archive != "" || job.dedupLease == 0 {
+		return nil
+	}
+	_, err := d.db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]types.AttributeValue{
+			"Id":        &types.AttributeValueMemberS{Value: id},
+			"State":     &types.AttributeValueMemberS{Value: "DONE"},
+			"ExpiresAt": numberAttr(d.clock.Now().Add(d.window).Unix()),
+		},
+	})
+	if err != nil {
+		log.Printf("Warning: failed to mark event %s as done in %s: %v", id, d.table, err)
+	}
+	return nil
+}
+
+// OnFailure는 이 호출이 선점한 표시를 지워 재시도가 바로 처리되게 합니다. Claim 전에 실패했거나 선점하지 못했으면
+// 다른 호출의 표시이므로 건드리지 않습니다. 임대가 끝나 다른 호출이 다시 선점한 표시도 지우지 않도록
+// 처리 중 상태와 선점할 때의 ExpiresAt이 그대로일 때만 지웁니다.
+func (d *eventDedup) OnFailure(ctx context.Context, job *Job, err error) {
+	id := dedupID(job)
+	if id == "" || job.Archive != "" || job.dedupLease == 0 {
+		return
+	}
+	_, delErr := d.db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                aws.String(d.table),
+		Key:                      map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: id}},
+		ConditionExpression:      aws.String("#state = :inProgress AND ExpiresAt = :lease"),
+		ExpressionAttributeNames: map[string]string{"#state": "State"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":inProgress": &types.AttributeValueMemberS{Value: "IN_PROGRESS"},
+			":lease":      numberAttr(job.dedupLease),
+		},
+	})
+	var conflict *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(delErr, &conflict):
+		log.Printf("Event %s in %s was claimed again after the lease expired, leaving it", id, d.table)
+	case delErr != nil:
+		log.Printf("Warning: failed to release event %s in %s, retries are suppressed until it expires: %v", id, d.table, delErr)
+	}
+}
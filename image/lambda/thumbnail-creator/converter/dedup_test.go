@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamo는 PutItem의 조건 실패와 DeleteItem 호출을 흉내 내는 DynamoAPI입니다.
+type fakeDynamo struct {
+	DynamoAPI
+
+	putErr  error
+	puts    []*dynamodb.PutItemInput
+	deletes []*dynamodb.DeleteItemInput
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.puts = append(f.puts, params)
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamo) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.deletes = append(f.deletes, params)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestEventDedupReleasesOnlyOwnClaim(t *testing.T) {
+	failure := errors.New("failed to process image with vips from buffer")
+	tests := []struct {
+		name string
+		// claim은 OnFailure 전에 Claim을 부를지입니다. false이면 Claim 전에 실패한 요청입니다.
+		claim       bool
+		putErr      error
+		wantDeletes int
+	}{
+		{name: "claimed", claim: true, wantDeletes: 1},
+		{name: "failed before claim"},
+		{name: "duplicate", claim: true, putErr: &types.ConditionalCheckFailedException{}},
+		{name: "claim write failed", claim: true, putErr: errors.New("throttled")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDynamo{putErr: tt.putErr}
+			d := &eventDedup{db: db, table: "events", clock: newManualClock(), window: time.Minute}
+			job := &Job{Bucket: "uploads", SrcKey: "photos/cat.png", Event: S3Event{S3Sequencer: "0055AED6DCD90281E5"}}
+
+			if tt.claim {
+				err := d.Claim(context.Background(), job)
+				var skipped *skipError
+				var conflict *types.ConditionalCheckFailedException
+				if errors.As(err, &skipped) != errors.As(tt.putErr, &conflict) {
+					t.Fatalf("Claim = %v, want a skip only for a conditional check failure", err)
+				}
+			}
+			d.OnFailure(context.Background(), job, failure)
+			if len(db.deletes) != tt.wantDeletes {
+				t.Fatalf("DeleteItem called %d times, want %d", len(db.deletes), tt.wantDeletes)
+			}
+			if tt.wantDeletes == 0 {
+				if err := d.PostConvert(context.Background(), job); err != nil {
+					t.Fatal(err)
+				}
+				if len(db.puts) > 1 {
+					t.Errorf("PostConvert marked an event this call did not claim as done")
+				}
+				return
+			}
+			del := db.deletes[0]
+			if aws.ToString(del.ConditionExpression) != "#state = :inProgress AND ExpiresAt = :lease" {
+				t.Errorf("DeleteItem condition = %q, want conditional on the claim", aws.ToString(del.ConditionExpression))
+			}
+			claimed := db.puts[0].Item["ExpiresAt"].(*types.AttributeValueMemberN).Value
+			if lease := del.ExpressionAttributeValues[":lease"].(*types.AttributeValueMemberN).Value; lease != claimed {
+				t.Errorf("DeleteItem lease = %s, want claimed ExpiresAt %s", lease, claimed)
+			}
+		})
+	}
+}
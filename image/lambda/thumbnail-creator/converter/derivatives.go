@@ -0,0 +1,216 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/berryssoda/test-encode/pipeline"
+	"github.com/cshum/vipsgen/vips"
+)
+
+// derivativeName은 파생 출력 이름의 형식입니다. 키 템플릿의 {name}에 그대로 들어갑니다.
+var derivativeName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// defaultDerivativeKey는 Key가 비어 있을 때의 키 템플릿입니다.
+const defaultDerivativeKey = "{base}_{name}{ext}"
+
+// Derivative는 한 번 디코딩한 이미지에서 만드는 출력 하나입니다. 프리셋과 달리 출력마다 포맷, 품질, 키, 업로드 설정이 다릅니다.
+// 예: {"name": "hero", "width": 1200, "format": "avif"}, {"name": "avatar", "width": 64, "height": 64, "fit": "cover", "format": "jpeg"}
+type Derivative struct {
+	Name string `json:"name"`
+	// Format이 "blurhash"이면 파일을 올리지 않고 결과의 blurHash에 문자열을 넣습니다. 크기와 업로드 설정은 쓰지 않습니다.
+	// 비어 있으면 요청의 출력 포맷(프리셋·규칙·파이프라인 format 단계를 거친 값)입니다.
+	Format  string `json:"format,omitempty"`
+	Quality int    `json:"quality,omitempty"` // 0이면 포맷별 기본 품질
+	// Width와 Height가 모두 0이면 크기를 바꾸지 않습니다. 한쪽만 두면 비율을 유지합니다.
+	Width      int                  `json:"width,omitempty"`
+	Height     int                  `json:"height,omitempty"`
+	Fit        string               `json:"fit,omitempty"` // inside(기본) | cover | fill | pad
+	Background string               `json:"background,omitempty"`
+	Sharpen    *pipeline.Sharpening `json:"sharpen,omitempty"`
+	// MaxBytes는 이 출력의 크기 상한입니다. 0이면 요청의 maxOutputBytes입니다.
+	MaxBytes int `json:"maxBytes,omitempty"`
+	// Key는 출력 키 템플릿입니다. {base}(출력 기준 키에서 확장자를 뺀 값), {name}, {width}, {height}(요청한 크기),
+	// {ext}(포맷 확장자)를 쓸 수 있으며 기본은 {base}_{name}{ext}입니다.
+	Key string `json:"key,omitempty"`
+	// StorageClass와 CacheControl은 이 출력의 업로드 설정입니다. StorageClass가 비어 있으면 OUTPUT_STORAGE_CLASS(테넌트 설정)입니다.
+	StorageClass string `json:"storageClass,omitempty"`
+	CacheControl string `json:"cacheControl,omitempty"`
+}
+
+// validateDerivatives는 파생 출력 목록을 디코딩 전에 검증합니다. 이름과 키 템플릿을 펼친 키가 겹치면 안 됩니다.
+func (h *Handler) validateDerivatives(derivatives []Derivative) error {
+	names := map[string]bool{}
+	keys := map[string]string{}
+	blurHashes := 0
+	for i := range derivatives {
+		d := &derivatives[i]
+		if !derivativeName.MatchString(d.Name) {
+			return fmt.Errorf("derivative %d: name %q must be 1-64 lowercase letters, digits, '-' or '_'", i, d.Name)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("derivative %q is listed twice", d.Name)
+		}
+		names[d.Name] = true
+		if d.Format == "jpg" {
+			d.Format = "jpeg"
+		}
+		if d.Format == "blurhash" {
+			if blurHashes++; blurHashes > 1 {
+				return fmt.Errorf("derivative %q: only one blurhash derivative is allowed", d.Name)
+			}
+			continue
+		}
+		if d.Format != "" {
+			if err := pipeline.ValidateFormat(d.Format, d.Quality); err != nil {
+				return fmt.Errorf("derivative %q: %w", d.Name, err)
+			}
+			if _, err := h.encoders.Get(d.Format); err != nil {
+				return fmt.Errorf("derivative %q: %w", d.Name, err)
+			}
+		} else if d.Quality < 0 || d.Quality > 100 {
+			return fmt.Errorf("derivative %q: quality must be between 1 and 100", d.Name)
+		}
+		if d.Fit == "" {
+			d.Fit = "inside"
+		}
+		if d.Width != 0 || d.Height != 0 {
+			if err := pipeline.ValidateSize(d.Width, d.Height, d.Fit); err != nil {
+				return fmt.Errorf("derivative %q: %w", d.Name, err)
+			}
+		}
+		if err := pipeline.ValidateBackground(d.Background); err != nil {
+			return fmt.Errorf("derivative %q: %w", d.Name, err)
+		}
+		if d.Sharpen != nil {
+			if err := d.Sharpen.Validate(); err != nil {
+				return fmt.Errorf("derivative %q: %w", d.Name, err)
+			}
+		}
+		if d.MaxBytes < 0 {
+			return fmt.Errorf("derivative %q: maxBytes must not be negative", d.Name)
+		}
+		if d.StorageClass != "" && !knownStorageClass(d.StorageClass) {
+			return fmt.Errorf("derivative %q: unknown storage class %q", d.Name, d.StorageClass)
+		}
+		if d.Key == "" {
+			d.Key = defaultDerivativeKey
+		}
+		// {base}는 모든 출력에 같으므로 임의의 값으로 펼쳐도 겹치는지 알 수 있습니다. 포맷이 비어 있으면 다른 출력과 같은 포맷이 됩니다.
+		key := d.key("base", "format")
+		if strings.ContainsAny(key, "{}") {
+			return fmt.Errorf("derivative %q: unknown placeholder in key template %q", d.Name, d.Key)
+		}
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("derivatives %q and %q resolve to the same key", other, d.Name)
+		}
+		keys[key] = d.Name
+	}
+	return nil
+}
+
+// key는 키 템플릿을 펼칩니다.
+func (d Derivative) key(base, format string) string {
+	if d.Format != "" {
+		format = d.Format
+	}
+	return strings.NewReplacer(
+		"{base}", base,
+		"{name}", d.Name,
+		"{width}", strconv.Itoa(d.Width),
+		"{height}", strconv.Itoa(d.Height),
+		"{ext}", extensionOf(format),
+	).Replace(d.Key)
+}
+
+// encodeDerivatives는 파생 출력마다 이미지 사본을 줄여 각자의 포맷으로 인코딩합니다. 프리셋 크기처럼
+// VARIANT_CONCURRENCY개까지 동시에 인코딩하며, JXL/JPEG 대체 출력은 만들지 않습니다. (필요하면 파생 출력으로 적습니다)
+// blurhash 파생 출력은 job.Result.BlurHash를 채우고 업로드 없는 빈 결과가 됩니다.
+func (h *Handler) encodeDerivatives(ctx context.Context, job *Job, image *vips.Image, outputFormat string, p EncodeOptions) ([]encodedVariant, error) {
+	derivatives := job.Event.Derivatives
+	variants, err := h.encodeConcurrently(ctx, len(derivatives), func(ctx context.Context, i int) (encodedVariant, error) {
+		d := derivatives[i]
+		if d.Format == "blurhash" {
+			hash, err := imageBlurHash(image)
+			if err != nil {
+				return encodedVariant{}, fmt.Errorf("failed to compute BlurHash for derivative %s: %w", d.Name, err)
+			}
+			job.mu.Lock()
+			job.Result.BlurHash = hash
+			job.mu.Unlock()
+			return encodedVariant{}, nil
+		}
+		return h.encodeDerivative(ctx, job, image, d, outputFormat, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 포맷을 비워 둔 출력은 처리 중에 포맷이 정해지므로, 다른 출력과 키가 겹치는지 여기서 한 번 더 확인합니다.
+	owners := map[string]string{}
+	for i, v := range variants {
+		for _, u := range v.uploads {
+			if other, ok := owners[u.Key]; ok {
+				return nil, fmt.Errorf("invalid event: derivatives %q and %q resolve to the same key %s", other, derivatives[i].Name, u.Key)
+			}
+			owners[u.Key] = derivatives[i].Name
+		}
+	}
+	return variants, nil
+}
+
+// encodeDerivative는 이미지 사본에 파생 출력 하나의 크기·알파·샘플링 설정을 적용해 인코딩합니다.
+func (h *Handler) encodeDerivative(ctx context.Context, job *Job, image *vips.Image, d Derivative, outputFormat string, p EncodeOptions) (encodedVariant, error) {
+	format := outputFormat
+	if d.Format != "" && d.Format != outputFormat {
+		// 요청의 품질과 샘플링은 요청의 출력 포맷에 맞춘 값이므로 다른 포맷에는 그 포맷의 기본값을 씁니다.
+		format = d.Format
+		p.Quality = 0
+		var err error
+		if p.Subsample, p.Bitdepth, err = resolveSampling(format, job.Event, h.conf); err != nil {
+			return encodedVariant{}, fmt.Errorf("invalid event: derivative %s: %w", d.Name, err)
+		}
+	}
+	if d.Quality > 0 {
+		p.Quality = d.Quality
+	}
+	limit := job.Event.MaxOutputBytes
+	if d.MaxBytes > 0 {
+		limit = d.MaxBytes
+	}
+
+	variant, err := image.Copy(nil)
+	if err != nil {
+		return encodedVariant{}, err
+	}
+	defer variant.Close()
+	if d.Width != 0 || d.Height != 0 {
+		if err := pipeline.Resize(variant, d.Width, d.Height, d.Fit, false, d.Background); err != nil {
+			return encodedVariant{}, fmt.Errorf("failed to resize derivative %s: %w", d.Name, err)
+		}
+	}
+	if d.Sharpen != nil {
+		if err := pipeline.Sharpen(variant, *d.Sharpen); err != nil {
+			return encodedVariant{}, fmt.Errorf("failed to sharpen derivative %s: %w", d.Name, err)
+		}
+	}
+	// 요청의 출력 포맷이 알파를 지원해 남겨 둔 알파는 JPEG 같은 포맷에서 배경색에 합성합니다.
+	if _, err := applyAlphaPolicy(variant, format, job.Background, h.conf); err != nil {
+		return encodedVariant{}, fmt.Errorf("derivative %s: %w", d.Name, err)
+	}
+
+	key := d.key(replaceExtension(job.BaseKey, ""), format)
+	u, encoder, err := h.encodePrimary(ctx, job, key, variant, format, p, limit)
+	if err != nil {
+		return encodedVariant{}, err
+	}
+	u.Key = key
+	u.Derivative = d.Name
+	u.StorageClass = d.StorageClass
+	u.CacheControl = d.CacheControl
+	log.Printf("Encoded derivative %s: %dx%d %s, %d bytes", d.Name, u.Width, u.Height, strings.ToUpper(format), len(u.Body))
+	return encodedVariant{uploads: []*Upload{u}, encoder: encoder}, nil
+}
@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// destinationSessionName은 대상 역할을 맡을 때의 세션 이름입니다. 상대 계정의 CloudTrail에 남습니다.
+const destinationSessionName = "thumbnail-creator"
+
+// newDestinationS3Client는 DestinationRoleARN을 맡은 자격 증명으로 출력용 S3 클라이언트를 만듭니다.
+// 자격 증명은 캐시되며 만료 5분 전에 AssumeRole로 다시 받으므로, 실행 환경이 오래 살아 있어도 끊기지 않습니다.
+func newDestinationS3Client(cfg aws.Config, c Config) *s3.Client {
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), c.DestinationRoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = destinationSessionName
+		if c.DestinationExternalID != "" {
+			o.ExternalID = aws.String(c.DestinationExternalID)
+		}
+	})
+	destination := cfg.Copy()
+	destination.Credentials = aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = 5 * time.Minute
+	})
+	return newS3Client(destination, c)
+}
+
+// UseDestinationRole은 buckets에 대한 출력 쓰기(업로드, 덮어쓰기 확인)를 client로 보냅니다.
+// 원본 읽기와 원본 버킷의 태그·복사는 계속 함수 역할로 합니다.
+func (h *Handler) UseDestinationRole(client S3API, buckets []string) {
+	h.destination = countingS3{client}
+	h.destinationBuckets = buckets
+}
+
+// outputClient는 bucket에 출력을 쓸 때 사용할 S3 클라이언트입니다.
+func (h *Handler) outputClient(bucket string) S3API {
+	if h.destination != nil && slices.Contains(h.destinationBuckets, bucket) {
+		return h.destination
+	}
+	return h.s3
+}
@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// downloadPartSize는 병렬 다운로드에서 ranged GET 하나가 받는 크기입니다.
+const downloadPartSize = 8 << 20
+
+// sourceSize는 HeadObject로 원본 크기와 ETag를 확인합니다.
+// 병렬 다운로드를 쓸지 정하고 버퍼를 미리 할당하는 데 사용합니다.
+func (h *Handler) sourceSize(ctx context.Context, bucket, key string) (int64, *string, error) {
+	head, err := h.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return aws.ToInt64(head.ContentLength), head.ETag, nil
+}
+
+// downloadParallel은 큰 객체를 동시 ranged GET으로 받아 미리 할당한 버퍼에 채우고, 저장된 Content-Type과 함께 돌려줍니다.
+// 받는 도중 객체가 바뀌면 파트가 섞이지 않도록 etag(S3 알림 또는 HeadObject의 ETag)로 If-Match를 겁니다.
+func (h *Handler) downloadParallel(ctx context.Context, bucket, key string, size int64, etag *string) ([]byte, string, error) {
+	log.Printf("Downloading %d bytes with %d concurrent ranged GETs", size, h.conf.DownloadConcurrency)
+	buf := manager.NewWriteAtBuffer(make([]byte, 0, size))
+	client := &contentTypeRecorder{DownloadAPIClient: h.s3}
+	downloader := manager.NewDownloader(client, func(d *manager.Downloader) {
+		d.PartSize = downloadPartSize
+		d.Concurrency = h.conf.DownloadConcurrency
+	})
+	n, err := downloader.Download(ctx, buf, &s3.GetObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		IfMatch: etag,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get object from S3: %w", err)
+	}
+	return buf.Bytes()[:n], client.contentType, nil
+}
+
+// contentTypeRecorder는 병렬 다운로드의 ranged GET 응답에서 Content-Type을 기록합니다.
+// 크기를 S3 알림에서 알면 HeadObject를 부르지 않으므로 Content-Type은 GET 응답에서 얻습니다.
+type contentTypeRecorder struct {
+	manager.DownloadAPIClient
+
+	once        sync.Once
+	contentType string
+}
+
+func (r *contentTypeRecorder) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	out, err := r.DownloadAPIClient.GetObject(ctx, params, optFns...)
+	if err == nil {
+		r.once.Do(func() { r.contentType = aws.ToString(out.ContentType) })
+	}
+	return out, err
+}
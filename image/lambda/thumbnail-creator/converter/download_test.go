@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// TestDownloadSourceUsesEventSize는 S3 알림에 크기가 있으면 병렬 다운로드 여부를 정하려고 HeadObject를 부르지 않는지 확인합니다.
+func TestDownloadSourceUsesEventSize(t *testing.T) {
+	body := bytes.Repeat([]byte{0xab}, 1024)
+	tests := []struct {
+		name      string
+		size      int64
+		wantHeads int
+	}{
+		{name: "size from event", size: int64(len(body))},
+		{name: "size unknown", size: 0, wantHeads: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeS3()
+			client.put("uploads", "photos/cat.png", body)
+			h := &Handler{s3: client, conf: Config{ParallelDownloadThreshold: 32 << 20}}
+
+			got, _, err := h.downloadSource(context.Background(), "uploads", "photos/cat.png", tt.size, "0123abcd")
+			if err != nil {
+				t.Fatalf("downloadSource: %v", err)
+			}
+			if !bytes.Equal(got, body) {
+				t.Errorf("downloaded %d bytes, want %d", len(got), len(body))
+			}
+			if client.heads != tt.wantHeads {
+				t.Errorf("HeadObject called %d times, want %d", client.heads, tt.wantHeads)
+			}
+		})
+	}
+}
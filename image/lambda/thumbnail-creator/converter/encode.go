@@ -0,0 +1,184 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// EncodeOptions는 출력 하나를 인코딩할 때 필요한 값입니다.
+type EncodeOptions struct {
+	Graphics bool
+	Color    colorPlan
+	Keep     vips.Keep
+	Effort   int
+	// Quality가 0이면 포맷별 기본 품질을 사용합니다. 무손실 인코딩에서는 무시됩니다.
+	Quality int
+	// Subsample과 Bitdepth가 비어 있으면 인코더 기본값을 씁니다. (sampling.go 참고)
+	Subsample string
+	Bitdepth  int
+	// Debug이면 인코더 옵션 전체를 로그에 남깁니다. (LOG_LEVEL=debug 또는 이벤트의 debug)
+	Debug bool `json:"-"`
+}
+
+// Encoded는 인코딩 결과입니다. Encoder는 AVIF처럼 내부 인코더를 고르는 포맷에서만 채워집니다.
+type Encoded struct {
+	Data    []byte
+	Encoder string
+}
+
+// Encoder는 출력 포맷 하나의 인코더입니다. 새 포맷은 구현을 추가하고 newEncoderRegistry에 등록합니다.
+// vipsgen의 옵션 구조체는 모든 필드를 libvips에 넘기므로 구현은 Default*Options에서 시작합니다.
+type Encoder interface {
+	// Name은 이벤트·파이프라인에서 쓰는 포맷 이름입니다. 예: "avif"
+	Name() string
+	// ContentType은 업로드할 때의 Content-Type입니다.
+	ContentType() string
+	Encode(image *vips.Image, opts EncodeOptions) (Encoded, error)
+}
+
+// encoderRegistry는 포맷 이름별 인코더입니다.
+type encoderRegistry map[string]Encoder
+
+// newEncoderRegistry는 설정과 libvips 빌드에서 사용할 수 있는 인코더를 등록합니다.
+func newEncoderRegistry(c Config) encoderRegistry {
+	r := encoderRegistry{}
+	r.register(avifEncoder{c})
+	r.register(webpEncoder{c})
+	r.register(jpegEncoder{c})
+	r.register(pngEncoder{})
+	if vips.HasOperation("jxlsave_buffer") {
+		r.register(jxlEncoder{c})
+	}
+	return r
+}
+
+func (r encoderRegistry) register(e Encoder) {
+	r[e.Name()] = e
+}
+
+// Get은 format 이름의 인코더를 찾습니다.
+func (r encoderRegistry) Get(format string) (Encoder, error) {
+	e, ok := r[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format %q (available: %v)", format, r.Names())
+	}
+	return e, nil
+}
+
+// Names는 등록된 포맷 이름을 정렬해 돌려줍니다.
+func (r encoderRegistry) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ContentType은 업로드에 사용할 Content-Type입니다. 등록되지 않은 포맷은 image/<format>을 씁니다.
+func (r encoderRegistry) ContentType(format string) string {
+	if e, ok := r[format]; ok {
+		return e.ContentType()
+	}
+	return "image/" + format
+}
+
+type avifEncoder struct{ c Config }
+
+func (avifEncoder) Name() string        { return "avif" }
+func (avifEncoder) ContentType() string { return "image/avif" }
+
+func (e avifEncoder) Encode(image *vips.Image, o EncodeOptions) (Encoded, error) {
+	if o.Bitdepth > 0 {
+		o.Color.Bitdepth = o.Bitdepth
+	}
+	options, encoder := avifOptions(image, o.Graphics, o.Color, o.Keep, o.Effort, e.c)
+	if o.Quality > 0 && !options.Lossless {
+		options.Q = o.Quality
+	}
+	// 그래픽은 글자와 선이 번지지 않도록 설정과 관계없이 4:4:4를 유지합니다.
+	if o.Subsample != "" && !o.Graphics {
+		options.SubsampleMode = subsampleModes[o.Subsample]
+	}
+	if o.Debug {
+		log.Printf("DEBUG: Preparing to export with options: %+v", options)
+	}
+	buf, err := image.HeifsaveBuffer(options)
+	return Encoded{Data: buf, Encoder: encoder}, err
+}
+
+type webpEncoder struct{ c Config }
+
+func (webpEncoder) Name() string        { return "webp" }
+func (webpEncoder) ContentType() string { return "image/webp" }
+
+func (e webpEncoder) Encode(image *vips.Image, o EncodeOptions) (Encoded, error) {
+	options := vips.DefaultWebpsaveBufferOptions()
+	options.Keep = o.Keep
+	if o.Graphics {
+		options.Lossless = true
+		options.NearLossless = e.c.GraphicsNearLossless
+		options.Q = e.c.GraphicsNearLosslessQuality
+	} else if o.Quality > 0 {
+		options.Q = o.Quality
+	}
+	if o.Debug {
+		log.Printf("DEBUG: Preparing to export with options: %+v", options)
+	}
+	buf, err := image.WebpsaveBuffer(options)
+	return Encoded{Data: buf}, err
+}
+
+type jpegEncoder struct{ c Config }
+
+func (jpegEncoder) Name() string        { return "jpeg" }
+func (jpegEncoder) ContentType() string { return "image/jpeg" }
+
+func (e jpegEncoder) Encode(image *vips.Image, o EncodeOptions) (Encoded, error) {
+	quality := e.c.JPEGQuality
+	if o.Quality > 0 {
+		quality = o.Quality
+	}
+	subsample := o.Subsample
+	if subsample == "" {
+		subsample = "auto"
+	}
+	buf, err := image.JpegsaveBuffer(jpegOptions(quality, o.Keep, subsample))
+	return Encoded{Data: buf}, err
+}
+
+type pngEncoder struct{}
+
+func (pngEncoder) Name() string        { return "png" }
+func (pngEncoder) ContentType() string { return "image/png" }
+
+func (pngEncoder) Encode(image *vips.Image, o EncodeOptions) (Encoded, error) {
+	options := vips.DefaultPngsaveBufferOptions()
+	options.Keep = o.Keep
+	buf, err := image.PngsaveBuffer(options)
+	return Encoded{Data: buf}, err
+}
+
+// compressionOf는 결과에 기록할 압축 방식입니다.
+func compressionOf(format string, graphics bool, c Config) string {
+	switch {
+	case format == "png":
+		return "lossless"
+	case !graphics || format == "jpeg":
+		return "lossy"
+	case c.GraphicsNearLossless:
+		return "near-lossless"
+	}
+	return "lossless"
+}
+
+// extensionOf는 출력 포맷의 파일 확장자입니다.
+func extensionOf(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return "." + format
+}
@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// EventBridgeAPI는 완료 이벤트 발행에 사용하는 EventBridge 호출입니다.
+type EventBridgeAPI interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// UseEventBridge는 변환이 끝날 때마다 EVENT_BUS_NAME(또는 테넌트 버스)에 이벤트를 발행하는 알림 대상을 추가합니다.
+func (h *Handler) UseEventBridge(api EventBridgeAPI) {
+	spec := NotifierSpec{Type: "eventbridge", Name: "eventbridge", Events: []string{"converted", "failed", "metadata"}, Retry: defaultNotifyRetry}
+	h.UseNotifier(spec, h.newEventPublisher(api, ""))
+}
+
+// newEventPublisher는 bus에 발행하는 Notifier를 만듭니다. bus가 비어 있으면 테넌트 버스 → EVENT_BUS_NAME 순입니다.
+func (h *Handler) newEventPublisher(api EventBridgeAPI, bus string) *eventPublisher {
+	p := &eventPublisher{api: api, bus: h.conf.EventBusName, source: h.conf.EventSource, limiter: h.notifyLimiter, namespace: h.conf.MetricsNamespace}
+	if bus != "" {
+		p.bus, p.fixedBus = bus, true
+	}
+	return p
+}
+
+// conversionEvent는 이벤트의 detail입니다. 성공하면 ConversionResult 필드가, 실패하면 error 필드가 채워집니다.
+type conversionEvent struct {
+	Bucket string `json:"bucket"`
+	ConversionResult
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+}
+
+// eventPublisher는 변환 결과를 detail-type image.converted / image.failed 이벤트로 발행하는 Notifier입니다.
+type eventPublisher struct {
+	api    EventBridgeAPI
+	bus    string
+	source string
+	// fixedBus이면 NOTIFIERS의 eventBus로 정한 버스이며 테넌트 버스를 쓰지 않습니다.
+	fixedBus bool
+	// limiter는 이벤트 버스별 발행 한도입니다. 한도를 넘으면 RateLimited로 변환을 미룹니다.
+	limiter   *rateLimiter
+	namespace string
+}
+
+func (p *eventPublisher) Notify(ctx context.Context, job *Job, detailType string, detail conversionEvent) error {
+	return p.publish(ctx, p.busFor(job), detailType, detail)
+}
+
+// busFor는 테넌트의 이벤트 버스를, 없으면 EVENT_BUS_NAME을 돌려줍니다. 둘 다 없으면 발행하지 않습니다.
+func (p *eventPublisher) busFor(job *Job) string {
+	if !p.fixedBus && job.Tenant != nil && job.Tenant.EventBus != "" {
+		return job.Tenant.EventBus
+	}
+	return p.bus
+}
+
+func (p *eventPublisher) publish(ctx context.Context, bus, detailType string, detail conversionEvent) error {
+	if bus == "" {
+		return nil
+	}
+	if err := p.limiter.Wait(ctx, "events://"+bus, p.namespace); err != nil {
+		return err
+	}
+	body, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", detailType, err)
+	}
+	out, err := p.api.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{{
+			EventBusName: &bus,
+			Source:       &p.source,
+			DetailType:   aws.String(detailType),
+			Detail:       aws.String(string(body)),
+			Resources:    []string{fmt.Sprintf("arn:aws:s3:::%s/%s", detail.Bucket, detail.OriginalKey)},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", detailType, err)
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("failed to publish %s event: %s %s", detailType, aws.ToString(out.Entries[0].ErrorCode), aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	log.Printf("Published %s event to %s", detailType, bus)
+	return nil
+}
@@ -0,0 +1,92 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// FaceAPI는 redact 단계의 "auto-faces"가 사용하는 Rekognition 호출입니다.
+type FaceAPI interface {
+	DetectFaces(ctx context.Context, params *rekognition.DetectFacesInput, optFns ...func(*rekognition.Options)) (*rekognition.DetectFacesOutput, error)
+}
+
+// faceAnalysisSize는 Rekognition에 보내는 분석용 이미지의 긴 변 최대 길이입니다.
+// 요청 본문 5MB 제한 안에 들어가면서 작은 얼굴(40px 이상)도 찾을 수 있는 크기입니다.
+const faceAnalysisSize = 1920
+
+// facePadding은 감지된 얼굴 상자를 사방으로 넓히는 비율입니다. 머리카락과 턱선까지 가리기 위해 사용합니다.
+const facePadding = 0.15
+
+// UseFaceDetection은 redact 단계의 "auto-faces"에 쓸 얼굴 감지를 연결합니다.
+func (h *Handler) UseFaceDetection(api FaceAPI) {
+	h.faces = api
+}
+
+// faceDetector는 pipeline.Env.DetectFaces 구현입니다. 얼굴 감지가 연결되지 않았으면 nil입니다.
+func (h *Handler) faceDetector(ctx context.Context) func(*vips.Image) ([]pipeline.Region, error) {
+	if h.faces == nil {
+		return nil
+	}
+	return func(image *vips.Image) ([]pipeline.Region, error) {
+		return h.detectFaces(ctx, image)
+	}
+}
+
+// detectFaces는 이미지를 분석용 JPEG으로 줄여 Rekognition DetectFaces를 호출하고,
+// 비율로 돌아온 얼굴 상자를 원본 이미지 픽셀 좌표로 바꿉니다.
+func (h *Handler) detectFaces(ctx context.Context, image *vips.Image) ([]pipeline.Region, error) {
+	analysis, err := image.Copy(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer analysis.Close()
+	if err := pipeline.Resize(analysis, faceAnalysisSize, faceAnalysisSize, "inside", false, ""); err != nil {
+		return nil, err
+	}
+	buf, err := analysis.JpegsaveBuffer(jpegOptions(85, vips.KeepNone, "auto"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode face analysis image: %w", err)
+	}
+	out, err := h.faces.DetectFaces(ctx, &rekognition.DetectFacesInput{
+		Image: &types.Image{Bytes: buf},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := float64(image.Width()), float64(image.Height())
+	regions := make([]pipeline.Region, 0, len(out.FaceDetails))
+	for _, face := range out.FaceDetails {
+		box := face.BoundingBox
+		if box == nil {
+			continue
+		}
+		w, hgt := float64(aws.ToFloat32(box.Width)), float64(aws.ToFloat32(box.Height))
+		left := float64(aws.ToFloat32(box.Left)) - w*facePadding
+		top := float64(aws.ToFloat32(box.Top)) - hgt*facePadding
+		w, hgt = w*(1+2*facePadding), hgt*(1+2*facePadding)
+		// 상자가 이미지 밖으로 나가면 음수 좌표가 되므로 안쪽으로 자릅니다. 오른쪽·아래쪽은 redact 단계에서 자릅니다.
+		if left < 0 {
+			w, left = w+left, 0
+		}
+		if top < 0 {
+			hgt, top = hgt+top, 0
+		}
+		regions = append(regions, pipeline.Region{
+			Left:   int(left * width),
+			Top:    int(top * height),
+			Width:  int(w*width) + 1,
+			Height: int(hgt*height) + 1,
+		})
+	}
+	log.Printf("Face detection: %d faces found", len(regions))
+	return regions, nil
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// SkipRules는 원본을 다운로드하기 전에 평가하는 포함·제외 규칙입니다. (SKIP_RULES, JSON)
+// 규칙에 걸린 요청은 S3 GET 없이 SKIPPED_FILTERED로 끝납니다.
+type SkipRules struct {
+	// Include가 있으면 그중 하나에 맞는 키만 변환합니다.
+	Include []KeyRule `json:"include,omitempty"`
+	// Exclude 중 하나라도 맞으면 건너뜁니다. Include보다 우선합니다.
+	Exclude []KeyRule `json:"exclude,omitempty"`
+	// MinBytes와 MaxBytes는 원본 크기 범위입니다. 0이면 제한하지 않습니다.
+	// 크기는 S3 알림의 object.size를, 없으면 HeadObject를 씁니다.
+	MinBytes int64 `json:"minBytes,omitempty"`
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+}
+
+// KeyRule은 원본 버킷·키 조건 하나입니다. 채워진 조건이 모두 맞아야 규칙이 맞습니다.
+type KeyRule struct {
+	Bucket string `json:"bucket,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	// Regex는 키 전체에 대해 찾는 정규 표현식(RE2)입니다. 예: "(^|/)\\._" (macOS 리소스 포크)
+	Regex string `json:"regex,omitempty"`
+	re    *regexp.Regexp
+}
+
+// parseSkipRules는 SKIP_RULES JSON을 읽고 정규 표현식을 컴파일합니다.
+func parseSkipRules(data []byte) (*SkipRules, error) {
+	var rules SkipRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	if rules.MinBytes < 0 || rules.MaxBytes < 0 || (rules.MaxBytes > 0 && rules.MinBytes > rules.MaxBytes) {
+		return nil, fmt.Errorf("minBytes and maxBytes must be non-negative and minBytes must not exceed maxBytes")
+	}
+	for name, list := range map[string][]KeyRule{"include": rules.Include, "exclude": rules.Exclude} {
+		for i := range list {
+			if err := list[i].compile(); err != nil {
+				return nil, fmt.Errorf("%s rule %d: %w", name, i, err)
+			}
+		}
+	}
+	return &rules, nil
+}
+
+// compile은 조건이 하나 이상 있는지 확인하고 정규 표현식을 컴파일합니다.
+func (r *KeyRule) compile() error {
+	if r.Bucket == "" && r.Prefix == "" && r.Suffix == "" && r.Regex == "" {
+		return fmt.Errorf("rule has no condition")
+	}
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return err
+		}
+		r.re = re
+	}
+	return nil
+}
+
+func (r KeyRule) match(bucket, key string) bool {
+	return (r.Bucket == "" || r.Bucket == bucket) &&
+		strings.HasPrefix(key, r.Prefix) &&
+		strings.HasSuffix(key, r.Suffix) &&
+		(r.re == nil || r.re.MatchString(key))
+}
+
+func (r KeyRule) String() string {
+	var parts []string
+	for _, p := range [][2]string{{"bucket", r.Bucket}, {"prefix", r.Prefix}, {"suffix", r.Suffix}, {"regex", r.Regex}} {
+		if p[1] != "" {
+			parts = append(parts, fmt.Sprintf("%s=%q", p[0], p[1]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// filterSource는 SKIP_RULES에 걸린 원본을 SKIPPED_FILTERED로 건너뜁니다. 규칙이 없으면 아무것도 하지 않습니다.
+// 키 규칙을 먼저 보고, 크기 규칙이 있을 때만 크기를 확인합니다.
+func (h *Handler) filterSource(ctx context.Context, job *Job) error {
+	rules := h.conf.SkipRules
+	if rules == nil {
+		return nil
+	}
+	for _, r := range rules.Exclude {
+		if r.match(job.Bucket, job.SrcKey) {
+			return skip(StatusSkippedFiltered, fmt.Sprintf("Key matches exclude rule (%s). Skipping conversion.", r))
+		}
+	}
+	if len(rules.Include) > 0 {
+		included := false
+		for _, r := range rules.Include {
+			if r.match(job.Bucket, job.SrcKey) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return skip(StatusSkippedFiltered, "Key matches no include rule. Skipping conversion.")
+		}
+	}
+	if rules.MinBytes == 0 && rules.MaxBytes == 0 {
+		return nil
+	}
+	size := job.Event.S3Size
+	if size == 0 {
+		// HeadObject가 실패하면 건너뛰지 않고 다운로드에서 실제 오류를 보고합니다.
+		var err error
+		if size, _, err = h.sourceSize(ctx, job.Bucket, job.SrcKey); err != nil {
+			log.Printf("Warning: failed to check source size for skip rules: %v", err)
+			return nil
+		}
+	}
+	if size < rules.MinBytes {
+		return skip(StatusSkippedFiltered, fmt.Sprintf("Source is %d bytes, below minBytes %d. Skipping conversion.", size, rules.MinBytes))
+	}
+	if rules.MaxBytes > 0 && size > rules.MaxBytes {
+		return skip(StatusSkippedFiltered, fmt.Sprintf("Source is %d bytes, above maxBytes %d. Skipping conversion.", size, rules.MaxBytes))
+	}
+	return nil
+}
+
+// emptySource는 빈 객체와 MIN_SOURCE_BYTES보다 작은 객체를 SKIPPED_EMPTY_OBJECT로 건너뜁니다.
+// 콘솔의 "폴더"(/로 끝나는 0바이트 키)나 중단된 업로드가 만든 객체는 어떤 이미지로도 디코딩되지 않으므로,
+// libvips 오류로 실패해 재시도되는 대신 바로 끝냅니다. size가 음수이면 아직 크기를 모르는 것입니다.
+func (h *Handler) emptySource(job *Job, size int64) error {
+	if strings.HasSuffix(job.SrcKey, "/") && size <= 0 {
+		return skip(StatusSkippedEmptyObject, "Object is a folder placeholder. Skipping conversion.")
+	}
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		return skip(StatusSkippedEmptyObject, "Object is empty. Skipping conversion.")
+	}
+	if size < h.conf.MinSourceBytes {
+		return skip(StatusSkippedEmptyObject, fmt.Sprintf("Object is %d bytes, below MIN_SOURCE_BYTES %d. Skipping conversion.", size, h.conf.MinSourceBytes))
+	}
+	return nil
+}
+
+// UseCompressedSourceSkip은 이미 충분히 압축된 원본을 인코딩 전에 건너뛰는 미들웨어를 등록합니다.
+func (h *Handler) UseCompressedSourceSkip() {
+	h.hooks.Use(compressedSourceSkip{threshold: h.conf.SkipBytesPerPixel})
+}
+
+// compressedSourceSkip은 원본의 픽셀당 바이트가 threshold보다 작으면 SKIPPED_ALREADY_OPTIMIZED로 끝냅니다.
+// 작고 강하게 압축된 WebP·JPEG는 다시 인코딩해도 줄어드는 양이 거의 없어 백필 시간만 씁니다.
+// skipAlreadyAVIF와 같이 크기 조정이나 처리 단계를 요청한 경우에는 건너뛰지 않습니다.
+type compressedSourceSkip struct {
+	threshold float64
+}
+
+func (s compressedSourceSkip) PostDecode(ctx context.Context, job *Job) error {
+	transformed := job.Steps.Len() > 0 || job.Preset != nil || job.Event.Rotate != 0 || job.Event.Flip != ""
+	pixels := float64(job.Image.Width()) * float64(job.Image.Height())
+	if transformed || pixels == 0 {
+		return nil
+	}
+	bpp := float64(len(job.Source)) / pixels
+	if bpp >= s.threshold {
+		return nil
+	}
+	return skip(StatusSkippedAlreadyOptimized, fmt.Sprintf("Source is %.3f bytes per pixel, below SKIP_BYTES_PER_PIXEL %g. Skipping conversion.", bpp, s.threshold))
+}
@@ -0,0 +1,130 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/appconfigdata"
+)
+
+// 기능 플래그 이름입니다. 플래그 문서에 없으면 환경 변수 설정을 그대로 씁니다.
+const (
+	// flagJXLOutput은 JXL_OUTPUT 대신 JXL 대체 출력을 켭니다.
+	flagJXLOutput = "jxl-output"
+	// flagJPEGFallback은 JPEG_FALLBACK 대신 JPEG 대체 출력을 켭니다.
+	flagJPEGFallback = "jpeg-fallback"
+)
+
+// AppConfigDataAPI는 기능 플래그를 읽는 데 사용하는 AppConfig 호출입니다.
+type AppConfigDataAPI interface {
+	StartConfigurationSession(ctx context.Context, params *appconfigdata.StartConfigurationSessionInput, optFns ...func(*appconfigdata.Options)) (*appconfigdata.StartConfigurationSessionOutput, error)
+	GetLatestConfiguration(ctx context.Context, params *appconfigdata.GetLatestConfigurationInput, optFns ...func(*appconfigdata.Options)) (*appconfigdata.GetLatestConfigurationOutput, error)
+}
+
+// featureFlag는 AppConfig 기능 플래그 프로필(AWS.AppConfig.FeatureFlags)의 플래그 하나입니다.
+// buckets와 percent는 플래그 속성으로 정의해 버킷별로, 또는 원본 키 일부에만 단계적으로 켭니다.
+type featureFlag struct {
+	Enabled bool `json:"enabled"`
+	// Buckets가 있으면 이 원본 버킷들에서만 켭니다.
+	Buckets []string `json:"buckets,omitempty"`
+	// Percent는 켤 원본 키의 비율(0~100)입니다. 키 해시로 정하므로 같은 키는 항상 같은 결과입니다. 없으면 100입니다.
+	Percent *float64 `json:"percent,omitempty"`
+}
+
+// UseFeatureFlags는 AppConfig 기능 플래그를 연결합니다. 실행 역할에 appconfig:StartConfigurationSession /
+// appconfig:GetLatestConfiguration 권한이 필요합니다.
+func (h *Handler) UseFeatureFlags(api AppConfigDataAPI) {
+	h.flags = &featureFlags{
+		api:     api,
+		clock:   h.clock,
+		app:     h.conf.AppConfigApplication,
+		env:     h.conf.AppConfigEnvironment,
+		profile: h.conf.AppConfigProfile,
+	}
+}
+
+// featureFlags는 AppConfig 구성 세션으로 플래그 문서를 가져와 캐시합니다.
+// AppConfig가 알려 준 폴링 간격이 지난 뒤에만 다시 묻고, 바뀌지 않았으면 빈 응답을 받아 이전 문서를 씁니다.
+type featureFlags struct {
+	api               AppConfigDataAPI
+	clock             Clock
+	app, env, profile string
+	mu                sync.Mutex
+	token             *string
+	next              time.Time
+	flags             map[string]featureFlag
+}
+
+// Enabled는 job의 원본에 플래그가 켜져 있는지 돌려줍니다. 플래그를 쓰지 않거나, 문서에 플래그가 없거나,
+// 한 번도 읽지 못했으면 def(환경 변수 설정)입니다.
+func (f *featureFlags) Enabled(ctx context.Context, name string, job *Job, def bool) bool {
+	if f == nil {
+		return def
+	}
+	flag, ok := f.lookup(ctx, name)
+	if !ok {
+		return def
+	}
+	if !flag.Enabled || (len(flag.Buckets) > 0 && !slices.Contains(flag.Buckets, job.Bucket)) {
+		return false
+	}
+	if flag.Percent == nil {
+		return true
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(job.Bucket + "/" + job.SrcKey))
+	return float64(hash.Sum32()%10000) < *flag.Percent*100
+}
+
+func (f *featureFlags) lookup(ctx context.Context, name string) (featureFlag, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.clock.Now().Before(f.next) {
+		if err := f.poll(ctx); err != nil {
+			// 다음 호출에서 다시 시도하되, 그동안은 마지막으로 받은 문서를 씁니다.
+			log.Printf("Warning: failed to refresh feature flags, using the last known flags: %v", err)
+			f.next = f.clock.Now().Add(30 * time.Second)
+		}
+	}
+	flag, ok := f.flags[name]
+	return flag, ok
+}
+
+func (f *featureFlags) poll(ctx context.Context) error {
+	if f.token == nil {
+		session, err := f.api.StartConfigurationSession(ctx, &appconfigdata.StartConfigurationSessionInput{
+			ApplicationIdentifier:          aws.String(f.app),
+			EnvironmentIdentifier:          aws.String(f.env),
+			ConfigurationProfileIdentifier: aws.String(f.profile),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start AppConfig session: %w", err)
+		}
+		f.token = session.InitialConfigurationToken
+	}
+	out, err := f.api.GetLatestConfiguration(ctx, &appconfigdata.GetLatestConfigurationInput{ConfigurationToken: f.token})
+	if err != nil {
+		// 토큰은 24시간 뒤 만료되므로 다음에는 세션을 새로 엽니다.
+		f.token = nil
+		return fmt.Errorf("failed to get AppConfig configuration: %w", err)
+	}
+	f.token = out.NextPollConfigurationToken
+	f.next = f.clock.Now().Add(time.Duration(out.NextPollIntervalInSeconds) * time.Second)
+	if len(out.Configuration) == 0 {
+		return nil
+	}
+	var flags map[string]featureFlag
+	if err := json.Unmarshal(out.Configuration, &flags); err != nil {
+		return fmt.Errorf("invalid feature flags %s: %w", aws.ToString(out.VersionLabel), err)
+	}
+	f.flags = flags
+	log.Printf("Feature flags loaded: version=%s, %d flags", aws.ToString(out.VersionLabel), len(flags))
+	return nil
+}
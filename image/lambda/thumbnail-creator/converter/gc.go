@@ -0,0 +1,245 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// GCRequest는 "mode": "gc" 요청의 설정입니다. EventBridge 예약 규칙의 입력으로 주기적으로 실행하는 용도입니다.
+// OutputPrefix 아래의 출력마다 원본 키(<OutputPrefix><원본 키>에서 확장자·접미사를 뗀 것)가 SourcePrefix 아래에
+// 있는지 확인하고, 없으면 지웁니다. 출력과 원본이 같은 위치에 섞여 있으면 둘을 구분할 수 없으므로, 같은 버킷이면
+// OutputPrefix가 있어야 하고 SourcePrefix가 그 아래이면 안 됩니다. OutputPrefix 아래 객체는 원본으로 보지 않습니다.
+type GCRequest struct {
+	OutputBucket string `json:"outputBucket,omitempty"` // 기본 s3Bucket
+	OutputPrefix string `json:"outputPrefix"`           // 예: 테넌트의 outputPrefix
+	SourceBucket string `json:"sourceBucket,omitempty"` // 기본 s3Bucket
+	SourcePrefix string `json:"sourcePrefix,omitempty"`
+	// DryRun이 false가 아니면 지우지 않고 보고서만 남깁니다. 기본 true입니다.
+	DryRun *bool `json:"dryRun,omitempty"`
+	// MinAgeHours보다 최근에 바뀐 출력은 지우지 않습니다. 원본을 다시 올리는 도중의 출력을 지키려는 것입니다. 기본 24
+	MinAgeHours *int `json:"minAgeHours,omitempty"`
+	// MaxDeletes는 한 번에 지울 최대 개수입니다. 기본 GC_MAX_DELETES
+	MaxDeletes int `json:"maxDeletes,omitempty"`
+	// ReportKey는 보고서 키입니다. 기본 <GC_REPORTS_PREFIX><시각>.json이며, REPORTS_BUCKET(없으면 출력 버킷)에 올립니다.
+	ReportKey string `json:"reportKey,omitempty"`
+}
+
+// GCReport는 정리 결과 요약입니다. 고아 출력 목록은 보고서 객체에만 들어갑니다.
+type GCReport struct {
+	OutputBucket string `json:"outputBucket"`
+	OutputPrefix string `json:"outputPrefix"`
+	SourceBucket string `json:"sourceBucket"`
+	SourcePrefix string `json:"sourcePrefix"`
+	DryRun       bool   `json:"dryRun"`
+	Sources      int    `json:"sources"`
+	Scanned      int    `json:"scanned"`
+	// Orphans와 OrphanBytes는 원본이 없는 출력이며, 그중 MinAgeHours보다 최근인 출력 수는 Recent입니다.
+	Orphans     int   `json:"orphans"`
+	OrphanBytes int64 `json:"orphanBytes"`
+	Recent      int   `json:"recent,omitempty"`
+	Deleted     int   `json:"deleted"`
+	Failed      int   `json:"failed,omitempty"`
+	// Truncated는 MaxDeletes나 남은 실행 시간 때문에 일부 고아 출력을 지우지 않았으면 true입니다.
+	Truncated bool   `json:"truncated,omitempty"`
+	ReportKey string `json:"reportKey,omitempty"`
+}
+
+// gcOrphan은 보고서의 고아 출력 하나입니다.
+type gcOrphan struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"lastModified"`
+	// Action은 deleted, failed, recent(MinAgeHours보다 최근), kept(dry-run, 한도 초과) 중 하나입니다.
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// gcReportFile은 REPORTS_BUCKET에 올리는 보고서 객체입니다.
+type gcReportFile struct {
+	GeneratedAt string     `json:"generatedAt"`
+	Summary     GCReport   `json:"summary"`
+	Orphans     []gcOrphan `json:"orphans"`
+}
+
+// GC는 원본이 지워진 출력(고아 출력)을 찾아 지우고 보고서를 올립니다.
+// 출력 키는 원본 키의 확장자를 바꾸고 크기 접미사(_w512 등)나 부가 접미사(.sprite 등)를 붙인 것이므로,
+// 출력의 기준 경로에서 확장자와 접미사를 하나씩 떼어 가며 확장자를 뺀 원본 키와 맞춰 봅니다.
+// OUTPUT_NAMING=content의 해시 키는 원본 경로가 드러나지 않으므로 이 방법으로 정리할 수 없습니다.
+func (h *Handler) GC(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if event.GC == nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: gc requires gc settings")
+	}
+	req := *event.GC
+	if req.OutputBucket == "" {
+		req.OutputBucket = event.S3Bucket
+	}
+	if req.SourceBucket == "" {
+		req.SourceBucket = event.S3Bucket
+	}
+	if req.OutputBucket == "" || req.SourceBucket == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: gc requires s3Bucket or outputBucket and sourceBucket")
+	}
+	sameBucket := req.OutputBucket == req.SourceBucket
+	if sameBucket && (req.OutputPrefix == "" || strings.HasPrefix(req.SourcePrefix, req.OutputPrefix)) {
+		return ConversionResult{}, fmt.Errorf("invalid event: gc outputs and sources in bucket %s need a separate outputPrefix (outputPrefix %q, sourcePrefix %q)", req.OutputBucket, req.OutputPrefix, req.SourcePrefix)
+	}
+	minAge := 24
+	if req.MinAgeHours != nil {
+		minAge = *req.MinAgeHours
+	}
+	if minAge < 0 {
+		return ConversionResult{}, fmt.Errorf("invalid event: gc minAgeHours must not be negative")
+	}
+	if req.MaxDeletes == 0 {
+		req.MaxDeletes = h.conf.GCMaxDeletes
+	}
+	if req.MaxDeletes < 0 {
+		return ConversionResult{}, fmt.Errorf("invalid event: gc maxDeletes must not be negative")
+	}
+	now := h.clock.Now().UTC()
+	reportBucket := h.conf.ReportsBucket
+	if reportBucket == "" {
+		reportBucket = req.OutputBucket
+	}
+	if req.ReportKey == "" {
+		req.ReportKey = h.conf.GCReportsPrefix + now.Format("2006-01-02T15-04-05Z") + ".json"
+	}
+	if reportBucket == req.OutputBucket && strings.HasPrefix(req.ReportKey, req.OutputPrefix) {
+		return ConversionResult{}, fmt.Errorf("invalid event: gc reportKey %s is under outputPrefix %q", req.ReportKey, req.OutputPrefix)
+	}
+
+	report := GCReport{
+		OutputBucket: req.OutputBucket,
+		OutputPrefix: req.OutputPrefix,
+		SourceBucket: req.SourceBucket,
+		SourcePrefix: req.SourcePrefix,
+		DryRun:       req.DryRun == nil || *req.DryRun,
+		ReportKey:    req.ReportKey,
+	}
+	sources := map[string]bool{}
+	err := h.listObjects(ctx, h.s3, req.SourceBucket, req.SourcePrefix, func(obj types.Object) {
+		key := aws.ToString(obj.Key)
+		if sameBucket && strings.HasPrefix(key, req.OutputPrefix) {
+			return
+		}
+		sources[h.outputKey(replaceExtension(key, ""))] = true
+		report.Sources++
+	})
+	if err != nil {
+		return ConversionResult{}, err
+	}
+
+	var orphans []gcOrphan
+	outputs := h.outputClient(req.OutputBucket)
+	err = h.listObjects(ctx, outputs, req.OutputBucket, req.OutputPrefix, func(obj types.Object) {
+		report.Scanned++
+		key := aws.ToString(obj.Key)
+		if derivedFrom(replaceExtension(strings.TrimPrefix(key, req.OutputPrefix), ""), sources) {
+			return
+		}
+		modified := aws.ToTime(obj.LastModified)
+		orphan := gcOrphan{Key: key, Size: aws.ToInt64(obj.Size), LastModified: modified.UTC().Format(time.RFC3339), Action: "kept"}
+		report.Orphans++
+		report.OrphanBytes += orphan.Size
+		if now.Sub(modified) < time.Duration(minAge)*time.Hour {
+			report.Recent++
+			orphan.Action = "recent"
+		}
+		orphans = append(orphans, orphan)
+	})
+	if err != nil {
+		return ConversionResult{}, err
+	}
+
+	var errs []ResultError
+	for i := range orphans {
+		o := &orphans[i]
+		if report.DryRun || o.Action == "recent" {
+			continue
+		}
+		if report.Deleted+report.Failed >= req.MaxDeletes {
+			report.Truncated = true
+			break
+		}
+		if err := h.checkBudget(ctx, "gc delete"); err != nil {
+			log.Printf("Warning: stopping gc early: %v", err)
+			report.Truncated = true
+			break
+		}
+		if _, err := outputs.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(req.OutputBucket), Key: aws.String(o.Key)}); err != nil {
+			log.Printf("Warning: failed to delete orphaned output s3://%s/%s: %v", req.OutputBucket, o.Key, err)
+			o.Action, o.Error = "failed", err.Error()
+			report.Failed++
+			errs = append(errs, resultError(err, o.Key))
+			continue
+		}
+		o.Action = "deleted"
+		report.Deleted++
+	}
+
+	body, err := json.MarshalIndent(gcReportFile{GeneratedAt: now.Format(time.RFC3339), Summary: report, Orphans: orphans}, "", "  ")
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode gc report: %w", err)
+	}
+	if err := h.putObject(ctx, reportBucket, req.ReportKey, "application/json", body); err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to upload gc report %s: %w", req.ReportKey, err)
+	}
+
+	msg := fmt.Sprintf("GC of s3://%s/%s: %d outputs scanned, %d orphans (%d bytes), %d deleted", req.OutputBucket, req.OutputPrefix, report.Scanned, report.Orphans, report.OrphanBytes, report.Deleted)
+	if report.DryRun {
+		msg += " (dry run)"
+	}
+	log.Println(msg)
+	return ConversionResult{
+		Status:  StatusGCCompleted,
+		NewKey:  req.ReportKey,
+		Outputs: []OutputResult{{Key: req.ReportKey, Format: "json", Size: int64(len(body))}},
+		Message: msg,
+		Errors:  errs,
+		GC:      &report,
+	}, nil
+}
+
+// derivedFrom은 출력의 기준 경로(확장자를 뗀 키)가 sources의 원본 하나에서 나왔는지 확인합니다.
+// 파일 이름 끝에서 "_"나 "."로 시작하는 접미사를 하나씩 떼며 비교하고, 디렉터리 부분은 떼지 않습니다.
+func derivedFrom(stem string, sources map[string]bool) bool {
+	dir := strings.LastIndex(stem, "/")
+	for {
+		if sources[stem] {
+			return true
+		}
+		i := strings.LastIndexAny(stem, "._")
+		if i <= dir {
+			return false
+		}
+		stem = stem[:i]
+	}
+}
+
+// listObjects는 prefix 아래 객체마다 fn을 호출합니다. "/"로 끝나는 디렉터리 표시 객체는 뺍니다.
+func (h *Handler) listObjects(ctx context.Context, client S3API, bucket, prefix string, fn func(types.Object)) error {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)}
+	for {
+		out, err := client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, obj := range out.Contents {
+			if !strings.HasSuffix(aws.ToString(obj.Key), "/") {
+				fn(obj)
+			}
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			return nil
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+}
@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// restoreEstimates는 저장 클래스(Intelligent-Tiering은 접근 계층)와 복원 등급별 예상 복원 시간입니다.
+// AWS가 안내하는 범위의 끝값이며, 재시도 시각을 넉넉히 잡으려는 것입니다.
+var restoreEstimates = map[string]map[types.Tier]time.Duration{
+	string(types.StorageClassGlacier):                           {types.TierExpedited: 5 * time.Minute, types.TierStandard: 5 * time.Hour, types.TierBulk: 12 * time.Hour},
+	string(types.StorageClassDeepArchive):                       {types.TierStandard: 12 * time.Hour, types.TierBulk: 48 * time.Hour},
+	string(types.IntelligentTieringAccessTierArchiveAccess):     {types.TierStandard: 5 * time.Hour, types.TierBulk: 12 * time.Hour},
+	string(types.IntelligentTieringAccessTierDeepArchiveAccess): {types.TierStandard: 12 * time.Hour, types.TierBulk: 48 * time.Hour},
+}
+
+// SourceArchived는 원본이 Glacier Flexible Retrieval, Deep Archive, Intelligent-Tiering 보관 계층에 있어
+// 복원하기 전에는 읽을 수 없는 경우입니다. GLACIER_RESTORE_TIER가 없으면 이 오류로 실패합니다.
+type SourceArchived struct {
+	Key          string
+	StorageClass string
+}
+
+func (e *SourceArchived) Error() string {
+	return fmt.Sprintf("source %s is archived in %s and must be restored before conversion (set GLACIER_RESTORE_TIER to restore automatically)", e.Key, e.StorageClass)
+}
+
+// archivedSource는 다운로드 오류가 보관된 원본 때문이면 복원을 요청하고 RETRY_AFTER_RESTORE로 끝냅니다.
+// 복원이 이미 진행 중이어도 같은 상태를 돌려주며, 그 밖의 오류는 그대로 돌려줍니다.
+func (h *Handler) archivedSource(ctx context.Context, job *Job, err error) error {
+	var state *types.InvalidObjectState
+	if !errors.As(err, &state) {
+		return err
+	}
+	class := string(state.StorageClass)
+	if state.AccessTier != "" {
+		class = string(state.AccessTier)
+	}
+	if h.conf.GlacierRestoreTier == "" {
+		return &SourceArchived{Key: job.SrcKey, StorageClass: class}
+	}
+	tier := types.Tier(h.conf.GlacierRestoreTier)
+	if _, ok := restoreEstimates[class][tier]; !ok {
+		// Deep Archive와 Intelligent-Tiering 보관 계층에는 Expedited가 없습니다.
+		tier = types.TierStandard
+	}
+	restore := &types.RestoreRequest{GlacierJobParameters: &types.GlacierJobParameters{Tier: tier}}
+	// Intelligent-Tiering 객체는 복원하면 자주 접근 계층으로 돌아오므로 보관 기간을 지정하지 않습니다.
+	if state.AccessTier == "" {
+		restore.Days = aws.Int32(int32(h.conf.GlacierRestoreDays))
+	}
+	_, restoreErr := h.s3.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket:         aws.String(job.Bucket),
+		Key:            aws.String(job.SrcKey),
+		RestoreRequest: restore,
+	})
+	var api smithy.APIError
+	inProgress := errors.As(restoreErr, &api) && api.ErrorCode() == "RestoreAlreadyInProgress"
+	if restoreErr != nil && !inProgress {
+		return fmt.Errorf("failed to request restore of archived source %s: %w", job.SrcKey, restoreErr)
+	}
+	retryAfter := h.clock.Now().Add(restoreEstimates[class][tier]).UTC()
+	msg := fmt.Sprintf("Source is archived in %s. Requested a %s restore, retry after %s.", class, tier, retryAfter.Format(time.RFC3339))
+	if inProgress {
+		msg = fmt.Sprintf("Source is archived in %s and a restore is already in progress. Retry after %s.", class, retryAfter.Format(time.RFC3339))
+	} else {
+		job.SourceChanges = append(job.SourceChanges, fmt.Sprintf("restore requested (%s)", tier))
+	}
+	log.Println(msg)
+	return &skipError{Status: StatusRetryAfterRestore, Message: msg, RetryAfter: retryAfter.Format(time.RFC3339)}
+}
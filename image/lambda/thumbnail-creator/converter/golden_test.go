@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/golden from the current conversion results")
+
+// goldenResult는 골든 파일에 남기는 변환 결과입니다. 인코더 버전에 따라 바뀌는 출력 크기(바이트)는 남기지 않습니다.
+type goldenResult struct {
+	Status Status `json:"status,omitempty"`
+	Format string `json:"format,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Alpha  string `json:"alpha,omitempty"`
+	// Bands는 업로드된 주 출력을 다시 디코딩한 밴드 수입니다. 알파가 남아 있으면 4(흑백이면 2)입니다.
+	Bands int `json:"bands,omitempty"`
+	// Error는 HandleRequest가 오류를 돌려준 경우 true입니다. libvips 오류 문구는 버전마다 달라 남기지 않습니다.
+	Error bool `json:"error,omitempty"`
+}
+
+// goldenCase는 testdata의 원본 하나를 기본 설정으로 변환하는 경우입니다.
+type goldenCase struct {
+	name string
+	key  string
+	// fixture는 testdata의 원본 파일이고, 비어 있으면 source가 원본을 만듭니다.
+	fixture string
+	source  func(t *testing.T) []byte
+	env     map[string]string
+	// requires는 이 경우에 필요한 libvips 연산입니다. 빌드에 없으면 건너뜁니다.
+	requires string
+}
+
+func TestGolden(t *testing.T) {
+	runGolden(t, []goldenCase{
+		{name: "jpeg", key: "golden/photo.jpg", fixture: "photo.jpg"},
+		{name: "png-alpha", key: "golden/alpha.png", fixture: "alpha.png"},
+		{name: "cmyk", key: "golden/cmyk.jpg", fixture: "cmyk.jpg"},
+		{name: "animated-gif", key: "golden/animated.gif", fixture: "animated.gif", requires: "gifload_buffer"},
+		{name: "heic", key: "golden/photo.heic", source: heifFixture, requires: "heifsave_buffer"},
+		{name: "corrupt", key: "golden/corrupt.jpg", fixture: "corrupt.jpg"},
+	})
+}
+
+// heifFixture는 photo.jpg를 HEIF 컨테이너로 다시 인코딩한 원본입니다.
+// 빌드 단계의 libheif에는 HEVC 인코더가 없으므로 AV1로 인코딩하며, 변환기는 둘 다 heifload로 읽습니다.
+func heifFixture(t *testing.T) []byte {
+	return encodeAVIF(t, readFixture(t, "photo.jpg"))
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+// runGolden은 경우마다 변환 결과를 testdata/golden/<name>.json과 비교합니다. -update이면 결과로 파일을 다시 씁니다.
+func runGolden(t *testing.T, cases []goldenCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			startVips(t)
+			if tc.requires != "" && !vips.HasOperation(tc.requires) {
+				t.Skipf("libvips was built without %s", tc.requires)
+			}
+			source := tc.source
+			if source == nil {
+				source = func(t *testing.T) []byte { return readFixture(t, tc.fixture) }
+			}
+			client := newFakeS3()
+			client.put("uploads", tc.key, source(t))
+			h := newTestHandler(t, client, tc.env)
+
+			got := convertGolden(t, h, client, tc.key)
+			path := filepath.Join("testdata", "golden", tc.name+".json")
+			if *update {
+				data, err := json.MarshalIndent(got, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run go test -update to create it): %v", err)
+			}
+			var want goldenResult
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("invalid golden file %s: %v", path, err)
+			}
+			if got != want {
+				t.Errorf("result differs from %s\n got: %+v\nwant: %+v", path, got, want)
+			}
+		})
+	}
+}
+
+// convertGolden은 key를 변환하고 결과와 업로드된 주 출력을 goldenResult로 요약합니다.
+func convertGolden(t *testing.T, h *Handler, client *fakeS3, key string) goldenResult {
+	t.Helper()
+	result, err := h.HandleRequest(context.Background(), S3Event{S3Bucket: "uploads", S3Key: key})
+	if err != nil {
+		t.Logf("HandleRequest: %v", err)
+		if puts := client.uploaded(); len(puts) > 0 {
+			t.Errorf("uploaded %v for a failed conversion", puts)
+		}
+		return goldenResult{Error: true}
+	}
+	got := goldenResult{Status: result.Status, Format: result.Format, Alpha: result.Alpha}
+	if len(result.Outputs) == 0 {
+		return got
+	}
+	primary := result.Outputs[0]
+	got.Width, got.Height = primary.Width, primary.Height
+	body, ok := client.object("uploads", primary.Key)
+	if !ok {
+		t.Fatalf("%s was not uploaded, uploaded %v", primary.Key, client.uploaded())
+	}
+	output, err := vips.NewImageFromBuffer(body, nil)
+	if err != nil {
+		t.Fatalf("failed to decode uploaded %s: %v", primary.Key, err)
+	}
+	defer output.Close()
+	got.Bands = output.Bands()
+	return got
+}
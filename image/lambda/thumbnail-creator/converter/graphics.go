@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// graphicsLoaders는 스크린샷, 로고 같은 그래픽 콘텐츠가 주로 담겨 오는 로더 접두사입니다.
+// JPEG 등 사진 위주 포맷은 휴리스틱 대상에서 제외합니다.
+var graphicsLoaders = []string{"pngload", "gifload", "webpload"}
+
+// colorSampleSize는 색상 수를 셀 때 사용하는 샘플 이미지의 최대 변 길이입니다.
+const colorSampleSize = 256
+
+// detectGraphics는 입력 이미지가 무손실로 인코딩해야 할 그래픽 콘텐츠인지 판별합니다.
+// 판별 결과와 함께 로그에 남길 사유를 돌려줍니다.
+func detectGraphics(image *vips.Image, loader string, c Config) (bool, string) {
+	switch c.GraphicsMode {
+	case "always":
+		return true, "forced by GRAPHICS_MODE=always"
+	case "never":
+		return false, "disabled by GRAPHICS_MODE=never"
+	}
+
+	if !hasGraphicsLoader(loader) {
+		return false, fmt.Sprintf("loader %q is not a graphics loader", loader)
+	}
+
+	// 팔레트(인덱스 컬러) PNG/GIF는 대부분 그래픽입니다.
+	if image.HasField("palette-bit-depth") {
+		return true, "palette-indexed source"
+	}
+	if palette, err := image.GetInt("palette"); err == nil && palette != 0 {
+		return true, "palette-indexed source"
+	}
+
+	// 알파가 있는 로고는 가장자리 안티앨리어싱으로 색상 수가 늘어나므로 한도를 넉넉히 잡습니다.
+	limit := c.GraphicsMaxColors
+	if image.HasAlpha() {
+		limit *= 4
+	}
+	colors, err := countColors(image, limit)
+	if err != nil {
+		return false, fmt.Sprintf("failed to count colors: %v", err)
+	}
+	if colors <= limit {
+		return true, fmt.Sprintf("%d distinct colors (limit %d, alpha=%t)", colors, limit, image.HasAlpha())
+	}
+	return false, fmt.Sprintf("more than %d distinct colors", limit)
+}
+
+func hasGraphicsLoader(loader string) bool {
+	for _, prefix := range graphicsLoaders {
+		if strings.HasPrefix(loader, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// countColors는 이미지를 점 샘플링으로 축소한 뒤 서로 다른 픽셀 값의 개수를 셉니다.
+// 보간으로 새로운 색이 생기지 않도록 리샘플링 대신 Subsample을 사용하며,
+// limit를 넘으면 즉시 limit+1을 돌려줍니다.
+func countColors(image *vips.Image, limit int) (int, error) {
+	sample, err := image.Copy(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer sample.Close()
+
+	factor := max(1, (max(sample.Width(), sample.Height())+colorSampleSize-1)/colorSampleSize)
+	if factor > 1 {
+		if err := sample.Subsample(factor, factor, &vips.SubsampleOptions{Point: true}); err != nil {
+			return 0, err
+		}
+	}
+	if sample.Interpretation() != vips.InterpretationSrgb && sample.Interpretation() != vips.InterpretationBW {
+		if err := sample.Colourspace(vips.InterpretationSrgb, nil); err != nil {
+			return 0, err
+		}
+	}
+	if sample.BandFormat() != vips.BandFormatUchar {
+		if err := sample.Cast(vips.BandFormatUchar, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	pixels, err := sample.RawsaveBuffer(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	bands := sample.Bands()
+	seen := make(map[string]struct{}, limit+1)
+	for i := 0; i+bands <= len(pixels); i += bands {
+		seen[string(pixels[i:i+bands])] = struct{}{}
+		if len(seen) > limit {
+			return limit + 1, nil
+		}
+	}
+	return len(seen), nil
+}
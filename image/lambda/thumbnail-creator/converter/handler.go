@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3API는 Handler가 사용하는 S3 클라이언트 메서드입니다. *s3.Client가 구현하며,
+// 테스트에서는 메모리 기반 가짜 구현으로 바꿀 수 있습니다.
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	RestoreObject(ctx context.Context, params *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+}
+
+// Clock은 현재 시각을 돌려줍니다. 시간을 재는 코드는 time.Now 대신 Handler의 clock을 사용합니다.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Handler는 Lambda 호출을 처리하며 변환에 필요한 의존성을 모두 들고 있습니다.
+type Handler struct {
+	s3       S3API
+	clock    Clock
+	conf     Config
+	encoders encoderRegistry
+	hooks    *hookChain
+	memory   *memoryTracker
+	// records는 RECORDS_TABLE이 설정된 경우에만 있습니다.
+	records *conversionRecords
+	// faces는 FACE_DETECTION이 켜진 경우에만 있습니다.
+	faces FaceAPI
+	// uploadLimiter와 notifyLimiter는 출력 버킷, 알림 대상별 요청 한도입니다. 한도가 없으면 nil입니다.
+	uploadLimiter *rateLimiter
+	notifyLimiter *rateLimiter
+	// breaker는 인코더 회로 차단기입니다. ENCODER_BREAKER_THRESHOLD가 0이면 nil입니다.
+	breaker *encoderBreaker
+	// quarantine은 QUARANTINE_AFTER가 설정된 경우에만 있습니다.
+	quarantine *quarantine
+	// audit은 AUDIT_BUCKET이 설정된 경우에만 있습니다.
+	audit *auditLog
+	// dedup은 DEDUP_TABLE이 설정된 경우에만 있습니다.
+	dedup *eventDedup
+	// notify는 알림 대상(EVENT_BUS_NAME, NOTIFIERS)이 하나라도 있을 때만 있습니다.
+	notify *notifyFanout
+	// flags는 APPCONFIG_APPLICATION이 설정된 경우에만 있습니다.
+	flags *featureFlags
+	// objectLambda는 OBJECT_LAMBDA가 켜진 경우에만 있습니다.
+	objectLambda *objectLambda
+	// destination은 DESTINATION_ROLE_ARN을 맡은 출력용 클라이언트이며, destinationBuckets에 쓸 때만 씁니다.
+	destination        S3API
+	destinationBuckets []string
+
+	// active는 이 Handler로 처리 중인 요청이 읽기 잠금으로 잡습니다. refreshHandler가 종료 전에 쓰기 잠금으로 기다립니다.
+	active sync.RWMutex
+}
+
+// NewHandler는 기본 인코더와 미들웨어가 등록된 Handler를 만듭니다.
+// 인코더 등록은 libvips 기능을 확인하므로 vips.Startup 이후에 호출해야 합니다.
+func NewHandler(client S3API, clock Clock, c Config) *Handler {
+	return &Handler{
+		s3:       countingS3{client},
+		clock:    clock,
+		conf:     c,
+		encoders: newEncoderRegistry(c),
+		hooks:    newHookChain(),
+		memory:   &memoryTracker{},
+
+		uploadLimiter: newRateLimiter(clock, c.UploadRateLimit, c.UploadRateBurst, c.RateLimitMaxWait),
+		notifyLimiter: newRateLimiter(clock, c.NotifyRateLimit, c.NotifyRateBurst, c.RateLimitMaxWait),
+		breaker:       newEncoderBreaker(clock, c.EncoderBreakerThreshold, c.EncoderBreakerCooldown, c.MetricsNamespace),
+	}
+}
+
+// newS3Client는 설정된 엔드포인트, 리전, 주소 방식, 가속·듀얼 스택 옵션으로 S3 클라이언트를 만듭니다.
+// 설정이 없으면 SDK 기본 리전·엔드포인트 해석을 그대로 따릅니다.
+func newS3Client(cfg aws.Config, c Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if c.S3EndpointURL != "" {
+			o.BaseEndpoint = aws.String(c.S3EndpointURL)
+		}
+		if c.S3Region != "" {
+			o.Region = c.S3Region
+		}
+		o.UsePathStyle = c.S3UsePathStyle
+		o.UseAccelerate = c.S3UseAccelerate
+		if c.S3UseDualStack {
+			o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		}
+	})
+}
+
+// UseRecords는 변환 기록 저장소를 연결하고 기록 미들웨어로 등록합니다.
+func (h *Handler) UseRecords(db DynamoAPI, table string) {
+	h.records = &conversionRecords{db: db, table: table, clock: h.clock}
+	h.hooks.Use(h.records)
+}
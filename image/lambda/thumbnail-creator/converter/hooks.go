@@ -0,0 +1,317 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// Job은 변환 요청 하나의 진행 상태이며 모든 훅에 전달됩니다.
+// 단계가 진행될수록 필드가 채워집니다. (Source → Image/Loader → Result)
+type Job struct {
+	Event   S3Event
+	Bucket  string
+	SrcKey  string
+	BaseKey string
+	Steps   *pipeline.Pipeline
+	Preset  *Preset
+
+	// Tenant는 원본 버킷·키로 찾은 테넌트이며 없으면 nil입니다. OutputBucket은 출력을 올릴 버킷입니다.
+	Tenant       *Tenant
+	OutputBucket string
+	// Archive는 아카이브 항목을 변환할 때의 아카이브 키입니다. 이때 SrcKey는 "<아카이브 키>/<항목 경로>"입니다.
+	Archive string
+	// Started는 요청 처리를 시작한 시각입니다.
+	Started time.Time
+	// Background는 알파를 합성할 배경색입니다. 이벤트의 background가 없으면 ALPHA_BACKGROUND입니다.
+	Background []float64
+	// Effort와 Quality는 주 출력에 적용한 인코딩 노력 수준과 품질(0이면 포맷별 기본값)입니다.
+	Effort  int
+	Quality int
+
+	// Source는 다운로드한 원본 바이트입니다. PreDecode 훅에서 교체할 수 있습니다.
+	Source []byte
+	// SourceContentType은 원본 객체에 저장된 Content-Type입니다. SOURCE_CONTENT_TYPE이 켜져 있으면
+	// PreDecode에서 내용(매직 바이트)으로 알아낸 값으로 바뀝니다.
+	SourceContentType string
+	// Extras는 PreDecode/PostDecode 훅이 추가한 부가 출력(동영상 미리보기 등)이며, 주 출력 뒤에 함께 업로드됩니다.
+	Extras []*Upload
+	// Image와 Loader는 디코딩 뒤에 채워집니다.
+	Image  *vips.Image
+	Loader string
+
+	// Serve는 S3 Object Lambda 요청일 때만 있습니다. 이때 출력은 S3에 올리지 않고 응답으로 돌려줍니다.
+	Serve *objectLambdaRequest
+
+	// Uploading은 출력 업로드를 시작했으면 true입니다. 이후의 실패는 원본 때문이 아니므로 격리 횟수에 세지 않습니다.
+	Uploading bool
+
+	// SourceChanges는 원본 객체에 가한 변경(태그, 복사 등)입니다. 감사 레코드에 남습니다.
+	SourceChanges []string
+
+	// Result는 반환할 결과입니다. 업로드된 출력은 PostUpload 훅에서 기록됩니다.
+	Result *ConversionResult
+
+	// dedupLease는 DEDUP_TABLE에 이 요청이 선점한 처리 중 표시의 ExpiresAt(Unix 초)입니다. 선점하지 않았으면 0입니다.
+	dedupLease int64
+
+	// encoderFailed는 이 요청에서 회로 차단기에 실패를 기록한 인코더입니다. (firstEncoderFailure)
+	encoderFailed map[string]bool
+
+	// mu는 프리셋 크기를 동시에 인코딩하는 동안 Result 필드를 읽고 쓸 때 잡습니다. 훅은 동시에 호출되지 않습니다.
+	mu sync.Mutex
+}
+
+// firstEncoderFailure는 이 요청에서 format 인코더가 처음 실패했으면 true를 돌려줍니다.
+// 회로 차단기는 요청 하나의 실패를 한 번만 셉니다.
+func (j *Job) firstEncoderFailure(format string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.encoderFailed[format] {
+		return false
+	}
+	if j.encoderFailed == nil {
+		j.encoderFailed = map[string]bool{}
+	}
+	j.encoderFailed[format] = true
+	return true
+}
+
+// Upload는 S3에 올릴 출력 파일 하나입니다. PreUpload 훅에서 Key나 Body를 바꿀 수 있습니다.
+type Upload struct {
+	Key    string
+	Format string
+	Body   []byte
+	Width  int
+	Height int
+	// Primary는 변형(variant)의 주 출력이면 true, JXL/JPEG 대체 출력이면 false입니다.
+	Primary bool
+	// Overwrite는 같은 키의 객체가 이미 있으면 true입니다. 확인하는 미들웨어(cdnInvalidator)가 채웁니다.
+	Overwrite bool
+	// LogicalKey는 내용 주소 키를 쓸 때 원래 키입니다. (contentAddresser)
+	// SHA256은 Body의 해시(16진)이며, 내용 주소 키나 업로드 검증을 쓸 때 채워집니다.
+	LogicalKey string
+	SHA256     string
+	// EncodeMs는 Body를 인코딩하는 데 걸린 시간(밀리초)입니다. 디코딩 훅이 만든 부가 출력은 0입니다.
+	EncodeMs int64
+	// Derivative, StorageClass, CacheControl은 derivatives 요청의 출력일 때 그 파생 출력의 이름과 업로드 설정입니다.
+	Derivative   string
+	StorageClass string
+	CacheControl string
+}
+
+// PreDecodeHook은 원본을 다운로드한 뒤, 디코딩하기 전에 호출됩니다.
+type PreDecodeHook interface {
+	PreDecode(ctx context.Context, job *Job) error
+}
+
+// PostDecodeHook은 디코딩 직후, 파이프라인과 인코딩 전에 호출됩니다.
+type PostDecodeHook interface {
+	PostDecode(ctx context.Context, job *Job) error
+}
+
+// PreUploadHook은 출력 파일마다 업로드 직전에 호출됩니다.
+type PreUploadHook interface {
+	PreUpload(ctx context.Context, job *Job, upload *Upload) error
+}
+
+// PostUploadHook은 출력 파일마다 업로드가 성공한 뒤 호출됩니다.
+type PostUploadHook interface {
+	PostUpload(ctx context.Context, job *Job, output OutputResult) error
+}
+
+// PostConvertHook은 모든 출력이 업로드되어 변환이 성공한 뒤 한 번 호출됩니다.
+type PostConvertHook interface {
+	PostConvert(ctx context.Context, job *Job) error
+}
+
+// FailureHook은 변환이 오류로 끝나면 호출됩니다. 건너뛴 요청(skipError)에서는 호출되지 않습니다.
+type FailureHook interface {
+	OnFailure(ctx context.Context, job *Job, err error)
+}
+
+// ShutdownHook은 실행 환경이 종료될 때 한 번 호출됩니다. 버퍼링한 텔레메트리를 내보낼 때 사용합니다.
+type ShutdownHook interface {
+	Shutdown()
+}
+
+// skipError를 돌려주는 훅은 변환을 오류 없이 중단시키고, 해당 상태를 결과로 반환하게 합니다.
+type skipError struct {
+	Status  Status
+	Message string
+	// RetryAfter는 RETRY_AFTER_RESTORE에서 다시 요청할 시각(RFC3339)입니다.
+	RetryAfter string
+}
+
+func (e *skipError) Error() string {
+	return string(e.Status) + ": " + e.Message
+}
+
+func skip(status Status, message string) error {
+	return &skipError{Status: status, Message: message}
+}
+
+// hookChain은 등록된 미들웨어를 단계별로 모아 등록 순서대로 실행합니다.
+type hookChain struct {
+	preDecode  []PreDecodeHook
+	postDecode []PostDecodeHook
+	preUpload  []PreUploadHook
+	postUpload []PostUploadHook
+	postConv   []PostConvertHook
+	failure    []FailureHook
+	shutdown   []ShutdownHook
+}
+
+// Use는 미들웨어를 등록합니다. 하나의 값이 여러 훅 인터페이스를 구현하면 각 단계에 모두 등록됩니다.
+func (h *hookChain) Use(middleware any) {
+	registered := false
+	if m, ok := middleware.(PreDecodeHook); ok {
+		h.preDecode = append(h.preDecode, m)
+		registered = true
+	}
+	if m, ok := middleware.(PostDecodeHook); ok {
+		h.postDecode = append(h.postDecode, m)
+		registered = true
+	}
+	if m, ok := middleware.(PreUploadHook); ok {
+		h.preUpload = append(h.preUpload, m)
+		registered = true
+	}
+	if m, ok := middleware.(PostUploadHook); ok {
+		h.postUpload = append(h.postUpload, m)
+		registered = true
+	}
+	if m, ok := middleware.(PostConvertHook); ok {
+		h.postConv = append(h.postConv, m)
+		registered = true
+	}
+	if m, ok := middleware.(FailureHook); ok {
+		h.failure = append(h.failure, m)
+		registered = true
+	}
+	if m, ok := middleware.(ShutdownHook); ok {
+		h.shutdown = append(h.shutdown, m)
+		registered = true
+	}
+	if !registered {
+		panic(fmt.Sprintf("middleware %T implements no hook interface", middleware))
+	}
+}
+
+func (h *hookChain) PreDecode(ctx context.Context, job *Job) error {
+	for _, m := range h.preDecode {
+		if err := m.PreDecode(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hookChain) PostDecode(ctx context.Context, job *Job) error {
+	for _, m := range h.postDecode {
+		if err := m.PostDecode(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hookChain) PreUpload(ctx context.Context, job *Job, upload *Upload) error {
+	for _, m := range h.preUpload {
+		if err := m.PreUpload(ctx, job, upload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hookChain) PostUpload(ctx context.Context, job *Job, output OutputResult) error {
+	for _, m := range h.postUpload {
+		if err := m.PostUpload(ctx, job, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hookChain) PostConvert(ctx context.Context, job *Job) error {
+	for _, m := range h.postConv {
+		if err := m.PostConvert(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hookChain) OnFailure(ctx context.Context, job *Job, err error) {
+	for _, m := range h.failure {
+		m.OnFailure(ctx, job, err)
+	}
+}
+
+func (h *hookChain) Shutdown() {
+	for _, m := range h.shutdown {
+		m.Shutdown()
+	}
+}
+
+// newHookChain은 기본 동작을 담당하는 미들웨어가 등록된 체인을 만듭니다.
+// 기능별 미들웨어는 NewHandler 이후 handler.hooks.Use로 뒤에 추가합니다.
+func newHookChain() *hookChain {
+	h := &hookChain{}
+	h.Use(skipAlreadyAVIF{})
+	h.Use(sourceGuard{})
+	h.Use(alphaVerifier{})
+	h.Use(outputRecorder{})
+	return h
+}
+
+// skipAlreadyAVIF는 변환할 처리 단계가 없는 AVIF 입력을 건너뜁니다.
+type skipAlreadyAVIF struct{}
+
+func (skipAlreadyAVIF) PostDecode(ctx context.Context, job *Job) error {
+	transformed := job.Steps.Len() > 0 || job.Preset != nil || len(job.Event.Derivatives) > 0 || job.Event.Rotate != 0 || job.Event.Flip != ""
+	if strings.HasPrefix(job.Loader, "heifload") && !transformed {
+		return skip(StatusSkippedAlreadyAVIF, "Image is already in AVIF format. Skipping conversion.")
+	}
+	return nil
+}
+
+// sourceGuard는 출력이 원본 객체를 덮어쓰지 않도록 막습니다.
+type sourceGuard struct{}
+
+func (sourceGuard) PreUpload(ctx context.Context, job *Job, upload *Upload) error {
+	if upload.Key == job.SrcKey && job.OutputBucket == job.Bucket {
+		return fmt.Errorf("output key %s would overwrite the source object", upload.Key)
+	}
+	return nil
+}
+
+// alphaVerifier는 알파를 유지하기로 한 경우 인코딩 결과에 알파 채널이 남아 있는지 확인합니다.
+type alphaVerifier struct{}
+
+func (alphaVerifier) PreUpload(ctx context.Context, job *Job, upload *Upload) error {
+	if job.Result.Alpha != "preserved" || !formatSupportsAlpha(upload.Format) {
+		return nil
+	}
+	return verifyAlphaPreserved(upload.Body, upload.Format)
+}
+
+// outputRecorder는 업로드된 출력을 결과에 기록합니다. 첫 번째 주 출력이 NewKey가 됩니다.
+type outputRecorder struct{}
+
+func (outputRecorder) PostUpload(ctx context.Context, job *Job, output OutputResult) error {
+	if job.Result.NewKey == "" {
+		job.Result.NewKey = output.Key
+	}
+	job.Result.Outputs = append(job.Result.Outputs, output)
+	log.Printf("Uploaded %s output: key=%s, size=%d bytes", output.Format, output.Key, output.Size)
+	return nil
+}
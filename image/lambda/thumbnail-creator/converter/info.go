@@ -0,0 +1,136 @@
+package converter
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/berryssoda/test-encode/pipeline"
+	"github.com/cshum/vipsgen/vips"
+)
+
+// buildCommit과 buildVersion은 빌드 시 -ldflags "-X github.com/berryssoda/test-encode/converter.buildCommit=..."로 주입합니다.
+// buildCommit이 비어 있으면 Go 빌드 정보의 vcs.revision을 씁니다.
+var (
+	buildCommit  string
+	buildVersion string
+)
+
+// probedFormats는 info 모드에서 로더·세이버 지원 여부를 확인하는 libvips 포맷입니다.
+var probedFormats = []string{"jpeg", "png", "webp", "heif", "jxl", "tiff", "gif", "svg", "pdf", "jp2k", "magick"}
+
+// sensitiveSettings에 해당하는 이름의 환경 변수는 참조가 아닌 평문이면 값을 가립니다.
+var sensitiveSettings = []string{"SECRET", "TOKEN", "PASSWORD", "CREDENTIAL", "EXTERNAL_ID", "HEADERS", "NOTIFIERS"}
+
+// DeploymentInfo는 "mode": "info" 요청의 응답입니다. 배포가 어떤 포맷과 설정으로 동작하는지 보여 줍니다.
+type DeploymentInfo struct {
+	Vips     string   `json:"vips"`
+	Go       string   `json:"go"`
+	Commit   string   `json:"commit,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	Function string   `json:"function,omitempty"`
+	Loaders  []string `json:"loaders"`
+	Savers   []string `json:"savers"`
+	Encoders []string `json:"encoders"`
+	// Operations는 내장 단계와 pipeline.RegisterOperation으로 등록한 단계입니다.
+	Operations []string `json:"operations"`
+	AVIFCodec  string   `json:"avifEncoder"`
+	// Settings는 설정된 환경 변수입니다. ssm:/secretsmanager: 참조는 해석한 값 대신 참조 그대로,
+	// 비밀로 보이는 이름의 평문 값은 "<redacted>"로, URL 값은 사용자 정보와 쿼리를 지우고 보여 줍니다. 설정하지 않은 변수는 기본값을 씁니다.
+	Settings map[string]string `json:"settings"`
+}
+
+// Info는 libvips 버전, 사용할 수 있는 로더·세이버, 등록된 인코더, 빌드 커밋과 적용 중인 설정을 돌려줍니다.
+func (h *Handler) Info() (ConversionResult, error) {
+	info := &DeploymentInfo{
+		Vips:       vips.Version,
+		Go:         runtime.Version(),
+		Commit:     commitHash(),
+		Version:    buildVersion,
+		Function:   os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		Encoders:   h.encoders.Names(),
+		Operations: pipeline.Operations(),
+		AVIFCodec:  h.conf.AVIFEncoder,
+		Settings:   redactSettings(h.conf.Settings),
+	}
+	for _, format := range probedFormats {
+		if vips.HasOperation(format + "load_buffer") {
+			info.Loaders = append(info.Loaders, format)
+		}
+		if vips.HasOperation(format + "save_buffer") {
+			info.Savers = append(info.Savers, format)
+		}
+	}
+	log.Printf("Reported deployment info: vips %s, commit %s, loaders %v, savers %v", info.Vips, info.Commit, info.Loaders, info.Savers)
+	return ConversionResult{Status: StatusInfo, Message: "vips " + info.Vips, Info: info}, nil
+}
+
+func commitHash() string {
+	if buildCommit != "" {
+		return buildCommit
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+func redactSettings(settings map[string]string) map[string]string {
+	out := make(map[string]string, len(settings))
+	for key, value := range settings {
+		switch {
+		case isSecretRef(value):
+		case isSensitiveSetting(key):
+			value = "<redacted>"
+		default:
+			value = redactURL(value)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// redactURL은 URL로 읽히는 값(PROMETHEUS_PUSH_URL, S3_ENDPOINT_URL, OTEL_EXPORTER_OTLP_*_ENDPOINT, SQS_QUEUE_URL 등)의
+// 사용자 정보와 쿼리를 "redacted"로 바꾸고 프래그먼트를 지웁니다. 설정 이름과 관계없이 자격 증명이 들어갈 수 있는 자리입니다.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+	if u.User == nil && u.RawQuery == "" && u.Fragment == "" {
+		return value
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+func isSensitiveSetting(key string) bool {
+	for _, s := range sensitiveSettings {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package converter
+
+import "github.com/cshum/vipsgen/vips"
+
+// encodeJPEGFallback는 AVIF/WebP를 지원하지 않는 클라이언트용 progressive JPEG을 인코딩합니다.
+// 원본 이미지는 다른 출력에서도 쓰이므로 복사본에서 알파를 background에 합성합니다.
+// trellis 양자화, overshoot deringing, scan 최적화는 libvips가 mozjpeg과 링크된 경우에만 적용되며
+// libjpeg-turbo에서는 무시되고 Huffman 최적화와 progressive 인코딩만 적용됩니다.
+func encodeJPEGFallback(image *vips.Image, keep vips.Keep, background []float64, c Config) ([]byte, error) {
+	fallback, err := image.Copy(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer fallback.Close()
+
+	if _, err := applyAlphaPolicy(fallback, "jpeg", background, c); err != nil {
+		return nil, err
+	}
+	return fallback.JpegsaveBuffer(jpegOptions(c.JPEGQuality, keep, c.Subsample["jpeg"]))
+}
+
+func jpegOptions(quality int, keep vips.Keep, subsample string) *vips.JpegsaveBufferOptions {
+	options := vips.DefaultJpegsaveBufferOptions()
+	options.Q = quality
+	options.OptimizeCoding = true
+	options.Interlace = true
+	options.TrellisQuant = true
+	options.OvershootDeringing = true
+	options.OptimizeScans = true
+	options.QuantTable = 3
+	options.SubsampleMode = subsampleModes[subsample]
+	options.Keep = keep
+	return options
+}
@@ -0,0 +1,28 @@
+package converter
+
+import "github.com/cshum/vipsgen/vips"
+
+// encodeJXL은 실험적 JPEG XL 출력을 인코딩합니다.
+// 그래픽 입력으로 판별되어 주 출력이 무손실이면 JXL도 무손실로 저장합니다.
+// quality가 0이면 JXL_QUALITY를 사용합니다.
+func encodeJXL(image *vips.Image, keep vips.Keep, lossless bool, quality int, c Config) ([]byte, error) {
+	options := vips.DefaultJxlsaveBufferOptions()
+	options.Q = c.JXLQuality
+	if quality > 0 {
+		options.Q = quality
+	}
+	options.Effort = c.JXLEffort
+	options.Lossless = lossless
+	options.Keep = keep
+	return image.JxlsaveBuffer(options)
+}
+
+type jxlEncoder struct{ c Config }
+
+func (jxlEncoder) Name() string        { return "jxl" }
+func (jxlEncoder) ContentType() string { return "image/jxl" }
+
+func (e jxlEncoder) Encode(image *vips.Image, o EncodeOptions) (Encoded, error) {
+	buf, err := encodeJXL(image, o.Keep, o.Graphics, o.Quality, e.c)
+	return Encoded{Data: buf}, err
+}
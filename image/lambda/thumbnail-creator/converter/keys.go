@@ -0,0 +1,39 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// decodeKey는 이벤트의 키를 GetObject에 쓸 원본 키로 바꿉니다. (KEY_DECODING)
+// S3 알림 레코드의 object.key는 URL 인코딩되어(공백은 +) 오지만, 직접 호출·배치 items·HTTP API의 키는 원래 키이므로
+// 기본값 auto는 인코딩된 것으로 알려진 키(encoded, s3KeyEncoded)만 디코딩합니다. 원래 키의 +와 %를 바꾸지 않아야
+// "a+b.jpg"나 "100%.png" 같은 키의 GetObject가 NoSuchKey로 실패하지 않습니다.
+// always는 예전처럼 모든 키를 디코딩하고, never는 디코딩하지 않습니다.
+func (h *Handler) decodeKey(key string, encoded bool) (string, error) {
+	switch h.conf.KeyDecoding {
+	case "never":
+		return key, nil
+	case "auto":
+		if !encoded {
+			return key, nil
+		}
+	}
+	decoded, err := url.QueryUnescape(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode S3 key: %w", err)
+	}
+	return decoded, nil
+}
+
+// outputKey는 원본 키에서 만든 출력 키의 기준 경로를 유니코드 NFC로 정규화합니다. (OUTPUT_KEY_NFC)
+// macOS에서 올린 한글 파일명은 NFD(자모 분리)로 오는 경우가 많아, 정규화하지 않으면 같은 이름이 두 가지 키로 저장됩니다.
+// S3 키는 바이트 단위로 비교하므로 원본 키는 정규화하지 않고 그대로 읽습니다.
+func (h *Handler) outputKey(key string) string {
+	if !h.conf.OutputKeyNFC {
+		return key
+	}
+	return norm.NFC.String(key)
+}
@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// nfdHangul은 macOS가 올리는 자모 분리(NFD) 형태의 "한글"입니다.
+const (
+	nfcHangul = "한글"
+	nfdHangul = "\u1112\u1161\u11ab\u1100\u1173\u11af"
+)
+
+func TestDecodeKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		key      string
+		encoded  bool
+		want     string
+		wantFail bool
+	}{
+		{name: "auto encoded plus", mode: "auto", key: "photos/a+b.jpg", encoded: true, want: "photos/a b.jpg"},
+		{name: "auto raw plus", mode: "auto", key: "photos/a+b.jpg", want: "photos/a+b.jpg"},
+		{name: "auto encoded space", mode: "auto", key: "photos/my%20cat.jpg", encoded: true, want: "photos/my cat.jpg"},
+		{name: "auto raw space", mode: "auto", key: "photos/my cat.jpg", want: "photos/my cat.jpg"},
+		{name: "auto raw percent", mode: "auto", key: "photos/100%.png", want: "photos/100%.png"},
+		{name: "auto encoded slash", mode: "auto", key: "photos%2F2024%2Fa.jpg", encoded: true, want: "photos/2024/a.jpg"},
+		{name: "auto encoded emoji", mode: "auto", key: "photos/%F0%9F%90%B1.png", encoded: true, want: "photos/\U0001f431.png"},
+		{name: "auto raw emoji", mode: "auto", key: "photos/\U0001f431.png", want: "photos/\U0001f431.png"},
+		{name: "auto encoded nfd hangul", mode: "auto", key: "photos/%E1%84%92%E1%85%A1%E1%86%AB%E1%84%80%E1%85%B3%E1%86%AF.jpg", encoded: true, want: "photos/" + nfdHangul + ".jpg"},
+		{name: "auto encoded invalid", mode: "auto", key: "photos/100%.png", encoded: true, wantFail: true},
+		{name: "always raw plus", mode: "always", key: "photos/a+b.jpg", want: "photos/a b.jpg"},
+		{name: "always invalid", mode: "always", key: "photos/100%.png", wantFail: true},
+		{name: "never encoded plus", mode: "never", key: "photos/a+b.jpg", encoded: true, want: "photos/a+b.jpg"},
+		{name: "never encoded percent", mode: "never", key: "photos/100%.png", encoded: true, want: "photos/100%.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{conf: Config{KeyDecoding: tt.mode}}
+			got, err := h.decodeKey(tt.key, tt.encoded)
+			if tt.wantFail {
+				if err == nil {
+					t.Fatalf("decodeKey(%q) = %q, want error", tt.key, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeKey(%q): %v", tt.key, err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputKey(t *testing.T) {
+	tests := []struct {
+		name string
+		nfc  bool
+		key  string
+		want string
+	}{
+		{name: "nfd hangul", nfc: true, key: "photos/" + nfdHangul + ".jpg", want: "photos/" + nfcHangul + ".jpg"},
+		{name: "nfc hangul", nfc: true, key: "photos/" + nfcHangul + ".jpg", want: "photos/" + nfcHangul + ".jpg"},
+		{name: "nfd hangul disabled", nfc: false, key: "photos/" + nfdHangul + ".jpg", want: "photos/" + nfdHangul + ".jpg"},
+		{name: "plus", nfc: true, key: "photos/a+b.jpg", want: "photos/a+b.jpg"},
+		{name: "space", nfc: true, key: "photos/my cat.jpg", want: "photos/my cat.jpg"},
+		{name: "emoji", nfc: true, key: "photos/\U0001f431.png", want: "photos/\U0001f431.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{conf: Config{OutputKeyNFC: tt.nfc}}
+			if got := h.outputKey(tt.key); got != tt.want {
+				t.Errorf("outputKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// keySeeds는 퍼즈 대상의 시드 키입니다. +, %2F, 유니코드, 끝의 점, 숨김 파일, 확장자 없는 이름을 담습니다.
+var keySeeds = []string{
+	"photos/a+b.jpg",
+	"photos%2F2024%2Fa.jpg",
+	"photos/100%.png",
+	"photos/my cat.JPG",
+	"photos/\U0001f431.png",
+	"photos/" + nfdHangul + ".heic",
+	"photos/a.",
+	"photos/a..",
+	"photos/..",
+	"photos/.hidden",
+	"photos/.hidden.png",
+	"photos.d/README",
+	"photos/",
+	"",
+}
+
+func FuzzKeyExtension(f *testing.F) {
+	for _, key := range keySeeds {
+		f.Add(key)
+	}
+	f.Fuzz(func(t *testing.T, key string) {
+		ext := keyExtension(key)
+		if !strings.HasSuffix(key, ext) {
+			t.Fatalf("keyExtension(%q) = %q, not a suffix", key, ext)
+		}
+		if ext == "" {
+			return
+		}
+		name := key[strings.LastIndex(key, "/")+1:]
+		if !strings.HasPrefix(ext, ".") || strings.Contains(ext, "/") || ext == name {
+			t.Fatalf("keyExtension(%q) = %q, want extension of last element %q", key, ext, name)
+		}
+		if strings.Contains(ext[1:], ".") {
+			t.Fatalf("keyExtension(%q) = %q, want a single extension", key, ext)
+		}
+	})
+}
+
+func FuzzReplaceExtension(f *testing.F) {
+	for _, key := range keySeeds {
+		f.Add(key, ".avif")
+		f.Add(key, "")
+	}
+	f.Fuzz(func(t *testing.T, key, newExt string) {
+		ext := keyExtension(key)
+		stem := strings.TrimSuffix(key, ext)
+		got := replaceExtension(key, newExt)
+		if got != stem+newExt {
+			t.Fatalf("replaceExtension(%q, %q) = %q, want %q", key, newExt, got, stem+newExt)
+		}
+		// 새 확장자가 다시 확장자로 읽히면 원래 확장자로 되돌릴 수 있어야 합니다.
+		if keyExtension(got) != newExt {
+			return
+		}
+		if back := replaceExtension(got, ext); back != key {
+			t.Fatalf("replaceExtension(replaceExtension(%q, %q), %q) = %q, want %q", key, newExt, ext, back, key)
+		}
+	})
+}
+
+func FuzzDecodeKey(f *testing.F) {
+	for _, key := range keySeeds {
+		f.Add(key)
+	}
+	auto := &Handler{conf: Config{KeyDecoding: "auto"}}
+	always := &Handler{conf: Config{KeyDecoding: "always"}}
+	never := &Handler{conf: Config{KeyDecoding: "never"}}
+	f.Fuzz(func(t *testing.T, key string) {
+		// S3 알림처럼 인코딩한 키는 원래 키로 돌아와야 합니다.
+		got, err := auto.decodeKey(url.QueryEscape(key), true)
+		if err != nil {
+			t.Fatalf("decodeKey(QueryEscape(%q)): %v", key, err)
+		}
+		if got != key {
+			t.Fatalf("decodeKey(QueryEscape(%q)) = %q", key, got)
+		}
+		// 인코딩되지 않은 키는 auto와 never에서 바뀌지 않습니다.
+		if got, err := auto.decodeKey(key, false); err != nil || got != key {
+			t.Fatalf("auto decodeKey(%q, false) = %q, %v", key, got, err)
+		}
+		if got, err := never.decodeKey(key, true); err != nil || got != key {
+			t.Fatalf("never decodeKey(%q, true) = %q, %v", key, got, err)
+		}
+		// always는 잘못된 % 시퀀스에서 패닉 없이 오류를 돌려줍니다.
+		if _, err := always.decodeKey(key, false); err != nil && !strings.Contains(err.Error(), "failed to decode S3 key") {
+			t.Fatalf("always decodeKey(%q): unexpected error %v", key, err)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Run은 RUN_MODE에 따라 Lambda 런타임, SQS 폴러, 단일 작업 중 하나로 실행합니다. (worker.go 참고)
+// 같은 변환기를 HTTP로 제공하는 실행 파일은 cmd/thumbnail-api입니다. (ServeAPI)
+func Run() {
+	switch current.Load().conf.RunMode {
+	case "sqs":
+		runSQSPoller(sqs.NewFromConfig(awsConfig))
+	case "worker":
+		runWorker()
+	default:
+		// SIGTERM을 받으려면 내부 확장을 등록해야 하며, WithEnableSIGTERM이 이를 대신합니다.
+		lambda.StartWithOptions(invoke, lambda.WithEnableSIGTERM(func() {
+			current.Load().Shutdown()
+			otelProviders.Shutdown()
+		}))
+	}
+}
+
+// invoke는 요청 하나를 처리합니다. 실행 모드와 관계없이 모든 요청이 이 경로를 지납니다.
+func invoke(ctx context.Context, event S3Event) (ConversionResult, error) {
+	refreshHandler(ctx)
+	h, release := acquireHandler()
+	defer release()
+	ctx, end := startPhase(ctx, "invoke", attribute.String("thumbnail.mode", event.Mode))
+	result, err := h.HandleRequest(ctx, event)
+	end(err, attribute.String("thumbnail.status", string(result.Status)))
+	otelProviders.Flush(ctx)
+	return result, err
+}
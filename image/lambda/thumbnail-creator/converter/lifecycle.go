@@ -0,0 +1,109 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// warmupSize는 워밍업에 사용하는 합성 이미지의 한 변 길이입니다.
+const warmupSize = 64
+
+// vipsConfig는 vips.Startup에 넘길 설정입니다. libvips는 Startup 시점에만 이 값들을 읽습니다.
+func vipsConfig(c Config) *vips.Config {
+	return &vips.Config{
+		ConcurrencyLevel: c.VipsConcurrency,
+		MaxCacheSize:     c.VipsMaxCacheSize,
+		MaxCacheMem:      c.VipsMaxCacheMem,
+		MaxCacheFiles:    c.VipsMaxCacheFiles,
+	}
+}
+
+// Warmup은 작은 합성 이미지를 등록된 모든 인코더로 인코딩해 코덱 초기화 비용을 미리 치릅니다.
+func (h *Handler) Warmup() (ConversionResult, error) {
+	start := h.clock.Now()
+	warmed, err := h.warmEncoders(h.encoders.Names())
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	msg := fmt.Sprintf("Warmed up encoders: %s in %s", strings.Join(warmed, ", "), h.clock.Now().Sub(start))
+	log.Println(msg)
+	return ConversionResult{Status: StatusWarmedUp, Message: msg}, nil
+}
+
+// InitWarmup은 INIT_WARMUP_FORMATS의 인코더를 초기화 단계에서 미리 초기화합니다.
+// 코덱 상태는 libvips 프로세스 전역이므로 실행 환경마다 한 번이면 되고, 설정을 다시 읽어 Handler를 바꿔도 다시 하지 않습니다.
+// Lambda 초기화 단계는 최대 10초이므로 이 시간을 넘지 않을 인코더만 고릅니다. 프로비저닝된 동시성에서는 호출 전에 끝납니다.
+// 실패해도 요청은 처리할 수 있으므로 경고만 남깁니다.
+func (h *Handler) InitWarmup() {
+	names := h.conf.InitWarmupFormats
+	if len(names) == 0 {
+		return
+	}
+	if slices.Contains(names, "all") {
+		names = h.encoders.Names()
+	}
+	var available []string
+	for _, name := range names {
+		if _, ok := h.encoders[name]; !ok {
+			log.Printf("Warning: INIT_WARMUP_FORMATS lists %s but the encoder is not available in this libvips build", name)
+			continue
+		}
+		available = append(available, name)
+	}
+	start := h.clock.Now()
+	warmed, err := h.warmEncoders(available)
+	if err != nil {
+		log.Printf("Warning: init warmup stopped after %s: %v", strings.Join(warmed, ", "), err)
+		return
+	}
+	log.Printf("Warmed up encoders during init: %s in %s", strings.Join(warmed, ", "), h.clock.Now().Sub(start))
+}
+
+// warmEncoders는 작은 합성 이미지를 names의 인코더로 인코딩하고 마친 이름을 돌려줍니다.
+// AVIF는 픽셀 수에 따라 고르는 두 AV1 인코더를 모두 거칩니다.
+func (h *Handler) warmEncoders(names []string) ([]string, error) {
+	image, err := vips.NewBlack(warmupSize, warmupSize, &vips.BlackOptions{Bands: 3})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create warmup image: %w", err)
+	}
+	defer image.Close()
+	if err := image.Cast(vips.BandFormatUchar, nil); err != nil {
+		return nil, fmt.Errorf("failed to create warmup image: %w", err)
+	}
+
+	var warmed []string
+	for _, name := range names {
+		encoders := []Encoder{h.encoders[name]}
+		if name == "avif" {
+			encoders = nil
+			for av1 := range avifEncoders {
+				c := h.conf
+				c.AVIFEncoder = av1
+				encoders = append(encoders, avifEncoder{c})
+			}
+		}
+		for _, e := range encoders {
+			// 일반 변환과 같은 10비트 기본값으로 인코더 경로를 초기화합니다.
+			if _, err := e.Encode(image, EncodeOptions{Color: colorPlan{Bitdepth: 10}, Keep: vips.KeepNone}); err != nil {
+				return warmed, fmt.Errorf("warmup encode to %s failed: %w", name, err)
+			}
+		}
+		warmed = append(warmed, name)
+	}
+	return warmed, nil
+}
+
+// Shutdown은 Lambda 실행 환경이 종료될 때(SIGTERM) 호출됩니다.
+// acquireHandler로 잡은 요청이 모두 끝날 때까지 기다린 뒤 미들웨어의 남은 텔레메트리를 내보내고 vips를 종료합니다.
+// vips를 종료한 Handler로는 요청을 처리할 수 없으므로 잠금을 풀지 않습니다.
+func (h *Handler) Shutdown() {
+	log.Println("SIGTERM received, shutting down")
+	h.active.Lock()
+	h.hooks.Shutdown()
+	vips.Shutdown()
+	log.Println("vips shut down")
+}
@@ -0,0 +1,32 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// limitDimensions는 MAX_OUTPUT_WIDTH/HEIGHT를 넘는 이미지를 비율을 유지한 채 줄입니다.
+// 한계는 화면에 보이는 방향을 기준으로 하므로 줄이기 전에 EXIF 방향을 적용합니다.
+// 프리셋 크기 변환은 줄인 이미지에서 시작하므로 모든 출력이 한계 안에 들어갑니다.
+func limitDimensions(image *vips.Image, c Config) error {
+	if c.MaxOutputWidth == 0 && c.MaxOutputHeight == 0 {
+		return nil
+	}
+	if err := image.Autorot(); err != nil {
+		return fmt.Errorf("failed to auto-rotate image: %w", err)
+	}
+	if (c.MaxOutputWidth == 0 || image.Width() <= c.MaxOutputWidth) &&
+		(c.MaxOutputHeight == 0 || image.Height() <= c.MaxOutputHeight) {
+		return nil
+	}
+	width, height := image.Width(), image.Height()
+	if err := pipeline.Resize(image, c.MaxOutputWidth, c.MaxOutputHeight, "inside", false, ""); err != nil {
+		return fmt.Errorf("failed to downscale image to output limit: %w", err)
+	}
+	log.Printf("Downscaled %dx%d to %dx%d (limit %dx%d)", width, height, image.Width(), image.Height(), c.MaxOutputWidth, c.MaxOutputHeight)
+	return nil
+}
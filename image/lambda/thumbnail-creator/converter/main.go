@@ -0,0 +1,1218 @@
+package converter
+
+// io 패키지를 임포트해야 합니다.
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/appconfigdata"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/cshum/vipsgen/vips"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// S3Event는 Lambda 트리거로부터 받는 이벤트 정보입니다.
+type S3Event struct {
+	S3Bucket string `json:"s3Bucket"`
+	S3Key    string `json:"s3Key"`
+	// S3Size는 원본 크기(바이트)입니다. S3 알림 레코드에서 채워지며, SKIP_RULES의 크기 규칙에 씁니다.
+	S3Size int64 `json:"s3Size,omitempty"`
+	// S3ETag와 S3Sequencer는 S3 알림 레코드의 eTag, sequencer입니다. DEDUP_TABLE의 중복 이벤트 식별에 씁니다.
+	S3ETag      string `json:"s3ETag,omitempty"`
+	S3Sequencer string `json:"s3Sequencer,omitempty"`
+	// S3Region은 원본 버킷의 리전입니다. S3 알림 레코드의 awsRegion에서 채워지며, REPLICA_GUARD에 씁니다.
+	S3Region string `json:"s3Region,omitempty"`
+	// S3KeyEncoded는 s3Key가 S3 알림처럼 URL 인코딩되어 있음을 나타냅니다. S3 알림 레코드에서는 자동으로 채워집니다.
+	// KEY_DECODING=auto(기본)에서는 이 값이 true일 때만 s3Key를 디코딩합니다.
+	S3KeyEncoded bool `json:"s3KeyEncoded,omitempty"`
+	// GetObjectContext, UserRequest, Configuration은 S3 Object Lambda 이벤트 필드입니다. (ObjectLambda 참고)
+	GetObjectContext *GetObjectContext          `json:"getObjectContext,omitempty"`
+	UserRequest      *ObjectLambdaUserRequest   `json:"userRequest,omitempty"`
+	Configuration    *ObjectLambdaConfiguration `json:"configuration,omitempty"`
+	// serve는 Object Lambda 요청을 변환 요청으로 바꿀 때 채워지며, JSON으로 받지 않습니다.
+	serve *objectLambdaRequest
+
+	// Effort/Speed는 AVIF 인코딩 노력 수준을 요청별로 덮어씁니다. (0~9, 둘 중 하나만 지정)
+	Effort *int `json:"effort,omitempty"`
+	Speed  *int `json:"speed,omitempty"`
+	// Rotate(90 | 180 | 270, 시계 방향)와 Flip(horizontal | vertical | both)은 EXIF 방향을 적용한 뒤,
+	// 파이프라인과 프리셋 크기 변환보다 먼저 적용합니다. 회전 뒤 뒤집기 순서입니다.
+	Rotate int    `json:"rotate,omitempty"`
+	Flip   string `json:"flip,omitempty"`
+	// Background는 투명 입력을 JPEG처럼 알파가 없는 출력으로 만들 때 합성할 배경색입니다.
+	// "#RRGGBB" 또는 "r,g,b" 형식이며, 비어 있으면 ALPHA_BACKGROUND를 씁니다.
+	Background string `json:"background,omitempty"`
+	// Subsample/Bitdepth는 주 출력의 크로마 서브샘플링(auto | 444 | 420)과 비트 깊이(8 | 10 | 12)를
+	// <FORMAT>_SUBSAMPLE/<FORMAT>_BITDEPTH 설정 대신 사용합니다. 출력 포맷이 지원하지 않는 값은 오류입니다.
+	Subsample string `json:"subsample,omitempty"`
+	Bitdepth  int    `json:"bitdepth,omitempty"`
+	// MaxOutputBytes가 있으면 주 출력이 이 크기(바이트) 이하가 되도록 품질을, 마지막 수단으로 크기를 줄입니다.
+	// 맞추지 못하면 OutputTooLarge 오류를 돌려줍니다.
+	MaxOutputBytes int `json:"maxOutputBytes,omitempty"`
+	// PosterSeconds는 동영상 원본에서 포스터 프레임을 뽑을 시각(초)입니다. 비어 있으면 POSTER_SECONDS입니다.
+	PosterSeconds *float64 `json:"posterSeconds,omitempty"`
+
+	// Pipeline은 인코딩 전에 순서대로 적용할 처리 단계입니다. (pipeline 패키지 참고)
+	Pipeline []pipeline.Step `json:"pipeline,omitempty"`
+	// OutputKey는 출력 키의 기준 경로입니다. 비어 있으면 원본 키에서 확장자만 바꿉니다.
+	OutputKey string `json:"outputKey,omitempty"`
+	// Records는 SQS 배치 또는 S3 이벤트 알림의 레코드이고, Items는 여러 변환 요청을 한 번에 보내는 목록입니다.
+	// 둘 중 하나가 있으면 배치로 처리합니다. (Batch 참고)
+	Records []BatchRecord `json:"Records,omitempty"`
+	Items   []S3Event     `json:"items,omitempty"`
+	// Mode는 요청 종류입니다. 비어 있으면 변환이며, 그 밖의 값은 다음과 같습니다.
+	//   - "warmup": 인코더만 미리 초기화 (S3 필드 불필요)
+	//   - "benchmark": Benchmark 설정으로 반복 측정, 출력은 올리지 않음
+	//   - "savings-report": 하루치 변환 기록으로 절감량 보고서 생성 (S3 필드 불필요)
+	//   - "sprite": Sprite 설정으로 프레임을 스프라이트 시트와 WebVTT/JSON 색인으로 생성
+	//   - "montage": Montage 설정으로 여러 원본을 격자 이미지 하나로 합성해 outputKey에 업로드
+	//   - "compare": Compare 설정의 두 이미지를 비교해 SSIM·PSNR·크기 차이와 차이 히트맵 생성
+	//   - "metadata": 인코딩 없이 크기·포맷·EXIF·SHA-256·BlurHash만 뽑아 알림 대상에 image.metadata로 전송
+	//   - "info": libvips 버전, 로더·세이버, 인코더, 빌드 커밋, 적용 중인 설정(비밀 값 가림) 반환
+	//   - "self-test": 내장 이미지 디코딩, 모든 포맷 인코딩, 출력 버킷 쓰기·삭제를 점검해 보고서 반환
+	//   - "regression": 현재 인코더 설정으로 코퍼스를 인코딩해 SSIM·크기를 기준값과 비교 (Regression 참고)
+	//   - "backfill": 매니페스트의 키를 묶음으로 변환하며 체크포인트를 남기고, resumeFrom으로 이어서 처리 (Backfill 참고)
+	//   - "gc": 원본이 지워진 출력을 찾아 지우고 보고서를 올림. 기본은 dry-run (GC 참고)
+	//   - "campaign": 설정 객체에 따라 기존 출력이 있는 원본을 새 설정으로 다시 변환하고 진행 상황을 기록 (Campaign 참고)
+	//   - "tiles": Tiles 설정으로 원본 하나를 DZI·IIIF 타일 피라미드나 피라미드 TIFF로 만들어 업로드 (Tiles 참고)
+	Mode string `json:"mode,omitempty"`
+	// Benchmark는 "mode": "benchmark"일 때의 측정 설정입니다.
+	Benchmark *BenchmarkRequest `json:"benchmark,omitempty"`
+	// Sprite는 "mode": "sprite"일 때의 설정입니다.
+	Sprite *SpriteRequest `json:"sprite,omitempty"`
+	// Montage는 "mode": "montage"일 때의 설정입니다.
+	Montage *MontageRequest `json:"montage,omitempty"`
+	// Compare는 "mode": "compare"일 때의 설정입니다.
+	Compare *CompareRequest `json:"compare,omitempty"`
+	// Debug가 있으면 이 호출만 자세한 로그를 남기고, artifacts이면 중간 결과를 DEBUG_PREFIX 아래에 올립니다.
+	Debug *DebugRequest `json:"debug,omitempty"`
+	// Regression은 "mode": "regression"일 때의 설정입니다.
+	Regression *RegressionRequest `json:"regression,omitempty"`
+	// Backfill은 "mode": "backfill"일 때의 설정입니다.
+	Backfill *BackfillRequest `json:"backfill,omitempty"`
+	// GC는 "mode": "gc"일 때의 설정입니다.
+	GC *GCRequest `json:"gc,omitempty"`
+	// Campaign은 "mode": "campaign"일 때의 설정입니다.
+	Campaign *CampaignRequest `json:"campaign,omitempty"`
+	// Tiles는 "mode": "tiles"일 때의 설정입니다.
+	Tiles *TilesRequest `json:"tiles,omitempty"`
+	// ReportDate는 "mode": "savings-report"에서 집계할 날짜(YYYY-MM-DD, UTC)입니다. 기본은 전날입니다.
+	ReportDate string `json:"reportDate,omitempty"`
+	// DetailType/Time은 EventBridge 예약 이벤트 필드입니다. "Scheduled Event"는 절감량 보고서로 처리합니다.
+	DetailType string `json:"detail-type,omitempty"`
+	Time       string `json:"time,omitempty"`
+	// Preset은 설정된 변환 프리셋 이름입니다. 파이프라인 적용 뒤 프리셋의 크기마다 출력을 만듭니다.
+	Preset string `json:"preset,omitempty"`
+	// Derivatives는 한 번 디코딩한 이미지에서 만드는 출력 목록입니다. 출력마다 크기, 포맷, 키 템플릿, 업로드 설정이 다르며
+	// preset과 함께 쓸 수 없습니다. (Derivative 참고)
+	Derivatives []Derivative `json:"derivatives,omitempty"`
+	// Priority는 배치 안에서의 처리 순서입니다. high(사용자 업로드) → normal(기본) → low(백필) 순으로 시작하며,
+	// BATCH_LOW_PRIORITY_RESERVE_MS가 있으면 시간이 부족할 때 low 항목을 다음 호출로 미룹니다.
+	// SQS 메시지 본문의 priority는 그 메시지가 담은 S3 알림 레코드에도 적용됩니다.
+	Priority string `json:"priority,omitempty"`
+}
+
+// ConversionResult는 람다 함수의 실행 결과를 담는 구조체입니다.
+// 스키마 버전과 상태 코드는 status.go에 있습니다.
+type ConversionResult struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	Status        Status         `json:"status"`           // 상태 코드 (status.go의 Status 상수)
+	Tenant        string         `json:"tenant,omitempty"` // TENANTS로 연결된 테넌트 이름
+	OriginalKey   string         `json:"originalKey,omitempty"`
+	NewKey        string         `json:"newKey,omitempty"`      // 변환된 경우에만 값이 채워집니다.
+	Format        string         `json:"format,omitempty"`      // 출력 포맷: "avif" | "webp" | "jpeg" | "png" | "jxl"
+	Compression   string         `json:"compression,omitempty"` // "lossy" | "lossless" | "near-lossless"
+	Alpha         string         `json:"alpha,omitempty"`       // 투명 입력일 때: "preserved" | "flattened"
+	Color         string         `json:"color,omitempty"`       // HDR·광색역 입력일 때의 처리 내용
+	Encoder       string         `json:"encoder,omitempty"`     // AVIF 인코더: "svt" | "aom"
+	Outputs       []OutputResult `json:"outputs,omitempty"`
+	Message       string         `json:"message,omitempty"`
+	// BlurHash는 derivatives에 "format": "blurhash" 출력이 있을 때 채워집니다.
+	BlurHash string `json:"blurHash,omitempty"`
+	// Errors는 실패한 항목의 오류 코드입니다. 배치·아카이브에서는 항목별 결과와 전체 결과에 모두 들어갑니다.
+	Errors []ResultError `json:"errors,omitempty"`
+	// RetryAfter는 RETRY_AFTER_RESTORE일 때 원본 복원이 끝날 것으로 예상하는 시각(RFC3339)입니다.
+	RetryAfter string `json:"retryAfter,omitempty"`
+
+	// Items는 배치 요청의 항목별 결과이며, BatchItemFailures는 SQS 부분 배치 응답입니다.
+	Items             []ConversionResult `json:"items,omitempty"`
+	BatchItemFailures []BatchItemFailure `json:"batchItemFailures,omitempty"`
+	// Cost는 변환에 성공한 호출의 대략적인 비용입니다.
+	Cost *CostEstimate `json:"cost,omitempty"`
+	// Timings는 변환한 요청의 단계별 소요 시간이고, Input은 디코딩한 원본의 크기(EXIF 방향 적용 전)입니다.
+	Timings *Timings    `json:"timings,omitempty"`
+	Input   *Dimensions `json:"input,omitempty"`
+	// Benchmark는 "mode": "benchmark" 요청의 측정 결과입니다.
+	Benchmark *BenchmarkReport `json:"benchmark,omitempty"`
+	// Comparison은 "mode": "compare" 요청의 비교 결과입니다.
+	Comparison *CompareReport `json:"comparison,omitempty"`
+	// Regression은 "mode": "regression" 요청의 항목별 결과입니다.
+	Regression []RegressionResult `json:"regression,omitempty"`
+	// Backfill은 "mode": "backfill" 요청의 진행 상황입니다. (항목별 상태는 체크포인트 객체에 있습니다)
+	Backfill *BackfillCheckpoint `json:"backfill,omitempty"`
+	// GC는 "mode": "gc" 요청의 요약입니다. (고아 출력 목록은 보고서 객체에 있습니다)
+	GC *GCReport `json:"gc,omitempty"`
+	// Campaign은 "mode": "campaign" 요청의 진행 상황입니다. 이번 호출의 체크포인트 요약은 backfill에 있습니다.
+	Campaign *CampaignProgress `json:"campaign,omitempty"`
+	// Tiles는 "mode": "tiles" 요청의 타일 세트 요약입니다.
+	Tiles *TilesReport `json:"tiles,omitempty"`
+	// DebugArtifacts는 debug.artifacts 요청으로 올린 중간 결과 키입니다.
+	DebugArtifacts []string `json:"debugArtifacts,omitempty"`
+	// Manifest는 CONTENT_MANIFEST가 켜져 있을 때 올린 내용 주소 매니페스트 키입니다.
+	Manifest string `json:"manifest,omitempty"`
+	// SourceType은 원본의 Content-Type이 확장자나 내용과 어긋났을 때의 비교 결과입니다. (SOURCE_CONTENT_TYPE)
+	SourceType *SourceTypeCheck `json:"sourceType,omitempty"`
+	// Rules는 이 변환에 적용된 TRANSFORM_RULES 규칙 이름입니다.
+	Rules []string `json:"rules,omitempty"`
+	// Metadata는 "mode": "metadata" 요청에서 뽑은 원본 메타데이터입니다.
+	Metadata *ImageMetadata `json:"metadata,omitempty"`
+	// Info는 "mode": "info" 요청의 배포 정보입니다.
+	Info *DeploymentInfo `json:"info,omitempty"`
+	// Health는 "mode": "self-test" 요청의 점검 결과입니다.
+	Health *HealthReport `json:"health,omitempty"`
+	// Original은 ORIGINALS_STORAGE_CLASS가 설정된 경우 원본에 적용한 저장 클래스 처리입니다.
+	Original *OriginalArchive `json:"original,omitempty"`
+}
+
+// OutputResult는 업로드된 출력 파일 하나의 정보입니다.
+type OutputResult struct {
+	Key    string `json:"key"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Overwritten은 이미 있던 객체를 덮어쓴 경우 true입니다. CloudFront 무효화가 켜진 경우에만 확인합니다.
+	Overwritten bool `json:"overwritten,omitempty"`
+	// LogicalKey는 OUTPUT_NAMING=content일 때 원래 이름 규칙의 키입니다.
+	// SHA256은 출력 바이트의 해시이며 OUTPUT_NAMING=content나 VERIFY_UPLOADS일 때 채워집니다.
+	LogicalKey string `json:"logicalKey,omitempty"`
+	SHA256     string `json:"sha256,omitempty"`
+	// URL은 PRESIGN_EXPIRY_SECONDS가 있을 때 채우는 서명된 GET URL이며, URLExpires(RFC3339)까지 유효합니다.
+	URL        string `json:"url,omitempty"`
+	URLExpires string `json:"urlExpires,omitempty"`
+	// Pixels는 Width×Height이고, EncodeMs는 이 출력을 인코딩하는 데 걸린 시간입니다.
+	Pixels   int64 `json:"pixels,omitempty"`
+	EncodeMs int64 `json:"encodeMs,omitempty"`
+	// Checksum은 S3에 저장된 체크섬입니다. 알고리즘과 종류는 UPLOAD_CHECKSUM_ALGORITHM·UPLOAD_CHECKSUMS를 따릅니다.
+	Checksum *ObjectChecksum `json:"checksum,omitempty"`
+	// Derivative는 derivatives 요청에서 이 출력을 만든 파생 출력의 이름입니다.
+	Derivative string `json:"derivative,omitempty"`
+}
+
+// current는 콜드 스타트 시 만들어져 모든 호출에서 재사용하는 Handler입니다.
+// 참조한 비밀·설정 값이 바뀌면 refreshHandler가 새 설정으로 만든 Handler로 바꿉니다.
+// 요청을 처리할 때는 acquireHandler로 잡아 바뀌는 동안에도 같은 Handler를 끝까지 씁니다.
+var current atomic.Pointer[Handler]
+
+// refreshMu는 refreshHandler가 한 번에 하나만 실행되도록 잡습니다.
+var refreshMu sync.Mutex
+
+// awsConfig와 secrets는 Handler를 다시 만들 때 재사용하는 SDK 설정과 참조 해석기입니다.
+// otelProviders는 OTLP 내보내기가 설정된 경우에만 있습니다.
+var (
+	awsConfig     aws.Config
+	secrets       *secretResolver
+	otelProviders *telemetry
+)
+
+// Init은 실행 파일의 main에서 Run이나 ServeAPI보다 먼저 한 번 호출합니다. (Lambda는 콜드 스타트마다 한 번)
+// 설정을 읽고 vips 라이브러리와 S3 클라이언트로 Handler를 초기화하며, 설정이 잘못되었으면 프로세스를 끝냅니다.
+func Init() {
+	var err error
+	awsConfig, err = config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+	secrets = newSecretResolver(ssm.NewFromConfig(awsConfig), secretsmanager.NewFromConfig(awsConfig), systemClock{})
+	conf, err := loadConfig(context.Background(), secrets)
+	if err != nil {
+		log.Fatalf("invalid configuration, %v", err)
+	}
+	if telemetryEnabled(conf) {
+		if otelProviders, err = setupTelemetry(context.TODO(), conf); err != nil {
+			log.Fatalf("invalid configuration, %v", err)
+		}
+	}
+	vips.Startup(vipsConfig(conf))
+	log.Printf("vips %s started: concurrency=%d, cache ops=%d, cache mem=%dMB, cache files=%d",
+		vips.Version, conf.VipsConcurrency, conf.VipsMaxCacheSize, conf.VipsMaxCacheMem>>20, conf.VipsMaxCacheFiles)
+	h, err := newHandlerFromConfig(context.TODO(), awsConfig, conf)
+	if err != nil {
+		log.Fatalf("invalid configuration, %v", err)
+	}
+	current.Store(h)
+	log.Println("S3 client and vips initialized successfully")
+	h.InitWarmup()
+}
+
+// newHandlerFromConfig는 설정에 따라 클라이언트와 미들웨어를 연결한 Handler를 만듭니다.
+// vips.Startup 이후에 호출해야 합니다. VIPS_* 설정은 Startup에서만 적용되므로 다시 만들어도 바뀌지 않습니다.
+func newHandlerFromConfig(ctx context.Context, cfg aws.Config, conf Config) (*Handler, error) {
+	if conf.JXLOutput && !vips.HasOperation("jxlsave_buffer") {
+		log.Println("Warning: JXL_OUTPUT is enabled but libvips was built without jxlsave, disabling JXL output")
+		conf.JXLOutput = false
+	}
+	client := newS3Client(cfg, conf)
+	h := NewHandler(client, systemClock{}, conf)
+	if conf.AppConfigApplication != "" {
+		h.UseFeatureFlags(appconfigdata.NewFromConfig(cfg))
+	}
+	var destination *s3.Client
+	if conf.DestinationRoleARN != "" {
+		destination = newDestinationS3Client(cfg, conf)
+		h.UseDestinationRole(destination, conf.DestinationBuckets)
+	}
+	if conf.OutputNaming == "content" {
+		h.UseContentAddressedKeys()
+	}
+	if conf.VerifyUploads != "" {
+		h.UseUploadVerification()
+	}
+	if conf.SkipBytesPerPixel > 0 {
+		h.UseCompressedSourceSkip()
+	}
+	if conf.ObjectLambda {
+		h.UseObjectLambda(s3.NewFromConfig(cfg))
+	}
+	if conf.DedupTable != "" {
+		h.UseEventDedup(dynamodb.NewFromConfig(cfg), conf.DedupTable)
+	}
+	if conf.RecordsTable != "" {
+		h.UseRecords(dynamodb.NewFromConfig(cfg), conf.RecordsTable)
+	}
+	if conf.FaceDetection {
+		h.UseFaceDetection(rekognition.NewFromConfig(cfg))
+	}
+	if conf.CloudFrontDistributionID != "" {
+		h.UseCDNInvalidation(cloudfront.NewFromConfig(cfg))
+	}
+	if conf.SourceContentType != "off" {
+		h.UseSourceContentType()
+	}
+	if conf.OriginalsStorageClass != "" {
+		h.UseOriginalArchive()
+	}
+	// 알림 페이로드에 URL이 들어가도록 알림 미들웨어보다 먼저 등록합니다.
+	if conf.PresignExpiry > 0 {
+		var destinationSigner PresignAPI
+		if destination != nil {
+			destinationSigner = s3.NewPresignClient(destination)
+		}
+		h.UsePresignedURLs(s3.NewPresignClient(client), destinationSigner)
+	}
+	if conf.EventBusName != "" || tenantEventBuses(conf.Tenants) {
+		h.UseEventBridge(eventbridge.NewFromConfig(cfg))
+	}
+	if len(conf.Notifiers) > 0 {
+		h.UseNotifiers(cfg, conf.Notifiers)
+	}
+	if conf.AuditBucket != "" {
+		h.UseAudit()
+	}
+	if conf.QuarantineAfter > 0 {
+		h.UseQuarantine()
+	}
+	if conf.FFmpegPath != "" {
+		if _, err := exec.LookPath(conf.FFmpegPath); err != nil {
+			return nil, fmt.Errorf("FFMPEG_PATH: %w", err)
+		}
+		h.UseVideoPoster()
+	}
+	if conf.AnalyticsStream != "" {
+		h.UseAnalytics(firehose.NewFromConfig(cfg))
+	}
+	if conf.PresetsObject != "" {
+		presets, err := h.loadPresetObject(ctx, conf.PresetsObject)
+		if err != nil {
+			return nil, err
+		}
+		h.conf.Presets = mergePresets(conf.Presets, presets)
+	}
+	return h, nil
+}
+
+// refreshHandler는 SecretsRefresh 간격마다 참조 값을 다시 읽고, 바뀌었으면 새 설정으로 Handler를 바꿉니다.
+// 이전 Handler는 그것으로 처리 중인 요청이 모두 끝난 뒤에 종료합니다. 잡고 있는 Handler가 없을 때 호출해야 합니다.
+// 새 설정이 잘못되었으면 경고만 남기고 이전 Handler를 계속 씁니다.
+func refreshHandler(ctx context.Context) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	old := current.Load()
+	if !secrets.Refresh(ctx, old.conf.SecretsRefresh) {
+		return
+	}
+	conf, err := loadConfig(ctx, secrets)
+	if err == nil {
+		var next *Handler
+		if next, err = newHandlerFromConfig(ctx, awsConfig, conf); err == nil {
+			current.Store(next)
+			// 이전 Handler로 처리 중인 요청을 기다린 뒤 미들웨어가 버퍼링한 텔레메트리를 내보냅니다.
+			old.active.Lock()
+			old.hooks.Shutdown()
+			old.active.Unlock()
+			log.Println("Configuration reloaded after a referenced value changed")
+			return
+		}
+	}
+	log.Printf("Warning: keeping the previous configuration, reloaded configuration is invalid: %v", err)
+}
+
+// refreshEvery는 ctx가 끝날 때까지 interval마다 refreshHandler를 호출합니다. 요청을 동시에 처리하는 HTTP API는
+// 요청 안에서 Handler를 바꾸지 않고 이 고루틴 하나에서만 바꿉니다.
+func refreshEvery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshHandler(ctx)
+		}
+	}
+}
+
+// acquireHandler는 현재 Handler를 요청 하나 동안 잡고, 다 쓰면 호출할 release를 돌려줍니다.
+// 잡는 사이에 Handler가 바뀌었으면 새 Handler를 다시 잡으므로 종료된 Handler로 요청을 처리하지 않습니다.
+func acquireHandler() (h *Handler, release func()) {
+	for {
+		h = current.Load()
+		h.active.RLock()
+		if current.Load() == h {
+			return h, h.active.RUnlock
+		}
+		h.active.RUnlock()
+	}
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, event S3Event) (result ConversionResult, err error) {
+	defer result.stamp()
+	// EventBridge 예약 규칙의 기본 페이로드는 mode 없이 detail-type만 담아 옵니다.
+	if event.Mode == "" && event.DetailType == "Scheduled Event" {
+		event.Mode = "savings-report"
+	}
+	if h.conf.LogLevel == "debug" {
+		ctx = withDebug(ctx)
+	}
+	switch {
+	case event.GetObjectContext != nil:
+		defer h.memory.Report(h.conf)
+		return h.ObjectLambda(ctx, event)
+	case len(event.Records) > 0 || len(event.Items) > 0:
+		defer h.memory.Report(h.conf)
+		return h.Batch(ctx, event)
+	case event.Mode == "" || event.Mode == "metadata":
+		defer h.memory.Report(h.conf)
+		return h.convertEvent(ctx, event)
+	}
+	switch event.Mode {
+	case "savings-report":
+		return h.SavingsReport(ctx, event)
+	case "warmup":
+		return h.Warmup()
+	case "benchmark":
+		return h.Benchmark(ctx, event)
+	case "sprite":
+		return h.Sprite(ctx, event)
+	case "montage":
+		return h.Montage(ctx, event)
+	case "compare":
+		return h.Compare(ctx, event)
+	case "regression":
+		return h.Regression(ctx, event)
+	case "backfill":
+		defer h.memory.Report(h.conf)
+		return h.Backfill(ctx, event)
+	case "gc":
+		return h.GC(ctx, event)
+	case "campaign":
+		defer h.memory.Report(h.conf)
+		return h.Campaign(ctx, event)
+	case "tiles":
+		defer h.memory.Report(h.conf)
+		return h.Tiles(ctx, event)
+	case "self-test":
+		return h.SelfTest(ctx, event)
+	case "info":
+		return h.Info()
+	default:
+		return ConversionResult{}, fmt.Errorf("invalid event: unknown mode %q", event.Mode)
+	}
+}
+
+// convertEvent는 변환 요청 하나를 처리합니다. 배치에서는 항목마다 동시에 호출될 수 있습니다.
+func (h *Handler) convertEvent(ctx context.Context, event S3Event) (result ConversionResult, err error) {
+	srcKey, err := h.decodeKey(event.S3Key, event.S3KeyEncoded)
+	if err != nil {
+		// Fatalf 대신 에러 반환
+		return ConversionResult{}, err
+	}
+	log.Printf("Processing image: bucket=%s, key=%s", event.S3Bucket, srcKey)
+	if event.Debug != nil {
+		ctx = withDebug(ctx)
+	}
+	if debugEnabled(ctx) {
+		raw, _ := json.Marshal(event)
+		debugf(ctx, "Event: %s", raw)
+	}
+	start := h.clock.Now()
+	ctx, requests := withRequestCounter(ctx)
+	ctx, endSpan := startPhase(ctx, "convert", attribute.String("s3.bucket", event.S3Bucket), attribute.String("s3.key", srcKey))
+	job := &Job{
+		Event:   event,
+		Bucket:  event.S3Bucket,
+		Started: start,
+		SrcKey:  srcKey,
+		BaseKey: srcKey,
+		Result:  &ConversionResult{OriginalKey: srcKey},
+		Serve:   event.serve,
+	}
+	defer func() {
+		log.Printf("Finished processing %s in %s", srcKey, h.clock.Now().Sub(start))
+		recordOutcome(ctx, job, result, err)
+		status := result.Status
+		if status == "" {
+			status = StatusFailed
+		}
+		endSpan(err, attribute.String("thumbnail.status", string(status)), attribute.String("thumbnail.format", result.Format), attribute.Int("thumbnail.outputs", len(result.Outputs)))
+	}()
+	job.Tenant = resolveTenant(h.conf.Tenants, job.Bucket, srcKey)
+	job.OutputBucket = job.Bucket
+	if job.Tenant != nil {
+		job.Result.Tenant = job.Tenant.Name
+		if job.Tenant.OutputBucket != "" {
+			job.OutputBucket = job.Tenant.OutputBucket
+		}
+		log.Printf("Tenant resolved: %s (output bucket %s)", job.Tenant.Name, job.OutputBucket)
+	}
+	if event.Mode == "metadata" {
+		result, err = h.metadata(ctx, job)
+	} else {
+		result, err = h.convert(ctx, job)
+	}
+	if err == nil {
+		if result.Timings != nil {
+			result.Timings.TotalMs = h.since(start)
+		}
+		cost := estimateCost(h.clock.Now().Sub(start), requests, h.conf)
+		result.Cost = &cost
+		emitMetrics(h.conf.MetricsNamespace, "None", map[string]float64{"EstimatedCostUSD": cost.TotalUSD})
+	}
+	var skipped *skipError
+	if errors.As(err, &skipped) {
+		log.Println(skipped.Message)
+		result, err = ConversionResult{Status: skipped.Status, Tenant: job.Result.Tenant, OriginalKey: srcKey, Message: skipped.Message, RetryAfter: skipped.RetryAfter}, nil
+	} else if err != nil {
+		h.hooks.OnFailure(ctx, job, err)
+		if quarantined, ok := h.quarantine.handle(ctx, job, err); ok {
+			result, err = quarantined, nil
+		}
+	}
+	if auditErr := h.audit.Record(ctx, job, result, err); auditErr != nil {
+		if err == nil {
+			return result, auditErr
+		}
+		log.Printf("Error: %v", auditErr)
+	}
+	return result, err
+}
+
+// convert는 다운로드부터 업로드까지의 변환 흐름이며, 각 단계 사이에서 hooks를 호출합니다.
+func (h *Handler) convert(ctx context.Context, job *Job) (ConversionResult, error) {
+	event := job.Event
+	effort, err := resolveEffort(event, h.conf)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	job.Effort = effort
+	job.Background = h.conf.AlphaBackground
+	if event.Background != "" {
+		if job.Background, err = pipeline.ParseColor(event.Background); err != nil {
+			return ConversionResult{}, fmt.Errorf("invalid event: invalid background: %w", err)
+		}
+	}
+	if err := pipeline.ValidateOrientation(event.Rotate, event.Flip); err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if event.MaxOutputBytes < 0 {
+		return ConversionResult{}, fmt.Errorf("invalid event: maxOutputBytes must not be negative")
+	}
+	job.Steps, err = pipeline.Compile(event.Pipeline)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid pipeline: %w", err)
+	}
+	if event.Preset != "" {
+		p, ok := job.Tenant.preset(event.Preset, h.conf.Presets)
+		if !ok {
+			return ConversionResult{}, fmt.Errorf("invalid event: unknown preset %q", event.Preset)
+		}
+		job.Preset = &p
+	}
+	if len(event.Derivatives) > 0 {
+		if event.Preset != "" {
+			return ConversionResult{}, fmt.Errorf("invalid event: derivatives cannot be combined with preset")
+		}
+		if err := h.validateDerivatives(event.Derivatives); err != nil {
+			return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+		}
+	}
+	if _, err := priorityRank(event.Priority); err != nil {
+		return ConversionResult{}, err
+	}
+	if event.OutputKey != "" {
+		job.BaseKey = event.OutputKey
+	} else if job.Tenant != nil {
+		job.BaseKey = job.Tenant.OutputPrefix + job.SrcKey
+	}
+	job.BaseKey = h.outputKey(job.BaseKey)
+
+	if err := h.filterSource(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	if strings.HasPrefix(job.SrcKey, h.conf.SelfTestPrefix) {
+		return ConversionResult{}, skip(StatusSkippedSelfTest, "Object was written by a self-test. Skipping conversion.")
+	}
+	if strings.HasPrefix(job.SrcKey, h.conf.DebugPrefix) {
+		return ConversionResult{}, skip(StatusSkippedDebugArtifact, "Object is a debug artifact. Skipping conversion.")
+	}
+	// s3Size가 없는 이벤트와 0바이트 객체를 구분할 수 없으므로 다운로드 전에는 0보다 큰 크기만 믿습니다.
+	knownSize := job.Event.S3Size
+	if knownSize == 0 {
+		knownSize = -1
+	}
+	if err := h.emptySource(job, knownSize); err != nil {
+		return ConversionResult{}, err
+	}
+	h.debugJSON(ctx, job, "event.json", job.Event)
+	if job.Serve != nil {
+		return h.serve(ctx, job)
+	}
+	if err := h.replicaGuard(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	if err := h.dedup.Claim(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+
+	// 1. S3에서 이미지 객체 다운로드
+	job.Result.Timings = &Timings{}
+	downloadStart := h.clock.Now()
+	downloadCtx, endDownload := startPhase(ctx, "download")
+	job.Source, job.SourceContentType, err = h.downloadSource(downloadCtx, job.Bucket, job.SrcKey, job.Event.S3Size, job.Event.S3ETag)
+	endDownload(err, attribute.Int("thumbnail.source.bytes", len(job.Source)))
+	job.Result.Timings.DownloadMs = h.since(downloadStart)
+	if err != nil {
+		return ConversionResult{}, h.archivedSource(ctx, job, err)
+	}
+	recordObjectSize(ctx, "source", keyExtension(job.SrcKey), len(job.Source))
+	if err := h.emptySource(job, int64(len(job.Source))); err != nil {
+		return ConversionResult{}, err
+	}
+	if err := h.hooks.PreDecode(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	if format := archiveFormat(job.Source); format != "" {
+		return h.convertArchive(ctx, job, format)
+	}
+	return h.process(ctx, job)
+}
+
+// process는 job.Source를 디코딩해 파이프라인, 프리셋, 인코딩, 업로드까지 처리합니다.
+func (h *Handler) process(ctx context.Context, job *Job) (ConversionResult, error) {
+	event := job.Event
+	if err := h.checkBudget(ctx, "decode"); err != nil {
+		return ConversionResult{}, err
+	}
+	if err := h.checkDecodeLimits(job); err != nil {
+		return ConversionResult{}, err
+	}
+
+	if job.Result.Timings == nil {
+		job.Result.Timings = &Timings{}
+	}
+	timings := job.Result.Timings
+
+	// [수정] 파일이 아닌 버퍼에서 이미지 로드
+	decodeStart := h.clock.Now()
+	_, endDecode := startPhase(ctx, "decode")
+	image, err := vips.NewImageFromBuffer(job.Source, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to process image with vips from buffer: %w", err)
+		endDecode(err)
+		return ConversionResult{}, err
+	}
+	defer image.Close() // 이미지 객체 메모리 해제
+	job.Image = image
+
+	job.Loader, err = image.GetString("vips-loader")
+	if err != nil {
+		// 오류가 발생해도 변환을 시도하도록 로그만 남기고 넘어갈 수 있습니다.
+		log.Printf("Warning: failed to get image format metadata: %v", err)
+	} else {
+		log.Printf("Detected loader: %s", job.Loader)
+	}
+	endDecode(nil, attribute.String("vips.loader", job.Loader), attribute.Int("image.width", image.Width()), attribute.Int("image.height", image.Height()))
+	timings.DecodeMs = h.since(decodeStart)
+	job.Result.Input = dimensionsOf(image.Width(), image.Height())
+	if err := h.hooks.PostDecode(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	if job.Event.Debug != nil && job.Event.Debug.Artifacts {
+		if snapshot, err := image.PngsaveBuffer(vips.DefaultPngsaveBufferOptions()); err != nil {
+			log.Printf("Warning: failed to encode debug snapshot: %v", err)
+		} else {
+			h.debugArtifact(ctx, job, "decoded.png", "image/png", snapshot)
+		}
+	}
+
+	graphics, reason := detectGraphics(image, job.Loader, h.conf)
+	log.Printf("Graphics detection: graphics=%t (%s)", graphics, reason)
+
+	outputFormat := "avif"
+	if graphics && h.conf.GraphicsFormat == "webp" {
+		outputFormat = "webp"
+	}
+
+	var quality int
+	if job.Tenant != nil {
+		quality = job.Tenant.Quality
+	}
+	if job.Preset != nil {
+		if job.Preset.Format != "" {
+			outputFormat = job.Preset.Format
+		}
+		quality = job.Preset.Quality
+	}
+	rules, err := h.matchTransformRules(job, image, graphics)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	if rules.Action == "copy" {
+		return h.copySource(ctx, job, rules.facts)
+	}
+	if rules.Format != "" {
+		outputFormat = rules.Format
+	}
+	if rules.Quality > 0 {
+		quality = rules.Quality
+	}
+	if rules.Lossless {
+		graphics = true
+	}
+	if rules.NoResize && job.Preset != nil {
+		log.Println("Transform rule disables preset resizing, encoding at source size")
+		job.Preset = nil
+	}
+	reoriented := event.Rotate != 0 || event.Flip != ""
+	if job.Steps.Len() > 0 || job.Preset != nil || len(event.Derivatives) > 0 || reoriented {
+		// 파이프라인 단계, 프리셋·파생 출력 크기, rotate/flip은 EXIF 방향이 적용된 좌표를 기준으로 합니다.
+		if err := image.Autorot(); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to auto-rotate image: %w", err)
+		}
+	}
+	if reoriented {
+		if err := pipeline.Orient(image, event.Rotate, event.Flip); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to rotate/flip image: %w", err)
+		}
+		log.Printf("Orientation applied: rotate=%d, flip=%s", event.Rotate, event.Flip)
+	}
+	if job.Steps.Len() > 0 {
+		if err := h.checkBudget(ctx, "pipeline"); err != nil {
+			return ConversionResult{}, err
+		}
+		pipelineStart := h.clock.Now()
+		_, endPipeline := startPhase(ctx, "pipeline", attribute.Int("pipeline.steps", job.Steps.Len()))
+		output, err := job.Steps.Run(image, pipeline.Env{
+			LoadObject:  func(key string) ([]byte, error) { return h.downloadObject(ctx, job.Bucket, key) },
+			DetectFaces: h.faceDetector(ctx),
+		})
+		endPipeline(err)
+		timings.PipelineMs = h.since(pipelineStart)
+		if err != nil {
+			return ConversionResult{}, fmt.Errorf("pipeline failed: %w", err)
+		}
+		log.Printf("Pipeline applied: %d steps, size=%dx%d", job.Steps.Len(), image.Width(), image.Height())
+		if output.Format != "" {
+			outputFormat = output.Format
+		}
+		if output.Quality > 0 {
+			quality = output.Quality
+		}
+	}
+	if err := limitDimensions(image, h.conf); err != nil {
+		return ConversionResult{}, err
+	}
+	subsample, bitdepth, err := resolveSampling(outputFormat, event, h.conf)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	job.Result.Format = outputFormat
+	job.Result.Compression = compressionOf(outputFormat, graphics, h.conf)
+
+	srcColor := inspectColor(image)
+	color, err := applyColorPolicy(image, srcColor, h.conf)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	if color.Action != "" {
+		log.Printf("Color policy applied: %s (transfer=%s, gamut=%s)", color.Action, srcColor.Transfer, srcColor.Gamut)
+	}
+	job.Result.Color = color.Action
+	keep := vips.KeepNone
+	if color.KeepICC {
+		keep = vips.KeepIcc
+	}
+
+	job.Result.Alpha, err = applyAlphaPolicy(image, outputFormat, job.Background, h.conf)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	if job.Result.Alpha != "" {
+		log.Printf("Alpha channel %s (policy=%s)", job.Result.Alpha, h.conf.AlphaPolicy)
+	}
+
+	params := EncodeOptions{
+		Graphics:  graphics,
+		Color:     color,
+		Keep:      keep,
+		Effort:    job.Effort,
+		Quality:   quality,
+		Subsample: subsample,
+		Bitdepth:  bitdepth,
+		Debug:     debugEnabled(ctx),
+	}
+	job.Quality = quality
+	h.debugJSON(ctx, job, "encode-params.json", debugParams{
+		Loader:       job.Loader,
+		Width:        image.Width(),
+		Height:       image.Height(),
+		OutputFormat: outputFormat,
+		Graphics:     reason,
+		Color:        color.Action,
+		Alpha:        job.Result.Alpha,
+		Options:      params,
+		Preset:       job.Preset,
+		Pipeline:     job.Steps.Len(),
+	})
+	// 프리셋이 없으면 처리된 이미지 그대로 출력 하나를 만듭니다.
+	sizes := []PresetSize{{}}
+	if job.Preset != nil {
+		sizes = job.Preset.Sizes
+	}
+	encodeStart := h.clock.Now()
+	var variants []encodedVariant
+	if len(event.Derivatives) > 0 {
+		variants, err = h.encodeDerivatives(ctx, job, image, outputFormat, params)
+	} else {
+		variants, err = h.encodeVariants(ctx, job, image, sizes, outputFormat, params)
+	}
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	timings.EncodeMs = h.since(encodeStart)
+	// 업로드와 업로드 훅은 프리셋 크기(파생 출력) 순서대로 하나씩 실행합니다.
+	for _, v := range variants {
+		for _, u := range v.uploads {
+			if err := h.upload(ctx, job, u); err != nil {
+				if u.Primary || u.Format != "jxl" {
+					return ConversionResult{}, err
+				}
+				log.Printf("Warning: %v", err)
+			}
+		}
+		if job.Result.Encoder == "" {
+			job.Result.Encoder = v.encoder
+		}
+	}
+
+	for _, extra := range job.Extras {
+		if err := h.upload(ctx, job, extra); err != nil {
+			return ConversionResult{}, err
+		}
+	}
+
+	job.Result.Status = StatusConverted
+	// S3 Object Lambda 응답은 저장하지 않으므로 변환 완료 훅(알림, 기록)을 부르지 않습니다.
+	if job.Serve != nil {
+		return *job.Result, nil
+	}
+	if err := h.hooks.PostConvert(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	return *job.Result, nil
+}
+
+// encodedVariant는 프리셋 크기 하나의 인코딩 결과입니다. uploads는 주 출력, JXL, JPEG 대체 출력 순서입니다.
+type encodedVariant struct {
+	uploads []*Upload
+	encoder string
+}
+
+// encodeVariants는 프리셋 크기마다 이미지를 줄여 인코딩합니다. 크기가 여럿이면 VARIANT_CONCURRENCY개까지 동시에 인코딩합니다.
+// 작업마다 디코딩한 이미지의 사본(vips.Image.Copy)을 쓰므로 원본 픽셀은 공유하되 서로의 연산에 영향을 주지 않습니다.
+func (h *Handler) encodeVariants(ctx context.Context, job *Job, image *vips.Image, sizes []PresetSize, outputFormat string, p EncodeOptions) ([]encodedVariant, error) {
+	if job.Preset == nil {
+		v, err := h.encodeVariant(ctx, job, job.BaseKey, image, outputFormat, p)
+		return []encodedVariant{v}, err
+	}
+	return h.encodeConcurrently(ctx, len(sizes), func(ctx context.Context, i int) (encodedVariant, error) {
+		return h.encodePresetSize(ctx, job, image, sizes[i], outputFormat, p)
+	})
+}
+
+// encodeConcurrently는 encode(0..n-1)를 VARIANT_CONCURRENCY개까지 동시에 실행하고 결과를 순서대로 돌려줍니다.
+// 하나라도 실패하면 나머지를 취소하고, 순서상 첫 번째 오류를 돌려줍니다.
+func (h *Handler) encodeConcurrently(ctx context.Context, n int, encode func(ctx context.Context, i int) (encodedVariant, error)) ([]encodedVariant, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	variants := make([]encodedVariant, n)
+	errs := make([]error, n)
+	slots := make(chan struct{}, h.conf.VariantConcurrency)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+			variants[i], errs[i] = encode(ctx, i)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	var canceled error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			canceled = err
+		default:
+			return nil, err
+		}
+	}
+	if canceled != nil {
+		return nil, canceled
+	}
+	return variants, nil
+}
+
+// encodePresetSize는 이미지 사본을 프리셋 크기로 줄이고 샤프닝한 뒤 인코딩합니다.
+func (h *Handler) encodePresetSize(ctx context.Context, job *Job, image *vips.Image, size PresetSize, outputFormat string, p EncodeOptions) (encodedVariant, error) {
+	variant, err := image.Copy(nil)
+	if err != nil {
+		return encodedVariant{}, err
+	}
+	defer variant.Close()
+	if err := pipeline.Resize(variant, size.Width, size.Height, job.Preset.Fit, false, job.Preset.Background); err != nil {
+		return encodedVariant{}, fmt.Errorf("failed to resize for preset %s%s: %w", job.Event.Preset, size.Suffix(), err)
+	}
+	if size.Sharpen != nil {
+		if err := pipeline.Sharpen(variant, *size.Sharpen); err != nil {
+			return encodedVariant{}, fmt.Errorf("failed to sharpen preset %s%s: %w", job.Event.Preset, size.Suffix(), err)
+		}
+	}
+	key := replaceExtension(job.BaseKey, size.Suffix()+keyExtension(job.BaseKey))
+	return h.encodeVariant(ctx, job, key, variant, outputFormat, p)
+}
+
+// encodeVariant는 이미지 하나를 주 출력 포맷과 설정된 JXL/JPEG 대체 포맷으로 인코딩합니다.
+// 다른 크기와 동시에 호출될 수 있으므로 job은 job.mu를 잡고서만 바꿉니다.
+func (h *Handler) encodeVariant(ctx context.Context, job *Job, baseKey string, image *vips.Image, outputFormat string, p EncodeOptions) (encodedVariant, error) {
+	originalSize := len(job.Source)
+	// maxOutputBytes는 주 출력에만 적용됩니다. JXL/JPEG 대체 출력은 원래 크기와 품질을 유지합니다.
+	u, encoder, err := h.encodePrimary(ctx, job, baseKey, image, outputFormat, p, job.Event.MaxOutputBytes)
+	if err != nil {
+		return encodedVariant{}, err
+	}
+	job.mu.Lock()
+	compression := job.Result.Compression
+	job.mu.Unlock()
+	v := encodedVariant{encoder: encoder, uploads: []*Upload{u}}
+
+	if h.flags.Enabled(ctx, flagJXLOutput, job, h.conf.JXLOutput) {
+		if u := h.encodeJXLOutput(ctx, job, baseKey, image, p, compression != "lossy"); u != nil {
+			v.uploads = append(v.uploads, u)
+		}
+	}
+
+	if h.flags.Enabled(ctx, flagJPEGFallback, job, h.conf.JPEGFallback) && outputFormat != "jpeg" {
+		if err := h.checkBudget(ctx, "jpeg fallback encode "+baseKey); err != nil {
+			return encodedVariant{}, err
+		}
+		jpegStart := h.clock.Now()
+		jpegBuffer, err := encodeJPEGFallback(image, p.Keep, job.Background, h.conf)
+		if err != nil {
+			return encodedVariant{}, fmt.Errorf("failed to encode JPEG fallback: vips_error: %s", err)
+		}
+		log.Printf("Successfully encoded JPEG fallback. Original size: %d bytes, New size: %d bytes", originalSize, len(jpegBuffer))
+		v.uploads = append(v.uploads, &Upload{
+			Key:      replaceExtension(baseKey, ".jpg"),
+			Format:   "jpeg",
+			Body:     jpegBuffer,
+			Width:    image.Width(),
+			Height:   image.Height(),
+			EncodeMs: h.since(jpegStart),
+		})
+	}
+	return v, nil
+}
+
+// encodePrimary는 이미지를 outputFormat으로 인코딩해 주 출력 Upload를 만듭니다. 키는 baseKey의 확장자를 포맷에 맞게 바꾼 값입니다.
+// limit이 0보다 크면 그 크기 안에 들도록 품질이나 크기를 줄입니다. (encodeWithin)
+func (h *Handler) encodePrimary(ctx context.Context, job *Job, baseKey string, image *vips.Image, outputFormat string, p EncodeOptions, limit int) (*Upload, string, error) {
+	encoder, err := h.encoders.Get(outputFormat)
+	if err != nil {
+		return nil, "", err
+	}
+	encodeStart := h.clock.Now()
+	encodeCtx, endEncode := startPhase(ctx, "encode", attribute.String("thumbnail.format", outputFormat))
+	primary, encoded, err := h.encodeWithin(encodeCtx, job, baseKey, image, encoder, p, limit)
+	endEncode(err, attribute.Int("thumbnail.output.bytes", len(encoded.Data)), attribute.String("thumbnail.encoder", encoded.Encoder))
+	if err != nil {
+		return nil, "", err
+	}
+	if primary != image {
+		defer primary.Close()
+	}
+	job.mu.Lock()
+	compression := job.Result.Compression
+	job.mu.Unlock()
+	log.Printf("Successfully encoded %dx%d to %s (%s). Original size: %d bytes, New size: %d bytes", primary.Width(), primary.Height(), strings.ToUpper(outputFormat), compression, len(job.Source), len(encoded.Data))
+	return &Upload{
+		Key:      replaceExtension(baseKey, extensionOf(outputFormat)),
+		Format:   outputFormat,
+		Body:     encoded.Data,
+		Width:    primary.Width(),
+		Height:   primary.Height(),
+		Primary:  true,
+		EncodeMs: h.since(encodeStart),
+	}, encoded.Encoder, nil
+}
+
+// encodeJXLOutput은 실험적 JXL 출력을 만듭니다. A/B 비교용이므로 실패하거나 시간이 부족하면
+// 경고만 남기고 nil을 돌려 주 변환 결과는 유지합니다. 업로드 실패도 경고로만 남습니다.
+func (h *Handler) encodeJXLOutput(ctx context.Context, job *Job, baseKey string, image *vips.Image, p EncodeOptions, lossless bool) *Upload {
+	if err := h.checkBudget(ctx, "jxl encode "+baseKey); err != nil {
+		log.Printf("Warning: skipping JXL output: %v", err)
+		return nil
+	}
+	start := h.clock.Now()
+	jxlBuffer, err := encodeJXL(image, p.Keep, lossless, 0, h.conf)
+	if err != nil {
+		log.Printf("Warning: JXL encode failed, skipping JXL output: %v", err)
+		return nil
+	}
+	log.Printf("Successfully encoded to JXL. Original size: %d bytes, New size: %d bytes", len(job.Source), len(jxlBuffer))
+	return &Upload{
+		Key:      replaceExtension(baseKey, ".jxl"),
+		Format:   "jxl",
+		Body:     jxlBuffer,
+		Width:    image.Width(),
+		Height:   image.Height(),
+		EncodeMs: h.since(start),
+	}
+}
+
+// upload는 PreUpload 훅을 거쳐 출력 파일을 업로드하고 PostUpload 훅을 호출합니다.
+func (h *Handler) upload(ctx context.Context, job *Job, u *Upload) error {
+	// S3 Object Lambda 요청은 주 출력 하나를 응답으로 돌려주고 아무것도 올리지 않습니다.
+	if job.Serve != nil {
+		if u.Primary && job.Serve.served == nil {
+			job.Serve.served = u
+		}
+		return nil
+	}
+	job.Uploading = true
+	if err := h.hooks.PreUpload(ctx, job, u); err != nil {
+		return err
+	}
+	// Lambda 제한 시간에 걸려 강제 종료되기 전에 업로드를 끊고 재시도 가능한 오류를 돌려줍니다.
+	uploadCtx, cancel := uploadContext(ctx)
+	defer cancel()
+	uploadCtx, endUpload := startPhase(uploadCtx, "upload", attribute.String("s3.key", u.Key), attribute.String("thumbnail.format", u.Format), attribute.Int("thumbnail.output.bytes", len(u.Body)))
+	if err := h.uploadLimiter.Wait(uploadCtx, "s3://"+job.OutputBucket, h.conf.MetricsNamespace); err != nil {
+		endUpload(err)
+		return asBudgetError("upload "+u.Key, err)
+	}
+	attrs := h.outputAttrs(job.Tenant)
+	if u.StorageClass != "" {
+		attrs.StorageClass = types.StorageClass(u.StorageClass)
+	}
+	if u.CacheControl != "" {
+		attrs.CacheControl = aws.String(u.CacheControl)
+	}
+	uploadStart := h.clock.Now()
+	checksum, err := h.uploadObject(uploadCtx, job.OutputBucket, u.Key, u.Format, u.Body, attrs)
+	if t := job.Result.Timings; t != nil {
+		t.UploadMs += h.since(uploadStart)
+	}
+	if err != nil {
+		endUpload(err)
+		return asBudgetError("upload "+u.Key, err)
+	}
+	endUpload(nil)
+	recordObjectSize(ctx, "output", u.Format, len(u.Body))
+	return h.hooks.PostUpload(ctx, job, OutputResult{
+		Key:         u.Key,
+		Format:      u.Format,
+		Size:        int64(len(u.Body)),
+		Width:       u.Width,
+		Height:      u.Height,
+		Overwritten: u.Overwrite,
+		LogicalKey:  u.LogicalKey,
+		SHA256:      u.SHA256,
+		Pixels:      int64(u.Width) * int64(u.Height),
+		EncodeMs:    u.EncodeMs,
+		Checksum:    checksum,
+		Derivative:  u.Derivative,
+	})
+}
+
+// downloadObject는 S3 객체를 메모리 버퍼로 읽어 옵니다.
+// PARALLEL_DOWNLOAD_THRESHOLD_MB 이상인 객체는 동시 ranged GET으로 받습니다.
+func (h *Handler) downloadObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	buf, _, err := h.downloadSource(ctx, bucket, key, 0, "")
+	return buf, err
+}
+
+// downloadSource는 downloadObject와 같지만 객체에 저장된 Content-Type도 돌려줍니다.
+// size와 etag는 S3 알림에 들어 있던 원본 크기와 eTag입니다. 크기를 알면 병렬 다운로드 여부를 정하려고 HeadObject를 부르지 않으며,
+// 0이면 모르는 것으로 보고 HeadObject로 확인합니다.
+func (h *Handler) downloadSource(ctx context.Context, bucket, key string, size int64, etag string) ([]byte, string, error) {
+	if h.conf.ParallelDownloadThreshold > 0 {
+		var match *string
+		if etag != "" {
+			// S3 알림의 eTag에는 따옴표가 없습니다.
+			match = aws.String(`"` + strings.Trim(etag, `"`) + `"`)
+		}
+		if size <= 0 {
+			// HeadObject가 실패하면 단일 GetObject로 넘어가 실제 오류를 그쪽에서 보고합니다.
+			var err error
+			if size, match, err = h.sourceSize(ctx, bucket, key); err != nil {
+				size = 0
+			}
+		}
+		if size >= h.conf.ParallelDownloadThreshold {
+			return h.downloadParallel(ctx, bucket, key, size, match)
+		}
+	}
+
+	s3Object, err := h.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get object from S3: %w", err)
+	}
+	defer s3Object.Body.Close()
+
+	// [수정] 스트림을 메모리 버퍼로 읽기
+	buf, err := io.ReadAll(s3Object.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image from S3 stream: %w", err)
+	}
+	return buf, aws.ToString(s3Object.ContentType), nil
+}
+
+// uploadObject는 인코딩된 이미지를 attrs의 속성으로 S3에 업로드하고 저장된 체크섬을 돌려줍니다.
+func (h *Handler) uploadObject(ctx context.Context, bucket, key, format string, buf []byte, attrs objectAttrs) (*ObjectChecksum, error) {
+	log.Printf("Uploading converted image to: bucket=%s, key=%s", bucket, key)
+	if h.conf.MultipartThreshold > 0 && int64(len(buf)) >= h.conf.MultipartThreshold {
+		return h.uploadMultipart(ctx, bucket, key, format, buf, attrs)
+	}
+
+	sum, err := h.putObjectChecksum(ctx, bucket, key, h.encoders.ContentType(format), buf, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s image to S3: %w", strings.ToUpper(format), err)
+	}
+	return sum, nil
+}
+
+// putObject는 버퍼 하나를 단일 PutObject로 업로드합니다. 이미지가 아닌 보고서·프로파일 업로드에도 사용합니다.
+func (h *Handler) putObject(ctx context.Context, bucket, key, contentType string, buf []byte) error {
+	return h.putObjectAttrs(ctx, bucket, key, contentType, buf, objectAttrs{})
+}
+
+// putObjectAttrs는 putObject와 같지만 저장 클래스 등 객체 속성을 붙입니다.
+func (h *Handler) putObjectAttrs(ctx context.Context, bucket, key, contentType string, buf []byte, attrs objectAttrs) error {
+	_, err := h.putObjectChecksum(ctx, bucket, key, contentType, buf, attrs)
+	return err
+}
+
+// putObjectChecksum은 버킷의 체크섬 설정(UPLOAD_CHECKSUMS)으로 계산한 체크섬을 붙여 업로드하고 그 체크섬을 돌려줍니다.
+// 단일 PutObject의 체크섬은 항상 객체 전체의 값입니다.
+func (h *Handler) putObjectChecksum(ctx context.Context, bucket, key, contentType string, buf []byte, attrs objectAttrs) (*ObjectChecksum, error) {
+	// 변수 선언을 추가합니다.
+	bufSize := int64(len(buf))
+	checksum := h.uploadChecksum(bucket)
+	value := checksum.sum(buf)
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket), // aws.String 헬퍼 사용
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf),
+		ContentType: aws.String(contentType), // aws.String 헬퍼 사용
+
+		ContentLength: &bufSize,
+		CacheControl:  attrs.CacheControl,
+
+		ChecksumAlgorithm: types.ChecksumAlgorithm(checksum.Algorithm),
+		StorageClass:      attrs.StorageClass,
+
+		ObjectLockMode:            attrs.LockMode,
+		ObjectLockRetainUntilDate: attrs.RetainUntil,
+		ObjectLockLegalHoldStatus: attrs.LegalHold,
+	}
+	input.ChecksumCRC32, input.ChecksumCRC32C, input.ChecksumCRC64NVME, input.ChecksumSHA1, input.ChecksumSHA256 = checksum.fields(value)
+	if _, err := h.outputClient(bucket).PutObject(ctx, input); err != nil {
+		return nil, objectLockHint(err, attrs)
+	}
+	return &ObjectChecksum{Algorithm: checksum.Algorithm, Type: string(types.ChecksumTypeFullObject), Value: value}, nil
+}
+
+// replaceExtension은 키의 확장자를 newExt로 바꿉니다. 확장자가 없으면 뒤에 붙입니다.
+func replaceExtension(key, newExt string) string {
+	ext := keyExtension(key)
+	return key[0:len(key)-len(ext)] + newExt
+}
+
+// keyExtension은 S3 키 마지막 경로 요소의 확장자입니다.
+// S3 키의 구분자는 OS와 관계없이 "/"이며, ".hidden"처럼 점으로 시작하는 이름은 확장자 없는 이름으로 봅니다.
+func keyExtension(key string) string {
+	name := key[strings.LastIndex(key, "/")+1:]
+	if ext := path.Ext(name); ext != name {
+		return ext
+	}
+	return ""
+}
@@ -0,0 +1,294 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cshum/vipsgen/vips"
+)
+
+// fakeS3는 메모리 맵으로 동작하는 S3API입니다. 변환 경로가 쓰는 GetObject, HeadObject, PutObject만 구현하며
+// 나머지 메서드는 호출되면 nil 인터페이스 호출로 패닉이 나므로, 테스트가 예상하지 않은 S3 호출을 바로 드러냅니다.
+type fakeS3 struct {
+	S3API
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	// getErr와 putErr가 있으면 GetObject·PutObject가 그 오류를 돌려줍니다.
+	getErr error
+	putErr error
+	puts   []string
+	// heads는 HeadObject 호출 수이고, gets는 GetObject로 받은 키를 호출 순서대로 담습니다.
+	heads int
+	gets  []string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: map[string][]byte{}}
+}
+
+func (f *fakeS3) put(bucket, key string, body []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[bucket+"/"+key] = body
+}
+
+func (f *fakeS3) object(bucket, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, ok := f.objects[bucket+"/"+key]
+	return body, ok
+}
+
+// uploaded는 PutObject로 올라온 키를 순서대로 돌려줍니다.
+func (f *fakeS3) uploaded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.puts...)
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	f.gets = append(f.gets, aws.ToString(params.Key))
+	f.mu.Unlock()
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	body, ok := f.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: aws.Int64(int64(len(body))),
+	}, nil
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	f.heads++
+	f.mu.Unlock()
+	body, ok := f.object(aws.ToString(params.Bucket), aws.ToString(params.Key))
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(body)))}, nil
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if f.putErr != nil {
+		return nil, f.putErr
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := aws.ToString(params.Bucket), aws.ToString(params.Key)
+	f.put(bucket, key, body)
+	f.mu.Lock()
+	f.puts = append(f.puts, key)
+	f.mu.Unlock()
+	return &s3.PutObjectOutput{}, nil
+}
+
+// fakeClock은 항상 같은 시각을 돌려주는 Clock입니다.
+type fakeClock struct{ now time.Time }
+
+func (c fakeClock) Now() time.Time { return c.now }
+
+// manualClock은 Advance로만 흐르는 Clock입니다. 회로 차단기·요청 한도처럼 시간에 따라 바뀌는 상태를 시험합니다.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var vipsOnce sync.Once
+
+// startVips는 libvips를 한 번만 시작합니다. 이미지를 디코딩하는 테스트만 호출하므로
+// 키·배치 테스트는 libvips 없이도 실행됩니다.
+func startVips(t *testing.T) {
+	t.Helper()
+	vipsOnce.Do(func() { vips.Startup(nil) })
+}
+
+// testConfig는 env를 환경 변수로 설정한 뒤 실제 실행과 같은 loadConfig로 설정을 읽습니다.
+func testConfig(t *testing.T, env map[string]string) Config {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	c, err := loadConfig(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	return c
+}
+
+// newTestHandler는 client와 env 설정으로 Handler를 만듭니다. libvips를 시작하므로 libvips가 설치된 환경이 필요합니다.
+func newTestHandler(t *testing.T, client S3API, env map[string]string) *Handler {
+	t.Helper()
+	startVips(t)
+	return NewHandler(client, fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, testConfig(t, env))
+}
+
+// encodeAVIF는 src를 AV1 HEIF(AVIF)로 인코딩해 이미 AVIF인 원본을 만듭니다.
+func encodeAVIF(t *testing.T, src []byte) []byte {
+	t.Helper()
+	startVips(t)
+	image, err := vips.NewImageFromBuffer(src, nil)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	defer image.Close()
+	buf, err := image.HeifsaveBuffer(&vips.HeifsaveBufferOptions{Q: 50, Compression: vips.HeifCompressionAv1})
+	if err != nil {
+		t.Fatalf("failed to encode AVIF fixture: %v", err)
+	}
+	return buf
+}
+
+func TestHandleRequestConverts(t *testing.T) {
+	client := newFakeS3()
+	client.put("uploads", "photos/cat.png", selfTestFixture)
+	h := newTestHandler(t, client, nil)
+
+	result, err := h.HandleRequest(context.Background(), S3Event{S3Bucket: "uploads", S3Key: "photos/cat.png"})
+	if err != nil {
+		t.Fatalf("HandleRequest: %v", err)
+	}
+	if result.Status != StatusConverted {
+		t.Fatalf("status = %s, want %s", result.Status, StatusConverted)
+	}
+	if result.NewKey != "photos/cat.avif" || result.Format != "avif" {
+		t.Errorf("newKey = %q, format = %q, want photos/cat.avif avif", result.NewKey, result.Format)
+	}
+	if len(result.Outputs) != 1 || result.Outputs[0].Size == 0 {
+		t.Fatalf("outputs = %+v, want one non-empty output", result.Outputs)
+	}
+	body, ok := client.object("uploads", "photos/cat.avif")
+	if !ok {
+		t.Fatalf("photos/cat.avif was not uploaded, uploaded %v", client.uploaded())
+	}
+	if int64(len(body)) != result.Outputs[0].Size {
+		t.Errorf("uploaded %d bytes, result reports %d", len(body), result.Outputs[0].Size)
+	}
+}
+
+func TestHandleRequestGetObjectError(t *testing.T) {
+	client := newFakeS3()
+	client.getErr = errors.New("access denied")
+	h := newTestHandler(t, client, nil)
+
+	_, err := h.HandleRequest(context.Background(), S3Event{S3Bucket: "uploads", S3Key: "photos/cat.png"})
+	if !errors.Is(err, client.getErr) {
+		t.Fatalf("HandleRequest error = %v, want %v", err, client.getErr)
+	}
+	if !strings.Contains(err.Error(), "failed to get object from S3") {
+		t.Errorf("HandleRequest error = %v, want download error", err)
+	}
+	if puts := client.uploaded(); len(puts) > 0 {
+		t.Errorf("uploaded %v after failed download", puts)
+	}
+}
+
+func TestHandleRequestUploadFailure(t *testing.T) {
+	client := newFakeS3()
+	client.put("uploads", "photos/cat.png", selfTestFixture)
+	client.putErr = errors.New("slow down")
+	h := newTestHandler(t, client, nil)
+
+	_, err := h.HandleRequest(context.Background(), S3Event{S3Bucket: "uploads", S3Key: "photos/cat.png"})
+	if !errors.Is(err, client.putErr) {
+		t.Fatalf("HandleRequest error = %v, want %v", err, client.putErr)
+	}
+	if !strings.Contains(err.Error(), "failed to upload AVIF image to S3") {
+		t.Errorf("HandleRequest error = %v, want upload error", err)
+	}
+}
+
+func TestHandleRequestSkips(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		body   []byte
+		env    map[string]string
+		status Status
+	}{
+		{name: "self-test output", key: ".selftest/probe.png", body: selfTestFixture, status: StatusSkippedSelfTest},
+		{name: "debug artifact", key: ".debug/photos/cat/decoded.png", body: selfTestFixture, status: StatusSkippedDebugArtifact},
+		{name: "folder placeholder", key: "photos/", status: StatusSkippedEmptyObject},
+		{name: "empty object", key: "photos/empty.png", body: []byte{}, status: StatusSkippedEmptyObject},
+		{name: "already avif", key: "photos/cat.avif", body: encodeAVIF(t, selfTestFixture), status: StatusSkippedAlreadyAVIF},
+		{
+			name:   "skip rule",
+			key:    "tmp/cat.png",
+			body:   selfTestFixture,
+			env:    map[string]string{"SKIP_RULES": `{"exclude": [{"prefix": "tmp/"}]}`},
+			status: StatusSkippedFiltered,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeS3()
+			if tt.body != nil {
+				client.put("uploads", tt.key, tt.body)
+			}
+			h := newTestHandler(t, client, tt.env)
+
+			result, err := h.HandleRequest(context.Background(), S3Event{S3Bucket: "uploads", S3Key: tt.key})
+			if err != nil {
+				t.Fatalf("HandleRequest: %v", err)
+			}
+			if result.Status != tt.status {
+				t.Errorf("status = %s (%s), want %s", result.Status, result.Message, tt.status)
+			}
+			if puts := client.uploaded(); len(puts) > 0 {
+				t.Errorf("uploaded %v for skipped object", puts)
+			}
+		})
+	}
+}
+
+func TestUploadRefusesToOverwriteSource(t *testing.T) {
+	client := newFakeS3()
+	h := newTestHandler(t, client, nil)
+	job := &Job{
+		Bucket:       "uploads",
+		OutputBucket: "uploads",
+		SrcKey:       "photos/cat.avif",
+		Result:       &ConversionResult{},
+	}
+
+	err := h.upload(context.Background(), job, &Upload{Key: "photos/cat.avif", Format: "avif", Body: []byte("avif"), Primary: true})
+	if err == nil || !strings.Contains(err.Error(), "would overwrite the source object") {
+		t.Fatalf("upload error = %v, want source overwrite error", err)
+	}
+	if puts := client.uploaded(); len(puts) > 0 {
+		t.Errorf("uploaded %v over the source object", puts)
+	}
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// memoryTracker는 호출이 끝날 때마다 vips 추적 메모리와 Go 런타임 메모리를 기록하고,
+// warm 호출이 이어지는 동안 vips 메모리가 계속 늘어나면 누수 의심 경고를 남깁니다.
+// 모든 이미지가 닫혔다면 호출이 끝난 시점의 vips 추적 메모리는 매번 비슷해야 합니다.
+// HTTP API에서는 요청이 동시에 끝나므로 mu를 잡고 갱신합니다.
+type memoryTracker struct {
+	mu          sync.Mutex
+	invocations int
+	lastMem     int64
+	lastAllocs  int64
+	streak      int
+}
+
+// Report는 현재 메모리 상태를 로그로 남기고 누수 의심 여부를 판단합니다.
+func (t *memoryTracker) Report(c Config) {
+	var vm vips.MemoryStats
+	vips.ReadVipsMemStats(&vm)
+	var gm runtime.MemStats
+	runtime.ReadMemStats(&gm)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.invocations++
+
+	log.Printf("Memory: vips mem=%d high=%d allocs=%d files=%d; go heap=%d sys=%d gc=%d (invocation %d)",
+		vm.Mem, vm.MemHigh, vm.Allocs, vm.Files, gm.HeapAlloc, gm.Sys, gm.NumGC, t.invocations)
+
+	// 첫 호출은 기준값만 잡습니다.
+	if t.invocations > 1 && vm.Mem > t.lastMem && vm.Allocs > t.lastAllocs {
+		t.streak++
+	} else {
+		t.streak = 0
+	}
+	t.lastMem, t.lastAllocs = vm.Mem, vm.Allocs
+
+	if c.MemoryLeakStreak > 0 && t.streak >= c.MemoryLeakStreak {
+		log.Printf("Warning: vips tracked memory grew for %d consecutive warm invocations (now %d bytes, %d allocations); images may not be closed on some path",
+			t.streak, vm.Mem, vm.Allocs)
+		emitMetrics(c.MetricsNamespace, "Count", map[string]float64{"VipsMemoryLeakSuspected": 1})
+	}
+}
@@ -0,0 +1,194 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/berryssoda/test-encode/pipeline"
+	"github.com/cshum/vipsgen/vips"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// blurHashSource는 BlurHash를 계산하기 전에 줄이는 크기(px)입니다. 성분 4×3에는 이 정도면 충분합니다.
+const blurHashSource = 32
+
+// maxMetadataValue보다 긴 EXIF 값(MakerNote 등 제조사 바이너리)은 결과에 넣지 않습니다.
+const maxMetadataValue = 256
+
+// ImageMetadata는 "mode": "metadata" 요청의 결과입니다. 크기는 EXIF 방향을 적용한, 화면에 보이는 기준입니다.
+type ImageMetadata struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Format      string `json:"format"` // 로더 이름에서 뺀 포맷 (jpeg, png, heif, webp 등)
+	Loader      string `json:"loader"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	BlurHash    string `json:"blurHash,omitempty"`
+	Pages       int    `json:"pages,omitempty"`
+	Bands       int    `json:"bands"`
+	HasAlpha    bool   `json:"hasAlpha,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
+	ICCProfile  bool   `json:"iccProfile,omitempty"`
+	// EXIF는 libvips가 읽은 EXIF 태그입니다. 키는 "ifd0-Make"처럼 IFD와 태그 이름이며, 값은 사람이 읽는 형태입니다.
+	// GPS 태그(ifd3)는 METADATA_INCLUDE_GPS가 켜진 경우에만 넣습니다.
+	EXIF map[string]string `json:"exif,omitempty"`
+}
+
+// metadata는 인코딩 없이 원본의 크기, 포맷, EXIF, 해시, BlurHash만 뽑아 알림 대상(image.metadata)에 보냅니다.
+// 다운로드 전 건너뛰기 규칙과 디코딩 한도는 변환과 같고, 출력은 올리지 않으므로 업로드 훅은 호출되지 않습니다.
+func (h *Handler) metadata(ctx context.Context, job *Job) (ConversionResult, error) {
+	if err := h.filterSource(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	knownSize := job.Event.S3Size
+	if knownSize == 0 {
+		knownSize = -1
+	}
+	if err := h.emptySource(job, knownSize); err != nil {
+		return ConversionResult{}, err
+	}
+
+	downloadCtx, endDownload := startPhase(ctx, "download")
+	source, err := h.downloadObject(downloadCtx, job.Bucket, job.SrcKey)
+	endDownload(err, attribute.Int("thumbnail.source.bytes", len(source)))
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	job.Source = source
+	recordObjectSize(ctx, "source", keyExtension(job.SrcKey), len(source))
+	if err := h.emptySource(job, int64(len(source))); err != nil {
+		return ConversionResult{}, err
+	}
+	if err := h.checkDecodeLimits(job); err != nil {
+		return ConversionResult{}, err
+	}
+
+	_, endDecode := startPhase(ctx, "decode")
+	meta, err := h.extractMetadata(source)
+	endDecode(err, attribute.String("vips.loader", meta.Loader))
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	job.Loader = meta.Loader
+
+	job.Result.Status = StatusMetadataExtracted
+	job.Result.OriginalKey = job.SrcKey
+	job.Result.Format = meta.Format
+	job.Result.Metadata = &meta
+	job.Result.Message = fmt.Sprintf("Extracted metadata: %dx%d %s, %d EXIF tags", meta.Width, meta.Height, meta.Format, len(meta.EXIF))
+	log.Println(job.Result.Message)
+	if err := h.notify.Metadata(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	return *job.Result, nil
+}
+
+// extractMetadata는 헤더만 읽어 필드를 채우고, 작게 줄인 사본으로 BlurHash를 계산합니다.
+// libvips는 픽셀이 필요할 때까지 디코딩을 미루므로 큰 원본도 전체를 풀지 않습니다.
+func (h *Handler) extractMetadata(source []byte) (ImageMetadata, error) {
+	sum := sha256.Sum256(source)
+	meta := ImageMetadata{Size: int64(len(source)), SHA256: hex.EncodeToString(sum[:])}
+
+	image, err := vips.NewImageFromBuffer(source, nil)
+	if err != nil {
+		return meta, fmt.Errorf("failed to read image header: %w", err)
+	}
+	defer image.Close()
+	meta.Loader, _ = image.GetString("vips-loader")
+	meta.Format = loaderFormat(meta.Loader)
+	meta.Width, meta.Height = image.Width(), image.Height()
+	if image.HasField("page-height") {
+		if pageHeight, err := image.GetInt("page-height"); err == nil && pageHeight > 0 {
+			meta.Height = pageHeight
+		}
+	}
+	meta.Orientation = image.Orientation()
+	if meta.Orientation >= 5 {
+		meta.Width, meta.Height = meta.Height, meta.Width
+	}
+	meta.Pages = image.Pages()
+	meta.Bands = image.Bands()
+	meta.HasAlpha = image.HasAlpha()
+	meta.ICCProfile = image.HasField("icc-profile-data")
+	for _, field := range image.GetFields() {
+		name, ok := strings.CutPrefix(field, "exif-")
+		if !ok || name == "data" || (strings.HasPrefix(name, "ifd3-") && !h.conf.MetadataIncludeGPS) {
+			continue
+		}
+		value, err := image.GetAsString(field)
+		if err != nil || len(value) > maxMetadataValue {
+			continue
+		}
+		// libvips는 "Canon (Canon, ASCII, 6 components, 6 bytes)"처럼 형식 설명을 붙입니다.
+		if i := strings.LastIndex(value, " ("); i > 0 && strings.HasSuffix(value, ")") {
+			value = value[:i]
+		}
+		if meta.EXIF == nil {
+			meta.EXIF = map[string]string{}
+		}
+		meta.EXIF[name] = value
+	}
+
+	if hash, err := sourceBlurHash(source); err != nil {
+		// BlurHash는 미리보기용 부가 정보이므로 실패해도 나머지 메타데이터는 돌려줍니다.
+		log.Printf("Warning: failed to compute BlurHash: %v", err)
+	} else {
+		meta.BlurHash = hash
+	}
+	return meta, nil
+}
+
+// loaderFormat은 로더 이름에서 포맷을 뽑습니다. 예: jpegload_buffer → jpeg, heifload → heif
+func loaderFormat(loader string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(loader, "_buffer"), "load")
+}
+
+// sourceBlurHash는 원본을 blurHashSource 크기로 줄여(EXIF 방향 적용, 알파는 흰색에 합성) BlurHash를 계산합니다.
+func sourceBlurHash(source []byte) (string, error) {
+	thumb, err := vips.NewThumbnailBuffer(source, blurHashSource, &vips.ThumbnailBufferOptions{Height: blurHashSource})
+	if err != nil {
+		return "", err
+	}
+	defer thumb.Close()
+	return thumbnailBlurHash(thumb)
+}
+
+// imageBlurHash는 디코딩·처리한 이미지의 사본을 blurHashSource 크기로 줄여 BlurHash를 계산합니다. image는 바꾸지 않습니다.
+func imageBlurHash(image *vips.Image) (string, error) {
+	thumb, err := image.Copy(nil)
+	if err != nil {
+		return "", err
+	}
+	defer thumb.Close()
+	if err := pipeline.Resize(thumb, blurHashSource, blurHashSource, "inside", false, ""); err != nil {
+		return "", err
+	}
+	return thumbnailBlurHash(thumb)
+}
+
+// thumbnailBlurHash는 작게 줄인 이미지를 8비트 sRGB로 바꾸고(알파는 흰색에 합성) BlurHash를 계산합니다.
+func thumbnailBlurHash(thumb *vips.Image) (string, error) {
+	if err := thumb.Colourspace(vips.InterpretationSrgb, nil); err != nil {
+		return "", err
+	}
+	if thumb.HasAlpha() {
+		if err := thumb.Flatten(&vips.FlattenOptions{Background: []float64{255, 255, 255}}); err != nil {
+			return "", err
+		}
+	}
+	if err := thumb.Cast(vips.BandFormatUchar, nil); err != nil {
+		return "", err
+	}
+	pixels, err := thumb.RawsaveBuffer(vips.DefaultRawsaveBufferOptions())
+	if err != nil {
+		return "", err
+	}
+	if thumb.Bands() != 3 || len(pixels) < thumb.Width()*thumb.Height()*3 {
+		return "", fmt.Errorf("unexpected %d-band thumbnail", thumb.Bands())
+	}
+	return blurHash(pixels, thumb.Width(), thumb.Height(), blurHashX, blurHashY), nil
+}
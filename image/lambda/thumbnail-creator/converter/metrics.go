@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// emitMetrics는 CloudWatch Embedded Metric Format(EMF) 로그 한 줄로 지표를 남깁니다.
+// Lambda가 표준 출력의 EMF JSON을 지표로 변환하므로 SDK 호출이나 추가 권한이 필요 없습니다.
+// 모든 지표에는 함수 이름 차원(FunctionName)이 붙습니다.
+func emitMetrics(namespace string, unit string, values map[string]float64) {
+	metrics := make([]map[string]string, 0, len(values))
+	doc := map[string]any{
+		"FunctionName": os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+	}
+	for name, v := range values {
+		metrics = append(metrics, map[string]string{"Name": name, "Unit": unit})
+		doc[name] = v
+	}
+	doc["_aws"] = map[string]any{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  namespace,
+			"Dimensions": [][]string{{"FunctionName"}},
+			"Metrics":    metrics,
+		}},
+	}
+	line, err := json.Marshal(doc)
+	if err != nil {
+		log.Printf("Warning: failed to encode metrics: %v", err)
+		return
+	}
+	// EMF는 로그 이벤트 전체가 JSON이어야 하므로 log 접두사 없이 출력합니다.
+	fmt.Fprintln(os.Stdout, string(line))
+}
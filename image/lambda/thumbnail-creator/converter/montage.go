@@ -0,0 +1,221 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// MontageRequest는 "mode": "montage" 요청의 설정입니다. Keys의 이미지를 순서대로 격자에 배치합니다.
+type MontageRequest struct {
+	Keys       []string `json:"keys"`
+	Columns    int      `json:"columns,omitempty"`    // 0이면 정사각형에 가깝게 정합니다.
+	CellWidth  int      `json:"cellWidth,omitempty"`  // 기본 300
+	CellHeight int      `json:"cellHeight,omitempty"` // 기본 300
+	Fit        string   `json:"fit,omitempty"`        // cover(기본, 잘라서 채움) | pad(여백으로 채움)
+	Gap        *int     `json:"gap,omitempty"`        // 칸 사이와 바깥 여백(px), 기본 8
+	Background string   `json:"background,omitempty"` // 기본 #ffffff
+	// Labels는 칸마다 아래에 쓸 글자입니다. LabelFilenames가 true이면 비어 있는 칸에 원본 파일 이름을 씁니다.
+	Labels         []string `json:"labels,omitempty"`
+	LabelFilenames bool     `json:"labelFilenames,omitempty"`
+	LabelSize      int      `json:"labelSize,omitempty"`  // 기본 14
+	LabelColor     string   `json:"labelColor,omitempty"` // 기본 #000000
+	Format         string   `json:"format,omitempty"`     // 기본 jpeg
+	Quality        int      `json:"quality,omitempty"`    // 0이면 포맷별 기본 품질
+}
+
+// Montage는 여러 원본을 한 장의 격자 이미지(컨택트 시트)로 합성해 outputKey에 올립니다.
+func (h *Handler) Montage(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if event.Montage == nil || len(event.Montage.Keys) == 0 {
+		return ConversionResult{}, fmt.Errorf("invalid event: montage requires keys")
+	}
+	req := *event.Montage
+	if err := req.validate(h.conf); err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if event.OutputKey == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: montage requires outputKey")
+	}
+	encoder, err := h.encoders.Get(req.Format)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	background, _ := pipeline.ParseColor(req.Background)
+	labelColor, _ := pipeline.ParseColor(req.LabelColor)
+
+	cells := make([]*vips.Image, 0, len(req.Keys))
+	defer func() {
+		for _, c := range cells {
+			c.Close()
+		}
+	}()
+	labelHeight := 0
+	if len(req.Labels) > 0 || req.LabelFilenames {
+		labelHeight = req.LabelSize * 2
+	}
+	for i, key := range req.Keys {
+		if err := h.checkBudget(ctx, "montage cell "+key); err != nil {
+			return ConversionResult{}, err
+		}
+		cell, err := h.montageCell(ctx, event.S3Bucket, key, req, background)
+		if err != nil {
+			return ConversionResult{}, err
+		}
+		cells = append(cells, cell)
+		if labelHeight > 0 {
+			if err := labelCell(cell, req.label(i), labelHeight, req, background, labelColor); err != nil {
+				return ConversionResult{}, fmt.Errorf("failed to label montage cell %s: %w", key, err)
+			}
+		}
+	}
+
+	options := vips.DefaultArrayjoinOptions()
+	options.Across = min(req.Columns, len(cells))
+	options.Shim = *req.Gap
+	options.Background = background
+	sheet, err := vips.NewArrayjoin(cells, options)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to join montage cells: %w", err)
+	}
+	defer sheet.Close()
+	if gap := *req.Gap; gap > 0 {
+		err := sheet.Embed(gap, gap, sheet.Width()+2*gap, sheet.Height()+2*gap, &vips.EmbedOptions{Extend: vips.ExtendBackground, Background: background})
+		if err != nil {
+			return ConversionResult{}, err
+		}
+	}
+	encoded, err := encoder.Encode(sheet, EncodeOptions{Keep: vips.KeepNone, Effort: h.conf.AVIFEffort, Quality: req.Quality})
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode montage to %s: vips_error: %s", strings.ToUpper(req.Format), err)
+	}
+
+	key := replaceExtension(event.OutputKey, extensionOf(req.Format))
+	if _, err := h.uploadObject(ctx, event.S3Bucket, key, req.Format, encoded.Data, h.outputAttrs(nil)); err != nil {
+		return ConversionResult{}, err
+	}
+	msg := fmt.Sprintf("Montage of %d images (%d columns, %dx%d cells), %dx%d", len(cells), options.Across, req.CellWidth, req.CellHeight, sheet.Width(), sheet.Height())
+	log.Println(msg)
+	return ConversionResult{
+		Status:  StatusMontageCreated,
+		NewKey:  key,
+		Format:  req.Format,
+		Outputs: []OutputResult{{Key: key, Format: req.Format, Size: int64(len(encoded.Data)), Width: sheet.Width(), Height: sheet.Height()}},
+		Message: msg,
+	}, nil
+}
+
+func (r *MontageRequest) validate(c Config) error {
+	if len(r.Keys) > c.MontageMaxImages {
+		return fmt.Errorf("montage has %d keys, more than MONTAGE_MAX_IMAGES %d", len(r.Keys), c.MontageMaxImages)
+	}
+	if len(r.Labels) > len(r.Keys) {
+		return fmt.Errorf("montage has more labels than keys")
+	}
+	if r.Columns == 0 {
+		r.Columns = int(math.Ceil(math.Sqrt(float64(len(r.Keys)))))
+	}
+	if r.CellWidth == 0 {
+		r.CellWidth = 300
+	}
+	if r.CellHeight == 0 {
+		r.CellHeight = 300
+	}
+	if r.Fit == "" {
+		r.Fit = "cover"
+	}
+	if r.Gap == nil {
+		gap := 8
+		r.Gap = &gap
+	}
+	if r.Background == "" {
+		r.Background = "#ffffff"
+	}
+	if r.LabelSize == 0 {
+		r.LabelSize = 14
+	}
+	if r.LabelColor == "" {
+		r.LabelColor = "#000000"
+	}
+	if r.Format == "" {
+		r.Format = "jpeg"
+	}
+	switch {
+	case r.Columns < 1 || r.CellWidth < 1 || r.CellHeight < 1 || *r.Gap < 0 || r.LabelSize < 1:
+		return fmt.Errorf("montage columns, cell size and label size must be positive and gap must not be negative")
+	case r.Fit != "cover" && r.Fit != "pad":
+		return fmt.Errorf("montage fit must be cover or pad")
+	}
+	if _, err := pipeline.ParseColor(r.Background); err != nil {
+		return fmt.Errorf("invalid montage background: %w", err)
+	}
+	if _, err := pipeline.ParseColor(r.LabelColor); err != nil {
+		return fmt.Errorf("invalid montage labelColor: %w", err)
+	}
+	return nil
+}
+
+// label은 i번째 칸의 글자입니다.
+func (r *MontageRequest) label(i int) string {
+	if i < len(r.Labels) && r.Labels[i] != "" {
+		return r.Labels[i]
+	}
+	if r.LabelFilenames {
+		return path.Base(r.Keys[i])
+	}
+	return ""
+}
+
+// montageCell은 원본 하나를 읽어 sRGB로 맞추고 알파를 배경에 합성한 뒤 칸 크기로 맞춥니다.
+func (h *Handler) montageCell(ctx context.Context, bucket, key string, req MontageRequest, background []float64) (*vips.Image, error) {
+	data, err := h.downloadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	cell, err := vips.NewImageFromBuffer(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode montage image %s: %w", key, err)
+	}
+	steps := []func() error{
+		cell.Autorot,
+		func() error { return cell.Colourspace(vips.InterpretationSrgb, nil) },
+		func() error {
+			if !cell.HasAlpha() {
+				return nil
+			}
+			return cell.Flatten(&vips.FlattenOptions{Background: background})
+		},
+		func() error {
+			return pipeline.Resize(cell, req.CellWidth, req.CellHeight, req.Fit, true, req.Background)
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			cell.Close()
+			return nil, fmt.Errorf("failed to prepare montage image %s: %w", key, err)
+		}
+	}
+	return cell, nil
+}
+
+// labelCell은 칸 아래에 height만큼 배경을 늘리고 글자를 가운데에 씁니다.
+func labelCell(cell *vips.Image, text string, height int, req MontageRequest, background, color []float64) error {
+	err := cell.Embed(0, 0, cell.Width(), cell.Height()+height, &vips.EmbedOptions{Extend: vips.ExtendBackground, Background: background})
+	if err != nil || text == "" {
+		return err
+	}
+	overlay, err := pipeline.TextOverlay(text, "sans", req.LabelSize, color, 1)
+	if err != nil {
+		return err
+	}
+	defer overlay.Close()
+	x := max(0, (cell.Width()-overlay.Width())/2)
+	y := cell.Height() - height + max(0, (height-overlay.Height())/2)
+	return cell.Composite2(overlay, vips.BlendModeOver, &vips.Composite2Options{X: x, Y: y})
+}
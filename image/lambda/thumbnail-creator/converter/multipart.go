@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// multipartPartSize는 멀티파트 업로드의 파트 크기입니다. S3 최소값(5MiB)보다 크게 잡습니다.
+const multipartPartSize = 8 << 20
+
+// abortTimeout은 취소된 요청 컨텍스트와 별개로 AbortMultipartUpload에 주는 시간입니다.
+// 파트 업로드는 업로드 제한 시간보다 이만큼 먼저 끊어 중단 요청이 제한 시간 안에 끝나게 합니다.
+const abortTimeout = 5 * time.Second
+
+// uploadMultipart는 큰 출력을 파트로 나누어 업로드하고 저장된 체크섬을 돌려줍니다.
+// 도중에 실패하거나 컨텍스트가 취소되면 AbortMultipartUpload로 올라간 파트를 정리하므로
+// 수명 주기 규칙으로 고아 파트를 치울 필요가 없습니다.
+// 파트마다 체크섬을 계산해 보내고, FULL_OBJECT이면 완료 요청에 객체 전체의 체크섬도 보내 S3가 검증하게 합니다.
+func (h *Handler) uploadMultipart(ctx context.Context, bucket, key, format string, buf []byte, attrs objectAttrs) (_ *ObjectChecksum, err error) {
+	// 중단 요청은 ctx의 제한 시간이 지난 뒤에 시작하면 실행 환경과 함께 끊기므로 abortTimeout을 남겨 둡니다.
+	uploadCtx := ctx
+	ctx, cancel := withMargin(ctx, abortTimeout)
+	defer cancel()
+	checksum := h.uploadChecksum(bucket)
+	created, err := h.outputClient(bucket).CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		ContentType:       aws.String(h.encoders.ContentType(format)),
+		CacheControl:      attrs.CacheControl,
+		ChecksumAlgorithm: types.ChecksumAlgorithm(checksum.Algorithm),
+		ChecksumType:      types.ChecksumType(checksum.Type),
+		StorageClass:      attrs.StorageClass,
+
+		ObjectLockMode:            attrs.LockMode,
+		ObjectLockRetainUntilDate: attrs.RetainUntil,
+		ObjectLockLegalHoldStatus: attrs.LegalHold,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start multipart upload of %s image: %w", strings.ToUpper(format), err)
+	}
+	uploadID := created.UploadId
+	defer func() {
+		if err != nil {
+			h.abortMultipart(uploadCtx, bucket, key, uploadID)
+		}
+	}()
+
+	var parts []types.CompletedPart
+	for offset, number := 0, int32(1); offset < len(buf); offset, number = offset+multipartPartSize, number+1 {
+		part := buf[offset:min(offset+multipartPartSize, len(buf))]
+		size := int64(len(part))
+		input := &s3.UploadPartInput{
+			Bucket:            aws.String(bucket),
+			Key:               aws.String(key),
+			UploadId:          uploadID,
+			PartNumber:        aws.Int32(number),
+			Body:              bytes.NewReader(part),
+			ContentLength:     &size,
+			ChecksumAlgorithm: types.ChecksumAlgorithm(checksum.Algorithm),
+		}
+		input.ChecksumCRC32, input.ChecksumCRC32C, input.ChecksumCRC64NVME, input.ChecksumSHA1, input.ChecksumSHA256 = checksum.fields(checksum.sum(part))
+		out, err := h.outputClient(bucket).UploadPart(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d of %s image: %w", number, strings.ToUpper(format), err)
+		}
+		completed := types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(number)}
+		completed.ChecksumCRC32, completed.ChecksumCRC32C, completed.ChecksumCRC64NVME, completed.ChecksumSHA1, completed.ChecksumSHA256 =
+			checksum.fields(checksum.pick(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumCRC64NVME, out.ChecksumSHA1, out.ChecksumSHA256))
+		parts = append(parts, completed)
+	}
+
+	complete := &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	}
+	if checksum.Type == string(types.ChecksumTypeFullObject) {
+		complete.ChecksumType = types.ChecksumTypeFullObject
+		complete.ChecksumCRC32, complete.ChecksumCRC32C, complete.ChecksumCRC64NVME, complete.ChecksumSHA1, complete.ChecksumSHA256 = checksum.fields(checksum.sum(buf))
+	}
+	out, err := h.outputClient(bucket).CompleteMultipartUpload(ctx, complete)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload of %s image: %w", strings.ToUpper(format), err)
+	}
+	return &ObjectChecksum{
+		Algorithm: checksum.Algorithm,
+		Type:      checksum.Type,
+		Value:     checksum.pick(out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumCRC64NVME, out.ChecksumSHA1, out.ChecksumSHA256),
+	}, nil
+}
+
+// abortMultipart는 실패한 멀티파트 업로드를 중단합니다. 요청 컨텍스트가 이미 취소되었을 수 있으므로
+// 취소를 물려받지 않는 별도 컨텍스트를 사용합니다.
+func (h *Handler) abortMultipart(ctx context.Context, bucket, key string, uploadID *string) {
+	abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+	if _, err := h.outputClient(bucket).AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	}); err != nil {
+		log.Printf("Warning: failed to abort multipart upload %s for key %s: %v", aws.ToString(uploadID), key, err)
+		return
+	}
+	log.Printf("Aborted multipart upload %s for key %s", aws.ToString(uploadID), key)
+}
@@ -0,0 +1,368 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// Notifier는 변환 결과를 받는 하위 시스템 하나(EventBridge, SNS, 웹훅, DynamoDB)입니다.
+// detailType은 image.converted, image.failed, image.metadata("mode": "metadata") 중 하나이며, detail은 EventBridge 이벤트의 detail과 같습니다.
+type Notifier interface {
+	Notify(ctx context.Context, job *Job, detailType string, detail conversionEvent) error
+}
+
+// SNSAPI는 SNS 알림에 사용하는 호출입니다.
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// NotifierSpec은 NOTIFIERS(JSON 배열) 항목 하나입니다.
+type NotifierSpec struct {
+	// Type은 eventbridge | sns | webhook | dynamodb입니다. Name은 로그에 쓰는 이름이며 기본값은 Type입니다.
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	// EventBus(eventbridge, 없으면 테넌트 버스 → EVENT_BUS_NAME), TopicARN(sns),
+	// URL과 Headers(webhook), Table(dynamodb) 중 Type에 맞는 값을 씁니다.
+	EventBus string            `json:"eventBus,omitempty"`
+	TopicARN string            `json:"topicArn,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Table    string            `json:"table,omitempty"`
+	// Events는 보낼 알림(converted | failed | metadata)입니다. 비어 있으면 converted와 failed를 보냅니다.
+	Events []string `json:"events,omitempty"`
+	// Retry는 이 대상의 재시도 정책입니다.
+	Retry NotifyRetry `json:"retry"`
+	// Optional이면 image.converted 전송 실패가 변환을 실패시키지 않고 경고만 남깁니다.
+	// 기본값(false)에서는 구독자가 결과를 놓치지 않도록 오류를 돌려 재시도하게 합니다.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// NotifyRetry는 대상별 재시도 정책입니다. BackoffMs부터 시도마다 두 배씩 기다립니다.
+// RateLimited는 재시도하지 않고 바로 돌려줍니다. (기본 attempts 3, backoffMs 100)
+type NotifyRetry struct {
+	Attempts  int `json:"attempts,omitempty"`
+	BackoffMs int `json:"backoffMs,omitempty"`
+}
+
+const maxNotifyAttempts = 10
+
+var defaultNotifyRetry = NotifyRetry{Attempts: 3, BackoffMs: 100}
+
+// parseNotifiers는 NOTIFIERS JSON을 읽고 기본값을 채워 검증합니다.
+func parseNotifiers(data []byte) ([]NotifierSpec, error) {
+	var specs []NotifierSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, err
+	}
+	for i := range specs {
+		s := &specs[i]
+		if s.Name == "" {
+			s.Name = s.Type
+		}
+		if err := s.normalize(); err != nil {
+			return nil, fmt.Errorf("notifier %d (%s): %w", i, s.Name, err)
+		}
+	}
+	return specs, nil
+}
+
+func (s *NotifierSpec) normalize() error {
+	switch s.Type {
+	case "eventbridge":
+	case "sns":
+		if s.TopicARN == "" {
+			return fmt.Errorf("sns notifier requires topicArn")
+		}
+	case "webhook":
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("webhook notifier requires an http(s) url")
+		}
+	case "dynamodb":
+		if s.Table == "" {
+			return fmt.Errorf("dynamodb notifier requires table")
+		}
+	default:
+		return fmt.Errorf("unknown type %q: must be eventbridge, sns, webhook or dynamodb", s.Type)
+	}
+	for _, e := range s.Events {
+		if e != "converted" && e != "failed" && e != "metadata" {
+			return fmt.Errorf("unknown event %q: must be converted, failed or metadata", e)
+		}
+	}
+	if s.Retry.Attempts == 0 {
+		s.Retry.Attempts = defaultNotifyRetry.Attempts
+	}
+	if s.Retry.BackoffMs == 0 {
+		s.Retry.BackoffMs = defaultNotifyRetry.BackoffMs
+	}
+	if s.Retry.Attempts < 1 || s.Retry.Attempts > maxNotifyAttempts || s.Retry.BackoffMs < 0 {
+		return fmt.Errorf("retry attempts must be between 1 and %d and backoffMs must not be negative", maxNotifyAttempts)
+	}
+	return nil
+}
+
+// wants는 이 대상이 detailType 알림을 받는지 돌려줍니다.
+func (s NotifierSpec) wants(detailType string) bool {
+	if len(s.Events) == 0 {
+		return detailType != "image.metadata"
+	}
+	for _, e := range s.Events {
+		if "image."+e == detailType {
+			return true
+		}
+	}
+	return false
+}
+
+// UseNotifier는 알림 대상 하나를 추가합니다. 처음 호출할 때 모든 대상에 나눠 보내는 미들웨어를 등록합니다.
+func (h *Handler) UseNotifier(spec NotifierSpec, n Notifier) {
+	if h.notify == nil {
+		h.notify = &notifyFanout{namespace: h.conf.MetricsNamespace}
+		h.hooks.Use(h.notify)
+	}
+	h.notify.sinks = append(h.notify.sinks, notifySink{spec: spec, notifier: n})
+}
+
+// UseNotifiers는 NOTIFIERS에 선언된 대상마다 클라이언트를 만들어 추가합니다.
+func (h *Handler) UseNotifiers(cfg aws.Config, specs []NotifierSpec) {
+	for _, spec := range specs {
+		switch spec.Type {
+		case "eventbridge":
+			h.UseNotifier(spec, h.newEventPublisher(eventbridge.NewFromConfig(cfg), spec.EventBus))
+		case "sns":
+			h.UseNotifier(spec, &snsNotifier{api: sns.NewFromConfig(cfg), topic: spec.TopicARN, limiter: h.notifyLimiter, namespace: h.conf.MetricsNamespace})
+		case "webhook":
+			h.UseNotifier(spec, &webhookNotifier{client: &http.Client{Timeout: 10 * time.Second}, url: spec.URL, headers: spec.Headers, limiter: h.notifyLimiter, namespace: h.conf.MetricsNamespace})
+		case "dynamodb":
+			h.UseNotifier(spec, &dynamoNotifier{db: dynamodb.NewFromConfig(cfg), table: spec.Table, clock: h.clock, limiter: h.notifyLimiter, namespace: h.conf.MetricsNamespace})
+		}
+	}
+}
+
+type notifySink struct {
+	spec     NotifierSpec
+	notifier Notifier
+}
+
+// notifyFanout은 변환 결과를 등록된 모든 알림 대상에 동시에 보내는 미들웨어입니다.
+// 건너뛴 요청(SKIPPED_*)은 보내지 않습니다.
+type notifyFanout struct {
+	sinks     []notifySink
+	namespace string
+}
+
+// PostConvert는 image.converted를 보냅니다. Optional이 아닌 대상이 실패하면 오류를 돌려 재시도하게 합니다.
+// 출력 키는 같으므로 다시 변환해도 안전하지만, 이미 받은 대상은 같은 알림을 한 번 더 받습니다.
+func (f *notifyFanout) PostConvert(ctx context.Context, job *Job) error {
+	return f.publish(ctx, job, "image.converted")
+}
+
+// Metadata는 "mode": "metadata"의 결과를 image.metadata로 보냅니다. 실패 처리는 PostConvert와 같습니다.
+// 알림 대상이 없으면 결과만 돌려줍니다.
+func (f *notifyFanout) Metadata(ctx context.Context, job *Job) error {
+	if f == nil {
+		return nil
+	}
+	return f.publish(ctx, job, "image.metadata")
+}
+
+// publish는 job.Result를 detailType으로 보내고, Optional이 아닌 대상의 실패만 오류로 모아 돌려줍니다.
+func (f *notifyFanout) publish(ctx context.Context, job *Job, detailType string) error {
+	detail := conversionEvent{Bucket: job.Bucket, ConversionResult: *job.Result}
+	detail.stamp()
+	errs := f.send(ctx, job, detailType, detail)
+	var required []error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if f.sinks[i].spec.Optional {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		required = append(required, err)
+	}
+	return errors.Join(required...)
+}
+
+// OnFailure는 image.failed를 보냅니다. 원래 오류를 가리지 않도록 전송 실패는 로그만 남깁니다.
+func (f *notifyFanout) OnFailure(ctx context.Context, job *Job, err error) {
+	detail := conversionEvent{
+		Bucket:           job.Bucket,
+		ConversionResult: failedResult(job.Result.Tenant, job.SrcKey, err),
+		Error:            err.Error(),
+		ErrorType:        errorType(err),
+	}
+	detail.stamp()
+	for _, err := range f.send(ctx, job, "image.failed", detail) {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// send는 detailType을 받는 대상마다 고루틴으로 재시도 정책에 따라 보내고, 대상 순서대로 오류를 돌려줍니다.
+func (f *notifyFanout) send(ctx context.Context, job *Job, detailType string, detail conversionEvent) []error {
+	errs := make([]error, len(f.sinks))
+	var wg sync.WaitGroup
+	for i, s := range f.sinks {
+		if !s.spec.wants(detailType) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.deliver(ctx, job, detailType, detail); err != nil {
+				emitMetrics(f.namespace, "Count", map[string]float64{"NotificationFailed": 1})
+				errs[i] = fmt.Errorf("notifier %s: %w", s.spec.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func (s notifySink) deliver(ctx context.Context, job *Job, detailType string, detail conversionEvent) error {
+	backoff := time.Duration(s.spec.Retry.BackoffMs) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := s.notifier.Notify(ctx, job, detailType, detail)
+		var limited *RateLimited
+		if err == nil || errors.As(err, &limited) || attempt >= s.spec.Retry.Attempts {
+			return err
+		}
+		log.Printf("Warning: notifier %s attempt %d/%d failed, retrying in %s: %v", s.spec.Name, attempt, s.spec.Retry.Attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// snsNotifier는 알림을 SNS 주제에 JSON 메시지로 보냅니다.
+// 구독 필터 정책에 쓸 수 있도록 detailType, status, tenant를 메시지 속성으로 붙입니다.
+type snsNotifier struct {
+	api       SNSAPI
+	topic     string
+	limiter   *rateLimiter
+	namespace string
+}
+
+func (n *snsNotifier) Notify(ctx context.Context, job *Job, detailType string, detail conversionEvent) error {
+	if err := n.limiter.Wait(ctx, "sns://"+n.topic, n.namespace); err != nil {
+		return err
+	}
+	body, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s notification: %w", detailType, err)
+	}
+	attrs := map[string]snstypes.MessageAttributeValue{
+		"detailType": {DataType: aws.String("String"), StringValue: aws.String(detailType)},
+		"status":     {DataType: aws.String("String"), StringValue: aws.String(string(detail.Status))},
+	}
+	if detail.Tenant != "" {
+		attrs["tenant"] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(detail.Tenant)}
+	}
+	if _, err := n.api.Publish(ctx, &sns.PublishInput{
+		TopicArn:          aws.String(n.topic),
+		Message:           aws.String(string(body)),
+		MessageAttributes: attrs,
+	}); err != nil {
+		return fmt.Errorf("failed to publish %s to %s: %w", detailType, n.topic, err)
+	}
+	log.Printf("Published %s notification to %s", detailType, n.topic)
+	return nil
+}
+
+// webhookNotifier는 알림을 {"detailType", "detail"} JSON으로 POST합니다. 2xx가 아니면 실패입니다.
+type webhookNotifier struct {
+	client    *http.Client
+	url       string
+	headers   map[string]string
+	limiter   *rateLimiter
+	namespace string
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, job *Job, detailType string, detail conversionEvent) error {
+	u, _ := url.Parse(n.url)
+	if err := n.limiter.Wait(ctx, "webhook://"+u.Host, n.namespace); err != nil {
+		return err
+	}
+	body, err := json.Marshal(struct {
+		DetailType string          `json:"detailType"`
+		Detail     conversionEvent `json:"detail"`
+	}{detailType, detail})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s notification: %w", detailType, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post %s to %s: %w", detailType, u.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to post %s to %s: %s", detailType, u.Host, resp.Status)
+	}
+	log.Printf("Posted %s notification to %s", detailType, u.Host)
+	return nil
+}
+
+// dynamoNotifier는 알림마다 테이블에 항목 하나를 씁니다. 원본별 변환 이력을 조회하는 서비스가 읽습니다.
+// 테이블 키: Source(파티션, 버킷/키) + At(정렬, RFC3339 시각). ExpiresAt은 TTL 속성입니다.
+type dynamoNotifier struct {
+	db        DynamoAPI
+	table     string
+	clock     Clock
+	limiter   *rateLimiter
+	namespace string
+}
+
+func (n *dynamoNotifier) Notify(ctx context.Context, job *Job, detailType string, detail conversionEvent) error {
+	if err := n.limiter.Wait(ctx, "dynamodb://"+n.table, n.namespace); err != nil {
+		return err
+	}
+	body, err := json.Marshal(detail)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s notification: %w", detailType, err)
+	}
+	now := n.clock.Now().UTC()
+	if _, err := n.db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(n.table),
+		Item: map[string]types.AttributeValue{
+			"Source":     &types.AttributeValueMemberS{Value: detail.Bucket + "/" + detail.OriginalKey},
+			"At":         &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+			"DetailType": &types.AttributeValueMemberS{Value: detailType},
+			"Status":     &types.AttributeValueMemberS{Value: string(detail.Status)},
+			"Detail":     &types.AttributeValueMemberS{Value: string(body)},
+			"ExpiresAt":  numberAttr(now.Add(recordRetention).Unix()),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to write %s to %s: %w", detailType, n.table, err)
+	}
+	return nil
+}
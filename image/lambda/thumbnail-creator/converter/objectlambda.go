@@ -0,0 +1,285 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// ObjectLambdaAPI는 S3 Object Lambda 응답에 쓰는 S3 클라이언트 메서드입니다.
+type ObjectLambdaAPI interface {
+	WriteGetObjectResponse(ctx context.Context, params *s3.WriteGetObjectResponseInput, optFns ...func(*s3.Options)) (*s3.WriteGetObjectResponseOutput, error)
+}
+
+// GetObjectContext, ObjectLambdaUserRequest, ObjectLambdaConfiguration은 S3 Object Lambda GetObject 이벤트 필드입니다.
+type GetObjectContext struct {
+	InputS3URL  string `json:"inputS3Url"`
+	OutputRoute string `json:"outputRoute"`
+	OutputToken string `json:"outputToken"`
+}
+
+type ObjectLambdaUserRequest struct {
+	URL string `json:"url"`
+}
+
+type ObjectLambdaConfiguration struct {
+	AccessPointARN           string `json:"accessPointArn"`
+	SupportingAccessPointARN string `json:"supportingAccessPointArn"`
+	// Payload는 Object Lambda 액세스 포인트에 설정한 문자열이며, 여기서는 기본 변환 인자 JSON 객체입니다.
+	// 예: {"format": "webp", "width": "1024"}. 요청 URL의 쿼리 인자가 같은 이름의 값을 덮어씁니다.
+	Payload string `json:"payload,omitempty"`
+}
+
+// objectLambdaParams는 요청 URL 쿼리와 payload에서 읽는 변환 인자입니다.
+var objectLambdaParams = []string{"preset", "size", "width", "height", "fit", "format", "quality"}
+
+// UseObjectLambda는 S3 Object Lambda 액세스 포인트로 들어온 GetObject에 변환한 이미지를 돌려주게 합니다.
+// 실행 역할에 s3-object-lambda:WriteGetObjectResponse 권한이 필요합니다.
+func (h *Handler) UseObjectLambda(api ObjectLambdaAPI) {
+	h.objectLambda = &objectLambda{api: api, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type objectLambda struct {
+	api    ObjectLambdaAPI
+	client *http.Client
+}
+
+// objectLambdaRequest는 GetObject 요청 하나의 응답 상태입니다. Job.Serve로 변환 경로에 넘깁니다.
+// 변환 경로는 이 값이 있으면 원본을 inputS3Url에서 받고, 주 출력을 올리는 대신 served에 담습니다.
+type objectLambdaRequest struct {
+	input string
+	// size는 size 인자로 고른 프리셋 크기 접미사(w512, 64x64)이며, 비어 있으면 프리셋의 첫 크기입니다.
+	size string
+	// source와 contentType은 받은 원본과 그 Content-Type이며, 변환하지 않은 요청에 그대로 돌려줍니다.
+	source      []byte
+	contentType string
+	served      *Upload
+}
+
+// ObjectLambda는 S3 Object Lambda GetObject 요청을 변환 요청으로 바꿔 처리하고 WriteGetObjectResponse로 응답합니다.
+// 인자(요청 URL의 쿼리, 없으면 액세스 포인트 payload): preset, size, width, height, fit, format, quality
+// 출력은 S3에 올리지 않으며, 건너뛴 요청(SKIPPED_*, 이미 AVIF 등)에는 원본을 그대로 돌려줍니다.
+// 변환 완료 훅(알림, 기록)은 부르지 않고, 실패는 OnFailure 훅을 거쳐 S3 오류 응답으로 돌려줍니다.
+func (h *Handler) ObjectLambda(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if h.objectLambda == nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: S3 Object Lambda requests require OBJECT_LAMBDA")
+	}
+	g := event.GetObjectContext
+	conv, err := objectLambdaEvent(event)
+	if err != nil {
+		return ConversionResult{}, h.objectLambda.fail(ctx, g, err)
+	}
+	result, err := h.convertEvent(ctx, conv)
+	if err != nil {
+		return result, h.objectLambda.fail(ctx, g, err)
+	}
+
+	req := conv.serve
+	// 다운로드 전에 건너뛴 요청(SKIP_RULES 등)은 원본을 아직 받지 않았습니다.
+	if req.served == nil && req.source == nil {
+		if _, err := h.objectLambda.fetch(ctx, req); err != nil {
+			return result, h.objectLambda.fail(ctx, g, err)
+		}
+	}
+	body, contentType := req.source, req.contentType
+	if req.served != nil {
+		body, contentType = req.served.Body, h.encoders.ContentType(req.served.Format)
+	}
+	if _, err := h.objectLambda.api.WriteGetObjectResponse(ctx, &s3.WriteGetObjectResponseInput{
+		RequestRoute:  aws.String(g.OutputRoute),
+		RequestToken:  aws.String(g.OutputToken),
+		StatusCode:    aws.Int32(http.StatusOK),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		ContentType:   aws.String(contentType),
+		CacheControl:  aws.String(h.conf.ObjectLambdaCacheControl),
+	}); err != nil {
+		return result, fmt.Errorf("failed to write Object Lambda response: %w", err)
+	}
+	return result, nil
+}
+
+// objectLambdaEvent는 GetObject 요청을 변환 요청으로 바꿉니다. 원본 키는 요청 URL의 경로이고,
+// 버킷 자리에는 지원 액세스 포인트 ARN을 둡니다. (SKIP_RULES, TENANTS의 버킷 조건과 로그에 씁니다)
+func objectLambdaEvent(event S3Event) (S3Event, error) {
+	g, c := event.GetObjectContext, event.Configuration
+	if g.InputS3URL == "" || g.OutputRoute == "" || g.OutputToken == "" || event.UserRequest == nil || c == nil {
+		return S3Event{}, fmt.Errorf("invalid event: incomplete S3 Object Lambda event")
+	}
+	u, err := url.Parse(event.UserRequest.URL)
+	if err != nil {
+		return S3Event{}, fmt.Errorf("invalid event: invalid userRequest url: %w", err)
+	}
+	params := map[string]string{}
+	if c.Payload != "" {
+		if err := json.Unmarshal([]byte(c.Payload), &params); err != nil {
+			return S3Event{}, fmt.Errorf("invalid event: access point payload must be a JSON object of strings: %w", err)
+		}
+	}
+	query := u.Query()
+	for _, name := range objectLambdaParams {
+		if v := query.Get(name); v != "" {
+			params[name] = v
+		}
+	}
+
+	conv := S3Event{
+		S3Bucket: c.SupportingAccessPointARN,
+		S3Key:    strings.TrimPrefix(u.Path, "/"),
+		Preset:   params["preset"],
+		serve:    &objectLambdaRequest{input: g.InputS3URL, size: params["size"]},
+	}
+	if params["width"] != "" || params["height"] != "" {
+		width, werr := optionalInt(params["width"])
+		height, herr := optionalInt(params["height"])
+		if werr != nil || herr != nil {
+			return S3Event{}, fmt.Errorf("invalid event: width and height must be integers")
+		}
+		step, _ := json.Marshal(map[string]any{"op": "resize", "width": width, "height": height, "fit": params["fit"]})
+		conv.Pipeline = append(conv.Pipeline, pipeline.Step{Op: "resize", Params: step})
+	}
+	if params["format"] != "" || params["quality"] != "" {
+		quality, err := optionalInt(params["quality"])
+		if err != nil {
+			return S3Event{}, fmt.Errorf("invalid event: quality must be an integer")
+		}
+		format := params["format"]
+		if format == "" {
+			format = "avif"
+		}
+		step, _ := json.Marshal(map[string]any{"op": "format", "format": format, "quality": quality})
+		conv.Pipeline = append(conv.Pipeline, pipeline.Step{Op: "format", Params: step})
+	}
+	return conv, nil
+}
+
+func optionalInt(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// fetch는 inputS3Url(미리 서명된 GetObject URL)에서 원본을 받습니다.
+func (o *objectLambda) fetch(ctx context.Context, req *objectLambdaRequest) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.input, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build source request: %w", err)
+	}
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from S3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &objectLambdaSourceError{StatusCode: resp.StatusCode}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object data: %w", err)
+	}
+	req.source, req.contentType = data, resp.Header.Get("Content-Type")
+	return data, nil
+}
+
+// objectLambdaSourceError는 inputS3Url이 200이 아닌 응답을 돌려준 경우입니다. 같은 상태 코드로 응답합니다.
+type objectLambdaSourceError struct {
+	StatusCode int
+}
+
+func (e *objectLambdaSourceError) Error() string {
+	return fmt.Sprintf("failed to get object from S3: status %d", e.StatusCode)
+}
+
+// serve는 convert에서 다운로드 대신 호출되는 Object Lambda 경로입니다. 복제 확인, 중복 이벤트 확인,
+// PreDecode 훅(원본 태그·보관)은 원본 객체를 바꾸거나 S3 알림을 전제로 하므로 거치지 않습니다.
+func (h *Handler) serve(ctx context.Context, job *Job) (ConversionResult, error) {
+	if job.Preset != nil {
+		if err := job.Serve.selectSize(job.Preset); err != nil {
+			return ConversionResult{}, err
+		}
+	}
+	downloadCtx, endDownload := startPhase(ctx, "download")
+	source, err := h.objectLambda.fetch(downloadCtx, job.Serve)
+	endDownload(err, attribute.Int("thumbnail.source.bytes", len(source)))
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	job.Source = source
+	recordObjectSize(ctx, "source", keyExtension(job.SrcKey), len(source))
+	if err := h.emptySource(job, int64(len(source))); err != nil {
+		return ConversionResult{}, err
+	}
+	if archiveFormat(source) != "" {
+		return ConversionResult{}, skip(StatusSkippedNotImage, "Archives are not converted through S3 Object Lambda. Returning the source.")
+	}
+	return h.process(ctx, job)
+}
+
+// selectSize는 프리셋에서 size 인자에 맞는 크기 하나만 남깁니다. 응답에는 출력 하나만 담을 수 있습니다.
+func (r *objectLambdaRequest) selectSize(p *Preset) error {
+	if len(p.Sizes) == 0 {
+		return nil
+	}
+	if r.size == "" {
+		p.Sizes = p.Sizes[:1]
+		return nil
+	}
+	for _, s := range p.Sizes {
+		if s.Suffix() == "_"+r.size {
+			p.Sizes = []PresetSize{s}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid event: preset has no size %q", r.size)
+}
+
+// fail은 오류를 S3 오류 응답으로 돌려줍니다. 응답을 쓰지 못하면 그 오류를, 쓰면 원래 오류를 돌려줍니다.
+func (o *objectLambda) fail(ctx context.Context, g *GetObjectContext, cause error) error {
+	status, code := http.StatusInternalServerError, "InternalError"
+	var source *objectLambdaSourceError
+	switch {
+	case errors.As(cause, &source):
+		status = source.StatusCode
+		switch status {
+		case http.StatusNotFound:
+			code = "NoSuchKey"
+		case http.StatusForbidden:
+			code = "AccessDenied"
+		}
+	default:
+		switch errorCode(cause) {
+		case ErrorInvalidEvent:
+			status, code = http.StatusBadRequest, "InvalidRequest"
+		case ErrorRateLimited, ErrorEncoderCircuitOpen:
+			status, code = http.StatusServiceUnavailable, "SlowDown"
+		}
+	}
+	log.Printf("Object Lambda request failed with %d %s: %v", status, code, cause)
+	if _, err := o.api.WriteGetObjectResponse(ctx, &s3.WriteGetObjectResponseInput{
+		RequestRoute: aws.String(g.OutputRoute),
+		RequestToken: aws.String(g.OutputToken),
+		StatusCode:   aws.Int32(int32(status)),
+		ErrorCode:    aws.String(code),
+		ErrorMessage: aws.String(cause.Error()),
+	}); err != nil {
+		return fmt.Errorf("failed to write Object Lambda error response: %w", err)
+	}
+	return cause
+}
@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxCopySize는 CopyObject 한 번으로 복사할 수 있는 최대 크기(5GiB)입니다.
+const maxCopySize = 5 << 30
+
+// OriginalArchive는 변환 뒤 원본에 적용한 저장 클래스 처리입니다.
+type OriginalArchive struct {
+	// Action은 "rewritten"(원본을 같은 키에 다시 씀) | "copied"(다른 위치에 사본) | "unchanged"(이미 해당 클래스)입니다.
+	Action       string `json:"action"`
+	StorageClass string `json:"storageClass"`
+	Location     string `json:"location"`
+}
+
+// UseOriginalArchive는 변환에 성공한 원본을 더 차가운 저장 클래스로 옮기는 미들웨어를 등록합니다.
+// 원본을 삭제하지 않습니다. 실행 역할에 s3:GetObject / s3:PutObject(복사 대상) 권한이 필요합니다.
+func (h *Handler) UseOriginalArchive() {
+	h.hooks.Use(&originalArchiver{
+		h:            h,
+		storageClass: types.StorageClass(h.conf.OriginalsStorageClass),
+		action:       h.conf.OriginalsAction,
+		bucket:       h.conf.OriginalsCopyBucket,
+		prefix:       h.conf.OriginalsCopyPrefix,
+	})
+}
+
+// originalArchiver는 PostConvert에서 원본을 storageClass로 다시 쓰거나(rewrite) 사본을 만듭니다(copy).
+// 실패하면 보존 정책을 지키지 못한 것이므로 오류를 돌려 재시도하게 합니다. 출력 키는 같으므로 다시 변환해도 안전합니다.
+type originalArchiver struct {
+	h            *Handler
+	storageClass types.StorageClass
+	action       string
+	// bucket과 prefix는 copy의 대상입니다. bucket이 비어 있으면 원본 버킷입니다.
+	bucket string
+	prefix string
+}
+
+// PreDecode는 원본 버킷에 만든 사본이 다시 이벤트로 들어오면 변환하지 않습니다.
+func (a *originalArchiver) PreDecode(ctx context.Context, job *Job) error {
+	if a.action == "copy" && a.destBucket(job) == job.Bucket && strings.HasPrefix(job.SrcKey, a.prefix) {
+		return skip(StatusSkippedArchivedOriginal, "Object is an archived copy of an original. Skipping conversion.")
+	}
+	return nil
+}
+
+func (a *originalArchiver) PostConvert(ctx context.Context, job *Job) error {
+	if job.Archive != "" {
+		return nil
+	}
+	bucket, key := job.Bucket, job.SrcKey
+	if a.action == "copy" {
+		bucket, key = a.destBucket(job), a.prefix+job.SrcKey
+	}
+	result := &OriginalArchive{Action: "rewritten", StorageClass: string(a.storageClass), Location: "s3://" + bucket + "/" + key}
+	if a.action == "copy" {
+		result.Action = "copied"
+	} else {
+		// 다시 쓴 원본은 ObjectCreated:Copy 알림으로 한 번 더 들어올 수 있으므로, 이미 옮긴 원본은 그대로 둡니다.
+		head, err := a.h.s3.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return fmt.Errorf("failed to check storage class of %s: %w", key, err)
+		}
+		if head.StorageClass == a.storageClass {
+			result.Action = "unchanged"
+			job.Result.Original = result
+			return nil
+		}
+	}
+	if int64(len(job.Source)) > maxCopySize {
+		return fmt.Errorf("failed to archive original %s: %d bytes exceeds the single copy limit", job.SrcKey, len(job.Source))
+	}
+	_, err := a.h.s3.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(url.PathEscape(job.Bucket + "/" + job.SrcKey)),
+		StorageClass:      a.storageClass,
+		MetadataDirective: types.MetadataDirectiveCopy,
+		TaggingDirective:  types.TaggingDirectiveCopy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive original %s to %s: %w", job.SrcKey, a.storageClass, err)
+	}
+	log.Printf("Original %s %s with storage class %s", result.Action, result.Location, a.storageClass)
+	job.Result.Original = result
+	job.SourceChanges = append(job.SourceChanges, fmt.Sprintf("%s to %s as %s", result.Action, result.Location, a.storageClass))
+	return nil
+}
+
+func (a *originalArchiver) destBucket(job *Job) string {
+	if a.bucket != "" {
+		return a.bucket
+	}
+	return job.Bucket
+}
+
+// validStorageClass는 원본 보관에 쓸 수 있는 S3 저장 클래스인지 확인합니다.
+func validStorageClass(class string) bool {
+	return class != string(types.StorageClassStandard) && knownStorageClass(class)
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// Preset은 이벤트에서 이름으로 참조하는 변환 프리셋입니다.
+// 크기마다 출력 하나(와 JXL/JPEG 대체 출력)를 만듭니다.
+type Preset struct {
+	Sizes   []PresetSize `json:"sizes"`
+	Fit     string       `json:"fit,omitempty"`     // inside(기본) | cover | fill | pad
+	Format  string       `json:"format,omitempty"`  // 비어 있으면 기본 출력 포맷
+	Quality int          `json:"quality,omitempty"` // 0이면 포맷별 기본 품질
+	// Background는 fit이 pad일 때 여백입니다. "#RRGGBB" 또는 "blur"(자기 이미지를 흐리게 늘린 배경), 기본 흰색
+	Background string `json:"background,omitempty"`
+}
+
+// PresetSize는 프리셋의 출력 크기 하나입니다. 한쪽을 0으로 두면 비율을 유지합니다.
+// Sharpen이 있으면 크기를 줄인 뒤 언샤프 마스크를 적용합니다. 예: "sharpen": {"sigma": 0.6}
+type PresetSize struct {
+	Width   int                  `json:"width,omitempty"`
+	Height  int                  `json:"height,omitempty"`
+	Sharpen *pipeline.Sharpening `json:"sharpen,omitempty"`
+}
+
+// Suffix는 출력 키에 붙는 크기 접미사입니다. 예: _w512, _h300, _1200x630
+func (s PresetSize) Suffix() string {
+	switch {
+	case s.Height == 0:
+		return fmt.Sprintf("_w%d", s.Width)
+	case s.Width == 0:
+		return fmt.Sprintf("_h%d", s.Height)
+	}
+	return fmt.Sprintf("_%dx%d", s.Width, s.Height)
+}
+
+// defaultPresets는 설정이 없어도 쓸 수 있는 기본 프리셋입니다. 같은 이름을 설정하면 덮어씁니다.
+var defaultPresets = map[string]Preset{
+	"avatar": {
+		Sizes: []PresetSize{{Width: 64, Height: 64}, {Width: 128, Height: 128}, {Width: 256, Height: 256}},
+		Fit:   "cover",
+	},
+	"hero": {
+		Sizes: []PresetSize{{Width: 1200}, {Width: 2400}},
+		Fit:   "inside",
+	},
+	// 소셜 미리보기 크롤러는 AVIF를 읽지 못하므로 JPEG으로 만듭니다.
+	"og-image": {
+		Sizes:   []PresetSize{{Width: 1200, Height: 630}},
+		Fit:     "cover",
+		Format:  "jpeg",
+		Quality: 85,
+	},
+}
+
+// parsePresets는 {"이름": Preset} 형식의 JSON을 읽어 검증합니다.
+func parsePresets(data []byte) (map[string]Preset, error) {
+	var presets map[string]Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, err
+	}
+	for name, p := range presets {
+		if err := p.normalize(); err != nil {
+			return nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		presets[name] = p
+	}
+	return presets, nil
+}
+
+func (p *Preset) normalize() error {
+	if len(p.Sizes) == 0 {
+		return fmt.Errorf("at least one size is required")
+	}
+	if p.Fit == "" {
+		p.Fit = "inside"
+	}
+	if err := pipeline.ValidateBackground(p.Background); err != nil {
+		return err
+	}
+	for _, size := range p.Sizes {
+		if err := pipeline.ValidateSize(size.Width, size.Height, p.Fit); err != nil {
+			return fmt.Errorf("size %s: %w", size.Suffix(), err)
+		}
+		if size.Sharpen != nil {
+			if err := size.Sharpen.Validate(); err != nil {
+				return fmt.Errorf("size %s: %w", size.Suffix(), err)
+			}
+		}
+	}
+	if p.Format == "jpg" {
+		p.Format = "jpeg"
+	}
+	if p.Format != "" || p.Quality != 0 {
+		format := p.Format
+		if format == "" {
+			format = "avif"
+		}
+		if err := pipeline.ValidateFormat(format, p.Quality); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergePresets는 기본 프리셋 위에 설정된 프리셋을 덮어씁니다.
+func mergePresets(base map[string]Preset, overrides map[string]Preset) map[string]Preset {
+	merged := make(map[string]Preset, len(base)+len(overrides))
+	for name, p := range base {
+		merged[name] = p
+	}
+	for name, p := range overrides {
+		merged[name] = p
+	}
+	return merged
+}
+
+// loadPresetObject는 PRESETS_OBJECT(s3://bucket/key)에 저장된 프리셋 JSON을 읽습니다.
+func (h *Handler) loadPresetObject(ctx context.Context, uri string) (map[string]Preset, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid PRESETS_OBJECT %q: expected s3://bucket/key", uri)
+	}
+	data, err := h.downloadObject(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PRESETS_OBJECT: %w", err)
+	}
+	presets, err := parsePresets(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PRESETS_OBJECT: %w", err)
+	}
+	return presets, nil
+}
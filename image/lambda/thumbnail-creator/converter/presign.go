@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PresignAPI는 출력 URL 서명에 쓰는 메서드입니다. *s3.PresignClient가 구현합니다.
+type PresignAPI interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// UsePresignedURLs는 변환이 끝나면 출력마다 PRESIGN_EXPIRY_SECONDS 동안 유효한 GET URL을 결과에 넣습니다.
+// 변환 완료 알림보다 먼저 실행되도록 알림 미들웨어보다 앞에 등록해야 웹훅 페이로드에도 URL이 들어갑니다.
+// destination은 DESTINATION_ROLE_ARN 대상 버킷의 출력에 쓰며, 없으면 signer를 씁니다.
+func (h *Handler) UsePresignedURLs(signer, destination PresignAPI) {
+	h.hooks.Use(&outputSigner{
+		signer:      signer,
+		destination: destination,
+		buckets:     h.destinationBuckets,
+		expiry:      h.conf.PresignExpiry,
+		clock:       h.clock,
+	})
+}
+
+// outputSigner는 결과의 출력마다 서명된 URL을 채우는 미들웨어입니다.
+// 서명은 자격 증명으로 로컬에서 계산하므로 S3 요청이 없고, URL로 읽을 수 있는 권한은 실행 역할의 s3:GetObject를 따릅니다.
+// 임시 자격 증명(Lambda 실행 역할)으로 서명한 URL은 만료 시각 전이라도 세션이 끝나면 더는 쓸 수 없습니다.
+type outputSigner struct {
+	signer      PresignAPI
+	destination PresignAPI
+	buckets     []string
+	expiry      time.Duration
+	clock       Clock
+}
+
+// PostConvert는 출력마다 URL을 서명합니다. 서명 실패는 변환을 실패시킵니다. 호출자가 URL을 기대하기 때문입니다.
+func (s *outputSigner) PostConvert(ctx context.Context, job *Job) error {
+	signer := s.signer
+	if s.destination != nil && slices.Contains(s.buckets, job.OutputBucket) {
+		signer = s.destination
+	}
+	expires := s.clock.Now().Add(s.expiry).UTC().Format(time.RFC3339)
+	for i := range job.Result.Outputs {
+		o := &job.Result.Outputs[i]
+		req, err := signer.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(job.OutputBucket),
+			Key:    aws.String(o.Key),
+		}, s3.WithPresignExpires(s.expiry))
+		if err != nil {
+			return fmt.Errorf("failed to presign output %s: %w", o.Key, err)
+		}
+		o.URL, o.URLExpires = req.URL, expires
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// attemptsTag는 원본 객체에 시도 횟수를 기록하는 태그 키입니다.
+const attemptsTag = "thumbnail-creator-attempts"
+
+// UseQuarantine은 계속 실패하는 원본을 격리하는 미들웨어를 등록합니다.
+// 실행 역할에 원본 객체의 s3:GetObjectTagging / s3:PutObjectTagging 권한이 필요합니다.
+func (h *Handler) UseQuarantine() {
+	h.quarantine = &quarantine{h: h, after: h.conf.QuarantineAfter, prefix: h.conf.QuarantinePrefix}
+	h.hooks.Use(h.quarantine)
+}
+
+// quarantineReport는 격리한 원본 옆에 올리는 실패 보고서입니다.
+type quarantineReport struct {
+	Bucket        string    `json:"bucket"`
+	Key           string    `json:"key"`
+	Attempts      int       `json:"attempts"`
+	Error         string    `json:"error,omitempty"`
+	ErrorType     string    `json:"errorType,omitempty"`
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// quarantine은 원본의 시도 횟수를 객체 태그로 셉니다. 디코딩 전에 횟수를 올려 두므로
+// 디코딩 중 실행 환경이 죽어도(OOM 등) 한 번의 시도로 남습니다.
+// 횟수가 after에 이르면 원본을 prefix 아래로 복사하고 보고서를 남긴 뒤 QUARANTINED로 성공 처리해
+// 재시도와 DLQ를 더 돌지 않게 합니다. 변환이 성공하면 태그를 지웁니다.
+type quarantine struct {
+	h      *Handler
+	after  int
+	prefix string
+}
+
+// PreDecode는 시도 횟수를 올립니다. 이전 시도가 모두 기록 없이 끝났다면(실행 환경 종료)
+// 디코딩하지 않고 바로 격리합니다.
+func (q *quarantine) PreDecode(ctx context.Context, job *Job) error {
+	if job.Archive != "" {
+		return nil
+	}
+	// 격리 사본이 같은 버킷에 올라가 다시 이벤트가 오더라도 변환하지 않습니다.
+	if strings.HasPrefix(job.SrcKey, q.prefix) {
+		return skip(StatusSkippedQuarantined, "Object is under the quarantine prefix. Skipping conversion.")
+	}
+	tags, attempts, err := q.attempts(ctx, job)
+	if err != nil {
+		log.Printf("Warning: failed to read attempt count for %s: %v", job.SrcKey, err)
+		return nil
+	}
+	if attempts >= q.after {
+		if err := q.isolate(ctx, job, attempts, nil); err != nil {
+			return err
+		}
+		return skip(StatusQuarantined, fmt.Sprintf("Source failed %d times and was quarantined to %s.", attempts, q.prefix+job.SrcKey))
+	}
+	if err := q.setAttempts(ctx, job, tags, attempts+1); err != nil {
+		log.Printf("Warning: failed to record attempt for %s: %v", job.SrcKey, err)
+	}
+	return nil
+}
+
+// PostConvert는 성공한 원본의 시도 횟수 태그를 지웁니다.
+func (q *quarantine) PostConvert(ctx context.Context, job *Job) error {
+	if job.Archive != "" {
+		return nil
+	}
+	tags, attempts, err := q.attempts(ctx, job)
+	if err == nil && attempts > 0 {
+		err = q.setAttempts(ctx, job, tags, 0)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to clear attempt count for %s: %v", job.SrcKey, err)
+	}
+	return nil
+}
+
+// handle은 실패한 변환을 처리합니다. 디코딩·파이프라인·인코딩 오류가 아닌 실패(sourceFailure 참고)는
+// 올려 둔 횟수를 되돌리고, 원본 때문에 실패한 횟수가 after에 이르면 격리한 결과를 돌려줍니다.
+func (q *quarantine) handle(ctx context.Context, job *Job, cause error) (ConversionResult, bool) {
+	if q == nil || job.Source == nil || job.Archive != "" || job.Serve != nil {
+		return ConversionResult{}, false
+	}
+	tags, attempts, err := q.attempts(ctx, job)
+	if err != nil {
+		log.Printf("Warning: failed to read attempt count for %s: %v", job.SrcKey, err)
+		return ConversionResult{}, false
+	}
+	if !sourceFailure(job, cause) {
+		if err := q.setAttempts(ctx, job, tags, max(attempts-1, 0)); err != nil {
+			log.Printf("Warning: failed to restore attempt count for %s: %v", job.SrcKey, err)
+		}
+		return ConversionResult{}, false
+	}
+	if attempts < q.after {
+		log.Printf("Conversion of %s failed (attempt %d of %d before quarantine)", job.SrcKey, attempts, q.after)
+		return ConversionResult{}, false
+	}
+	if err := q.isolate(ctx, job, attempts, cause); err != nil {
+		log.Printf("Error: failed to quarantine %s: %v", job.SrcKey, err)
+		return ConversionResult{}, false
+	}
+	return ConversionResult{
+		Status:      StatusQuarantined,
+		Tenant:      job.Result.Tenant,
+		OriginalKey: job.SrcKey,
+		Message:     fmt.Sprintf("Source failed %d times and was quarantined to %s: %v", attempts, q.prefix+job.SrcKey, cause),
+		Errors:      []ResultError{resultError(cause, job.SrcKey)},
+	}, true
+}
+
+// sourceFailure는 원본 때문에 실패했을 수 있는지 확인합니다. 업로드를 시작한 뒤의 실패와
+// S3·EventBridge 등 AWS API 오류(AccessDenied, 5xx, SlowDown), 다시 시도하면 성공할 수 있는 오류는 원본 탓이 아닙니다.
+func sourceFailure(job *Job, err error) bool {
+	var op *smithy.OperationError
+	return !job.Uploading && !errors.As(err, &op) && !transientFailure(err)
+}
+
+// transientFailure는 다시 시도하면 성공할 수 있는, 원본과 관계없는 오류인지 확인합니다.
+func transientFailure(err error) bool {
+	var budget *TimeoutBudgetExceeded
+	var limited *RateLimited
+	var circuit *EncoderCircuitOpen
+	return errors.As(err, &budget) || errors.As(err, &limited) || errors.As(err, &circuit) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// isolate는 원본을 격리 접두사 아래로 복사하고 <키>.failure.json 보고서를 올립니다.
+func (q *quarantine) isolate(ctx context.Context, job *Job, attempts int, cause error) error {
+	dest := q.prefix + job.SrcKey
+	_, err := q.h.s3.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(job.Bucket),
+		Key:        aws.String(dest),
+		CopySource: aws.String(url.PathEscape(job.Bucket + "/" + job.SrcKey)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to quarantine: %w", job.SrcKey, err)
+	}
+	job.SourceChanges = append(job.SourceChanges, "copied to s3://"+job.Bucket+"/"+dest)
+
+	report := quarantineReport{Bucket: job.Bucket, Key: job.SrcKey, Attempts: attempts, QuarantinedAt: q.h.clock.Now().UTC()}
+	if cause != nil {
+		report.Error = cause.Error()
+		report.ErrorType = strings.TrimPrefix(fmt.Sprintf("%T", cause), "*")
+	} else {
+		report.Error = "previous attempts ended without a result (crash or timeout)"
+	}
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := q.h.putObject(ctx, job.Bucket, dest+".failure.json", "application/json", body); err != nil {
+		return fmt.Errorf("failed to upload quarantine report: %w", err)
+	}
+	log.Printf("Quarantined %s after %d attempts: s3://%s/%s", job.SrcKey, attempts, job.Bucket, dest)
+	emitMetrics(q.h.conf.MetricsNamespace, "Count", map[string]float64{"Quarantined": 1})
+	return nil
+}
+
+// attempts는 원본의 태그와 기록된 시도 횟수를 읽습니다.
+func (q *quarantine) attempts(ctx context.Context, job *Job) ([]types.Tag, int, error) {
+	out, err := q.h.s3.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: &job.Bucket, Key: &job.SrcKey})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, t := range out.TagSet {
+		if aws.ToString(t.Key) == attemptsTag {
+			n, _ := strconv.Atoi(aws.ToString(t.Value))
+			return out.TagSet, n, nil
+		}
+	}
+	return out.TagSet, 0, nil
+}
+
+// setAttempts는 다른 태그를 유지한 채 시도 횟수 태그를 바꿉니다. 0이면 태그를 지웁니다.
+func (q *quarantine) setAttempts(ctx context.Context, job *Job, tags []types.Tag, attempts int) error {
+	tags = slices.DeleteFunc(slices.Clone(tags), func(t types.Tag) bool { return aws.ToString(t.Key) == attemptsTag })
+	if attempts > 0 {
+		tags = append(tags, types.Tag{Key: aws.String(attemptsTag), Value: aws.String(strconv.Itoa(attempts))})
+	}
+	_, err := q.h.s3.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  &job.Bucket,
+		Key:     &job.SrcKey,
+		Tagging: &types.Tagging{TagSet: tags},
+	})
+	if err == nil {
+		job.SourceChanges = append(job.SourceChanges, fmt.Sprintf("tag %s=%d", attemptsTag, attempts))
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func TestSourceFailure(t *testing.T) {
+	s3Error := func(code string) error {
+		return fmt.Errorf("failed to upload AVIF image to S3: %w", &smithy.OperationError{
+			ServiceID:     "S3",
+			OperationName: "PutObject",
+			Err:           &smithy.GenericAPIError{Code: code},
+		})
+	}
+	tests := []struct {
+		name      string
+		err       error
+		uploading bool
+		want      bool
+	}{
+		{name: "decode", err: errors.New("failed to process image with vips from buffer: VipsJpeg: premature end"), want: true},
+		{name: "pipeline", err: errors.New("pipeline failed: crop outside image"), want: true},
+		{name: "access denied", err: s3Error("AccessDenied"), uploading: true},
+		{name: "slow down", err: s3Error("SlowDown"), uploading: true},
+		{name: "api error before upload", err: s3Error("InternalError")},
+		{name: "after upload", err: errors.New("failed to publish image.converted event"), uploading: true},
+		{name: "deadline", err: fmt.Errorf("encode: %w", context.DeadlineExceeded)},
+		{name: "rate limited", err: &RateLimited{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceFailure(&Job{Uploading: tt.uploading}, tt.err); got != tt.want {
+				t.Errorf("sourceFailure(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RateLimited는 대상별 요청 한도를 넘어 변환을 미뤘음을 나타냅니다.
+// 출력 키가 같으므로 같은 이벤트로 다시 시도해도 안전하며, errorType으로 재시도 정책에서 구분할 수 있습니다.
+type RateLimited struct {
+	Target     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimited) Error() string {
+	return fmt.Sprintf("RATE_LIMITED: %s is over its rate limit, retry after %s", e.Target, e.RetryAfter.Round(time.Millisecond))
+}
+
+// rateLimiter는 대상(출력 버킷, 이벤트 버스)별 토큰 버킷입니다.
+// 실행 환경마다 따로 세므로 전체 한도는 대략 동시 실행 수 × rate입니다.
+type rateLimiter struct {
+	clock   Clock
+	rate    float64 // 초당 토큰
+	burst   float64
+	maxWait time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter는 rate가 0 이하이면 nil을 돌려줍니다. nil 리미터는 항상 통과시킵니다.
+func newRateLimiter(clock Clock, rate float64, burst int, maxWait time.Duration) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{clock: clock, rate: rate, burst: float64(burst), maxWait: maxWait, buckets: map[string]*tokenBucket{}}
+}
+
+// reserve는 target의 토큰 하나를 예약하고 기다려야 할 시간을 돌려줍니다.
+// maxWait보다 오래 기다려야 하면 예약하지 않고 false를 돌려줍니다.
+func (l *rateLimiter) reserve(target string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	b, ok := l.buckets[target]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[target] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	if wait > l.maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return max(wait, 0), true
+}
+
+// cancel은 reserve로 예약했지만 쓰지 않은 토큰을 돌려줍니다. 기다리는 동안 요청이 취소되면 다음 요청이 그만큼 덜 기다립니다.
+func (l *rateLimiter) cancel(target string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[target]; ok {
+		b.tokens = min(l.burst, b.tokens+1)
+	}
+}
+
+// Wait는 target에 요청을 보내도 될 때까지 기다립니다. RATE_LIMIT_MAX_WAIT_MS보다 오래 걸리면
+// 기다리지 않고 RateLimited를 돌려 작업을 뒤로 미룹니다.
+func (l *rateLimiter) Wait(ctx context.Context, target string, namespace string) error {
+	if l == nil {
+		return nil
+	}
+	wait, ok := l.reserve(target)
+	if !ok {
+		log.Printf("Rate limit reached for %s, deferring (retry after %s)", target, wait.Round(time.Millisecond))
+		emitMetrics(namespace, "Count", map[string]float64{"RateLimited": 1})
+		return &RateLimited{Target: target, RetryAfter: wait}
+	}
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel(target)
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+	clock := newManualClock()
+	l := newRateLimiter(clock, 2, 3, time.Second)
+
+	// 처음에는 burst만큼 기다리지 않고 통과합니다.
+	for i := range 3 {
+		if wait, ok := l.reserve("s3://uploads"); !ok || wait != 0 {
+			t.Fatalf("reserve %d = %s, %t, want no wait within burst", i, wait, ok)
+		}
+	}
+	// burst를 다 쓰면 초당 2개이므로 다음 토큰까지 0.5초를 기다립니다.
+	if wait, ok := l.reserve("s3://uploads"); !ok || wait != 500*time.Millisecond {
+		t.Fatalf("reserve after burst = %s, %t, want 500ms", wait, ok)
+	}
+	// 대상마다 따로 셉니다.
+	if wait, ok := l.reserve("events://bus"); !ok || wait != 0 {
+		t.Errorf("reserve for another target = %s, %t, want no wait", wait, ok)
+	}
+
+	// 1.5초가 지나면 빚진 토큰 하나를 갚고 두 개가 찹니다.
+	clock.Advance(1500 * time.Millisecond)
+	for i := range 2 {
+		if wait, ok := l.reserve("s3://uploads"); !ok || wait != 0 {
+			t.Fatalf("reserve %d after refill = %s, %t, want no wait", i, wait, ok)
+		}
+	}
+	// 오래 쉬어도 burst보다 많이 쌓이지 않습니다.
+	clock.Advance(time.Hour)
+	for i := range 3 {
+		if wait, ok := l.reserve("s3://uploads"); !ok || wait != 0 {
+			t.Fatalf("reserve %d after idle = %s, %t, want no wait", i, wait, ok)
+		}
+	}
+	if wait, _ := l.reserve("s3://uploads"); wait == 0 {
+		t.Errorf("reserve beyond burst after idle = 0, want a wait")
+	}
+}
+
+func TestRateLimiterMaxWait(t *testing.T) {
+	clock := newManualClock()
+	l := newRateLimiter(clock, 1, 1, 1500*time.Millisecond)
+	ctx := context.Background()
+
+	if err := l.Wait(ctx, "s3://uploads", "test"); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	// 토큰 하나를 빚으면 다음 요청은 1초를 기다려야 하고, 그 다음은 2초라 maxWait를 넘습니다.
+	if wait, ok := l.reserve("s3://uploads"); !ok || wait != time.Second {
+		t.Fatalf("reserve = %s, %t, want 1s", wait, ok)
+	}
+	err := l.Wait(ctx, "s3://uploads", "test")
+	var limited *RateLimited
+	if !errors.As(err, &limited) {
+		t.Fatalf("Wait = %v, want RateLimited", err)
+	}
+	if limited.Target != "s3://uploads" || limited.RetryAfter != 2*time.Second {
+		t.Errorf("RateLimited = %+v, want s3://uploads after 2s", limited)
+	}
+	// 미룬 요청은 토큰을 쓰지 않으므로 시간이 지나면 다시 통과합니다.
+	clock.Advance(2 * time.Second)
+	if wait, ok := l.reserve("s3://uploads"); !ok || wait != 0 {
+		t.Errorf("reserve after deferral = %s, %t, want no wait", wait, ok)
+	}
+}
+
+func TestRateLimiterReturnsTokenOnCancel(t *testing.T) {
+	clock := newManualClock()
+	l := newRateLimiter(clock, 1, 1, time.Minute)
+	if wait, ok := l.reserve("s3://uploads"); !ok || wait != 0 {
+		t.Fatalf("reserve = %s, %t, want no wait", wait, ok)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, "s3://uploads", "test"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait with canceled context = %v, want context.Canceled", err)
+	}
+	// 취소된 요청의 토큰을 돌려받았으므로 다음 요청은 한 토큰만 기다립니다.
+	if wait, ok := l.reserve("s3://uploads"); !ok || wait != time.Second {
+		t.Errorf("reserve after canceled Wait = %s, %t, want 1s", wait, ok)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := newRateLimiter(newManualClock(), 0, 1, 0)
+	for range 10 {
+		if err := l.Wait(context.Background(), "s3://uploads", "test"); err != nil {
+			t.Fatalf("nil limiter Wait = %v, want nil", err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// recordRetention은 변환 기록의 보관 기간입니다. 테이블의 TTL 속성(ExpiresAt)으로 지워집니다.
+const recordRetention = 400 * 24 * time.Hour
+
+// DynamoAPI는 변환 기록에 사용하는 DynamoDB 클라이언트 메서드입니다. *dynamodb.Client가 구현합니다.
+type DynamoAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// conversionRecord는 변환 한 건의 기록입니다. 절감량 보고서의 입력이 됩니다.
+// 테이블 키: Day(파티션, UTC YYYY-MM-DD) + Id(정렬, 원본 키#시각)
+type conversionRecord struct {
+	Day          string
+	ID           string
+	Bucket       string
+	Tenant       string // 테넌트가 없으면 비어 있습니다.
+	SourceKey    string
+	Format       string
+	SourceBytes  int64
+	PrimaryBytes int64 // 첫 번째 주 출력(NewKey)의 크기
+	OutputBytes  int64 // 대체 출력을 포함한 전체 출력 크기
+}
+
+// conversionRecords는 변환이 끝날 때마다 DynamoDB에 기록을 남기는 미들웨어입니다. (RECORDS_TABLE)
+type conversionRecords struct {
+	db    DynamoAPI
+	table string
+	clock Clock
+}
+
+// PostConvert는 변환 기록을 저장합니다. 기록 실패는 변환 결과를 바꾸지 않도록 경고만 남깁니다.
+func (r *conversionRecords) PostConvert(ctx context.Context, job *Job) error {
+	now := r.clock.Now().UTC()
+	rec := conversionRecord{
+		Day:         now.Format(time.DateOnly),
+		ID:          job.SrcKey + "#" + now.Format(time.RFC3339Nano),
+		Bucket:      job.Bucket,
+		Tenant:      job.Result.Tenant,
+		SourceKey:   job.SrcKey,
+		Format:      job.Result.Format,
+		SourceBytes: int64(len(job.Source)),
+	}
+	for _, o := range job.Result.Outputs {
+		if o.Key == job.Result.NewKey {
+			rec.PrimaryBytes = o.Size
+		}
+		rec.OutputBytes += o.Size
+	}
+	_, err := r.db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.table),
+		Item: map[string]types.AttributeValue{
+			"Day":          &types.AttributeValueMemberS{Value: rec.Day},
+			"Id":           &types.AttributeValueMemberS{Value: rec.ID},
+			"Bucket":       &types.AttributeValueMemberS{Value: rec.Bucket},
+			"Tenant":       &types.AttributeValueMemberS{Value: rec.Tenant},
+			"SourceKey":    &types.AttributeValueMemberS{Value: rec.SourceKey},
+			"Format":       &types.AttributeValueMemberS{Value: rec.Format},
+			"SourceBytes":  numberAttr(rec.SourceBytes),
+			"PrimaryBytes": numberAttr(rec.PrimaryBytes),
+			"OutputBytes":  numberAttr(rec.OutputBytes),
+			"ExpiresAt":    numberAttr(now.Add(recordRetention).Unix()),
+		},
+	})
+	if err != nil {
+		log.Printf("Warning: failed to write conversion record to %s: %v", r.table, err)
+	}
+	return nil
+}
+
+// ForDay는 하루치 변환 기록을 모두 읽어 옵니다.
+func (r *conversionRecords) ForDay(ctx context.Context, day string) ([]conversionRecord, error) {
+	var records []conversionRecord
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.table),
+		KeyConditionExpression: aws.String("#day = :day"),
+		ExpressionAttributeNames: map[string]string{
+			"#day": "Day",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":day": &types.AttributeValueMemberS{Value: day},
+		},
+	}
+	for {
+		out, err := r.db.Query(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query conversion records for %s: %w", day, err)
+		}
+		for _, item := range out.Items {
+			records = append(records, conversionRecord{
+				Day:          stringAttr(item["Day"]),
+				ID:           stringAttr(item["Id"]),
+				Bucket:       stringAttr(item["Bucket"]),
+				Tenant:       stringAttr(item["Tenant"]),
+				SourceKey:    stringAttr(item["SourceKey"]),
+				Format:       stringAttr(item["Format"]),
+				SourceBytes:  int64Attr(item["SourceBytes"]),
+				PrimaryBytes: int64Attr(item["PrimaryBytes"]),
+				OutputBytes:  int64Attr(item["OutputBytes"]),
+			})
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return records, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func numberAttr(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+func stringAttr(v types.AttributeValue) string {
+	if s, ok := v.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+func int64Attr(v types.AttributeValue) int64 {
+	if n, ok := v.(*types.AttributeValueMemberN); ok {
+		i, _ := strconv.ParseInt(n.Value, 10, 64)
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,283 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// RegressionRequest는 "mode": "regression" 요청의 설정입니다. 버킷이 비어 있으면 s3Bucket입니다.
+type RegressionRequest struct {
+	Prefix      string `json:"prefix,omitempty"`      // 코퍼스 접두사, 기본 REGRESSION_PREFIX
+	BaselineKey string `json:"baselineKey,omitempty"` // 기본 <prefix>baseline.json
+	// UpdateBaseline이 true이면 비교하지 않고 현재 결과로 기준값을 새로 씁니다.
+	UpdateBaseline bool `json:"updateBaseline,omitempty"`
+	// MaxSSIMDrop과 MaxSizeGrowthPercent는 기준값보다 SSIM이 떨어지거나 크기가 늘어도 되는 한도입니다.
+	MaxSSIMDrop          *float64 `json:"maxSSIMDrop,omitempty"`          // 기본 0.005
+	MaxSizeGrowthPercent *float64 `json:"maxSizeGrowthPercent,omitempty"` // 기본 5
+}
+
+// regressionBaseline은 코퍼스별 기준값 파일(baseline.json)입니다.
+type regressionBaseline struct {
+	GeneratedAt string                           `json:"generatedAt"`
+	Vips        string                           `json:"vips"`
+	Entries     map[string]regressionMeasurement `json:"entries"`
+}
+
+type regressionMeasurement struct {
+	Format string  `json:"format"`
+	Size   int64   `json:"size"`
+	SSIM   float64 `json:"ssim"`
+}
+
+// RegressionResult는 코퍼스 항목 하나의 결과입니다. Status는 PASS | FAIL | NEW(기준값 없음)입니다.
+type RegressionResult struct {
+	Key      string                 `json:"key"`
+	Status   string                 `json:"status"`
+	Current  regressionMeasurement  `json:"current"`
+	Baseline *regressionMeasurement `json:"baseline,omitempty"`
+	Reason   string                 `json:"reason,omitempty"`
+}
+
+// Regression은 현재 인코더 설정으로 코퍼스를 인코딩해 원본 대비 SSIM과 크기를 기준값과 비교합니다.
+// 한 항목이라도 한도를 넘으면 REGRESSION_FAILED를 돌려줍니다. vipsgen이나 인코더 설정을 바꾸기 전에 실행합니다.
+func (h *Handler) Regression(ctx context.Context, event S3Event) (ConversionResult, error) {
+	req := RegressionRequest{}
+	if event.Regression != nil {
+		req = *event.Regression
+	}
+	bucket := event.S3Bucket
+	if bucket == "" {
+		bucket = h.conf.RegressionBucket
+	}
+	if bucket == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: regression requires s3Bucket or REGRESSION_BUCKET")
+	}
+	if req.Prefix == "" {
+		req.Prefix = h.conf.RegressionPrefix
+	}
+	if req.BaselineKey == "" {
+		req.BaselineKey = req.Prefix + "baseline.json"
+	}
+	maxDrop, maxGrowth := 0.005, 5.0
+	if req.MaxSSIMDrop != nil {
+		maxDrop = *req.MaxSSIMDrop
+	}
+	if req.MaxSizeGrowthPercent != nil {
+		maxGrowth = *req.MaxSizeGrowthPercent
+	}
+
+	keys, err := h.listKeys(ctx, bucket, req.Prefix)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	keys = slices.DeleteFunc(keys, func(k string) bool { return k == req.BaselineKey })
+	if len(keys) == 0 {
+		return ConversionResult{}, fmt.Errorf("regression corpus s3://%s/%s is empty", bucket, req.Prefix)
+	}
+	baseline := regressionBaseline{Entries: map[string]regressionMeasurement{}}
+	if !req.UpdateBaseline {
+		if baseline, err = h.loadBaseline(ctx, bucket, req.BaselineKey); err != nil {
+			return ConversionResult{}, err
+		}
+	}
+
+	current := regressionBaseline{GeneratedAt: h.clock.Now().UTC().Format(time.RFC3339), Vips: vips.Version, Entries: map[string]regressionMeasurement{}}
+	var results []RegressionResult
+	failed := 0
+	for _, key := range keys {
+		if err := h.checkBudget(ctx, "regression "+key); err != nil {
+			return ConversionResult{}, err
+		}
+		m, err := h.measureFixture(ctx, bucket, key)
+		if err != nil {
+			return ConversionResult{}, fmt.Errorf("regression fixture %s: %w", key, err)
+		}
+		current.Entries[key] = m
+		result := RegressionResult{Key: key, Status: "NEW", Current: m}
+		if base, ok := baseline.Entries[key]; ok && !req.UpdateBaseline {
+			result.Baseline = &base
+			result.Status = "PASS"
+			var reasons []string
+			if m.SSIM < base.SSIM-maxDrop {
+				reasons = append(reasons, fmt.Sprintf("SSIM dropped %.4f → %.4f", base.SSIM, m.SSIM))
+			}
+			if base.Size > 0 && float64(m.Size) > float64(base.Size)*(1+maxGrowth/100) {
+				reasons = append(reasons, fmt.Sprintf("size grew %d → %d bytes", base.Size, m.Size))
+			}
+			if m.Format != base.Format {
+				reasons = append(reasons, fmt.Sprintf("format changed %s → %s", base.Format, m.Format))
+			}
+			if len(reasons) > 0 {
+				result.Status, result.Reason = "FAIL", strings.Join(reasons, "; ")
+				failed++
+			}
+		}
+		log.Printf("Regression %s: %s format=%s size=%d ssim=%.4f %s", key, result.Status, m.Format, m.Size, m.SSIM, result.Reason)
+		results = append(results, result)
+	}
+
+	status := StatusRegressionPassed
+	msg := fmt.Sprintf("Regression over %d fixtures: %d failed (max SSIM drop %g, max size growth %g%%)", len(results), failed, maxDrop, maxGrowth)
+	if failed > 0 {
+		status = StatusRegressionFailed
+	}
+	var outputs []OutputResult
+	if req.UpdateBaseline {
+		body, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to encode regression baseline: %w", err)
+		}
+		if err := h.putObject(ctx, bucket, req.BaselineKey, "application/json", body); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to upload regression baseline: %w", err)
+		}
+		status = StatusBaselineUpdated
+		msg = fmt.Sprintf("Regression baseline updated with %d fixtures (vips %s)", len(results), vips.Version)
+		outputs = append(outputs, OutputResult{Key: req.BaselineKey, Format: "json", Size: int64(len(body))})
+	}
+	log.Println(msg)
+	return ConversionResult{
+		Status:     status,
+		Outputs:    outputs,
+		Message:    msg,
+		Regression: results,
+	}, nil
+}
+
+// measureFixture는 항목 하나를 기본 변환 설정으로 인코딩하고 원본 대비 SSIM과 크기를 잽니다. 업로드하지 않습니다.
+func (h *Handler) measureFixture(ctx context.Context, bucket, key string) (regressionMeasurement, error) {
+	source, err := h.downloadObject(ctx, bucket, key)
+	if err != nil {
+		return regressionMeasurement{}, err
+	}
+	format, encoded, err := h.encodeDefault(source)
+	if err != nil {
+		return regressionMeasurement{}, err
+	}
+
+	ref, err := comparableImage(source, key)
+	if err != nil {
+		return regressionMeasurement{}, err
+	}
+	defer ref.Close()
+	cand, err := comparableImage(encoded, key+" ("+format+")")
+	if err != nil {
+		return regressionMeasurement{}, err
+	}
+	defer cand.Close()
+	if cand.Width() != ref.Width() || cand.Height() != ref.Height() {
+		if err := pipeline.Resize(cand, ref.Width(), ref.Height(), "fill", true, ""); err != nil {
+			return regressionMeasurement{}, err
+		}
+	}
+	var report CompareReport
+	if err := measure(ref, cand, &report); err != nil {
+		return regressionMeasurement{}, err
+	}
+	return regressionMeasurement{Format: format, Size: int64(len(encoded)), SSIM: report.SSIM}, nil
+}
+
+// encodeDefault는 이벤트 옵션 없이 S3 알림으로 들어온 변환과 같은 설정(그래픽 판별, 색·알파 정책,
+// 포맷별 샘플링, 기본 노력 수준)으로 주 출력을 인코딩합니다.
+func (h *Handler) encodeDefault(source []byte) (string, []byte, error) {
+	image, err := vips.NewImageFromBuffer(source, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to process image with vips from buffer: %w", err)
+	}
+	defer image.Close()
+	loader, _ := image.GetString("vips-loader")
+	graphics, _ := detectGraphics(image, loader, h.conf)
+	format := "avif"
+	if graphics && h.conf.GraphicsFormat == "webp" {
+		format = "webp"
+	}
+	encoder, err := h.encoders.Get(format)
+	if err != nil {
+		return "", nil, err
+	}
+	effort, err := resolveEffort(S3Event{}, h.conf)
+	if err != nil {
+		return "", nil, err
+	}
+	subsample, bitdepth, err := resolveSampling(format, S3Event{}, h.conf)
+	if err != nil {
+		return "", nil, err
+	}
+	color, err := applyColorPolicy(image, inspectColor(image), h.conf)
+	if err != nil {
+		return "", nil, err
+	}
+	keep := vips.KeepNone
+	if color.KeepICC {
+		keep = vips.KeepIcc
+	}
+	if _, err := applyAlphaPolicy(image, format, h.conf.AlphaBackground, h.conf); err != nil {
+		return "", nil, err
+	}
+	encoded, err := encoder.Encode(image, EncodeOptions{
+		Graphics:  graphics,
+		Color:     color,
+		Keep:      keep,
+		Effort:    effort,
+		Subsample: subsample,
+		Bitdepth:  bitdepth,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode image to %s: vips_error: %s", strings.ToUpper(format), err)
+	}
+	return format, encoded.Data, nil
+}
+
+// loadBaseline은 기준값 파일을 읽습니다. 없으면 모든 항목이 NEW가 되도록 빈 기준값을 돌려줍니다.
+func (h *Handler) loadBaseline(ctx context.Context, bucket, key string) (regressionBaseline, error) {
+	baseline := regressionBaseline{Entries: map[string]regressionMeasurement{}}
+	data, err := h.downloadObject(ctx, bucket, key)
+	var missing *types.NoSuchKey
+	if errors.As(err, &missing) {
+		log.Printf("Warning: regression baseline s3://%s/%s not found, all fixtures are new", bucket, key)
+		return baseline, nil
+	}
+	if err != nil {
+		return baseline, err
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("invalid regression baseline %s: %w", key, err)
+	}
+	return baseline, nil
+}
+
+// listKeys는 prefix 아래 객체 키를 정렬해 돌려줍니다. "/"로 끝나는 디렉터리 표시 객체는 뺍니다.
+func (h *Handler) listKeys(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)}
+	for {
+		out, err := h.s3.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", bucket, prefix, err)
+		}
+		for _, obj := range out.Contents {
+			if key := aws.ToString(obj.Key); !strings.HasSuffix(key, "/") {
+				keys = append(keys, key)
+			}
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
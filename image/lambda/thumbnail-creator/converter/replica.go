@@ -0,0 +1,33 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// replicaGuard는 교차 리전 복제로 들어온 복제본을 SKIPPED_REPLICA로 건너뜁니다. REPLICA_GUARD가 꺼져 있으면 아무것도 하지 않습니다.
+// 원본 리전에서 이미 변환했고 출력도 복제되므로, 복제본까지 변환하면 같은 작업을 두 번 하게 됩니다.
+//  1. S3 알림의 awsRegion(s3Region)이 함수 리전과 다르면 S3 호출 없이 건너뜁니다.
+//  2. HeadObject의 복제 상태가 REPLICA이면 건너뜁니다. 확인에 실패하면 변환을 계속합니다.
+func (h *Handler) replicaGuard(ctx context.Context, job *Job) error {
+	if !h.conf.ReplicaGuard {
+		return nil
+	}
+	if region := job.Event.S3Region; region != "" && h.conf.FunctionRegion != "" && region != h.conf.FunctionRegion {
+		return skip(StatusSkippedReplica, fmt.Sprintf("Event is from bucket region %s but the function runs in %s. Skipping conversion.", region, h.conf.FunctionRegion))
+	}
+	head, err := h.s3.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(job.Bucket), Key: aws.String(job.SrcKey)})
+	if err != nil {
+		log.Printf("Warning: failed to check replication status of %s: %v", job.SrcKey, err)
+		return nil
+	}
+	if head.ReplicationStatus == types.ReplicationStatusReplica {
+		return skip(StatusSkippedReplica, "Object is a cross-region replica. Skipping conversion.")
+	}
+	return nil
+}
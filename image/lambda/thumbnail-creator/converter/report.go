@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// savingsRow는 접두사 하나(또는 전체)의 절감량 집계입니다.
+// 절감량은 원본 크기에서 주 출력 크기를 뺀 값이며, 대체 출력(JXL/JPEG)은 따로 OutputBytes에만 더합니다.
+type savingsRow struct {
+	Prefix       string  `json:"prefix"`
+	Conversions  int     `json:"conversions"`
+	SourceBytes  int64   `json:"sourceBytes"`
+	PrimaryBytes int64   `json:"primaryBytes"`
+	OutputBytes  int64   `json:"outputBytes"`
+	SavedBytes   int64   `json:"savedBytes"`
+	SavedPercent float64 `json:"savedPercent"`
+}
+
+func (r *savingsRow) add(rec conversionRecord) {
+	r.Conversions++
+	r.SourceBytes += rec.SourceBytes
+	r.PrimaryBytes += rec.PrimaryBytes
+	r.OutputBytes += rec.OutputBytes
+	r.SavedBytes = r.SourceBytes - r.PrimaryBytes
+	if r.SourceBytes > 0 {
+		r.SavedPercent = float64(r.SavedBytes) * 100 / float64(r.SourceBytes)
+	}
+}
+
+// savingsReport는 하루치 보고서입니다.
+type savingsReport struct {
+	Day         string       `json:"day"`
+	GeneratedAt string       `json:"generatedAt"`
+	Total       savingsRow   `json:"total"`
+	Prefixes    []savingsRow `json:"prefixes"`
+}
+
+// reportPrefix는 기록을 묶을 접두사입니다. "버킷/경로의 앞 depth개 요소"이며, 경로가 없으면 "버킷/"입니다.
+func reportPrefix(rec conversionRecord, depth int) string {
+	parts := strings.Split(rec.SourceKey, "/")
+	parts = parts[:len(parts)-1] // 파일 이름 제외
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return rec.Bucket + "/" + strings.Join(parts, "/")
+}
+
+// reportDay는 보고서 날짜입니다. reportDate가 없으면 예약 이벤트 시각(없으면 현재 시각)의 전날(UTC)입니다.
+func (h *Handler) reportDay(event S3Event) (string, error) {
+	if event.ReportDate != "" {
+		if _, err := time.Parse(time.DateOnly, event.ReportDate); err != nil {
+			return "", fmt.Errorf("invalid event: reportDate must be YYYY-MM-DD: %w", err)
+		}
+		return event.ReportDate, nil
+	}
+	now := h.clock.Now()
+	if event.Time != "" {
+		if t, err := time.Parse(time.RFC3339, event.Time); err == nil {
+			now = t
+		}
+	}
+	return now.UTC().AddDate(0, 0, -1).Format(time.DateOnly), nil
+}
+
+// SavingsReport는 RECORDS_TABLE의 하루치 변환 기록을 접두사별로 집계해
+// REPORTS_BUCKET/REPORTS_PREFIX 아래에 <날짜>.json과 <날짜>.csv로 올립니다.
+// EventBridge 예약 규칙("Scheduled Event")이나 "mode": "savings-report" 이벤트로 실행합니다.
+func (h *Handler) SavingsReport(ctx context.Context, event S3Event) (ConversionResult, error) {
+	if h.records == nil || h.conf.ReportsBucket == "" {
+		return ConversionResult{}, fmt.Errorf("savings report requires RECORDS_TABLE and REPORTS_BUCKET")
+	}
+	day, err := h.reportDay(event)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	records, err := h.records.ForDay(ctx, day)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+
+	report := savingsReport{
+		Day:         day,
+		GeneratedAt: h.clock.Now().UTC().Format(time.RFC3339),
+		Total:       savingsRow{Prefix: "TOTAL"},
+	}
+	byPrefix := map[string]*savingsRow{}
+	for _, rec := range records {
+		prefix := reportPrefix(rec, h.conf.ReportPrefixDepth)
+		row, ok := byPrefix[prefix]
+		if !ok {
+			row = &savingsRow{Prefix: prefix}
+			byPrefix[prefix] = row
+		}
+		row.add(rec)
+		report.Total.add(rec)
+	}
+	for _, row := range byPrefix {
+		report.Prefixes = append(report.Prefixes, *row)
+	}
+	sort.Slice(report.Prefixes, func(i, j int) bool {
+		return report.Prefixes[i].SavedBytes > report.Prefixes[j].SavedBytes
+	})
+
+	jsonBody, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode savings report: %w", err)
+	}
+	csvBody, err := savingsCSV(report)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode savings report: %w", err)
+	}
+
+	base := h.conf.ReportsPrefix + day
+	var outputs []OutputResult
+	for _, f := range []struct {
+		ext, contentType string
+		body             []byte
+	}{
+		{"json", "application/json", jsonBody},
+		{"csv", "text/csv", csvBody},
+	} {
+		key := base + "." + f.ext
+		if err := h.putObject(ctx, h.conf.ReportsBucket, key, f.contentType, f.body); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to upload savings report %s: %w", key, err)
+		}
+		outputs = append(outputs, OutputResult{Key: key, Format: f.ext, Size: int64(len(f.body))})
+	}
+
+	msg := fmt.Sprintf("Savings report for %s: %d conversions, %d bytes saved (%.1f%%)", day, report.Total.Conversions, report.Total.SavedBytes, report.Total.SavedPercent)
+	log.Println(msg)
+	return ConversionResult{
+		Status:  StatusReported,
+		NewKey:  outputs[0].Key,
+		Outputs: outputs,
+		Message: msg,
+	}, nil
+}
+
+func savingsCSV(report savingsReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"day", "prefix", "conversions", "source_bytes", "primary_bytes", "output_bytes", "saved_bytes", "saved_percent"})
+	for _, row := range append(report.Prefixes, report.Total) {
+		_ = w.Write([]string{
+			report.Day,
+			row.Prefix,
+			strconv.Itoa(row.Conversions),
+			strconv.FormatInt(row.SourceBytes, 10),
+			strconv.FormatInt(row.PrimaryBytes, 10),
+			strconv.FormatInt(row.OutputBytes, 10),
+			strconv.FormatInt(row.SavedBytes, 10),
+			strconv.FormatFloat(row.SavedPercent, 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,242 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// TransformRule은 원본 속성에 따라 변환을 바꾸는 규칙 하나입니다. (TRANSFORM_RULES, JSON 배열)
+// 디코딩 직후(헤더 기준) 순서대로 평가하며, 맞는 규칙을 모두 적용하고 뒤 규칙의 값이 앞 규칙을 덮어씁니다.
+// skip과 copy는 그 자리에서 변환을 끝냅니다.
+type TransformRule struct {
+	Name string        `json:"name"`
+	When RuleCondition `json:"when"`
+	Then RuleAction    `json:"then"`
+}
+
+// RuleCondition은 채워진 조건이 모두 맞아야 맞는 원본 조건입니다. 범위는 양 끝을 포함합니다.
+// 너비·높이는 EXIF 방향을 적용한, 화면에 보이는 기준이며 애니메이션은 한 프레임 크기입니다.
+type RuleCondition struct {
+	// Formats는 원본 포맷(jpeg, png, webp, gif, heif, tiff 등 로더 이름) 중 하나여야 합니다.
+	Formats   []string `json:"formats,omitempty"`
+	MinWidth  int      `json:"minWidth,omitempty"`
+	MaxWidth  int      `json:"maxWidth,omitempty"`
+	MinHeight int      `json:"minHeight,omitempty"`
+	MaxHeight int      `json:"maxHeight,omitempty"`
+	MinBytes  int64    `json:"minBytes,omitempty"`
+	MaxBytes  int64    `json:"maxBytes,omitempty"`
+	// Alpha, Animated, Graphics는 지정한 경우에만 비교합니다. Graphics는 GRAPHICS_DETECTION의 판정입니다.
+	Alpha    *bool `json:"alpha,omitempty"`
+	Animated *bool `json:"animated,omitempty"`
+	Graphics *bool `json:"graphics,omitempty"`
+	// Key는 원본 버킷·키 조건입니다. (SKIP_RULES의 규칙과 같은 형식)
+	Key *KeyRule `json:"key,omitempty"`
+}
+
+// RuleAction은 규칙이 맞을 때 바꿀 내용입니다.
+type RuleAction struct {
+	// Action이 skip이면 SKIPPED_RULE로 끝내고, copy이면 원본 바이트를 그대로 출력 키(원본 확장자)에 올립니다.
+	// 비어 있으면 아래 값으로 인코딩을 바꿉니다.
+	Action string `json:"action,omitempty"`
+	// Format과 Quality는 출력 포맷과 품질입니다. Lossless이면 그래픽 경로(무손실)로 인코딩합니다.
+	Format   string `json:"format,omitempty"`
+	Quality  int    `json:"quality,omitempty"`
+	Lossless bool   `json:"lossless,omitempty"`
+	// NoResize이면 프리셋 크기 대신 원본 크기의 출력 하나만 만듭니다. (작은 원본을 늘리거나 여러 번 올리지 않음)
+	NoResize bool `json:"noResize,omitempty"`
+}
+
+// parseTransformRules는 TRANSFORM_RULES JSON을 읽고 검증합니다. 이름이 없으면 rule-<순번>입니다.
+func parseTransformRules(data []byte) ([]TransformRule, error) {
+	var rules []TransformRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *TransformRule) validate() error {
+	w := r.When
+	if w.MinWidth < 0 || w.MaxWidth < 0 || w.MinHeight < 0 || w.MaxHeight < 0 || w.MinBytes < 0 || w.MaxBytes < 0 {
+		return fmt.Errorf("bounds must not be negative")
+	}
+	if w.Key != nil {
+		if err := w.Key.compile(); err != nil {
+			return fmt.Errorf("key: %w", err)
+		}
+	}
+	switch r.Then.Action {
+	case "", "skip", "copy":
+	default:
+		return fmt.Errorf("unknown action %q: must be skip or copy", r.Then.Action)
+	}
+	if r.Then.Format == "jpg" {
+		r.Then.Format = "jpeg"
+	}
+	if r.Then.Format != "" || r.Then.Quality != 0 {
+		format := r.Then.Format
+		if format == "" {
+			format = "avif"
+		}
+		if err := pipeline.ValidateFormat(format, r.Then.Quality); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceFacts는 규칙 조건과 비교할 원본 속성입니다.
+type sourceFacts struct {
+	Format   string
+	Width    int
+	Height   int
+	Bytes    int64
+	Alpha    bool
+	Animated bool
+	Graphics bool
+	Bucket   string
+	Key      string
+}
+
+func factsOf(job *Job, image *vips.Image, graphics bool) sourceFacts {
+	f := sourceFacts{
+		Format:   loaderFormat(job.Loader),
+		Width:    image.Width(),
+		Height:   image.Height(),
+		Bytes:    int64(len(job.Source)),
+		Alpha:    image.HasAlpha(),
+		Animated: image.Pages() > 1,
+		Graphics: graphics,
+		Bucket:   job.Bucket,
+		Key:      job.SrcKey,
+	}
+	if pageHeight, err := image.GetInt("page-height"); err == nil && pageHeight > 0 {
+		f.Height = pageHeight
+	}
+	if image.Orientation() >= 5 {
+		f.Width, f.Height = f.Height, f.Width
+	}
+	return f
+}
+
+func (w RuleCondition) match(f sourceFacts) bool {
+	if len(w.Formats) > 0 && !contains(w.Formats, f.Format) {
+		return false
+	}
+	inRange := func(v, lo, hi int64) bool { return v >= lo && (hi == 0 || v <= hi) }
+	return inRange(int64(f.Width), int64(w.MinWidth), int64(w.MaxWidth)) &&
+		inRange(int64(f.Height), int64(w.MinHeight), int64(w.MaxHeight)) &&
+		inRange(f.Bytes, w.MinBytes, w.MaxBytes) &&
+		(w.Alpha == nil || *w.Alpha == f.Alpha) &&
+		(w.Animated == nil || *w.Animated == f.Animated) &&
+		(w.Graphics == nil || *w.Graphics == f.Graphics) &&
+		(w.Key == nil || w.Key.match(f.Bucket, f.Key))
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleDecision은 맞은 규칙을 모두 적용한 결과입니다.
+type ruleDecision struct {
+	RuleAction
+	Matched []string
+	facts   sourceFacts
+}
+
+// evaluateRules는 규칙을 순서대로 평가합니다. skip/copy 규칙을 만나면 거기서 멈춥니다.
+func evaluateRules(rules []TransformRule, f sourceFacts) ruleDecision {
+	var d ruleDecision
+	for _, r := range rules {
+		if !r.When.match(f) {
+			continue
+		}
+		d.Matched = append(d.Matched, r.Name)
+		t := r.Then
+		if t.Action != "" {
+			d.Action = t.Action
+			return d
+		}
+		if t.Format != "" {
+			d.Format = t.Format
+		}
+		if t.Quality > 0 {
+			d.Quality = t.Quality
+		}
+		d.Lossless = d.Lossless || t.Lossless
+		d.NoResize = d.NoResize || t.NoResize
+	}
+	return d
+}
+
+// matchTransformRules는 TRANSFORM_RULES를 평가하고, skip 규칙이면 SKIPPED_RULE을 돌려줍니다.
+// copy와 나머지 결정은 호출자(process)가 반영합니다.
+func (h *Handler) matchTransformRules(job *Job, image *vips.Image, graphics bool) (ruleDecision, error) {
+	if len(h.conf.TransformRules) == 0 {
+		return ruleDecision{}, nil
+	}
+	f := factsOf(job, image, graphics)
+	d := evaluateRules(h.conf.TransformRules, f)
+	d.facts = f
+	if len(d.Matched) == 0 {
+		return d, nil
+	}
+	job.Result.Rules = d.Matched
+	log.Printf("Transform rules matched: %s", strings.Join(d.Matched, ", "))
+	if d.Action == "skip" {
+		return d, skip(StatusSkippedRule, fmt.Sprintf("Transform rule %s skips this image. Skipping conversion.", d.Matched[len(d.Matched)-1]))
+	}
+	return d, nil
+}
+
+// copySource는 원본 바이트를 출력 키(원본 확장자)에 그대로 올리고 변환을 끝냅니다.
+// 출력 키가 원본 객체와 같으면 올릴 것이 없으므로 SKIPPED_RULE로 끝냅니다.
+func (h *Handler) copySource(ctx context.Context, job *Job, f sourceFacts) (ConversionResult, error) {
+	ext := keyExtension(job.SrcKey)
+	format := strings.TrimPrefix(strings.ToLower(ext), ".")
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	if format == "" {
+		format = f.Format
+		ext = extensionOf(format)
+	}
+	key := replaceExtension(job.BaseKey, ext)
+	if key == job.SrcKey && job.OutputBucket == job.Bucket {
+		return ConversionResult{}, skip(StatusSkippedRule, "Transform rule copies the source as-is and the output key is the source object. Skipping conversion.")
+	}
+	job.Result.Format = format
+	job.Result.Compression = "original"
+	if err := h.upload(ctx, job, &Upload{Key: key, Format: format, Body: job.Source, Width: f.Width, Height: f.Height, Primary: true}); err != nil {
+		return ConversionResult{}, err
+	}
+	job.Result.Status = StatusCopied
+	if job.Serve != nil {
+		return *job.Result, nil
+	}
+	if err := h.hooks.PostConvert(ctx, job); err != nil {
+		return ConversionResult{}, err
+	}
+	return *job.Result, nil
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// samplingCapabilities는 포맷별로 지정할 수 있는 크로마 서브샘플링과 비트 깊이입니다.
+// "auto"와 0(인코더 기본값)은 모든 포맷에서 허용합니다.
+// WebP 손실 압축은 항상 4:2:0이며, JXL과 PNG는 지정할 수 있는 값이 없습니다.
+var samplingCapabilities = map[string]struct {
+	Subsample []string
+	Bitdepths []int
+}{
+	"avif": {Subsample: []string{"444", "420"}, Bitdepths: []int{8, 10, 12}},
+	"jpeg": {Subsample: []string{"444", "420"}, Bitdepths: []int{8}},
+	"webp": {Subsample: []string{"420"}, Bitdepths: []int{8}},
+}
+
+// subsampleModes는 설정 값과 libvips 서브샘플링 모드의 대응입니다.
+var subsampleModes = map[string]vips.Subsample{
+	"auto": vips.SubsampleAuto,
+	"444":  vips.SubsampleOff,
+	"420":  vips.SubsampleOn,
+}
+
+// validateSampling은 format 인코더가 subsample과 bitdepth를 지원하는지 확인합니다.
+func validateSampling(format, subsample string, bitdepth int) error {
+	caps := samplingCapabilities[format]
+	if subsample != "auto" && !slices.Contains(caps.Subsample, subsample) {
+		return fmt.Errorf("subsample %q is not supported by %s (supported: auto %v)", subsample, format, caps.Subsample)
+	}
+	if bitdepth != 0 && !slices.Contains(caps.Bitdepths, bitdepth) {
+		return fmt.Errorf("bitdepth %d is not supported by %s (supported: %v)", bitdepth, format, caps.Bitdepths)
+	}
+	return nil
+}
+
+// resolveSampling은 <FORMAT>_SUBSAMPLE/<FORMAT>_BITDEPTH 설정에 이벤트의 subsample/bitdepth를 덮어써
+// 출력 포맷에 적용할 값을 정합니다.
+func resolveSampling(format string, event S3Event, c Config) (string, int, error) {
+	subsample, bitdepth := c.Subsample[format], c.Bitdepth[format]
+	if subsample == "" {
+		subsample = "auto"
+	}
+	if event.Subsample != "" {
+		subsample = event.Subsample
+	}
+	if event.Bitdepth != 0 {
+		bitdepth = event.Bitdepth
+	}
+	if err := validateSampling(format, subsample, bitdepth); err != nil {
+		return "", 0, err
+	}
+	return subsample, bitdepth, nil
+}
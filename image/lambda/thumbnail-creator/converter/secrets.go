@@ -0,0 +1,131 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// 환경 변수 값이 이 접두사로 시작하면 값 대신 참조로 보고 콜드 스타트에 읽어 옵니다.
+//
+//	ssm:/thumbnail/tenants                 SSM Parameter Store (SecureString은 복호화)
+//	secretsmanager:thumbnail/signing       Secrets Manager 비밀 문자열
+//	secretsmanager:thumbnail/signing#hmac  JSON 비밀의 hmac 필드
+const (
+	ssmRefPrefix    = "ssm:"
+	secretRefPrefix = "secretsmanager:"
+)
+
+// SSMAPI와 SecretsManagerAPI는 설정 참조를 읽는 데 사용하는 호출입니다.
+type SSMAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// secretResolver는 환경 변수의 ssm:/secretsmanager: 참조를 실제 값으로 바꾸고 캐시합니다.
+// 값이 콘솔과 Terraform 상태에 평문으로 남지 않도록, 환경 변수에는 참조만 둡니다.
+type secretResolver struct {
+	ssm     SSMAPI
+	secrets SecretsManagerAPI
+	clock   Clock
+
+	mu     sync.Mutex
+	values map[string]string
+	// fetched는 마지막으로 모든 참조를 다시 읽은 시각입니다.
+	fetched time.Time
+}
+
+func newSecretResolver(ssmAPI SSMAPI, secretsAPI SecretsManagerAPI, clock Clock) *secretResolver {
+	return &secretResolver{ssm: ssmAPI, secrets: secretsAPI, clock: clock, values: map[string]string{}}
+}
+
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, ssmRefPrefix) || strings.HasPrefix(value, secretRefPrefix)
+}
+
+// Resolve는 참조 하나의 값을 돌려줍니다. 이미 읽은 참조는 캐시된 값을 씁니다.
+func (r *secretResolver) Resolve(ctx context.Context, ref string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.values[ref]; ok {
+		return v, nil
+	}
+	v, err := r.fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if len(r.values) == 0 {
+		r.fetched = r.clock.Now()
+	}
+	r.values[ref] = v
+	return v, nil
+}
+
+// Refresh는 마지막으로 읽은 지 interval이 지났으면 모든 참조를 다시 읽고, 값이 하나라도 바뀌었는지 돌려줍니다.
+// 읽기에 실패한 참조는 이전 값을 유지합니다.
+func (r *secretResolver) Refresh(ctx context.Context, interval time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if interval <= 0 || len(r.values) == 0 || r.clock.Now().Sub(r.fetched) < interval {
+		return false
+	}
+	r.fetched = r.clock.Now()
+	changed := false
+	for ref, old := range r.values {
+		v, err := r.fetch(ctx, ref)
+		if err != nil {
+			log.Printf("Warning: failed to refresh %s, keeping the previous value: %v", ref, err)
+			continue
+		}
+		if v != old {
+			log.Printf("Configuration reference %s changed", ref)
+			r.values[ref] = v
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (r *secretResolver) fetch(ctx context.Context, ref string) (string, error) {
+	if name, ok := strings.CutPrefix(ref, ssmRefPrefix); ok {
+		out, err := r.ssm.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name), WithDecryption: aws.Bool(true)})
+		if err != nil {
+			return "", fmt.Errorf("failed to get SSM parameter %s: %w", name, err)
+		}
+		return aws.ToString(out.Parameter.Value), nil
+	}
+	id, field, _ := strings.Cut(strings.TrimPrefix(ref, secretRefPrefix), "#")
+	out, err := r.secrets.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", id, err)
+	}
+	value := aws.ToString(out.SecretString)
+	if field == "" {
+		return value, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", id, err)
+	}
+	v, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %q", id, field)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	// 숫자나 중첩 객체(테넌트 설정 등)는 JSON 그대로 돌려줍니다.
+	raw, err := json.Marshal(v)
+	return string(raw), err
+}
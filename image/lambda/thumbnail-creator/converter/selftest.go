@@ -0,0 +1,137 @@
+package converter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cshum/vipsgen/vips"
+)
+
+// selfTestFixture는 자체 점검에 쓰는 16x16 RGBA PNG(부분 투명)입니다.
+//
+//go:embed fixtures/selftest.png
+var selfTestFixture []byte
+
+// HealthReport는 "mode": "self-test" 요청의 점검 결과입니다.
+type HealthReport struct {
+	Healthy bool          `json:"healthy"`
+	Vips    string        `json:"vips"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+// HealthCheck는 점검 하나의 결과입니다. Status는 "ok" | "failed"입니다.
+type HealthCheck struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+	Detail     string  `json:"detail,omitempty"`
+}
+
+// SelfTest는 내장 이미지를 디코딩하고, 등록된 모든 포맷으로 인코딩해 다시 디코딩한 뒤,
+// 출력 버킷에 작은 객체를 올렸다 지워 권한과 연결을 확인합니다. 점검이 하나라도 실패하면 UNHEALTHY입니다.
+// 실패해도 오류 대신 보고서를 돌려주므로, 카나리는 status를 확인합니다.
+func (h *Handler) SelfTest(ctx context.Context, event S3Event) (ConversionResult, error) {
+	report := &HealthReport{Healthy: true, Vips: vips.Version}
+	check := func(name string, fn func() (string, error)) bool {
+		start := h.clock.Now()
+		detail, err := fn()
+		c := HealthCheck{Name: name, Status: "ok", DurationMs: float64(h.clock.Now().Sub(start).Microseconds()) / 1000, Detail: detail}
+		if err != nil {
+			c.Status, c.Detail = "failed", err.Error()
+			report.Healthy = false
+			log.Printf("Self-test %s failed: %v", name, err)
+		}
+		report.Checks = append(report.Checks, c)
+		return err == nil
+	}
+
+	var image *vips.Image
+	decoded := check("decode", func() (string, error) {
+		var err error
+		if image, err = vips.NewImageFromBuffer(selfTestFixture, nil); err != nil {
+			return "", fmt.Errorf("failed to decode fixture: %w", err)
+		}
+		return fmt.Sprintf("%dx%d, %d bands", image.Width(), image.Height(), image.Bands()), nil
+	})
+	if decoded {
+		defer image.Close()
+		for _, name := range h.encoders.Names() {
+			check("encode:"+name, func() (string, error) {
+				return h.selfTestEncode(image, name)
+			})
+		}
+	}
+
+	bucket := event.S3Bucket
+	if bucket == "" {
+		bucket = h.conf.SelfTestBucket
+	}
+	if bucket != "" {
+		check("s3:"+bucket, func() (string, error) {
+			return h.selfTestWrite(ctx, bucket)
+		})
+	}
+
+	status := StatusHealthy
+	var failed []string
+	for _, c := range report.Checks {
+		if c.Status != "ok" {
+			failed = append(failed, c.Name)
+		}
+	}
+	msg := fmt.Sprintf("Self-test passed %d checks", len(report.Checks))
+	if !report.Healthy {
+		status = StatusUnhealthy
+		msg = fmt.Sprintf("Self-test failed %d of %d checks: %s", len(failed), len(report.Checks), strings.Join(failed, ", "))
+	}
+	log.Println(msg)
+	emitMetrics(h.conf.MetricsNamespace, "Count", map[string]float64{"SelfTestFailures": float64(len(failed))})
+	return ConversionResult{Status: status, Message: msg, Health: report}, nil
+}
+
+// selfTestEncode는 이미지를 한 포맷으로 인코딩하고 결과를 다시 디코딩합니다. 원본은 바꾸지 않습니다.
+func (h *Handler) selfTestEncode(image *vips.Image, format string) (string, error) {
+	encoder, err := h.encoders.Get(format)
+	if err != nil {
+		return "", err
+	}
+	copied, err := image.Copy(nil)
+	if err != nil {
+		return "", err
+	}
+	defer copied.Close()
+	encoded, err := encoder.Encode(copied, EncodeOptions{Color: colorPlan{Bitdepth: 8}, Keep: vips.KeepNone})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode to %s: %w", format, err)
+	}
+	roundtrip, err := vips.NewImageFromBuffer(encoded.Data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s output: %w", format, err)
+	}
+	defer roundtrip.Close()
+	if roundtrip.Width() != image.Width() || roundtrip.Height() != image.Height() {
+		return "", fmt.Errorf("%s output is %dx%d, want %dx%d", format, roundtrip.Width(), roundtrip.Height(), image.Width(), image.Height())
+	}
+	detail := fmt.Sprintf("%d bytes", len(encoded.Data))
+	if encoded.Encoder != "" {
+		detail += " (" + encoded.Encoder + ")"
+	}
+	return detail, nil
+}
+
+// selfTestWrite는 SELFTEST_PREFIX 아래에 작은 객체를 올리고 지웁니다. 출력과 같은 클라이언트(대상 역할 포함)와 속성을 씁니다.
+func (h *Handler) selfTestWrite(ctx context.Context, bucket string) (string, error) {
+	key := fmt.Sprintf("%s%d.txt", h.conf.SelfTestPrefix, h.clock.Now().UnixNano())
+	if err := h.putObjectAttrs(ctx, bucket, key, "text/plain", []byte("thumbnail-creator self-test\n"), h.outputAttrs(nil)); err != nil {
+		return "", fmt.Errorf("failed to put %s: %w", key, err)
+	}
+	if _, err := h.outputClient(bucket).DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}); err != nil {
+		return "", fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return "put and deleted " + key, nil
+}
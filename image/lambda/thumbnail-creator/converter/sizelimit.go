@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// minFitQuality는 maxOutputBytes를 맞추기 위해 낮출 수 있는 가장 낮은 품질입니다.
+// 여기까지 낮춰도 크면 크기를 줄입니다.
+const minFitQuality = 20
+
+// maxFitDownscales는 품질을 최저로 낮춘 뒤 크기를 줄여 다시 시도하는 최대 횟수입니다.
+const maxFitDownscales = 4
+
+// OutputTooLarge는 품질과 크기를 줄여도 출력이 maxOutputBytes 안에 들어가지 않았음을 나타냅니다.
+// Lambda 오류 응답의 errorType이 타입 이름이 되므로 재시도할 필요가 없는 실패로 구분할 수 있습니다.
+type OutputTooLarge struct {
+	Key    string
+	Format string
+	Size   int
+	Limit  int
+}
+
+func (e *OutputTooLarge) Error() string {
+	return fmt.Sprintf("OUTPUT_TOO_LARGE: smallest %s encoding of %s is %d bytes, limit is %d bytes", e.Format, e.Key, e.Size, e.Limit)
+}
+
+// formatQuality는 품질을 지정하지 않았을 때 인코더가 쓰는 품질입니다.
+func formatQuality(format string, c Config) int {
+	switch format {
+	case "jpeg":
+		return c.JPEGQuality
+	case "jxl":
+		return c.JXLQuality
+	case "webp":
+		return 75
+	}
+	return 50
+}
+
+// encodeWithin은 출력이 limit 바이트 안에 들어가도록 인코딩합니다.
+// 무손실 출력이 크면 손실 압축으로 바꾸고, 품질을 이진 탐색으로 낮춘 뒤, 그래도 크면 비율을 유지하며 크기를 줄입니다.
+// 크기를 줄였다면 새 이미지를 돌려주며 호출한 쪽에서 닫아야 합니다. limit이 0이면 한 번만 인코딩합니다.
+func (h *Handler) encodeWithin(ctx context.Context, job *Job, key string, image *vips.Image, encoder Encoder, p EncodeOptions, limit int) (*vips.Image, Encoded, error) {
+	encode := func(img *vips.Image, p EncodeOptions) (Encoded, error) {
+		if err := h.checkBudget(ctx, "encode "+key); err != nil {
+			return Encoded{}, err
+		}
+		if err := h.breaker.Allow(encoder.Name()); err != nil {
+			return Encoded{}, err
+		}
+		encoded, err := encoder.Encode(img, p)
+		h.breaker.Record(encoder.Name(), err, err != nil && !job.firstEncoderFailure(encoder.Name()))
+		if err != nil {
+			return Encoded{}, fmt.Errorf("failed to encode image to %s: vips_error: %s", strings.ToUpper(encoder.Name()), err)
+		}
+		return encoded, nil
+	}
+
+	encoded, err := encode(image, p)
+	if err != nil || limit == 0 || len(encoded.Data) <= limit {
+		return image, encoded, err
+	}
+	log.Printf("Encoded %s is %d bytes, over the %d byte limit; reducing quality", key, len(encoded.Data), limit)
+	smallest := len(encoded.Data)
+
+	if encoder.Name() != "png" {
+		if p.Graphics {
+			p.Graphics = false
+			job.mu.Lock()
+			job.Result.Compression = compressionOf(encoder.Name(), false, h.conf)
+			job.mu.Unlock()
+		}
+		start := p.Quality
+		if start == 0 {
+			start = formatQuality(encoder.Name(), h.conf)
+		}
+		var best *Encoded
+		for lo, hi := minFitQuality, start-1; lo <= hi; {
+			p.Quality = (lo + hi) / 2
+			candidate, err := encode(image, p)
+			if err != nil {
+				return nil, Encoded{}, err
+			}
+			log.Printf("Size fit: quality=%d, size=%d bytes", p.Quality, len(candidate.Data))
+			smallest = min(smallest, len(candidate.Data))
+			if len(candidate.Data) <= limit {
+				best = &candidate
+				lo = p.Quality + 1
+			} else {
+				hi = p.Quality - 1
+			}
+		}
+		if best != nil {
+			return image, *best, nil
+		}
+		p.Quality = min(start, minFitQuality)
+	}
+
+	resized, err := image.Copy(nil)
+	if err != nil {
+		return nil, Encoded{}, err
+	}
+	for range maxFitDownscales {
+		// 출력 크기는 대략 픽셀 수에 비례하므로 변 길이는 크기 비율의 제곱근만큼 줄입니다.
+		ratio := math.Sqrt(float64(limit)/float64(smallest)) * 0.9
+		width := max(1, int(float64(resized.Width())*ratio))
+		height := max(1, int(float64(resized.Height())*ratio))
+		if err := pipeline.Resize(resized, width, height, "inside", false, ""); err != nil {
+			resized.Close()
+			return nil, Encoded{}, fmt.Errorf("failed to downscale image to fit size limit: %w", err)
+		}
+		candidate, err := encode(resized, p)
+		if err != nil {
+			resized.Close()
+			return nil, Encoded{}, err
+		}
+		log.Printf("Size fit: %dx%d at quality=%d, size=%d bytes", resized.Width(), resized.Height(), p.Quality, len(candidate.Data))
+		if len(candidate.Data) <= limit {
+			return resized, candidate, nil
+		}
+		smallest = len(candidate.Data)
+	}
+	resized.Close()
+	return nil, Encoded{}, &OutputTooLarge{Key: key, Format: encoder.Name(), Size: smallest, Limit: limit}
+}
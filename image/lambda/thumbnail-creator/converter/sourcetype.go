@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// extensionTypes는 원본 확장자별 Content-Type입니다. sniffContentType이 알아보는 포맷만 둡니다.
+var extensionTypes = map[string]string{
+	".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".jpe": "image/jpeg",
+	".png": "image/png", ".gif": "image/gif", ".webp": "image/webp",
+	".avif": "image/avif", ".heic": "image/heic", ".heif": "image/heic",
+	".jxl": "image/jxl", ".tif": "image/tiff", ".tiff": "image/tiff",
+	".bmp": "image/bmp", ".svg": "image/svg+xml", ".pdf": "application/pdf",
+	".mp4": "video/mp4", ".m4v": "video/mp4", ".mov": "video/quicktime",
+	".zip": "application/zip", ".gz": "application/gzip", ".tgz": "application/gzip",
+}
+
+// SourceTypeCheck는 원본의 저장된 Content-Type, 확장자, 내용(매직 바이트)이 서로 다를 때 결과에 남기는 값입니다.
+type SourceTypeCheck struct {
+	Stored    string `json:"stored"`
+	Extension string `json:"extension,omitempty"`
+	Sniffed   string `json:"sniffed"`
+	// Corrected는 SOURCE_CONTENT_TYPE=fix로 원본의 Content-Type을 Sniffed로 고쳤으면 true입니다.
+	Corrected bool `json:"corrected,omitempty"`
+}
+
+// UseSourceContentType은 원본의 Content-Type을 내용과 맞춰 보는 미들웨어를 등록합니다. (SOURCE_CONTENT_TYPE)
+// 원본 보관(UseOriginalArchive)이 고친 Content-Type을 복사하도록 그보다 먼저 등록합니다.
+func (h *Handler) UseSourceContentType() {
+	h.hooks.Use(&sourceTyper{h: h, fix: h.conf.SourceContentType == "fix"})
+}
+
+// sourceTyper는 PreDecode에서 저장된 Content-Type·확장자·매직 바이트를 비교해 어긋나면 기록하고,
+// job.SourceContentType을 내용으로 알아낸 값으로 바꿉니다. 디코딩은 libvips가 내용으로 로더를 고르므로 이미 내용을 따릅니다.
+// fix이면 변환에 성공한 뒤 CopyObject(MetadataDirective=REPLACE)로 원본의 Content-Type을 고칩니다.
+type sourceTyper struct {
+	h   *Handler
+	fix bool
+}
+
+func (t *sourceTyper) PreDecode(ctx context.Context, job *Job) error {
+	sniffed := sniffContentType(job.Source)
+	if sniffed == "" {
+		return nil
+	}
+	stored := normalizeContentType(job.SourceContentType)
+	extension := extensionTypes[strings.ToLower(keyExtension(job.SrcKey))]
+	if stored == sniffed && (extension == "" || extension == sniffed) {
+		return nil
+	}
+	log.Printf("Warning: source content type mismatch for %s: stored=%q extension=%q sniffed=%q, processing as %s",
+		job.SrcKey, job.SourceContentType, extension, sniffed, sniffed)
+	emitMetrics(t.h.conf.MetricsNamespace, "Count", map[string]float64{"SourceContentTypeMismatch": 1})
+	job.Result.SourceType = &SourceTypeCheck{Stored: job.SourceContentType, Extension: extension, Sniffed: sniffed}
+	job.SourceContentType = sniffed
+	return nil
+}
+
+// PostConvert는 fix일 때 저장된 Content-Type이 내용과 다른 원본을 고칩니다. 확장자만 다르면 키를 바꿀 수 없으므로 기록만 합니다.
+// 같은 키로 다시 쓰므로 ObjectCreated:Copy 알림으로 한 번 더 변환될 수 있지만, 그때는 Content-Type이 맞아 다시 고치지 않습니다.
+func (t *sourceTyper) PostConvert(ctx context.Context, job *Job) error {
+	check := job.Result.SourceType
+	if !t.fix || check == nil || job.Archive != "" || normalizeContentType(check.Stored) == check.Sniffed {
+		return nil
+	}
+	if int64(len(job.Source)) > maxCopySize {
+		log.Printf("Warning: not correcting content type of %s: %d bytes exceeds the single copy limit", job.SrcKey, len(job.Source))
+		return nil
+	}
+	// REPLACE는 메타데이터를 모두 새로 쓰므로 기존 사용자 메타데이터와 헤더, 저장 클래스를 옮겨 적습니다.
+	head, err := t.h.s3.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(job.Bucket), Key: aws.String(job.SrcKey)})
+	if err != nil {
+		return fmt.Errorf("failed to read metadata of %s to correct its content type: %w", job.SrcKey, err)
+	}
+	_, err = t.h.s3.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:             aws.String(job.Bucket),
+		Key:                aws.String(job.SrcKey),
+		CopySource:         aws.String(url.PathEscape(job.Bucket + "/" + job.SrcKey)),
+		CopySourceIfMatch:  head.ETag,
+		MetadataDirective:  types.MetadataDirectiveReplace,
+		TaggingDirective:   types.TaggingDirectiveCopy,
+		ContentType:        aws.String(check.Sniffed),
+		Metadata:           head.Metadata,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+		StorageClass:       head.StorageClass,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to correct content type of %s to %s: %w", job.SrcKey, check.Sniffed, err)
+	}
+	log.Printf("Corrected content type of %s from %q to %s", job.SrcKey, check.Stored, check.Sniffed)
+	check.Corrected = true
+	job.SourceChanges = append(job.SourceChanges, fmt.Sprintf("content type %q corrected to %s", check.Stored, check.Sniffed))
+	return nil
+}
+
+// normalizeContentType은 매개변수를 떼고 소문자로 바꾸며, 흔한 비표준 이름을 표준 이름으로 바꿉니다.
+func normalizeContentType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		media = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	switch media {
+	case "image/jpg", "image/pjpeg":
+		return "image/jpeg"
+	case "image/heif":
+		return "image/heic"
+	case "image/x-png":
+		return "image/png"
+	case "image/tif", "image/x-tiff", "image/x-adobe-dng", "image/dng":
+		// DNG는 TIFF 구조이므로 내용으로는 TIFF와 구분하지 않습니다.
+		return "image/tiff"
+	}
+	return media
+}
+
+// sniffContentType은 내용의 매직 바이트로 Content-Type을 알아냅니다. 알 수 없으면 빈 문자열입니다.
+func sniffContentType(data []byte) string {
+	switch {
+	case len(data) >= 3 && data[0] == 0xff && data[1] == 0xd8 && data[2] == 0xff:
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")):
+		return "image/gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp"
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		switch brand := string(data[8:12]); {
+		case brand == "avif" || brand == "avis" || (heifBrands[brand] && avifCompatible(data)):
+			return "image/avif"
+		case heifBrands[brand]:
+			return "image/heic"
+		case brand == "qt  ":
+			return "video/quicktime"
+		default:
+			return "video/mp4"
+		}
+	case bytes.HasPrefix(data, []byte{0xff, 0x0a}) || bytes.HasPrefix(data, []byte("\x00\x00\x00\x0cJXL \r\n\x87\n")):
+		return "image/jxl"
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		return "image/tiff"
+	case bytes.HasPrefix(data, []byte("BM")) && len(data) >= 14:
+		return "image/bmp"
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return "application/pdf"
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return "application/zip"
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return "application/gzip"
+	}
+	head := data[:min(len(data), 1024)]
+	if bytes.Contains(head, []byte("<svg")) && bytes.HasPrefix(bytes.TrimSpace(head), []byte("<")) {
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+// avifCompatible은 ftyp 상자의 호환 brand에 avif가 있는지 확인합니다. 일부 인코더는 major brand를 mif1로 씁니다.
+func avifCompatible(data []byte) bool {
+	size := min(int(binary.BigEndian.Uint32(data[:4])), len(data))
+	for i := 16; i+4 <= size; i += 4 {
+		if brand := string(data[i : i+4]); brand == "avif" || brand == "avis" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/vipsgen/vips"
+
+	"github.com/berryssoda/test-encode/pipeline"
+)
+
+// SpriteRequest는 "mode": "sprite" 요청의 설정입니다. Keys가 있으면 그 이미지들을, 없으면 s3Key의 동영상에서
+// Interval초마다 뽑은 프레임을 순서대로 한 장의 스프라이트 시트로 배치합니다.
+type SpriteRequest struct {
+	Keys       []string `json:"keys,omitempty"`       // 프레임으로 쓸 이미지 키 (s3Bucket 기준)
+	Interval   float64  `json:"interval,omitempty"`   // 프레임 간격(초), 기본 5. Keys에서는 WebVTT 시각 계산에만 씁니다.
+	Columns    int      `json:"columns,omitempty"`    // 한 줄의 타일 수, 기본 10
+	TileWidth  int      `json:"tileWidth,omitempty"`  // 기본 160
+	TileHeight int      `json:"tileHeight,omitempty"` // 0이면 첫 프레임 비율을 따릅니다.
+	Format     string   `json:"format,omitempty"`     // 기본 jpeg
+	Quality    int      `json:"quality,omitempty"`    // 0이면 포맷별 기본 품질
+	MaxFrames  int      `json:"maxFrames,omitempty"`  // 기본 SPRITE_MAX_FRAMES
+}
+
+// spriteIndex는 스프라이트 시트와 함께 올리는 JSON 색인입니다.
+type spriteIndex struct {
+	Image      string        `json:"image"`
+	TileWidth  int           `json:"tileWidth"`
+	TileHeight int           `json:"tileHeight"`
+	Columns    int           `json:"columns"`
+	Rows       int           `json:"rows"`
+	Frames     []spriteFrame `json:"frames"`
+}
+
+type spriteFrame struct {
+	Start float64 `json:"start"` // 초
+	End   float64 `json:"end"`
+	Key   string  `json:"key,omitempty"`
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+}
+
+// Sprite는 프레임을 격자로 배치한 스프라이트 시트와 WebVTT(#xywh=)·JSON 색인을 올립니다.
+// 출력 키는 outputKey(없으면 s3Key)에서 확장자를 바꾼 <키>.sprite.<포맷>, .sprite.vtt, .sprite.json입니다.
+func (h *Handler) Sprite(ctx context.Context, event S3Event) (ConversionResult, error) {
+	req := SpriteRequest{}
+	if event.Sprite != nil {
+		req = *event.Sprite
+	}
+	req.withDefaults(h.conf)
+	if req.Columns < 1 || req.TileWidth < 1 || req.TileHeight < 0 || req.Interval <= 0 || req.MaxFrames < 1 {
+		return ConversionResult{}, fmt.Errorf("invalid event: sprite columns, tileWidth, interval and maxFrames must be positive")
+	}
+	if req.MaxFrames > h.conf.SpriteMaxFrames {
+		return ConversionResult{}, fmt.Errorf("invalid event: sprite maxFrames %d is more than SPRITE_MAX_FRAMES %d", req.MaxFrames, h.conf.SpriteMaxFrames)
+	}
+	encoder, err := h.encoders.Get(req.Format)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	srcKey, err := h.decodeKey(event.S3Key, event.S3KeyEncoded)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	base := event.OutputKey
+	if base == "" {
+		base = srcKey
+	}
+	if base == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: sprite requires s3Key or outputKey")
+	}
+	base = h.outputKey(base)
+
+	frames, err := h.spriteFrames(ctx, event.S3Bucket, srcKey, req)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	defer func() {
+		for _, f := range frames {
+			f.Close()
+		}
+	}()
+	if len(frames) == 0 {
+		return ConversionResult{}, fmt.Errorf("no frames for sprite sheet")
+	}
+
+	if req.TileHeight == 0 {
+		req.TileHeight = max(1, int(math.Round(float64(req.TileWidth)*float64(frames[0].Height())/float64(frames[0].Width()))))
+	}
+	for i, f := range frames {
+		if err := pipeline.Resize(f, req.TileWidth, req.TileHeight, "cover", true, ""); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to resize sprite frame %d: %w", i, err)
+		}
+	}
+	options := vips.DefaultArrayjoinOptions()
+	options.Across = min(req.Columns, len(frames))
+	sheet, err := vips.NewArrayjoin(frames, options)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to join sprite frames: %w", err)
+	}
+	defer sheet.Close()
+	if _, err := applyAlphaPolicy(sheet, req.Format, h.conf.AlphaBackground, h.conf); err != nil {
+		return ConversionResult{}, err
+	}
+	encoded, err := encoder.Encode(sheet, EncodeOptions{Keep: vips.KeepNone, Effort: h.conf.AVIFEffort, Quality: req.Quality})
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode sprite sheet to %s: vips_error: %s", strings.ToUpper(req.Format), err)
+	}
+
+	imageKey := replaceExtension(base, ".sprite"+extensionOf(req.Format))
+	index := spriteIndex{
+		Image:      path.Base(imageKey),
+		TileWidth:  req.TileWidth,
+		TileHeight: req.TileHeight,
+		Columns:    options.Across,
+		Rows:       (len(frames) + options.Across - 1) / options.Across,
+	}
+	for i := range frames {
+		frame := spriteFrame{
+			Start: float64(i) * req.Interval,
+			End:   float64(i+1) * req.Interval,
+			X:     i % options.Across * req.TileWidth,
+			Y:     i / options.Across * req.TileHeight,
+		}
+		if len(req.Keys) > 0 {
+			frame.Key = req.Keys[i]
+		}
+		index.Frames = append(index.Frames, frame)
+	}
+	indexBody, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to encode sprite index: %w", err)
+	}
+
+	if _, err := h.uploadObject(ctx, event.S3Bucket, imageKey, req.Format, encoded.Data, h.outputAttrs(nil)); err != nil {
+		return ConversionResult{}, err
+	}
+	outputs := []OutputResult{{Key: imageKey, Format: req.Format, Size: int64(len(encoded.Data)), Width: sheet.Width(), Height: sheet.Height()}}
+	for _, f := range []struct {
+		ext, contentType string
+		body             []byte
+	}{
+		{"vtt", "text/vtt", spriteVTT(index)},
+		{"json", "application/json", indexBody},
+	} {
+		key := replaceExtension(base, ".sprite."+f.ext)
+		if err := h.putObject(ctx, event.S3Bucket, key, f.contentType, f.body); err != nil {
+			return ConversionResult{}, fmt.Errorf("failed to upload sprite index %s: %w", key, err)
+		}
+		outputs = append(outputs, OutputResult{Key: key, Format: f.ext, Size: int64(len(f.body))})
+	}
+
+	msg := fmt.Sprintf("Sprite sheet with %d frames (%dx%d tiles, %d columns)", len(frames), req.TileWidth, req.TileHeight, index.Columns)
+	log.Println(msg)
+	return ConversionResult{
+		Status:      StatusSpriteCreated,
+		OriginalKey: srcKey,
+		NewKey:      imageKey,
+		Format:      req.Format,
+		Outputs:     outputs,
+		Message:     msg,
+	}, nil
+}
+
+func (r *SpriteRequest) withDefaults(c Config) {
+	if r.Interval == 0 {
+		r.Interval = 5
+	}
+	if r.Columns == 0 {
+		r.Columns = 10
+	}
+	if r.TileWidth == 0 {
+		r.TileWidth = 160
+	}
+	if r.Format == "" {
+		r.Format = "jpeg"
+	}
+	if r.MaxFrames == 0 {
+		r.MaxFrames = c.SpriteMaxFrames
+	}
+}
+
+// spriteFrames는 Keys의 이미지나 동영상에서 뽑은 프레임을 디코딩해 돌려줍니다.
+func (h *Handler) spriteFrames(ctx context.Context, bucket, srcKey string, req SpriteRequest) ([]*vips.Image, error) {
+	var sources [][]byte
+	if len(req.Keys) > 0 {
+		if len(req.Keys) > req.MaxFrames {
+			return nil, fmt.Errorf("invalid event: sprite has %d keys, more than maxFrames %d", len(req.Keys), req.MaxFrames)
+		}
+		for _, key := range req.Keys {
+			data, err := h.downloadObject(ctx, bucket, key)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, data)
+		}
+	} else {
+		if h.conf.FFmpegPath == "" {
+			return nil, fmt.Errorf("sprite from video requires FFMPEG_PATH")
+		}
+		video, err := h.downloadObject(ctx, bucket, srcKey)
+		if err != nil {
+			return nil, err
+		}
+		if sources, err = h.videoFrames(ctx, video, req); err != nil {
+			return nil, err
+		}
+	}
+
+	frames := make([]*vips.Image, 0, len(sources))
+	for i, data := range sources {
+		frame, err := vips.NewImageFromBuffer(data, nil)
+		if err == nil {
+			err = frame.Autorot()
+		}
+		if err != nil {
+			for _, f := range frames {
+				f.Close()
+			}
+			return nil, fmt.Errorf("failed to load sprite frame %d: %w", i, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// videoFrames는 동영상에서 req.Interval초마다 최대 req.MaxFrames개의 프레임을 PNG로 뽑습니다.
+func (h *Handler) videoFrames(ctx context.Context, video []byte, req SpriteRequest) ([][]byte, error) {
+	dir, err := os.MkdirTemp("", "sprite-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary video directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	if err := os.WriteFile(source, video, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temporary video file: %w", err)
+	}
+	err = runFFmpeg(ctx, h.conf.FFmpegPath, nil, "-i", source, "-an",
+		"-vf", "fps=1/"+strconv.FormatFloat(req.Interval, 'f', -1, 64),
+		"-frames:v", strconv.Itoa(req.MaxFrames),
+		filepath.Join(dir, "frame-%04d.png"))
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to extract sprite frames: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "frame-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	frames := make([][]byte, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, data)
+	}
+	return frames, nil
+}
+
+// spriteVTT는 플레이어의 탐색 미리보기용 WebVTT입니다. 각 큐는 시트 파일 이름과 #xywh= 영역입니다.
+func spriteVTT(index spriteIndex) []byte {
+	var b bytes.Buffer
+	b.WriteString("WEBVTT\n")
+	for _, f := range index.Frames {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", vttTime(f.Start), vttTime(f.End), index.Image, f.X, f.Y, index.TileWidth, index.TileHeight)
+	}
+	return b.Bytes()
+}
+
+// vttTime은 초를 WebVTT 시각(HH:MM:SS.mmm)으로 바꿉니다.
+func vttTime(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
@@ -0,0 +1,157 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// resultSchemaVersion은 ConversionResult JSON의 스키마 버전입니다. (schemaVersion)
+// 필드를 더하는 것은 호환되는 변경이므로 올리지 않고, 필드를 빼거나 의미·형식을 바꿀 때만 올립니다.
+// 소비자는 모르는 필드와 모르는 status 값을 무시해야 합니다. schemaVersion이 없는 결과는 버전 1입니다.
+const resultSchemaVersion = 2
+
+// Status는 ConversionResult의 상태 코드입니다. 값은 아래 상수 중 하나이며, 새 값은 뒤에 더하기만 합니다.
+// SKIPPED_*와 REJECTED_DECODE_LIMIT는 오류 없이 변환하지 않은 경우입니다. (Skipped 참고)
+type Status string
+
+// 변환 결과
+const (
+	StatusConverted         Status = "CONVERTED"          // 출력을 만들어 올림
+	StatusArchiveConverted  Status = "ARCHIVE_CONVERTED"  // ZIP 원본의 항목을 변환함 (항목별 결과는 items)
+	StatusCopied            Status = "COPIED"             // TRANSFORM_RULES copy: 원본 바이트를 그대로 올림
+	StatusMetadataExtracted Status = "METADATA_EXTRACTED" // "mode": "metadata"
+	StatusQuarantined       Status = "QUARANTINED"        // 계속 실패한 원본을 격리함
+	StatusFailed            Status = "FAILED"             // 배치·아카이브 항목의 실패 (errors에 코드)
+)
+
+// 변환하지 않은 결과
+const (
+	StatusSkippedAlreadyAVIF      Status = "SKIPPED_ALREADY_AVIF"
+	StatusSkippedAlreadyOptimized Status = "SKIPPED_ALREADY_OPTIMIZED"
+	StatusSkippedArchivedOriginal Status = "SKIPPED_ARCHIVED_ORIGINAL"
+	StatusSkippedDebugArtifact    Status = "SKIPPED_DEBUG_ARTIFACT"
+	StatusSkippedDuplicateEvent   Status = "SKIPPED_DUPLICATE_EVENT"
+	StatusSkippedEmptyObject      Status = "SKIPPED_EMPTY_OBJECT"
+	StatusSkippedFiltered         Status = "SKIPPED_FILTERED"
+	StatusSkippedNotImage         Status = "SKIPPED_NOT_IMAGE"
+	StatusSkippedQuarantined      Status = "SKIPPED_QUARANTINED"
+	StatusSkippedReplica          Status = "SKIPPED_REPLICA"
+	StatusSkippedRule             Status = "SKIPPED_RULE"
+	StatusSkippedSelfTest         Status = "SKIPPED_SELFTEST"
+	StatusRejectedDecodeLimit     Status = "REJECTED_DECODE_LIMIT"
+	// StatusRetryAfterRestore는 보관된 원본의 복원을 요청했거나 복원 중인 경우입니다. retryAfter 뒤에 다시 요청합니다.
+	StatusRetryAfterRestore Status = "RETRY_AFTER_RESTORE"
+)
+
+// 변환 외 모드의 결과
+const (
+	StatusBatchCompleted     Status = "BATCH_COMPLETED"
+	StatusBackfillCompleted  Status = "BACKFILL_COMPLETED"
+	StatusBackfillPartial    Status = "BACKFILL_PARTIAL"
+	StatusGCCompleted        Status = "GC_COMPLETED"
+	StatusCampaignCompleted  Status = "CAMPAIGN_COMPLETED"
+	StatusCampaignInProgress Status = "CAMPAIGN_IN_PROGRESS"
+	StatusCampaignExpired    Status = "CAMPAIGN_EXPIRED"
+	StatusTilesCreated       Status = "TILES_CREATED"
+	StatusWarmedUp           Status = "WARMED_UP"
+	StatusBenchmarked        Status = "BENCHMARKED"
+	StatusReported           Status = "REPORTED"
+	StatusSpriteCreated      Status = "SPRITE_CREATED"
+	StatusMontageCreated     Status = "MONTAGE_CREATED"
+	StatusCompared           Status = "COMPARED"
+	StatusRegressionPassed   Status = "REGRESSION_PASSED"
+	StatusRegressionFailed   Status = "REGRESSION_FAILED"
+	StatusBaselineUpdated    Status = "BASELINE_UPDATED"
+	StatusHealthy            Status = "HEALTHY"
+	StatusUnhealthy          Status = "UNHEALTHY"
+	StatusInfo               Status = "INFO"
+)
+
+// Skipped는 오류 없이 변환을 건너뛴 상태인지 확인합니다.
+func (s Status) Skipped() bool {
+	return strings.HasPrefix(string(s), "SKIPPED_") || s == StatusRejectedDecodeLimit
+}
+
+// ErrorCode는 errors 항목의 기계 판독용 오류 코드입니다. 메시지는 바뀔 수 있으므로 코드로 구분해야 합니다.
+type ErrorCode string
+
+const (
+	ErrorInvalidEvent             ErrorCode = "INVALID_EVENT"
+	ErrorSourceNotFound           ErrorCode = "SOURCE_NOT_FOUND"
+	ErrorSourceArchived           ErrorCode = "SOURCE_ARCHIVED"
+	ErrorAccessDenied             ErrorCode = "ACCESS_DENIED"
+	ErrorTimeoutBudgetExceeded    ErrorCode = "TIMEOUT_BUDGET_EXCEEDED"
+	ErrorDeadlineExceeded         ErrorCode = "DEADLINE_EXCEEDED"
+	ErrorRateLimited              ErrorCode = "RATE_LIMITED"
+	ErrorEncoderCircuitOpen       ErrorCode = "ENCODER_CIRCUIT_OPEN"
+	ErrorOutputTooLarge           ErrorCode = "OUTPUT_TOO_LARGE"
+	ErrorUploadVerificationFailed ErrorCode = "UPLOAD_VERIFICATION_FAILED"
+	ErrorInternal                 ErrorCode = "INTERNAL"
+)
+
+// ResultError는 결과의 errors 항목 하나입니다. Retryable이면 같은 요청을 다시 보내 성공할 수 있습니다.
+type ResultError struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Key       string    `json:"key,omitempty"` // 배치·아카이브에서 실패한 항목의 키
+	Retryable bool      `json:"retryable,omitempty"`
+}
+
+// resultError는 오류를 코드로 분류합니다.
+func resultError(err error, key string) ResultError {
+	return ResultError{Code: errorCode(err), Message: err.Error(), Key: key, Retryable: transientFailure(err)}
+}
+
+func errorCode(err error) ErrorCode {
+	var budget *TimeoutBudgetExceeded
+	var limited *RateLimited
+	var circuit *EncoderCircuitOpen
+	var tooLarge *OutputTooLarge
+	var verify *UploadVerificationFailed
+	var archived *SourceArchived
+	var noKey *types.NoSuchKey
+	var api smithy.APIError
+	switch {
+	case strings.HasPrefix(err.Error(), "invalid event:"):
+		return ErrorInvalidEvent
+	case errors.As(err, &budget):
+		return ErrorTimeoutBudgetExceeded
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorDeadlineExceeded
+	case errors.As(err, &limited):
+		return ErrorRateLimited
+	case errors.As(err, &circuit):
+		return ErrorEncoderCircuitOpen
+	case errors.As(err, &tooLarge):
+		return ErrorOutputTooLarge
+	case errors.As(err, &verify):
+		return ErrorUploadVerificationFailed
+	case errors.As(err, &archived):
+		return ErrorSourceArchived
+	case errors.As(err, &noKey):
+		return ErrorSourceNotFound
+	case errors.As(err, &api) && (api.ErrorCode() == "NotFound" || api.ErrorCode() == "NoSuchKey"):
+		return ErrorSourceNotFound
+	case errors.As(err, &api) && api.ErrorCode() == "AccessDenied":
+		return ErrorAccessDenied
+	default:
+		return ErrorInternal
+	}
+}
+
+// failedResult는 배치·아카이브 항목 하나의 실패 결과입니다.
+func failedResult(tenant, key string, err error) ConversionResult {
+	return ConversionResult{Status: StatusFailed, Tenant: tenant, OriginalKey: key, Message: err.Error(), Errors: []ResultError{resultError(err, key)}}
+}
+
+// stamp는 결과와 항목별 결과에 스키마 버전을 채웁니다. 밖으로 나가는 결과마다 한 번 호출합니다.
+func (r *ConversionResult) stamp() {
+	r.SchemaVersion = resultSchemaVersion
+	for i := range r.Items {
+		r.Items[i].stamp()
+	}
+}
@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// objectAttrs는 출력 객체에 붙이는 S3 속성입니다. 비어 있는 필드는 버킷 기본값을 따릅니다.
+type objectAttrs struct {
+	StorageClass types.StorageClass
+	// LockMode와 RetainUntil은 Object Lock 보존 설정이고, LegalHold는 법적 보존입니다.
+	// 대상 버킷에 Object Lock이 켜져 있어야 합니다.
+	LockMode    types.ObjectLockMode
+	RetainUntil *time.Time
+	LegalHold   types.ObjectLockLegalHoldStatus
+	// CacheControl은 nil이 아니면 Cache-Control 헤더로 저장합니다.
+	CacheControl *string
+}
+
+// outputAttrs는 출력 이미지에 적용할 속성입니다. 테넌트 설정이 OUTPUT_STORAGE_CLASS보다 우선합니다.
+// 보존 기한은 업로드 시각부터 OUTPUT_RETENTION_DAYS일입니다.
+func (h *Handler) outputAttrs(t *Tenant) objectAttrs {
+	attrs := objectAttrs{StorageClass: types.StorageClass(h.conf.OutputStorageClass)}
+	if t != nil && t.StorageClass != "" {
+		attrs.StorageClass = types.StorageClass(t.StorageClass)
+	}
+	if h.conf.OutputLockMode != "" {
+		attrs.LockMode = types.ObjectLockMode(h.conf.OutputLockMode)
+		attrs.RetainUntil = aws.Time(h.clock.Now().UTC().AddDate(0, 0, h.conf.OutputRetentionDays))
+	}
+	if h.conf.OutputLegalHold {
+		attrs.LegalHold = types.ObjectLockLegalHoldStatusOn
+	}
+	return attrs
+}
+
+// knownStorageClass는 SDK가 아는 S3 저장 클래스인지 확인합니다.
+func knownStorageClass(class string) bool {
+	return slices.Contains(types.StorageClass("").Values(), types.StorageClass(class))
+}
+
+// objectLockHint는 Object Lock 때문에 업로드가 거부된 경우 고칠 방법을 오류에 덧붙입니다.
+func objectLockHint(err error, attrs objectAttrs) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "object lock") {
+		return err
+	}
+	if attrs.LockMode == "" && attrs.LegalHold == "" {
+		return fmt.Errorf("%w (the destination bucket enforces Object Lock; set OUTPUT_OBJECT_LOCK_MODE and OUTPUT_RETENTION_DAYS or OUTPUT_LEGAL_HOLD)", err)
+	}
+	return fmt.Errorf("%w (check that Object Lock is enabled on the destination bucket and the role has s3:PutObjectRetention / s3:PutObjectLegalHold)", err)
+}
@@ -0,0 +1,242 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName은 span과 지표의 계측 범위 이름입니다.
+const instrumentationName = "github.com/berryssoda/test-encode"
+
+// telemetryFlushTimeout은 호출이 끝날 때 span과 지표를 내보내는 데 쓰는 최대 시간입니다.
+// Lambda는 응답을 돌려준 뒤 실행 환경을 멈추므로, 그 전에 내보내지 않으면 다음 호출까지 남거나 사라집니다.
+const telemetryFlushTimeout = 2 * time.Second
+
+// tracer와 phaseDuration 등은 전역 공급자에서 얻습니다. OTLP 내보내기를 설정하지 않으면 아무것도 하지 않는 구현입니다.
+var (
+	tracer = otel.Tracer(instrumentationName)
+	meter  = otel.Meter(instrumentationName)
+
+	phaseDuration, _ = meter.Float64Histogram("thumbnail.phase.duration",
+		metric.WithUnit("ms"), metric.WithDescription("Duration of one conversion phase"))
+	conversions, _ = meter.Int64Counter("thumbnail.conversions",
+		metric.WithDescription("Conversion requests by result status"))
+	objectBytes, _ = meter.Int64Histogram("thumbnail.object.size",
+		metric.WithUnit("By"), metric.WithDescription("Size of downloaded sources and uploaded outputs"))
+	failures, _ = meter.Int64Counter("thumbnail.failures",
+		metric.WithDescription("Failed conversion requests by error type"))
+	bytesSaved, _ = meter.Int64Counter("thumbnail.bytes.saved",
+		metric.WithUnit("By"), metric.WithDescription("Source bytes minus primary output bytes of converted images"))
+)
+
+// telemetry는 OTLP와 Prometheus로 span과 지표를 내보내는 공급자입니다. 설정하지 않았으면 nil이며, 메서드는 nil에서도 동작합니다.
+// traces는 OTLP를 설정한 경우에만 있습니다.
+type telemetry struct {
+	traces  *sdktrace.TracerProvider
+	metrics *sdkmetric.MeterProvider
+	// pusher와 server는 PROMETHEUS_PUSH_URL, PROMETHEUS_LISTEN_ADDR을 설정한 경우에만 있습니다.
+	pusher *push.Pusher
+	server *http.Server
+}
+
+// telemetryEnabled는 OTLP나 Prometheus 내보내기 중 하나라도 설정되었는지 돌려줍니다.
+func telemetryEnabled(c Config) bool {
+	return c.OTelEnabled || c.PrometheusPushURL != "" || c.PrometheusListenAddr != ""
+}
+
+// setupTelemetry는 내보내기를 만들고 전역 공급자로 등록합니다.
+// OTLP는 표준 OTEL_* 환경 변수(OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS,
+// OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES 등)를 따르고, Prometheus는 같은 지표를 텍스트 형식으로
+// PROMETHEUS_PUSH_URL에 밀어 넣거나 PROMETHEUS_LISTEN_ADDR에서 내어 줍니다.
+func setupTelemetry(ctx context.Context, c Config) (*telemetry, error) {
+	function := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", function),
+			attribute.String("faas.name", function),
+			attribute.String("faas.version", os.Getenv("AWS_LAMBDA_FUNCTION_VERSION")),
+			attribute.String("cloud.provider", "aws"),
+			attribute.String("cloud.region", os.Getenv("AWS_REGION")),
+		),
+		// OTEL_SERVICE_NAME과 OTEL_RESOURCE_ATTRIBUTES가 위 기본값보다 우선합니다.
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry resource: %w", err)
+	}
+	t := &telemetry{}
+	options := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	if c.OTelEnabled {
+		traceExporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+		}
+		metricExporter, err := otlpmetrichttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+		}
+		t.traces = sdktrace.NewTracerProvider(sdktrace.WithResource(res), sdktrace.WithBatcher(traceExporter))
+		// 주기적 내보내기 대신 호출마다 Flush로 내보내므로 간격은 길게 둡니다.
+		options = append(options, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(time.Minute))))
+	}
+	if c.PrometheusPushURL != "" || c.PrometheusListenAddr != "" {
+		registry := prometheus.NewRegistry()
+		exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+		}
+		options = append(options, sdkmetric.WithReader(exporter))
+		if c.PrometheusPushURL != "" {
+			// 카운터는 실행 환경마다 누적되므로 로그 스트림(실행 환경) 단위로 그룹을 나눠 덮어씁니다.
+			t.pusher = push.New(c.PrometheusPushURL, c.PrometheusJob).Gatherer(registry).
+				Grouping("instance", os.Getenv("AWS_LAMBDA_LOG_STREAM_NAME"))
+		}
+		if c.PrometheusListenAddr != "" {
+			listener, err := net.Listen("tcp", c.PrometheusListenAddr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to listen on PROMETHEUS_LISTEN_ADDR %s: %w", c.PrometheusListenAddr, err)
+			}
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			t.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+			go func() {
+				if err := t.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Printf("Warning: Prometheus metrics endpoint stopped: %v", err)
+				}
+			}()
+			log.Printf("Serving Prometheus metrics on http://%s/metrics", listener.Addr())
+		}
+	}
+	t.metrics = sdkmetric.NewMeterProvider(options...)
+	if t.traces != nil {
+		otel.SetTracerProvider(t.traces)
+	}
+	otel.SetMeterProvider(t.metrics)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return t, nil
+}
+
+// Flush는 쌓인 span과 지표를 내보냅니다. 응답을 돌려주기 전에 호출하며, 요청이 취소되어도 끝까지 보냅니다.
+func (t *telemetry) Flush(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), telemetryFlushTimeout)
+	defer cancel()
+	if t.traces != nil {
+		if err := t.traces.ForceFlush(ctx); err != nil {
+			log.Printf("Warning: failed to flush traces: %v", err)
+		}
+	}
+	if err := t.metrics.ForceFlush(ctx); err != nil {
+		log.Printf("Warning: failed to flush metrics: %v", err)
+	}
+	if t.pusher != nil {
+		if err := t.pusher.PushContext(ctx); err != nil {
+			log.Printf("Warning: failed to push Prometheus metrics: %v", err)
+		}
+	}
+}
+
+// Shutdown은 실행 환경이 종료될 때 남은 데이터를 내보내고 공급자를 닫습니다.
+func (t *telemetry) Shutdown() {
+	if t == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+	defer cancel()
+	if t.traces != nil {
+		if err := t.traces.Shutdown(ctx); err != nil {
+			log.Printf("Warning: failed to shut down tracer provider: %v", err)
+		}
+	}
+	if t.pusher != nil {
+		if err := t.pusher.PushContext(ctx); err != nil {
+			log.Printf("Warning: failed to push Prometheus metrics: %v", err)
+		}
+	}
+	if t.server != nil {
+		t.server.Shutdown(ctx)
+	}
+	if err := t.metrics.Shutdown(ctx); err != nil {
+		log.Printf("Warning: failed to shut down meter provider: %v", err)
+	}
+}
+
+// startPhase는 변환 단계 하나의 span을 시작합니다. 단계가 끝나면 돌려준 end를 결과 오류와 함께 호출하며,
+// 이때 span을 닫고 thumbnail.phase.duration에 걸린 시간을 남깁니다.
+func startPhase(ctx context.Context, phase string, attrs ...attribute.KeyValue) (context.Context, func(error, ...attribute.KeyValue)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, phase, trace.WithAttributes(attrs...))
+	return ctx, func(err error, more ...attribute.KeyValue) {
+		span.SetAttributes(more...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		phaseDuration.Record(ctx, float64(time.Since(start).Microseconds())/1000, metric.WithAttributes(attribute.String("phase", phase)))
+	}
+}
+
+// recordObjectSize는 원본이나 출력 하나의 크기를 지표로 남깁니다. kind는 "source" | "output"입니다.
+func recordObjectSize(ctx context.Context, kind, format string, size int) {
+	objectBytes.Record(ctx, int64(size), metric.WithAttributes(attribute.String("kind", kind), attribute.String("format", format)))
+}
+
+// recordOutcome은 변환 요청 하나의 결과를 지표로 남깁니다. 실패는 오류 타입별로, 변환 성공은 절감한 바이트를 셉니다.
+func recordOutcome(ctx context.Context, job *Job, result ConversionResult, err error) {
+	status := result.Status
+	if status == "" {
+		status = StatusFailed
+	}
+	conversions.Add(ctx, 1, metric.WithAttributes(attribute.String("status", string(status)), attribute.String("tenant", result.Tenant)))
+	if err != nil {
+		failures.Add(ctx, 1, metric.WithAttributes(attribute.String("error.type", errorType(err)), attribute.String("tenant", result.Tenant)))
+		return
+	}
+	if result.Status != StatusConverted {
+		return
+	}
+	for _, o := range result.Outputs {
+		if o.Key == result.NewKey {
+			bytesSaved.Add(ctx, int64(len(job.Source))-o.Size, metric.WithAttributes(attribute.String("format", o.Format), attribute.String("tenant", result.Tenant)))
+		}
+	}
+}
+
+// errorType은 fmt.Errorf로 감싼 오류를 벗겨 낸 오류 타입 이름(TimeoutBudgetExceeded 등)입니다.
+func errorType(err error) string {
+	for {
+		name := strings.TrimPrefix(fmt.Sprintf("%T", err), "*")
+		inner := errors.Unwrap(err)
+		if inner == nil || (name != "fmt.wrapError" && name != "fmt.wrapErrors") {
+			return name
+		}
+		err = inner
+	}
+}
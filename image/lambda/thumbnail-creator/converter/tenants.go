@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Tenant는 한 제품 팀의 설정 블록입니다. 원본 버킷이나 키 접두사로 요청에 연결됩니다.
+// 비어 있는 필드는 함수 전체 설정을 따릅니다.
+type Tenant struct {
+	Name string `json:"-"`
+	// Buckets와 Prefixes는 이 테넌트에 속하는 원본 버킷과 키 접두사입니다. 둘 다 있으면 둘 다 맞아야 합니다.
+	Buckets  []string `json:"buckets,omitempty"`
+	Prefixes []string `json:"prefixes,omitempty"`
+	// OutputBucket과 OutputPrefix는 출력 위치입니다. 비어 있으면 원본 버킷, 원본 키 그대로입니다.
+	// OutputPrefix는 이벤트에 outputKey가 없을 때만 붙습니다.
+	OutputBucket string `json:"outputBucket,omitempty"`
+	OutputPrefix string `json:"outputPrefix,omitempty"`
+	// Presets는 같은 이름의 전체 프리셋보다 우선합니다.
+	Presets map[string]Preset `json:"presets,omitempty"`
+	// Quality는 프리셋·파이프라인이 품질을 정하지 않았을 때의 기본 품질입니다. 0이면 포맷별 기본값입니다.
+	Quality int `json:"quality,omitempty"`
+	// EventBus는 이 테넌트의 완료 이벤트를 보낼 EventBridge 버스입니다. 비어 있으면 EVENT_BUS_NAME입니다.
+	EventBus string `json:"eventBus,omitempty"`
+	// StorageClass는 이 테넌트 출력의 S3 저장 클래스입니다. 비어 있으면 OUTPUT_STORAGE_CLASS입니다.
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// parseTenants는 {"이름": Tenant} 형식의 JSON을 읽어 검증합니다.
+func parseTenants(data []byte) (map[string]*Tenant, error) {
+	var tenants map[string]*Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, err
+	}
+	for name, t := range tenants {
+		t.Name = name
+		if len(t.Buckets) == 0 && len(t.Prefixes) == 0 {
+			return nil, fmt.Errorf("tenant %q: at least one of buckets or prefixes is required", name)
+		}
+		if t.Quality < 0 || t.Quality > 100 {
+			return nil, fmt.Errorf("tenant %q: quality must be between 1 and 100", name)
+		}
+		if t.StorageClass != "" && !knownStorageClass(t.StorageClass) {
+			return nil, fmt.Errorf("tenant %q: unknown storage class %q", name, t.StorageClass)
+		}
+		for presetName, p := range t.Presets {
+			if err := p.normalize(); err != nil {
+				return nil, fmt.Errorf("tenant %q preset %q: %w", name, presetName, err)
+			}
+			t.Presets[presetName] = p
+		}
+	}
+	return tenants, nil
+}
+
+// resolveTenant는 원본 버킷과 키에 맞는 테넌트를 찾습니다. 없으면 nil입니다.
+// 여러 테넌트가 맞으면 더 긴 키 접두사가 맞은 쪽, 같으면 이름순으로 앞선 쪽을 고릅니다.
+func resolveTenant(tenants map[string]*Tenant, bucket, key string) *Tenant {
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var best *Tenant
+	bestPrefix := -1
+	for _, name := range names {
+		t := tenants[name]
+		if len(t.Buckets) > 0 && !slices.Contains(t.Buckets, bucket) {
+			continue
+		}
+		matched := 0
+		if len(t.Prefixes) > 0 {
+			matched = -1
+			for _, p := range t.Prefixes {
+				if strings.HasPrefix(key, p) && len(p) > matched {
+					matched = len(p)
+				}
+			}
+			if matched < 0 {
+				continue
+			}
+		}
+		if matched > bestPrefix {
+			best, bestPrefix = t, matched
+		}
+	}
+	return best
+}
+
+// tenantEventBuses는 자체 이벤트 버스를 가진 테넌트가 있는지 확인합니다.
+func tenantEventBuses(tenants map[string]*Tenant) bool {
+	for _, t := range tenants {
+		if t.EventBus != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// preset은 테넌트 프리셋을 먼저 찾고, 없으면 전체 프리셋을 찾습니다.
+func (t *Tenant) preset(name string, global map[string]Preset) (Preset, bool) {
+	if t != nil {
+		if p, ok := t.Presets[name]; ok {
+			return p, true
+		}
+	}
+	p, ok := global[name]
+	return p, ok
+}
@@ -0,0 +1,131 @@
+//go:build ignore
+
+// gen_fixtures는 converter 테스트의 원본 이미지를 표준 라이브러리만으로 다시 만듭니다.
+//
+//	cd converter/testdata && go run gen_fixtures.go
+//
+// cmyk.jpg는 Go 배포본의 image/testdata/video-001.cmyk.jpeg(BSD 라이선스)를 복사합니다.
+// 표준 라이브러리에 인코더가 없는 WebP(alpha.webp), HEIC는 여기서 만들지 않습니다. (README.txt 참고)
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+func main() {
+	write("photo.jpg", encodeJPEG(gradient(64, 48)))
+	write("alpha.png", encodePNG(transparentCircle(32, 32)))
+	write("palette-alpha.png", encodePNG(transparentSquare(32, 32)))
+	write("animated.gif", encodeGIF(24, 16, []color.Color{
+		color.RGBA{0xe0, 0x30, 0x30, 0xff},
+		color.RGBA{0x30, 0xe0, 0x30, 0xff},
+		color.RGBA{0x30, 0x30, 0xe0, 0xff},
+	}))
+	// SOI 뒤에 프레임 헤더가 없는 JPEG입니다. libjpeg가 헤더를 읽다가 실패합니다.
+	write("corrupt.jpg", append([]byte{0xff, 0xd8}, make([]byte, 64)...))
+
+	cmyk, err := os.ReadFile(filepath.Join(runtime.GOROOT(), "src", "image", "testdata", "video-001.cmyk.jpeg"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	write("cmyk.jpg", cmyk)
+}
+
+func write(name string, data []byte) {
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s (%d bytes)", name, len(data))
+}
+
+// gradient는 색상 수가 많아 그래픽으로 판정되지 않는 사진 대용 이미지입니다.
+func gradient(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) * 127 / (w + h)), 0xff})
+		}
+	}
+	return img
+}
+
+// transparentCircle은 가운데 불투명한 원, 반투명한 테두리, 완전히 투명한 바깥으로 된 RGBA 이미지입니다.
+func transparentCircle(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	cx, cy, r := w/2, h/2, w/3
+	for y := range h {
+		for x := range w {
+			d := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+			switch {
+			case d <= r*r:
+				img.SetNRGBA(x, y, color.NRGBA{0xd0, 0x20, 0x40, 0xff})
+			case d <= (r+3)*(r+3):
+				img.SetNRGBA(x, y, color.NRGBA{0xd0, 0x20, 0x40, 0x80})
+			}
+		}
+	}
+	return img
+}
+
+// transparentSquare는 투명 색(tRNS)을 가진 팔레트 PNG가 되도록 팔레트 이미지로 만든 투명 바탕의 사각형입니다.
+func transparentSquare(w, h int) image.Image {
+	img := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{
+		color.NRGBA{0, 0, 0, 0},
+		color.NRGBA{0x20, 0x40, 0xd0, 0xff},
+		color.NRGBA{0x20, 0x40, 0xd0, 0x80},
+	})
+	for y := 6; y < h-6; y++ {
+		for x := 6; x < w-6; x++ {
+			img.SetColorIndex(x, y, 1)
+			if x < 8 || y < 8 || x >= w-8 || y >= h-8 {
+				img.SetColorIndex(x, y, 2)
+			}
+		}
+	}
+	return img
+}
+
+func encodeJPEG(img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeGIF는 프레임마다 한 가지 색의 사각형을 투명한 테두리 안에 그린 애니메이션 GIF입니다.
+func encodeGIF(w, h int, colors []color.Color) []byte {
+	anim := &gif.GIF{}
+	for _, c := range colors {
+		frame := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Transparent, c})
+		for y := 2; y < h-2; y++ {
+			for x := 2; x < w-2; x++ {
+				frame.SetColorIndex(x, y, 1)
+			}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,269 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// tileLayouts는 TilesRequest.Layout 값과 dzsave 레이아웃입니다. "tiff"는 dzsave 대신 피라미드 TIFF 한 장입니다.
+var tileLayouts = map[string]vips.DzLayout{
+	"dz":    vips.DzLayoutDz,
+	"iiif":  vips.DzLayoutIiif,
+	"iiif3": vips.DzLayoutIiif3,
+}
+
+// TilesRequest는 "mode": "tiles" 요청의 설정입니다. 큰 스캔 이미지를 딥줌 뷰어(OpenSeadragon 등)용 타일 피라미드로 만듭니다.
+type TilesRequest struct {
+	// Layout은 dz(기본, <이름>.dzi + <이름>_files/) | iiif | iiif3(<이름>/info.json + 타일) | tiff(피라미드 TIFF)입니다.
+	Layout string `json:"layout,omitempty"`
+	// Prefix는 타일을 올릴 접두사입니다. 기본은 outputKey(없으면 s3Key)에서 확장자를 뺀 <키>_tiles/입니다.
+	Prefix   string `json:"prefix,omitempty"`
+	TileSize int    `json:"tileSize,omitempty"` // 기본 dz 254, 그 밖은 256
+	Overlap  *int   `json:"overlap,omitempty"`  // 기본 dz 1, 그 밖은 0
+	Format   string `json:"format,omitempty"`   // 타일 포맷 jpeg(기본) | webp | png
+	Quality  int    `json:"quality,omitempty"`  // 0이면 75
+	// IIIFBaseURL은 iiif·iiif3 info.json의 id 앞부분입니다. id는 <IIIFBaseURL>/<이름>이 됩니다.
+	IIIFBaseURL string `json:"iiifBaseUrl,omitempty"`
+}
+
+// TilesReport는 타일 출력 결과입니다.
+type TilesReport struct {
+	Layout     string `json:"layout"`
+	Prefix     string `json:"prefix"`
+	Descriptor string `json:"descriptor"` // .dzi, info.json 또는 .tif 키
+	Files      int    `json:"files"`
+	Bytes      int64  `json:"bytes"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+}
+
+// Tiles는 원본 하나(TIFF, DNG 등 libvips가 읽을 수 있는 이미지)를 타일 피라미드로 만들어 Prefix 아래에 올립니다.
+// 타일은 /tmp에 쓴 뒤 TILES_UPLOAD_CONCURRENCY개씩 동시에 올리므로, 함수의 임시 저장소가 타일 전체보다 커야 합니다.
+func (h *Handler) Tiles(ctx context.Context, event S3Event) (ConversionResult, error) {
+	req := TilesRequest{}
+	if event.Tiles != nil {
+		req = *event.Tiles
+	}
+	if err := req.withDefaults(); err != nil {
+		return ConversionResult{}, fmt.Errorf("invalid event: %w", err)
+	}
+	srcKey, err := h.decodeKey(event.S3Key, event.S3KeyEncoded)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	if srcKey == "" {
+		return ConversionResult{}, fmt.Errorf("invalid event: tiles requires s3Key")
+	}
+	base := event.OutputKey
+	if base == "" {
+		base = srcKey
+	}
+	base = h.outputKey(base)
+	if req.Prefix == "" {
+		req.Prefix = replaceExtension(base, "") + "_tiles/"
+	}
+	name := path.Base(replaceExtension(base, ""))
+
+	source, err := h.downloadObject(ctx, event.S3Bucket, srcKey)
+	if err != nil {
+		return ConversionResult{}, err
+	}
+	image, err := vips.NewImageFromBuffer(source, nil)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to process image with vips from buffer: %w", err)
+	}
+	defer image.Close()
+	if err := image.Autorot(); err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to apply EXIF orientation: %w", err)
+	}
+	if err := h.checkBudget(ctx, "tiles"); err != nil {
+		return ConversionResult{}, err
+	}
+
+	dir, err := os.MkdirTemp("", "tiles-*")
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to create temporary tiles directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	descriptor, err := req.save(image, dir, name)
+	if err != nil {
+		return ConversionResult{}, fmt.Errorf("failed to save %s tiles: vips_error: %s", req.Layout, err)
+	}
+
+	report := TilesReport{Layout: req.Layout, Prefix: req.Prefix, Descriptor: req.Prefix + descriptor, Width: image.Width(), Height: image.Height()}
+	if err := h.uploadTiles(ctx, event.S3Bucket, dir, req.Prefix, &report); err != nil {
+		return ConversionResult{}, err
+	}
+
+	msg := fmt.Sprintf("Tiled %dx%d image into %d %s files (%d bytes) under %s", report.Width, report.Height, report.Files, req.Layout, report.Bytes, req.Prefix)
+	log.Println(msg)
+	return ConversionResult{
+		Status:      StatusTilesCreated,
+		OriginalKey: srcKey,
+		NewKey:      report.Descriptor,
+		Format:      req.Format,
+		Outputs:     []OutputResult{{Key: report.Descriptor, Format: strings.TrimPrefix(path.Ext(descriptor), "."), Width: report.Width, Height: report.Height}},
+		Message:     msg,
+		Tiles:       &report,
+	}, nil
+}
+
+func (r *TilesRequest) withDefaults() error {
+	if r.Layout == "" {
+		r.Layout = "dz"
+	}
+	if _, ok := tileLayouts[r.Layout]; !ok && r.Layout != "tiff" {
+		return fmt.Errorf("unknown tiles layout %q", r.Layout)
+	}
+	if r.Format == "" {
+		r.Format = "jpeg"
+	}
+	if r.Format != "jpeg" && r.Format != "webp" && r.Format != "png" {
+		return fmt.Errorf("tiles format must be jpeg, webp or png")
+	}
+	if r.Layout == "tiff" && r.Format == "webp" {
+		return fmt.Errorf("tiff tiles support jpeg or png compression only")
+	}
+	if r.Quality == 0 {
+		r.Quality = 75
+	}
+	if r.TileSize == 0 {
+		r.TileSize = 254
+		if r.Layout != "dz" {
+			r.TileSize = 256
+		}
+	}
+	if r.Overlap == nil {
+		overlap := 0
+		if r.Layout == "dz" {
+			overlap = 1
+		}
+		r.Overlap = &overlap
+	}
+	if r.TileSize < 16 || r.TileSize > 8192 || *r.Overlap < 0 || *r.Overlap >= r.TileSize || r.Quality < 1 || r.Quality > 100 {
+		return fmt.Errorf("tiles tileSize must be between 16 and 8192, overlap smaller than tileSize and quality between 1 and 100")
+	}
+	if (r.Layout == "iiif" || r.Layout == "iiif3") && r.IIIFBaseURL == "" {
+		return fmt.Errorf("%s tiles require iiifBaseUrl", r.Layout)
+	}
+	return nil
+}
+
+// save는 dir 아래에 타일을 쓰고 dir 기준 설명 파일 경로를 돌려줍니다.
+func (r *TilesRequest) save(image *vips.Image, dir, name string) (string, error) {
+	if r.Layout == "tiff" {
+		options := vips.DefaultTiffsaveOptions()
+		options.Tile, options.Pyramid = true, true
+		options.TileWidth, options.TileHeight = r.TileSize, r.TileSize
+		options.Compression, options.Q = vips.TiffCompressionJpeg, r.Quality
+		if r.Format == "png" {
+			options.Compression = vips.TiffCompressionDeflate
+		}
+		options.Bigtiff = true
+		return name + ".tif", image.Tiffsave(filepath.Join(dir, name+".tif"), options)
+	}
+	options := vips.DefaultDzsaveOptions()
+	options.Layout = tileLayouts[r.Layout]
+	options.TileSize, options.Overlap = r.TileSize, *r.Overlap
+	options.Suffix = fmt.Sprintf(".%s[Q=%d]", extensionOf(r.Format)[1:], r.Quality)
+	if r.Format == "png" {
+		options.Suffix = ".png"
+	}
+	options.Container = vips.DzContainerFs
+	options.Keep = vips.KeepNone
+	if r.IIIFBaseURL != "" {
+		options.Id = strings.TrimSuffix(r.IIIFBaseURL, "/")
+	}
+	if err := image.Dzsave(filepath.Join(dir, name), options); err != nil {
+		return "", err
+	}
+	if r.Layout == "dz" {
+		return name + ".dzi", nil
+	}
+	return name + "/info.json", nil
+}
+
+// uploadTiles는 dir 아래 파일을 상대 경로 그대로 prefix 아래에 동시에 올리고 report에 개수와 크기를 더합니다.
+func (h *Handler) uploadTiles(ctx context.Context, bucket, dir, prefix string, report *TilesReport) error {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, p)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tiles: %w", err)
+	}
+	log.Printf("Uploading %d tile files to s3://%s/%s", len(files), bucket, prefix)
+
+	uploadCtx, cancel := uploadContext(ctx)
+	defer cancel()
+	slots := make(chan struct{}, h.conf.TilesUploadConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	var bytes atomic.Int64
+	for _, p := range files {
+		if uploadCtx.Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			rel, _ := filepath.Rel(dir, p)
+			key := prefix + filepath.ToSlash(rel)
+			err := h.checkBudget(ctx, "tile upload")
+			var body []byte
+			if err == nil {
+				body, err = os.ReadFile(p)
+			}
+			if err == nil {
+				err = h.putObjectAttrs(uploadCtx, bucket, key, tileContentType(key), body, h.outputAttrs(nil))
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = asBudgetError("upload "+key, err)
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			bytes.Add(int64(len(body)))
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return fmt.Errorf("failed to upload tiles to s3://%s/%s: %w", bucket, prefix, firstErr)
+	}
+	report.Files, report.Bytes = len(files), bytes.Load()
+	return nil
+}
+
+// tileContentType은 타일 세트 파일의 Content-Type입니다.
+func tileContentType(key string) string {
+	switch ext := path.Ext(key); ext {
+	case ".dzi":
+		return "application/xml"
+	case ".tif":
+		return "image/tiff"
+	default:
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,34 @@
+package converter
+
+import "time"
+
+// Timings는 변환 단계별 소요 시간(밀리초)입니다. 로그를 파싱하지 않고도 지연 원인을 나눠 볼 수 있습니다.
+// 출력별 인코딩 시간은 outputs의 encodeMs에 있습니다.
+type Timings struct {
+	DownloadMs int64 `json:"downloadMs"`
+	DecodeMs   int64 `json:"decodeMs"`
+	PipelineMs int64 `json:"pipelineMs,omitempty"`
+	// EncodeMs는 모든 크기·포맷을 인코딩하는 데 걸린 실제 시간입니다. VARIANT_CONCURRENCY가 1보다 크면
+	// 출력별 encodeMs의 합보다 짧을 수 있습니다.
+	EncodeMs int64 `json:"encodeMs"`
+	// UploadMs는 출력 업로드 시간의 합이며 UPLOAD_RATE_LIMIT 대기는 뺍니다.
+	UploadMs int64 `json:"uploadMs"`
+	// TotalMs는 요청 처리 시작부터 결과를 돌려주기까지의 시간입니다.
+	TotalMs int64 `json:"totalMs"`
+}
+
+// Dimensions는 이미지 크기와 픽셀 수입니다.
+type Dimensions struct {
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	Pixels int64 `json:"pixels"`
+}
+
+func dimensionsOf(width, height int) *Dimensions {
+	return &Dimensions{Width: width, Height: height, Pixels: int64(width) * int64(height)}
+}
+
+// since는 start부터 지금까지의 시간(밀리초)입니다.
+func (h *Handler) since(start time.Time) int64 {
+	return h.clock.Now().Sub(start).Milliseconds()
+}
@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cshum/vipsgen/vips"
+)
+
+// UploadVerificationFailed는 업로드한 출력이 인코딩한 바이트와 다르거나 디코딩되지 않음을 나타냅니다.
+// 다시 변환하면 같은 키를 새로 올리므로 재시도할 수 있는 실패입니다.
+type UploadVerificationFailed struct {
+	Key    string
+	Reason string
+}
+
+func (e *UploadVerificationFailed) Error() string {
+	return fmt.Sprintf("UPLOAD_VERIFICATION_FAILED: %s: %s", e.Key, e.Reason)
+}
+
+// UseUploadVerification은 출력 업로드를 검증하는 미들웨어를 등록합니다. (VERIFY_UPLOADS)
+func (h *Handler) UseUploadVerification() {
+	h.hooks.Use(&uploadVerifier{s3: h.outputClient, decode: h.conf.VerifyUploads == "full", namespace: h.conf.MetricsNamespace})
+}
+
+// uploadVerifier는 업로드 전에 출력의 SHA-256을 기록하고(decode이면 전체 픽셀을 디코딩해 보고),
+// 업로드 뒤 HeadObject로 S3에 저장된 크기와 체크섬(UPLOAD_CHECKSUM_ALGORITHM)이 올린 값과 같은지 확인합니다.
+// 어느 하나라도 어긋나면 CONVERTED 대신 UploadVerificationFailed로 변환을 실패시킵니다.
+type uploadVerifier struct {
+	s3        func(bucket string) S3API
+	decode    bool
+	namespace string
+}
+
+func (v *uploadVerifier) PreUpload(ctx context.Context, job *Job, upload *Upload) error {
+	if upload.SHA256 == "" {
+		sum := sha256.Sum256(upload.Body)
+		upload.SHA256 = hex.EncodeToString(sum[:])
+	}
+	if !v.decode {
+		return nil
+	}
+	if err := verifyDecodes(upload); err != nil {
+		emitMetrics(v.namespace, "Count", map[string]float64{"UploadVerificationFailed": 1})
+		return &UploadVerificationFailed{Key: upload.Key, Reason: err.Error()}
+	}
+	return nil
+}
+
+func (v *uploadVerifier) PostUpload(ctx context.Context, job *Job, output OutputResult) error {
+	head, err := v.s3(job.OutputBucket).HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(job.OutputBucket),
+		Key:          aws.String(output.Key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify upload %s: %w", output.Key, err)
+	}
+	var reason string
+	if size := aws.ToInt64(head.ContentLength); size != output.Size {
+		reason = fmt.Sprintf("stored size %d bytes, uploaded %d bytes", size, output.Size)
+	} else if sum := output.Checksum; sum != nil && !strings.Contains(sum.Value, "-") {
+		// COMPOSITE 멀티파트 업로드의 체크섬은 파트 체크섬의 체크섬(<값>-<파트 수>)이므로 크기만 비교합니다.
+		checksum := UploadChecksum{Algorithm: sum.Algorithm}
+		stored := checksum.pick(head.ChecksumCRC32, head.ChecksumCRC32C, head.ChecksumCRC64NVME, head.ChecksumSHA1, head.ChecksumSHA256)
+		if stored != "" && stored != sum.Value {
+			reason = fmt.Sprintf("stored %s %s, uploaded %s", sum.Algorithm, stored, sum.Value)
+		}
+	}
+	if reason != "" {
+		emitMetrics(v.namespace, "Count", map[string]float64{"UploadVerificationFailed": 1})
+		return &UploadVerificationFailed{Key: output.Key, Reason: reason}
+	}
+	log.Printf("Verified upload %s: %d bytes, sha256 %s", output.Key, output.Size, output.SHA256)
+	return nil
+}
+
+// verifyDecodes는 출력을 다시 열어 모든 픽셀을 읽어 봅니다. 잘린 비트스트림은 헤더는 열려도 픽셀을 읽을 때 실패합니다.
+func verifyDecodes(upload *Upload) error {
+	image, err := vips.NewImageFromBuffer(upload.Body, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reopen %s output: %w", upload.Format, err)
+	}
+	defer image.Close()
+	if upload.Width > 0 && (image.Width() != upload.Width || image.Height() != upload.Height) {
+		return fmt.Errorf("%s output decodes to %dx%d, encoded %dx%d", upload.Format, image.Width(), image.Height(), upload.Width, upload.Height)
+	}
+	if _, err := image.Avg(); err != nil {
+		return fmt.Errorf("failed to decode %s output pixels: %w", upload.Format, err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// heifBrands는 ftyp 상자를 쓰지만 동영상이 아닌 HEIF/AVIF 이미지의 major brand입니다.
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true, "hevc": true, "hevx": true,
+	"mif1": true, "msf1": true, "avif": true, "avis": true,
+}
+
+// isVideo는 원본이 MP4/MOV(ISO BMFF) 동영상인지 ftyp 상자로 확인합니다.
+func isVideo(source []byte) bool {
+	if len(source) < 12 || string(source[4:8]) != "ftyp" {
+		return false
+	}
+	return !heifBrands[string(source[8:12])]
+}
+
+// UseVideoPoster는 동영상 원본에서 포스터 프레임을 뽑아 이미지처럼 변환하는 미들웨어를 등록합니다.
+func (h *Handler) UseVideoPoster() {
+	h.hooks.Use(&posterFrame{ffmpeg: h.conf.FFmpegPath, seconds: h.conf.PosterSeconds, preview: h.conf.VideoPreview})
+}
+
+// posterFrame은 동영상 원본을 ffmpeg로 한 프레임짜리 PNG로 바꿔 job.Source를 교체합니다.
+// 이후 흐름(파이프라인, 프리셋, AVIF 인코딩)은 이미지 원본과 같으며, 출력 키도 원본 키에서 확장자만 바뀝니다.
+// preview가 설정되어 있으면 움직이는 미리보기를 만들어 job.Extras에 추가합니다.
+type posterFrame struct {
+	ffmpeg  string
+	seconds float64
+	preview videoPreview
+}
+
+func (p *posterFrame) PreDecode(ctx context.Context, job *Job) error {
+	if !isVideo(job.Source) {
+		return nil
+	}
+	seconds := p.seconds
+	if job.Event.PosterSeconds != nil {
+		seconds = *job.Event.PosterSeconds
+	}
+	if seconds < 0 {
+		return fmt.Errorf("invalid event: posterSeconds must not be negative")
+	}
+
+	// ffmpeg는 MP4/MOV의 moov 상자를 찾아 앞뒤로 탐색해야 하므로 표준 입력 대신 /tmp의 임시 파일을 넘깁니다.
+	dir, err := os.MkdirTemp("", "video-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary video directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	video := filepath.Join(dir, "source")
+	if err := os.WriteFile(video, job.Source, 0o600); err != nil {
+		return fmt.Errorf("failed to write temporary video file: %w", err)
+	}
+
+	if p.preview.Format != "" {
+		// 미리보기는 부가 출력이므로 실패해도 포스터 프레임 변환은 계속합니다.
+		if upload, err := p.preview.render(ctx, p.ffmpeg, video, dir, job.BaseKey); err != nil {
+			log.Printf("Warning: skipping animated preview: %v", err)
+		} else {
+			job.Extras = append(job.Extras, upload)
+		}
+	}
+
+	frame, err := p.extract(ctx, video, seconds)
+	if err == nil && len(frame) == 0 && seconds > 0 {
+		// 지정한 시각보다 짧은 동영상은 첫 프레임을 씁니다.
+		log.Printf("Video is shorter than %gs, using the first frame", seconds)
+		frame, err = p.extract(ctx, video, 0)
+	}
+	if err != nil {
+		return err
+	}
+	if len(frame) == 0 {
+		return fmt.Errorf("ffmpeg produced no poster frame for %s", job.SrcKey)
+	}
+	log.Printf("Extracted poster frame at %gs from video %s (%d bytes)", seconds, job.SrcKey, len(frame))
+	job.Source = frame
+	return nil
+}
+
+// extract는 seconds 시각의 프레임 하나를 PNG로 뽑습니다.
+func (p *posterFrame) extract(ctx context.Context, video string, seconds float64) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := runFFmpeg(ctx, p.ffmpeg, &stdout,
+		"-ss", strconv.FormatFloat(seconds, 'f', -1, 64),
+		"-i", video,
+		"-frames:v", "1",
+		"-f", "image2pipe", "-vcodec", "png", "-")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to extract poster frame: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// videoPreview는 동영상 앞부분의 움직이는 미리보기 설정입니다. Seconds, FPS, Width가 상한입니다.
+type videoPreview struct {
+	Format  string // "webp" | "avif", 비어 있으면 만들지 않습니다.
+	Seconds float64
+	FPS     int
+	Width   int
+	Quality int
+	// Bitexact이면 ffmpeg를 단일 스레드로 돌리고 생성 시각·인코더 버전 같은 메타데이터를 쓰지 않습니다. (DETERMINISTIC_ENCODE)
+	Bitexact bool
+}
+
+// render는 미리보기를 만들어 <기준 키>.preview.<포맷> 업로드로 돌려줍니다.
+// WebP는 ffmpeg로 뽑은 프레임을 libvips로 묶어 인코딩하고, AVIF는 libvips가 애니메이션을 쓰지 못하므로
+// ffmpeg의 AV1 인코더(libaom-av1)로 바로 인코딩합니다.
+func (v videoPreview) render(ctx context.Context, ffmpeg, video, dir, baseKey string) (*Upload, error) {
+	filter := fmt.Sprintf("fps=%d,scale='min(%d,iw)':-2", v.FPS, v.Width)
+	duration := strconv.FormatFloat(v.Seconds, 'f', -1, 64)
+
+	var body []byte
+	var width, height int
+	switch v.Format {
+	case "avif":
+		out := filepath.Join(dir, "preview.avif")
+		args := []string{"-t", duration, "-i", video, "-an", "-vf", filter,
+			"-c:v", "libaom-av1", "-cpu-used", "8", "-crf", strconv.Itoa(63 - v.Quality*63/100), "-pix_fmt", "yuv420p"}
+		if v.Bitexact {
+			args = append(args, "-threads", "1", "-map_metadata", "-1", "-fflags", "+bitexact", "-flags", "+bitexact")
+		}
+		err := runFFmpeg(ctx, ffmpeg, nil, append(args, "-f", "avif", out)...)
+		if err != nil {
+			return nil, fmt.Errorf("ffmpeg failed to encode AVIF preview: %w", err)
+		}
+		if body, err = os.ReadFile(out); err != nil {
+			return nil, err
+		}
+	case "webp":
+		pattern := filepath.Join(dir, "frame-%04d.png")
+		if err := runFFmpeg(ctx, ffmpeg, nil, "-t", duration, "-i", video, "-an", "-vf", filter, pattern); err != nil {
+			return nil, fmt.Errorf("ffmpeg failed to extract preview frames: %w", err)
+		}
+		var err error
+		if body, width, height, err = v.encodeWebP(dir); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Rendered %s animated preview: %gs at %dfps, %d bytes", v.Format, v.Seconds, v.FPS, len(body))
+	return &Upload{
+		Key:    replaceExtension(baseKey, ".preview"+extensionOf(v.Format)),
+		Format: v.Format,
+		Body:   body,
+		Width:  width,
+		Height: height,
+	}, nil
+}
+
+// encodeWebP는 dir의 frame-*.png를 세로로 이어 붙인 여러 페이지 이미지로 만들어 애니메이션 WebP로 인코딩합니다.
+func (v videoPreview) encodeWebP(dir string) ([]byte, int, int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "frame-*.png"))
+	if err != nil || len(paths) == 0 {
+		return nil, 0, 0, fmt.Errorf("ffmpeg produced no preview frames")
+	}
+	frames := make([]*vips.Image, 0, len(paths))
+	defer func() {
+		for _, f := range frames {
+			f.Close()
+		}
+	}()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		frame, err := vips.NewImageFromBuffer(data, nil)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to load preview frame: %w", err)
+		}
+		frames = append(frames, frame)
+	}
+
+	options := vips.DefaultArrayjoinOptions()
+	options.Across = 1
+	strip, err := vips.NewArrayjoin(frames, options)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to join preview frames: %w", err)
+	}
+	defer strip.Close()
+	width, height := frames[0].Width(), frames[0].Height()
+	if err := strip.SetPageHeight(height); err != nil {
+		return nil, 0, 0, err
+	}
+	strip.SetInt("gif-delay", max(1, 100/v.FPS)) // 센티초
+	strip.SetInt("loop", 0)
+
+	webp := vips.DefaultWebpsaveBufferOptions()
+	webp.Q = v.Quality
+	webp.Keep = vips.KeepNone
+	body, err := strip.WebpsaveBuffer(webp)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode WebP preview: vips_error: %s", err)
+	}
+	return body, width, height, nil
+}
+
+// runFFmpeg는 ffmpeg를 실행합니다. 실패하면 stderr를 오류에 붙입니다.
+func runFFmpeg(ctx context.Context, ffmpeg string, stdout io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, append([]string{"-hide_banner", "-loglevel", "error", "-y"}, args...)...)
+	cmd.Stdout, cmd.Stderr = stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// 이 파일은 Lambda 밖(ECS 작업, 배치 컨테이너)에서 같은 바이너리를 실행하는 모드입니다. (RUN_MODE)
+//   - sqs: SQS_QUEUE_URL을 직접 폴링해 Lambda 이벤트 소스 매핑과 같은 배치로 처리합니다.
+//   - worker: WORKER_EVENT(없으면 표준 입력)의 이벤트 하나를 처리하고 결과를 표준 출력에 쓴 뒤 끝납니다.
+// Lambda의 15분 제한 없이 긴 백필("mode": "backfill")을 돌릴 때 씁니다. 변환 경로와 설정은 Lambda와 같습니다.
+
+// SQSAPI는 폴러가 사용하는 SQS 클라이언트 메서드입니다.
+type SQSAPI interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+// ReceiveMessage가 실패하면 receiveBackoff부터 실패할 때마다 두 배씩, maxReceiveBackoff까지 기다렸다가 다시 받습니다.
+const (
+	receiveBackoff    = time.Second
+	maxReceiveBackoff = time.Minute
+)
+
+// runSQSPoller는 SIGTERM을 받을 때까지 메시지를 받아 처리합니다. 받은 배치는 끝까지 처리한 뒤에 멈춥니다.
+// 성공한 메시지만 지우고, batchItemFailures에 든 메시지는 가시성 제한 시간이 지나면 다시 받습니다.
+func runSQSPoller(client SQSAPI) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	log.Printf("Polling %s", current.Load().conf.SQSQueueURL)
+	backoff := receiveBackoff
+	for ctx.Err() == nil {
+		out, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(current.Load().conf.SQSQueueURL),
+			MaxNumberOfMessages: int32(current.Load().conf.SQSBatchSize),
+			WaitTimeSeconds:     20,
+			VisibilityTimeout:   int32(current.Load().conf.SQSVisibilityTimeout.Seconds()),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Warning: failed to receive messages, retrying in %s: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxReceiveBackoff)
+			continue
+		}
+		backoff = receiveBackoff
+		if len(out.Messages) > 0 {
+			// 신호를 받아도 처리 중인 배치는 끊지 않고, 가시성 제한 시간을 함수 제한 시간처럼 씁니다.
+			pollSQSBatch(context.WithoutCancel(ctx), client, out.Messages)
+		}
+	}
+	current.Load().Shutdown()
+	otelProviders.Shutdown()
+}
+
+func pollSQSBatch(ctx context.Context, client SQSAPI, messages []types.Message) {
+	ctx, cancel := context.WithTimeout(ctx, current.Load().conf.SQSVisibilityTimeout)
+	defer cancel()
+	event := S3Event{}
+	for _, m := range messages {
+		event.Records = append(event.Records, BatchRecord{
+			EventSource: "aws:sqs",
+			AWSRegion:   awsConfig.Region,
+			MessageID:   aws.ToString(m.MessageId),
+			Body:        aws.ToString(m.Body),
+		})
+	}
+	result, err := invoke(ctx, event)
+	if err != nil {
+		log.Printf("SQS batch of %d messages failed, leaving them for redelivery: %v", len(messages), err)
+		return
+	}
+	failed := map[string]bool{}
+	for _, f := range result.BatchItemFailures {
+		failed[f.ItemIdentifier] = true
+	}
+	var entries []types.DeleteMessageBatchRequestEntry
+	for _, m := range messages {
+		if !failed[aws.ToString(m.MessageId)] {
+			entries = append(entries, types.DeleteMessageBatchRequestEntry{Id: m.MessageId, ReceiptHandle: m.ReceiptHandle})
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+	out, err := client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(current.Load().conf.SQSQueueURL), Entries: entries})
+	if err != nil {
+		log.Printf("Warning: failed to delete processed messages: %v", err)
+		return
+	}
+	for _, f := range out.Failed {
+		log.Printf("Warning: failed to delete message %s: %s", aws.ToString(f.Id), aws.ToString(f.Message))
+	}
+}
+
+// runWorker는 이벤트 하나를 처리합니다. 오류가 나면 0이 아닌 코드로 끝나므로 작업 스케줄러가 실패로 봅니다.
+func runWorker() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	err := runWorkerEvent(ctx)
+	current.Load().Shutdown()
+	otelProviders.Shutdown()
+	if err != nil {
+		log.Fatalf("Worker failed: %v", err)
+	}
+}
+
+func runWorkerEvent(ctx context.Context) error {
+	var input io.Reader = os.Stdin
+	if current.Load().conf.WorkerEvent != "" {
+		input = strings.NewReader(current.Load().conf.WorkerEvent)
+	}
+	var event S3Event
+	if err := json.NewDecoder(input).Decode(&event); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+	result, err := invoke(ctx, event)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
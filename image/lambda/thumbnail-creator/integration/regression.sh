@@ -0,0 +1,35 @@
+#!/usr/bin/env bash
+# 배포된 함수(FUNCTION) 또는 실행 중인 에뮬레이터(INVOKE_URL)에 "mode": "regression"을 호출하고,
+# 코퍼스 중 하나라도 기준값보다 SSIM이 떨어지거나 크기가 늘면 실패로 끝납니다.
+# 필요: curl 또는 aws CLI
+# 사용법: BUCKET=<코퍼스 버킷> FUNCTION=<함수 이름> ./integration/regression.sh
+#         UPDATE=1이면 비교하지 않고 현재 결과로 기준값(baseline.json)을 새로 씁니다.
+set -euo pipefail
+
+: "${BUCKET:?BUCKET is required}"
+PREFIX=${PREFIX:-regression/corpus/}
+UPDATE=${UPDATE:-0}
+
+update=false
+if [ "$UPDATE" = "1" ]; then update=true; fi
+event="{\"mode\":\"regression\",\"s3Bucket\":\"$BUCKET\",\"regression\":{\"prefix\":\"$PREFIX\",\"updateBaseline\":$update"
+if [ -n "${MAX_SSIM_DROP:-}" ]; then event="$event,\"maxSSIMDrop\":$MAX_SSIM_DROP"; fi
+if [ -n "${MAX_SIZE_GROWTH_PERCENT:-}" ]; then event="$event,\"maxSizeGrowthPercent\":$MAX_SIZE_GROWTH_PERCENT"; fi
+event="$event}}"
+
+if [ -n "${FUNCTION:-}" ]; then
+  out=$(mktemp)
+  trap 'rm -f "$out"' EXIT
+  aws lambda invoke --function-name "$FUNCTION" --cli-binary-format raw-in-base64-out \
+    --cli-read-timeout 0 --payload "$event" "$out" >/dev/null
+  response=$(cat "$out")
+else
+  INVOKE_URL=${INVOKE_URL:-http://localhost:9001/2015-03-31/functions/function/invocations}
+  response=$(curl -s -XPOST "$INVOKE_URL" -d "$event")
+fi
+
+echo "$response"
+case "$response" in
+  *'"status":"REGRESSION_PASSED"'* | *'"status":"BASELINE_UPDATED"'*) exit 0 ;;
+  *) echo "FAIL: quality regression" >&2; exit 1 ;;
+esac
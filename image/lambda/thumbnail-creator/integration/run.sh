@@ -0,0 +1,83 @@
+#!/usr/bin/env bash
+# MinIO와 Lambda 런타임 인터페이스 에뮬레이터로 다운로드 → 변환 → 업로드 전체 흐름을 확인합니다.
+# 필요: docker (compose 플러그인 포함), curl
+# 사용법: ./integration/run.sh   (KEEP=1이면 끝난 뒤 컨테이너를 남겨 둡니다)
+set -euo pipefail
+
+cd "$(dirname "$0")"
+PROJECT=thumbnail-integration
+BUCKET=integration
+INVOKE_URL=http://localhost:9001/2015-03-31/functions/function/invocations
+
+compose() { docker compose -p "$PROJECT" "$@"; }
+mc() {
+  docker run --rm --network "${PROJECT}_default" -v "$WORKDIR:/work" --entrypoint sh minio/mc -c \
+    "mc alias set local http://minio:9000 minioadmin minioadmin >/dev/null && $*"
+}
+
+WORKDIR=$(mktemp -d)
+cleanup() {
+  rm -rf "$WORKDIR"
+  if [ "${KEEP:-0}" != "1" ]; then compose down -v; fi
+}
+trap cleanup EXIT
+
+# 16x16 RGBA PNG (부분 투명)
+base64 -d > "$WORKDIR/alpha.png" <<'PNG'
+iVBORw0KGgoAAAANSUhEUgAAABAAAAAQCAYAAAAf8/9hAAABfUlEQVR42hXQQY0FIRRFwZYwEpCABCQgAQlIQAISkIAEJCABCTj4UywqJ3fRpPO+72vl72u/QCTZWQuVZncdTJa99XD5vr/2+yMQ/1pJmilUu2lnMO2lm8O1vy94gBBaiZrIFLtqozPsqYvNsW94fxA9EFsJGklku2il0e2hk8W2j974HkhukPwBkWRnLVSa3XUwWfbWw03vgewPCMTsBpopVLtpZzDtpZvDze8GxQOE4gaayBS7qid+nWFPXWyOfcv7g+qB6gYaSWS7aKXR7aGTxbaP3voeaG7Q/AGRZGctVJrddTBZ9tbDbe+B7g8IxO4GmvHVr9pNO4NpL90cbn83GB4gDDfQRKbYVRudYU9dbI59x/uD6YHpBhpJZLtopdHtoZPFto/e+R5YbrD8AZFkZy1Umt11MFn21sNd74HtDwjE7QaacalftZt2BtNeujnc/W5wPEA4bqCJTLGrNjrDnrrYHPue9wfXA9cNNJLIdtFKo9tDJ4ttH738A8csR1+H8h8GAAAAAElFTkSuQmCC
+PNG
+
+compose up -d --build
+
+echo "Waiting for MinIO and the Lambda emulator..."
+for _ in $(seq 1 60); do
+  if curl -sf http://localhost:9000/minio/health/ready >/dev/null && curl -s -o /dev/null localhost:9001; then
+    break
+  fi
+  sleep 1
+done
+
+mc "mc mb -p local/$BUCKET && mc cp /work/alpha.png local/$BUCKET/fixtures/alpha.png"
+
+failures=0
+# invoke <설명> <이벤트 JSON> <응답에 포함되어야 하는 문자열...>
+invoke() {
+  local name=$1 event=$2
+  shift 2
+  local response
+  response=$(curl -s -XPOST "$INVOKE_URL" -d "$event")
+  for want in "$@"; do
+    if [[ "$response" != *"$want"* ]]; then
+      echo "FAIL: $name: missing $want in response: $response"
+      failures=$((failures + 1))
+      return
+    fi
+  done
+  echo "ok:   $name"
+}
+
+invoke "convert png with alpha" \
+  "{\"s3Bucket\":\"$BUCKET\",\"s3Key\":\"fixtures/alpha.png\"}" \
+  '"status":"CONVERTED"' '"alpha":"preserved"' '"key":"fixtures/alpha.avif"' '"key":"fixtures/alpha.jpg"'
+invoke "skip avif input" \
+  "{\"s3Bucket\":\"$BUCKET\",\"s3Key\":\"fixtures/alpha.avif\"}" \
+  '"status":"SKIPPED_ALREADY_AVIF"'
+invoke "avatar preset" \
+  "{\"s3Bucket\":\"$BUCKET\",\"s3Key\":\"fixtures/alpha.png\",\"preset\":\"avatar\",\"outputKey\":\"avatar/alpha.png\"}" \
+  '"key":"avatar/alpha_64x64.avif"' '"key":"avatar/alpha_256x256.avif"'
+invoke "pipeline format override" \
+  "{\"s3Bucket\":\"$BUCKET\",\"s3Key\":\"fixtures/alpha.png\",\"outputKey\":\"piped/alpha.png\",\"pipeline\":[{\"op\":\"rotate\",\"angle\":90},{\"op\":\"format\",\"format\":\"webp\"}]}" \
+  '"format":"webp"' '"key":"piped/alpha.webp"'
+
+# 업로드된 객체가 실제로 존재하는지 확인합니다.
+if ! mc "mc stat local/$BUCKET/fixtures/alpha.avif && mc stat local/$BUCKET/piped/alpha.webp" >/dev/null; then
+  echo "FAIL: converted objects not found in bucket"
+  failures=$((failures + 1))
+fi
+
+if [ "$failures" -gt 0 ]; then
+  echo "$failures check(s) failed"
+  compose logs lambda | tail -50
+  exit 1
+fi
+echo "All integration checks passed"
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseColor는 "#RRGGBB", "RRGGBB" 또는 "r,g,b" 형식의 색상 문자열을 0~255 값으로 변환합니다.
+func ParseColor(s string) ([]float64, error) {
+	if strings.Contains(s, ",") {
+		parts := strings.Split(s, ",")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("expected r,g,b but got %q", s)
+		}
+		rgb := make([]float64, 3)
+		for i, p := range parts {
+			v, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil || v < 0 || v > 255 {
+				return nil, fmt.Errorf("invalid color component %q", p)
+			}
+			rgb[i] = float64(v)
+		}
+		return rgb, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("expected #RRGGBB but got %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex color %q", s)
+	}
+	return []float64{float64(v >> 16 & 0xff), float64(v >> 8 & 0xff), float64(v & 0xff)}, nil
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// sepiaTone은 회색조 밝기에 곱하는 R, G, B 배율입니다.
+// 고전적인 세피아 행렬을 회색 입력(R=G=B)에 적용한 값과 같습니다.
+var sepiaTone = []float64{1.351, 1.203, 0.937}
+
+// grayscaleOp는 이미지를 회색조로 바꿉니다. 뒤 단계가 RGB를 가정하므로 밴드 수는 RGB로 유지합니다.
+type grayscaleOp struct{}
+
+func (o *grayscaleOp) apply(s *state) error {
+	return grayscale(s.image)
+}
+
+// sepiaOp는 회색조로 바꾼 뒤 갈색 톤을 입힙니다.
+type sepiaOp struct{}
+
+func (o *sepiaOp) apply(s *state) error {
+	if err := grayscale(s.image); err != nil {
+		return err
+	}
+	return scaleBands(s.image, sepiaTone, []float64{0, 0, 0})
+}
+
+// adjustOp는 brightness, contrast, saturation 단계입니다. amount는 배율이며 1이면 그대로입니다.
+// brightness: 밝기 배율, contrast: 중간 밝기를 기준으로 한 대비 배율, saturation: 채도 배율(0이면 회색조)
+type adjustOp struct {
+	Amount *float64 `json:"amount"`
+
+	kind string
+}
+
+func (o *adjustOp) validate() error {
+	if o.Amount == nil {
+		return fmt.Errorf("amount is required")
+	}
+	if *o.Amount < 0 || *o.Amount > 4 {
+		return fmt.Errorf("amount must be between 0 and 4")
+	}
+	return nil
+}
+
+func (o *adjustOp) apply(s *state) error {
+	amount := *o.Amount
+	switch o.kind {
+	case "brightness":
+		return scaleBands(s.image, []float64{amount, amount, amount}, []float64{0, 0, 0})
+	case "contrast":
+		offset := maxValue(s.image) / 2 * (1 - amount)
+		return scaleBands(s.image, []float64{amount, amount, amount}, []float64{offset, offset, offset})
+	}
+	return saturate(s.image, amount)
+}
+
+// rgbSpaces는 이미지의 비트 깊이에 맞는 회색조·RGB 색 공간입니다.
+func rgbSpaces(image *vips.Image) (vips.Interpretation, vips.Interpretation) {
+	if image.BandFormat() == vips.BandFormatUshort {
+		return vips.InterpretationGrey16, vips.InterpretationRgb16
+	}
+	return vips.InterpretationBW, vips.InterpretationSrgb
+}
+
+// maxValue는 밴드 값의 최댓값입니다. (8비트 255, 16비트 65535)
+func maxValue(image *vips.Image) float64 {
+	if image.BandFormat() == vips.BandFormatUshort {
+		return 65535
+	}
+	return 255
+}
+
+func grayscale(image *vips.Image) error {
+	grey, rgb := rgbSpaces(image)
+	if err := image.Colourspace(grey, nil); err != nil {
+		return err
+	}
+	return image.Colourspace(rgb, nil)
+}
+
+// scaleBands는 색 밴드마다 v*a + b를 적용하고 원래 밴드 형식으로 되돌립니다. 알파 밴드는 그대로 둡니다.
+// 결과가 범위를 넘으면 Cast에서 잘립니다.
+func scaleBands(image *vips.Image, a, b []float64) error {
+	if image.Bands() < 3 {
+		_, rgb := rgbSpaces(image)
+		if err := image.Colourspace(rgb, nil); err != nil {
+			return err
+		}
+	}
+	format := image.BandFormat()
+	a, b = keepAlpha(a, b, image.Bands())
+	if err := image.Linear(a, b, nil); err != nil {
+		return err
+	}
+	return image.Cast(format, nil)
+}
+
+// saturate는 LCh 색 공간에서 채도(C)에 amount를 곱합니다. 회색조 이미지는 바꾸지 않습니다.
+func saturate(image *vips.Image, amount float64) error {
+	if image.Bands() < 3 {
+		return nil
+	}
+	_, rgb := rgbSpaces(image)
+	if err := image.Colourspace(vips.InterpretationLch, nil); err != nil {
+		return err
+	}
+	a, b := keepAlpha([]float64{1, amount, 1}, []float64{0, 0, 0}, image.Bands())
+	if err := image.Linear(a, b, nil); err != nil {
+		return err
+	}
+	return image.Colourspace(rgb, nil)
+}
+
+// keepAlpha는 색 밴드용 Linear 계수 뒤에 알파 밴드를 그대로 두는 계수(1, 0)를 붙입니다.
+func keepAlpha(a, b []float64, bands int) ([]float64, []float64) {
+	a, b = append([]float64(nil), a...), append([]float64(nil), b...)
+	for len(a) < bands {
+		a, b = append(a, 1), append(b, 0)
+	}
+	return a, b
+}
@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// maxCoord는 한쪽 크기만 지정한 resize에서 다른 쪽 제한으로 쓰는 값입니다 (libvips VIPS_MAX_COORD).
+const maxCoord = 10_000_000
+
+// resizeOp는 이미지를 지정한 크기로 줄입니다.
+// fit: inside(기본, 비율 유지하며 상자 안에 맞춤) | cover(상자를 채우고 가운데를 자름) | fill(비율 무시)
+// | pad(상자 안에 맞춘 뒤 background로 여백을 채워 정확히 width×height를 만듦)
+type resizeOp struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Fit        string `json:"fit"`
+	Enlarge    bool   `json:"enlarge"`
+	Background string `json:"background"`
+}
+
+func (o *resizeOp) validate() error {
+	if o.Fit == "" {
+		o.Fit = "inside"
+	}
+	if err := ValidateBackground(o.Background); err != nil {
+		return err
+	}
+	return ValidateSize(o.Width, o.Height, o.Fit)
+}
+
+// ValidateSize는 Resize에 넘길 크기와 fit 조합을 검증합니다.
+func ValidateSize(width, height int, fit string) error {
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return fmt.Errorf("width or height must be positive")
+	}
+	switch fit {
+	case "inside":
+	case "cover", "fill", "pad":
+		if width == 0 || height == 0 {
+			return fmt.Errorf("fit %q requires both width and height", fit)
+		}
+	default:
+		return fmt.Errorf("unknown fit %q", fit)
+	}
+	return nil
+}
+
+func (o *resizeOp) apply(s *state) error {
+	return Resize(s.image, o.Width, o.Height, o.Fit, o.Enlarge, o.Background)
+}
+
+// Resize는 resize 단계와 같은 규칙으로 이미지 크기를 바꿉니다.
+// width나 height가 0이면 그쪽은 제한하지 않습니다. 프리셋 크기 변환에서도 사용합니다.
+// background는 fit이 pad일 때만 쓰입니다. (ValidateBackground 참고)
+func Resize(image *vips.Image, width, height int, fit string, enlarge bool, background string) error {
+	if fit == "pad" {
+		return pad(image, width, height, enlarge, background)
+	}
+	if width == 0 {
+		width = maxCoord
+	}
+	if height == 0 {
+		height = maxCoord
+	}
+	options := &vips.ThumbnailImageOptions{Height: height, Size: vips.SizeDown}
+	if enlarge {
+		options.Size = vips.SizeBoth
+	}
+	switch fit {
+	case "cover":
+		options.Crop = vips.InterestingCentre
+	case "fill":
+		options.Size = vips.SizeForce
+	}
+	return image.ThumbnailImage(width, options)
+}
+
+// cropOp는 지정한 영역을 잘라냅니다. left/top이 없으면 gravity 기준으로 width×height를 고릅니다.
+// gravity: centre(기본) | attention | entropy
+type cropOp struct {
+	Left    *int   `json:"left"`
+	Top     *int   `json:"top"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Gravity string `json:"gravity"`
+}
+
+var interestingByName = map[string]vips.Interesting{
+	"centre":    vips.InterestingCentre,
+	"center":    vips.InterestingCentre,
+	"attention": vips.InterestingAttention,
+	"entropy":   vips.InterestingEntropy,
+}
+
+func (o *cropOp) validate() error {
+	if o.Width <= 0 || o.Height <= 0 {
+		return fmt.Errorf("width and height must be positive")
+	}
+	if (o.Left == nil) != (o.Top == nil) {
+		return fmt.Errorf("left and top must be set together")
+	}
+	if o.Left != nil && (*o.Left < 0 || *o.Top < 0) {
+		return fmt.Errorf("left and top must not be negative")
+	}
+	if o.Gravity == "" {
+		o.Gravity = "centre"
+	}
+	if _, ok := interestingByName[o.Gravity]; !ok {
+		return fmt.Errorf("unknown gravity %q", o.Gravity)
+	}
+	return nil
+}
+
+func (o *cropOp) apply(s *state) error {
+	width, height := min(o.Width, s.image.Width()), min(o.Height, s.image.Height())
+	if o.Left == nil {
+		return s.image.Smartcrop(width, height, &vips.SmartcropOptions{Interesting: interestingByName[o.Gravity]})
+	}
+	if *o.Left+width > s.image.Width() || *o.Top+height > s.image.Height() {
+		return fmt.Errorf("crop area %dx%d+%d+%d is outside the %dx%d image", width, height, *o.Left, *o.Top, s.image.Width(), s.image.Height())
+	}
+	return s.image.ExtractArea(*o.Left, *o.Top, width, height)
+}
+
+// rotateOp는 이미지를 시계 방향으로 회전합니다. 90의 배수가 아니면 background로 빈 곳을 채웁니다.
+type rotateOp struct {
+	Angle      float64 `json:"angle"`
+	Background string  `json:"background"`
+
+	background []float64
+}
+
+func (o *rotateOp) validate() error {
+	if o.Background == "" {
+		o.Background = "#ffffff"
+	}
+	rgb, err := ParseColor(o.Background)
+	if err != nil {
+		return fmt.Errorf("invalid background: %w", err)
+	}
+	o.background = rgb
+	return nil
+}
+
+func (o *rotateOp) apply(s *state) error {
+	angle := math.Mod(o.Angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	switch angle {
+	case 0:
+		return nil
+	case 90:
+		return s.image.Rot(vips.AngleD90)
+	case 180:
+		return s.image.Rot(vips.AngleD180)
+	case 270:
+		return s.image.Rot(vips.AngleD270)
+	}
+	background := o.background
+	if s.image.HasAlpha() {
+		// 투명 이미지는 회전으로 생긴 모서리도 투명하게 둡니다.
+		background = append(append([]float64(nil), o.background...), 0)
+	}
+	return s.image.Rotate(angle, &vips.RotateOptions{Background: background})
+}
+
+// blurOp는 가우시안 블러를 적용합니다.
+type blurOp struct {
+	Sigma float64 `json:"sigma"`
+}
+
+func (o *blurOp) validate() error {
+	if o.Sigma <= 0 || o.Sigma > 100 {
+		return fmt.Errorf("sigma must be in (0, 100]")
+	}
+	return nil
+}
+
+func (o *blurOp) apply(s *state) error {
+	return s.image.Gaussblur(o.Sigma, nil)
+}
+
+// Sharpening은 크기를 줄인 뒤 적용하는 언샤프 마스크 설정입니다. sharpen 단계와 프리셋 크기에서 사용합니다.
+// sigma는 마스크 반경(기본 0.5), amount는 경계에 적용하는 기울기(기본 3)로 클수록 선명해집니다.
+type Sharpening struct {
+	Sigma  float64 `json:"sigma,omitempty"`
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// Validate는 빈 값을 기본값으로 채우고 범위를 검증합니다.
+func (o *Sharpening) Validate() error {
+	if o.Sigma == 0 {
+		o.Sigma = 0.5
+	}
+	if o.Amount == 0 {
+		o.Amount = 3
+	}
+	if o.Sigma < 0 || o.Sigma > 10 {
+		return fmt.Errorf("sharpen sigma must be in (0, 10]")
+	}
+	if o.Amount < 0 || o.Amount > 20 {
+		return fmt.Errorf("sharpen amount must be in (0, 20]")
+	}
+	return nil
+}
+
+// Sharpen은 검증된 설정으로 언샤프 마스크를 적용합니다. 평탄한 영역은 건드리지 않아 노이즈가 커지지 않습니다.
+func Sharpen(image *vips.Image, o Sharpening) error {
+	options := vips.DefaultSharpenOptions()
+	options.Sigma = o.Sigma
+	options.M2 = o.Amount
+	return image.Sharpen(options)
+}
+
+// sharpenOp는 언샤프 마스크를 적용합니다. 보통 resize 단계 뒤에 둡니다.
+type sharpenOp struct {
+	Sharpening
+}
+
+func (o *sharpenOp) validate() error {
+	return o.Sharpening.Validate()
+}
+
+func (o *sharpenOp) apply(s *state) error {
+	return Sharpen(s.image, o.Sharpening)
+}
+
+// formatOp는 출력 포맷과 품질을 지정합니다. 이미지 자체는 바꾸지 않습니다.
+type formatOp struct {
+	Format  string `json:"format"`
+	Quality int    `json:"quality"`
+}
+
+// Formats는 format 단계에서 지정할 수 있는 출력 포맷입니다.
+var Formats = []string{"avif", "webp", "jpeg", "png", "jxl"}
+
+func (o *formatOp) validate() error {
+	if o.Format == "jpg" {
+		o.Format = "jpeg"
+	}
+	return ValidateFormat(o.Format, o.Quality)
+}
+
+// ValidateFormat은 출력 포맷 이름과 품질(0이면 기본값)을 검증합니다.
+func ValidateFormat(format string, quality int) error {
+	known := false
+	for _, f := range Formats {
+		known = known || f == format
+	}
+	if !known {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	return nil
+}
+
+func (o *formatOp) apply(s *state) error {
+	s.output = Output{Format: o.Format, Quality: o.Quality}
+	return nil
+}
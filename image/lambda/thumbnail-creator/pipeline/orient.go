@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// flipDirections는 flip 값과 libvips 방향의 대응입니다.
+var flipDirections = map[string][]vips.Direction{
+	"horizontal": {vips.DirectionHorizontal},
+	"vertical":   {vips.DirectionVertical},
+	"both":       {vips.DirectionHorizontal, vips.DirectionVertical},
+}
+
+// rightAngles는 rotate 값과 libvips 회전 각도의 대응입니다.
+var rightAngles = map[int]vips.Angle{
+	90:  vips.AngleD90,
+	180: vips.AngleD180,
+	270: vips.AngleD270,
+}
+
+// ValidateOrientation은 Orient에 넘길 rotate(0 | 90 | 180 | 270)와 flip(비어 있음 | horizontal | vertical | both)을 검증합니다.
+func ValidateOrientation(rotate int, flip string) error {
+	if _, ok := rightAngles[rotate]; !ok && rotate != 0 {
+		return fmt.Errorf("rotate must be one of 0, 90, 180, 270")
+	}
+	if _, ok := flipDirections[flip]; !ok && flip != "" {
+		return fmt.Errorf("unknown flip %q: must be horizontal, vertical or both", flip)
+	}
+	return nil
+}
+
+// Orient는 이미지를 시계 방향으로 rotate만큼 돌린 뒤 flip 방향으로 뒤집습니다.
+// 90의 배수 회전과 뒤집기는 보간 없이 픽셀을 옮기므로 화질 손실이 없습니다.
+func Orient(image *vips.Image, rotate int, flip string) error {
+	if angle, ok := rightAngles[rotate]; ok {
+		if err := image.Rot(angle); err != nil {
+			return err
+		}
+	}
+	for _, direction := range flipDirections[flip] {
+		if err := image.Flip(direction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flipOp는 이미지를 좌우(horizontal), 상하(vertical) 또는 양쪽(both)으로 뒤집습니다.
+type flipOp struct {
+	Direction string `json:"direction"`
+}
+
+func (o *flipOp) validate() error {
+	if o.Direction == "" {
+		return fmt.Errorf("direction is required")
+	}
+	return ValidateOrientation(0, o.Direction)
+}
+
+func (o *flipOp) apply(s *state) error {
+	return Orient(s.image, 0, o.Direction)
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import "github.com/cshum/vipsgen/vips"
+
+// padBlurSigma는 "blur" 배경의 가우시안 블러 정도입니다.
+const padBlurSigma = 20
+
+// ValidateBackground는 pad 여백 값을 검증합니다. 비어 있음(흰색), "#RRGGBB"/"r,g,b", "blur"를 허용합니다.
+func ValidateBackground(background string) error {
+	if background == "" || background == "blur" {
+		return nil
+	}
+	_, err := ParseColor(background)
+	return err
+}
+
+// pad는 이미지를 width×height 상자 안에 맞춘 뒤 가운데에 두고 남는 곳을 background로 채웁니다.
+// "blur"이면 같은 이미지를 상자에 꽉 차게 늘려 흐리게 만든 배경 위에 올립니다.
+func pad(image *vips.Image, width, height int, enlarge bool, background string) error {
+	if image.Bands() < 3 {
+		// 색 배경을 합성하려면 RGB여야 합니다.
+		_, rgb := rgbSpaces(image)
+		if err := image.Colourspace(rgb, nil); err != nil {
+			return err
+		}
+	}
+	if background != "blur" {
+		if err := Resize(image, width, height, "inside", enlarge, ""); err != nil {
+			return err
+		}
+		color := []float64{255, 255, 255}
+		if background != "" {
+			color, _ = ParseColor(background)
+		}
+		return image.Embed((width-image.Width())/2, (height-image.Height())/2, width, height, &vips.EmbedOptions{
+			Extend:     vips.ExtendBackground,
+			Background: scaleColor(image, color),
+		})
+	}
+
+	inner, err := image.Copy(nil)
+	if err != nil {
+		return err
+	}
+	defer inner.Close()
+	if err := Resize(inner, width, height, "inside", enlarge, ""); err != nil {
+		return err
+	}
+	if err := Resize(image, width, height, "cover", true, ""); err != nil {
+		return err
+	}
+	if err := image.Gaussblur(padBlurSigma, nil); err != nil {
+		return err
+	}
+	return image.Insert(inner, (width-inner.Width())/2, (height-inner.Height())/2, nil)
+}
+
+// scaleColor는 0~255 RGB 색을 이미지의 비트 깊이에 맞추고, 알파가 있으면 불투명 알파를 붙입니다.
+func scaleColor(image *vips.Image, rgb []float64) []float64 {
+	scale := maxValue(image) / 255
+	color := make([]float64, 0, 4)
+	for _, v := range rgb {
+		color = append(color, v*scale)
+	}
+	if image.HasAlpha() {
+		color = append(color, maxValue(image))
+	}
+	return color
+}
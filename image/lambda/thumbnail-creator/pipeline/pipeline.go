@@ -0,0 +1,148 @@
+// Package pipeline은 JSON으로 정의한 이미지 처리 단계(resize, crop, rotate, flip, blur, sharpen, redact,
+// grayscale, sepia, brightness, contrast, saturation, watermark, format)를
+// vips 이미지에 순서대로 적용합니다. RegisterOperation으로 단계를 더할 수 있습니다.
+//
+// 이벤트 예시:
+//
+//	"pipeline": [
+//	  {"op": "resize", "width": 1200},
+//	  {"op": "sharpen", "sigma": 0.5, "amount": 3},
+//	  {"op": "watermark", "text": "© example", "gravity": "south-east"},
+//	  {"op": "format", "format": "webp", "quality": 80}
+//	]
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// Step은 파이프라인 정의의 단계 하나입니다. op 외의 필드는 단계별 파라미터입니다.
+type Step struct {
+	Op     string
+	Params json.RawMessage
+}
+
+func (s *Step) UnmarshalJSON(b []byte) error {
+	var head struct {
+		Op string `json:"op"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return err
+	}
+	s.Op = head.Op
+	s.Params = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+func (s Step) MarshalJSON() ([]byte, error) {
+	if len(s.Params) > 0 {
+		return s.Params, nil
+	}
+	return json.Marshal(map[string]string{"op": s.Op})
+}
+
+// Env는 단계가 외부 자원을 읽을 때 사용하는 환경입니다.
+type Env struct {
+	// LoadObject는 워터마크 이미지 같은 보조 객체를 키로 읽어 옵니다.
+	LoadObject func(key string) ([]byte, error)
+	// DetectFaces는 redact 단계의 "auto-faces"가 가릴 얼굴 영역을 찾습니다. nil이면 "auto-faces"는 실패합니다.
+	DetectFaces func(image *vips.Image) ([]Region, error)
+}
+
+// Output은 format 단계가 지정한 출력 설정입니다. 지정하지 않으면 빈 값입니다.
+type Output struct {
+	Format  string
+	Quality int
+}
+
+// operation은 파라미터가 채워진 단계 구현입니다.
+type operation interface {
+	apply(s *state) error
+}
+
+// validator를 구현한 단계는 컴파일 시점에 파라미터를 검증합니다.
+type validator interface {
+	validate() error
+}
+
+type state struct {
+	image  *vips.Image
+	env    Env
+	output Output
+}
+
+// operations는 op 이름과 단계 구현 생성자의 대응입니다.
+var operations = map[string]func() operation{
+	"resize":     func() operation { return &resizeOp{} },
+	"crop":       func() operation { return &cropOp{} },
+	"rotate":     func() operation { return &rotateOp{} },
+	"flip":       func() operation { return &flipOp{} },
+	"blur":       func() operation { return &blurOp{} },
+	"sharpen":    func() operation { return &sharpenOp{} },
+	"grayscale":  func() operation { return &grayscaleOp{} },
+	"sepia":      func() operation { return &sepiaOp{} },
+	"brightness": func() operation { return &adjustOp{kind: "brightness"} },
+	"contrast":   func() operation { return &adjustOp{kind: "contrast"} },
+	"saturation": func() operation { return &adjustOp{kind: "saturation"} },
+	"redact":     func() operation { return &redactOp{} },
+	"watermark":  func() operation { return &watermarkOp{} },
+	"format":     func() operation { return &formatOp{} },
+}
+
+// Pipeline은 검증이 끝난 실행 가능한 단계 목록입니다.
+type Pipeline struct {
+	steps []Step
+	ops   []operation
+}
+
+// Compile은 단계 정의를 파싱하고 검증합니다.
+// 이미지를 내려받기 전에 호출해 잘못된 요청을 빠르게 거절할 수 있습니다.
+func Compile(steps []Step) (*Pipeline, error) {
+	p := &Pipeline{steps: steps}
+	for i, step := range steps {
+		if op, ok, err := registered(step); ok {
+			if err != nil {
+				return nil, fmt.Errorf("step %d (%s): %w", i, step.Op, err)
+			}
+			p.ops = append(p.ops, op)
+			continue
+		}
+		newOp, ok := operations[step.Op]
+		if !ok {
+			return nil, fmt.Errorf("step %d: unknown operation %q", i, step.Op)
+		}
+		op := newOp()
+		if err := json.Unmarshal(step.Params, op); err != nil {
+			return nil, fmt.Errorf("step %d (%s): invalid parameters: %w", i, step.Op, err)
+		}
+		if v, ok := op.(validator); ok {
+			if err := v.validate(); err != nil {
+				return nil, fmt.Errorf("step %d (%s): %w", i, step.Op, err)
+			}
+		}
+		p.ops = append(p.ops, op)
+	}
+	return p, nil
+}
+
+// Len은 단계 수를 돌려줍니다.
+func (p *Pipeline) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.ops)
+}
+
+// Run은 이미지에 단계를 순서대로 적용합니다. vips 연산은 이미지를 제자리에서 바꿉니다.
+func (p *Pipeline) Run(image *vips.Image, env Env) (Output, error) {
+	s := &state{image: image, env: env}
+	for i, op := range p.ops {
+		if err := op.apply(s); err != nil {
+			return Output{}, fmt.Errorf("step %d (%s) failed: %w", i, p.steps[i].Op, err)
+		}
+	}
+	return s.output, nil
+}
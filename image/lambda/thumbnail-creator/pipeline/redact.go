@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// Region은 이미지 안의 사각형 영역(픽셀)입니다.
+type Region struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// redactOp는 번호판, 얼굴처럼 가려야 할 영역을 블러나 모자이크로 처리합니다.
+// regions는 Region 목록이거나 "auto-faces"이며, "auto-faces"는 Env.DetectFaces로 찾은 얼굴을 가립니다.
+// mode: blur(기본) | pixelate, strength: 블러 sigma(기본 20) 또는 모자이크 블록 크기(기본 16)
+//
+//	{"op": "redact", "regions": [{"left": 40, "top": 300, "width": 220, "height": 60}], "mode": "pixelate"}
+//	{"op": "redact", "regions": "auto-faces"}
+type redactOp struct {
+	Regions  json.RawMessage `json:"regions"`
+	Mode     string          `json:"mode"`
+	Strength float64         `json:"strength"`
+
+	regions   []Region
+	autoFaces bool
+}
+
+func (o *redactOp) validate() error {
+	var auto string
+	switch {
+	case len(o.Regions) == 0:
+		return fmt.Errorf("regions is required")
+	case json.Unmarshal(o.Regions, &auto) == nil:
+		if auto != "auto-faces" {
+			return fmt.Errorf("regions must be a list of rectangles or \"auto-faces\"")
+		}
+		o.autoFaces = true
+	default:
+		if err := json.Unmarshal(o.Regions, &o.regions); err != nil {
+			return fmt.Errorf("invalid regions: %w", err)
+		}
+		for i, r := range o.regions {
+			if r.Left < 0 || r.Top < 0 || r.Width <= 0 || r.Height <= 0 {
+				return fmt.Errorf("region %d: left/top must not be negative and width/height must be positive", i)
+			}
+		}
+	}
+	switch o.Mode {
+	case "":
+		o.Mode = "blur"
+	case "blur", "pixelate":
+	default:
+		return fmt.Errorf("unknown mode %q", o.Mode)
+	}
+	if o.Strength == 0 {
+		o.Strength = 20
+		if o.Mode == "pixelate" {
+			o.Strength = 16
+		}
+	}
+	if o.Strength < 0 || o.Strength > 200 {
+		return fmt.Errorf("strength must be in (0, 200]")
+	}
+	return nil
+}
+
+func (o *redactOp) apply(s *state) error {
+	regions := o.regions
+	if o.autoFaces {
+		if s.env.DetectFaces == nil {
+			return fmt.Errorf("face detection is not configured")
+		}
+		faces, err := s.env.DetectFaces(s.image)
+		if err != nil {
+			return fmt.Errorf("face detection failed: %w", err)
+		}
+		regions = faces
+	}
+	for _, r := range regions {
+		// 이미지 밖으로 나간 부분은 잘라 내고, 완전히 벗어난 영역은 건너뜁니다.
+		width := min(r.Left+r.Width, s.image.Width()) - r.Left
+		height := min(r.Top+r.Height, s.image.Height()) - r.Top
+		if width <= 0 || height <= 0 {
+			continue
+		}
+		if err := o.redact(s.image, r.Left, r.Top, width, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *redactOp) redact(image *vips.Image, left, top, width, height int) error {
+	patch, err := image.Copy(nil)
+	if err != nil {
+		return err
+	}
+	defer patch.Close()
+	if err := patch.ExtractArea(left, top, width, height); err != nil {
+		return err
+	}
+	if o.Mode == "blur" {
+		err = patch.Gaussblur(o.Strength, nil)
+	} else {
+		err = pixelate(patch, min(int(o.Strength), width, height))
+	}
+	if err != nil {
+		return err
+	}
+	return image.Insert(patch, left, top, nil)
+}
+
+// pixelate는 block×block 칸마다 평균색으로 채웁니다. 가장자리의 남는 칸은 인접한 칸 색으로 늘립니다.
+func pixelate(image *vips.Image, block int) error {
+	width, height := image.Width(), image.Height()
+	if err := image.Shrink(float64(block), float64(block), nil); err != nil {
+		return err
+	}
+	if err := image.Zoom(block, block); err != nil {
+		return err
+	}
+	return image.Embed(0, 0, width, height, &vips.EmbedOptions{Extend: vips.ExtendCopy})
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// Operation은 RegisterOperation으로 등록한 단계의 실행 함수입니다. 내장 단계처럼 image를 제자리에서 바꿉니다.
+type Operation func(image *vips.Image, env Env) error
+
+// OperationFactory는 단계 정의(op를 포함한 JSON 객체 전체)를 파싱·검증하고 실행 함수를 돌려줍니다.
+// Compile에서 단계마다 한 번 호출되므로, 오류를 돌려주면 이미지를 내려받기 전에 요청이 거절됩니다.
+type OperationFactory func(params json.RawMessage) (Operation, error)
+
+// registry는 RegisterOperation으로 등록한 단계입니다. 내장 단계(operations)와 이름이 겹칠 수 없습니다.
+var registry = struct {
+	sync.RWMutex
+	factories map[string]OperationFactory
+}{factories: map[string]OperationFactory{}}
+
+// RegisterOperation은 변환기를 고치지 않고 새 단계를 추가합니다. 보통 main 패키지에 둔 파일의 init에서 호출합니다.
+// 이름이 내장 단계나 이미 등록한 단계와 겹치거나 factory가 nil이면 패닉입니다.
+//
+//	func init() {
+//		pipeline.RegisterOperation("brand-frame", func(params json.RawMessage) (pipeline.Operation, error) {
+//			var p struct {
+//				Key string `json:"key"`
+//			}
+//			if err := json.Unmarshal(params, &p); err != nil || p.Key == "" {
+//				return nil, fmt.Errorf("key is required")
+//			}
+//			return func(image *vips.Image, env pipeline.Env) error {
+//				frame, err := env.LoadObject(p.Key)
+//				...
+//			}, nil
+//		})
+//	}
+//
+// 이벤트에서는 {"op": "brand-frame", "key": "frames/summer.png"}처럼 씁니다.
+func RegisterOperation(name string, factory OperationFactory) {
+	if factory == nil {
+		panic("pipeline: RegisterOperation factory is nil for " + name)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := operations[name]; ok {
+		panic("pipeline: RegisterOperation called with built-in operation name " + name)
+	}
+	if _, ok := registry.factories[name]; ok {
+		panic("pipeline: RegisterOperation called twice for " + name)
+	}
+	registry.factories[name] = factory
+}
+
+// Operations는 내장 단계와 등록한 단계의 이름을 정렬해 돌려줍니다.
+func Operations() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(operations)+len(registry.factories))
+	for name := range operations {
+		names = append(names, name)
+	}
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registered는 등록한 단계의 factory를 실행해 operation으로 감쌉니다. 등록되지 않았으면 ok가 false입니다.
+func registered(step Step) (op operation, ok bool, err error) {
+	registry.RLock()
+	factory, ok := registry.factories[step.Op]
+	registry.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	fn, err := factory(step.Params)
+	if err != nil {
+		return nil, true, err
+	}
+	if fn == nil {
+		return nil, true, fmt.Errorf("operation factory returned no function")
+	}
+	return customOp(fn), true, nil
+}
+
+// customOp는 등록한 단계를 내장 단계와 같은 operation으로 맞춥니다.
+type customOp Operation
+
+func (o customOp) apply(s *state) error {
+	return o(s.image, s.env)
+}
@@ -0,0 +1,190 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// watermarkOp는 텍스트 또는 이미지(key) 워터마크를 합성합니다.
+// 이미지 워터마크는 기준 이미지 너비의 scale 비율로 맞추고, opacity로 투명도를 조절합니다.
+type watermarkOp struct {
+	Text    string  `json:"text"`
+	Key     string  `json:"key"`
+	Gravity string  `json:"gravity"`
+	Margin  *int    `json:"margin"`
+	Opacity float64 `json:"opacity"`
+	Scale   float64 `json:"scale"`
+	Font    string  `json:"font"`
+	Size    int     `json:"size"`
+	Color   string  `json:"color"`
+
+	color []float64
+}
+
+// gravities는 워터마크 위치 이름입니다.
+var gravities = map[string]struct{}{
+	"centre": {}, "north": {}, "south": {}, "east": {}, "west": {},
+	"north-east": {}, "north-west": {}, "south-east": {}, "south-west": {},
+}
+
+func (o *watermarkOp) validate() error {
+	if (o.Text == "") == (o.Key == "") {
+		return fmt.Errorf("exactly one of text or key must be set")
+	}
+	if o.Gravity == "" {
+		o.Gravity = "south-east"
+	}
+	if _, ok := gravities[o.Gravity]; !ok {
+		return fmt.Errorf("unknown gravity %q", o.Gravity)
+	}
+	if o.Margin == nil {
+		margin := 16
+		o.Margin = &margin
+	}
+	if o.Opacity == 0 {
+		o.Opacity = 0.5
+	}
+	if o.Opacity < 0 || o.Opacity > 1 {
+		return fmt.Errorf("opacity must be between 0 and 1")
+	}
+	if o.Scale == 0 {
+		o.Scale = 0.2
+	}
+	if o.Scale < 0 || o.Scale > 1 {
+		return fmt.Errorf("scale must be between 0 and 1")
+	}
+	if o.Font == "" {
+		o.Font = "sans"
+	}
+	if o.Size == 0 {
+		o.Size = 24
+	}
+	if o.Color == "" {
+		o.Color = "#ffffff"
+	}
+	rgb, err := ParseColor(o.Color)
+	if err != nil {
+		return fmt.Errorf("invalid color: %w", err)
+	}
+	o.color = rgb
+	return nil
+}
+
+func (o *watermarkOp) apply(s *state) error {
+	var (
+		overlay *vips.Image
+		err     error
+	)
+	if o.Text != "" {
+		overlay, err = TextOverlay(o.Text, o.Font, o.Size, o.color, o.Opacity)
+	} else {
+		overlay, err = o.imageOverlay(s)
+	}
+	if err != nil {
+		return err
+	}
+	defer overlay.Close()
+
+	if s.image.Interpretation() != vips.InterpretationSrgb {
+		if err := s.image.Colourspace(vips.InterpretationSrgb, nil); err != nil {
+			return err
+		}
+	}
+	x, y := place(o.Gravity, *o.Margin, s.image.Width(), s.image.Height(), overlay.Width(), overlay.Height())
+	return s.image.Composite2(overlay, vips.BlendModeOver, &vips.Composite2Options{X: x, Y: y})
+}
+
+// TextOverlay는 텍스트 마스크로 color 색(0~255 RGB) + opacity 알파의 sRGB 오버레이를 만듭니다.
+func TextOverlay(text, font string, size int, color []float64, opacity float64) (*vips.Image, error) {
+	mask, err := vips.NewText(text, &vips.TextOptions{Font: fmt.Sprintf("%s %d", font, size), Dpi: 72})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render text: %w", err)
+	}
+	defer mask.Close()
+
+	ink, err := vips.NewBlack(mask.Width(), mask.Height(), &vips.BlackOptions{Bands: 3})
+	if err != nil {
+		return nil, err
+	}
+	defer ink.Close()
+	if err := ink.Linear([]float64{1, 1, 1}, color, nil); err != nil {
+		return nil, err
+	}
+	if err := ink.Cast(vips.BandFormatUchar, nil); err != nil {
+		return nil, err
+	}
+	if err := mask.Linear([]float64{opacity}, []float64{0}, nil); err != nil {
+		return nil, err
+	}
+	if err := mask.Cast(vips.BandFormatUchar, nil); err != nil {
+		return nil, err
+	}
+	overlay, err := vips.NewBandjoin([]*vips.Image{ink, mask})
+	if err != nil {
+		return nil, err
+	}
+	// bandjoin 결과는 multiband로 해석되므로 sRGB로 지정합니다.
+	tagged, err := overlay.Copy(&vips.CopyOptions{Interpretation: vips.InterpretationSrgb})
+	overlay.Close()
+	return tagged, err
+}
+
+// imageOverlay는 key의 이미지를 읽어 크기와 투명도를 맞춘 오버레이를 만듭니다.
+func (o *watermarkOp) imageOverlay(s *state) (*vips.Image, error) {
+	if s.env.LoadObject == nil {
+		return nil, fmt.Errorf("image watermark is not available in this environment")
+	}
+	buf, err := s.env.LoadObject(o.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watermark %q: %w", o.Key, err)
+	}
+	overlay, err := vips.NewImageFromBuffer(buf, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode watermark %q: %w", o.Key, err)
+	}
+	width := max(1, int(float64(s.image.Width())*o.Scale))
+	steps := []func() error{
+		func() error {
+			return overlay.ThumbnailImage(width, &vips.ThumbnailImageOptions{Height: maxCoord, Size: vips.SizeBoth})
+		},
+		func() error { return overlay.Colourspace(vips.InterpretationSrgb, nil) },
+		func() error {
+			if overlay.HasAlpha() {
+				return nil
+			}
+			return overlay.Addalpha()
+		},
+		func() error {
+			// 알파 밴드에만 opacity를 곱합니다.
+			scale := []float64{1, 1, 1, o.Opacity}
+			return overlay.Linear(scale, []float64{0, 0, 0, 0}, nil)
+		},
+		func() error { return overlay.Cast(vips.BandFormatUchar, nil) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			overlay.Close()
+			return nil, err
+		}
+	}
+	return overlay, nil
+}
+
+// place는 gravity와 margin으로 오버레이의 좌상단 좌표를 계산합니다.
+func place(gravity string, margin, width, height, w, h int) (int, int) {
+	x, y := (width-w)/2, (height-h)/2
+	switch gravity {
+	case "north", "north-east", "north-west":
+		y = margin
+	case "south", "south-east", "south-west":
+		y = height - h - margin
+	}
+	switch gravity {
+	case "west", "north-west", "south-west":
+		x = margin
+	case "east", "north-east", "south-east":
+		x = width - w - margin
+	}
+	return max(0, x), max(0, y)
+}